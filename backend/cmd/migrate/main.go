@@ -0,0 +1,85 @@
+// Command migrate inspects and rolls back the SQL store schema.
+//
+// Opening a store always applies pending migrations, so "up" simply opens the
+// database; "down N" then reverts the N most recent migrations.
+//
+//	go run ./cmd/migrate -driver postgres -dsn "$POSTGRES_DSN" version
+//	go run ./cmd/migrate -driver sqlite -dsn data.db down 1
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+
+	"assignment3/backend/internal/store"
+)
+
+func main() {
+	driver := flag.String("driver", os.Getenv("STORE_DRIVER"), "store driver: sqlite or postgres")
+	dsn := flag.String("dsn", "", "sqlite file path or postgres connection string (defaults to SQLITE_PATH / POSTGRES_DSN)")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "usage: migrate [flags] up|down [steps]|version\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	st, err := open(*driver, *dsn)
+	if err != nil {
+		log.Fatalf("failed to open store: %v", err)
+	}
+	defer st.Close()
+
+	switch flag.Arg(0) {
+	case "up":
+		// Pending migrations were applied while opening the store.
+	case "down":
+		steps := 1
+		if flag.NArg() > 1 {
+			steps, err = strconv.Atoi(flag.Arg(1))
+			if err != nil || steps < 1 {
+				log.Fatalf("invalid step count %q", flag.Arg(1))
+			}
+		}
+		if err := st.MigrateDown(steps); err != nil {
+			log.Fatalf("migrate down failed: %v", err)
+		}
+	case "version":
+	default:
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	version, err := st.SchemaVersion()
+	if err != nil {
+		log.Fatalf("failed to read schema version: %v", err)
+	}
+	log.Printf("schema version: %d", version)
+}
+
+func open(driver, dsn string) (*store.SQLStore, error) {
+	switch driver {
+	case "sqlite":
+		if dsn == "" {
+			dsn = os.Getenv("SQLITE_PATH")
+		}
+		if dsn == "" {
+			dsn = "assignment3.db"
+		}
+		return store.OpenSQLite(dsn)
+	case "postgres":
+		if dsn == "" {
+			dsn = os.Getenv("POSTGRES_DSN")
+		}
+		return store.OpenPostgres(dsn, store.DefaultPoolConfig())
+	default:
+		return nil, fmt.Errorf("unsupported driver %q", driver)
+	}
+}
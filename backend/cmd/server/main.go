@@ -1,15 +1,23 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
 	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"assignment3/backend/internal/api"
 	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/mail"
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
 	"assignment3/backend/internal/store"
 )
 
@@ -18,29 +26,88 @@ func main() {
 	jwtSecret := getenvDefault("JWT_SECRET", "change-me-in-production")
 	jwtIssuer := getenvDefault("JWT_ISSUER", "assignment3-backend")
 	expiryMinutes := getenvIntDefault("JWT_EXPIRY_MINUTES", 60)
+	refreshExpiryHours := getenvIntDefault("JWT_REFRESH_EXPIRY_HOURS", 168)
 
-	st := store.NewStore()
+	st, err := openStore(getenvDefault("STORE_DRIVER", "memory"))
+	if err != nil {
+		log.Fatalf("failed to open store: %v", err)
+	}
+	defer st.Close()
+
+	if path := getenvDefault("RBAC_POLICY_FILE", ""); path != "" {
+		policy, err := rbac.LoadPolicy(path)
+		if err != nil {
+			log.Fatalf("failed to load rbac policy: %v", err)
+		}
+		if err := st.ApplyPolicy(policy); err != nil {
+			log.Fatalf("failed to apply rbac policy: %v", err)
+		}
+		log.Printf("applied rbac policy with roles %s", strings.Join(policy.Roles(), ", "))
+	}
 
 	adminUsername := getenvDefault("ADMIN_USERNAME", "admin")
 	adminPassword := getenvDefault("ADMIN_PASSWORD", "admin123")
 
-	if _, created, err := st.EnsureAdminUser(adminUsername, adminPassword); err != nil {
+	admin, created, err := st.EnsureAdminUser(adminUsername, adminPassword)
+	if err != nil {
 		log.Fatalf("failed to ensure admin user: %v", err)
-	} else if created {
-		log.Printf("created default admin user '%s'", adminUsername)
+	}
+	if created {
+		log.Printf("created default admin user '%s'; its password must be changed on first sign-in", adminUsername)
+
+		// Seed with an example item to illustrate API responses. Persistent
+		// stores only get it once, alongside the freshly created admin.
+		if _, err := st.CreateItem(admin.ID, "Welcome Item", "You can edit or delete this item from the React app.", models.ItemPublic); err != nil {
+			log.Printf("warning: failed to seed welcome item: %v", err)
+		}
 	} else {
 		log.Printf("admin user '%s' already exists", adminUsername)
 	}
 
-	// Seed with an example item to illustrate API responses.
-	if _, err := st.CreateItem(adminUsername, "Welcome Item", "You can edit or delete this item from the React app."); err != nil {
-		log.Printf("warning: failed to seed welcome item: %v", err)
+	keys, err := loadKeySet(jwtSecret)
+	if err != nil {
+		log.Fatalf("failed to load signing keys: %v", err)
 	}
-
-	jwtService := auth.NewJWTService(jwtSecret, jwtIssuer, time.Duration(expiryMinutes)*time.Minute)
+	jwtService := auth.NewJWTServiceWithKeys(keys, jwtIssuer, time.Duration(expiryMinutes)*time.Minute, time.Duration(refreshExpiryHours)*time.Hour)
 	origins, allowAll := loadAllowedOrigins(port)
 
-	router := api.SetupRouter(st, jwtService, origins, allowAll)
+	var routerOpts []api.RouterOption
+	if getenvDefault("AUTH_MODE", "stateful") != "stateless" {
+		routerOpts = append(routerOpts, api.WithUserRevalidation(time.Duration(getenvIntDefault("AUTH_USER_CACHE_SECONDS", 10))*time.Second))
+	}
+	if getenvDefault("AUTH_SESSION", "token") == "cookie" {
+		cookies, err := loadCookieConfig()
+		if err != nil {
+			log.Fatalf("failed to configure session cookies: %v", err)
+		}
+		if allowAll {
+			log.Printf("warning: browsers do not send session cookies to FRONTEND_ORIGINS=*; list the frontend origins instead")
+		}
+		routerOpts = append(routerOpts, api.WithCookieSessions(cookies))
+	}
+	routerOpts = append(routerOpts, api.WithPasswordReset(
+		loadMailer(),
+		getenvDefault("PASSWORD_RESET_URL", "http://localhost:3000/"),
+		time.Duration(getenvIntDefault("PASSWORD_RESET_TTL_MINUTES", 30))*time.Minute,
+	))
+	routerOpts = append(routerOpts, api.WithLoginThrottle(loadThrottleConfig()))
+	routerOpts = append(routerOpts, api.WithMFAIssuer(getenvDefault("MFA_ISSUER", api.DefaultMFAIssuer)))
+	transferTTLHours := getenvIntDefault("ITEM_TRANSFER_TTL_HOURS", int(api.DefaultTransferTTL/time.Hour))
+	if transferTTLHours <= 0 {
+		log.Fatalf("ITEM_TRANSFER_TTL_HOURS must be positive, got %d", transferTTLHours)
+	}
+	routerOpts = append(routerOpts, api.WithItemTransfers(time.Duration(transferTTLHours)*time.Hour))
+	if opt, err := loadOIDC(); err != nil {
+		log.Fatalf("failed to configure single sign-on: %v", err)
+	} else if opt != nil {
+		routerOpts = append(routerOpts, opt)
+	}
+	if opt, err := loadLDAP(st); err != nil {
+		log.Fatalf("failed to configure ldap: %v", err)
+	} else if opt != nil {
+		routerOpts = append(routerOpts, opt)
+	}
+	router := api.SetupRouter(st, jwtService, origins, allowAll, routerOpts...)
 
 	log.Printf("server listening on :%s", port)
 	if err := router.Run(":" + port); err != nil {
@@ -48,6 +115,241 @@ func main() {
 	}
 }
 
+// loadCookieConfig reads the AUTH_COOKIE_* settings of cookie sessions.
+func loadCookieConfig() (auth.CookieConfig, error) {
+	cookies := auth.DefaultCookieConfig()
+	cookies.Domain = getenvDefault("AUTH_COOKIE_DOMAIN", "")
+	cookies.Secure = getenvDefault("AUTH_COOKIE_SECURE", "true") != "false"
+	switch sameSite := strings.ToLower(getenvDefault("AUTH_COOKIE_SAMESITE", "strict")); sameSite {
+	case "strict":
+		cookies.SameSite = http.SameSiteStrictMode
+	case "lax":
+		cookies.SameSite = http.SameSiteLaxMode
+	case "none":
+		if !cookies.Secure {
+			return auth.CookieConfig{}, fmt.Errorf("AUTH_COOKIE_SAMESITE=none requires secure cookies")
+		}
+		cookies.SameSite = http.SameSiteNoneMode
+	default:
+		return auth.CookieConfig{}, fmt.Errorf("unsupported AUTH_COOKIE_SAMESITE %q", sameSite)
+	}
+	return cookies, nil
+}
+
+// loadMailer delivers through SMTP_ADDR when set. Otherwise mail is appended
+// to MAIL_FILE, or logged, for development.
+func loadMailer() mail.Mailer {
+	from := getenvDefault("MAIL_FROM", "no-reply@localhost")
+	if addr := getenvDefault("SMTP_ADDR", ""); addr != "" {
+		log.Printf("sending mail through %s", addr)
+		return mail.NewSMTPMailer(mail.SMTPConfig{
+			Addr:     addr,
+			From:     from,
+			Username: getenvDefault("SMTP_USERNAME", ""),
+			Password: getenvDefault("SMTP_PASSWORD", ""),
+		})
+	}
+	return mail.NewFileMailer(getenvDefault("MAIL_FILE", ""), from)
+}
+
+// loadOIDC configures single sign-on when OIDC_ISSUER is set. The provider is
+// contacted on first use, so it may still be starting up.
+func loadOIDC() (api.RouterOption, error) {
+	issuer := getenvDefault("OIDC_ISSUER", "")
+	if issuer == "" {
+		return nil, nil
+	}
+	clientID := getenvDefault("OIDC_CLIENT_ID", "")
+	if clientID == "" {
+		return nil, fmt.Errorf("OIDC_CLIENT_ID is required with OIDC_ISSUER")
+	}
+	roles, err := auth.ParseClaimRoleMapping(getenvDefault("OIDC_ROLE_CLAIM", "groups"), getenvDefault("OIDC_ROLE_MAPPING", ""))
+	if err != nil {
+		return nil, err
+	}
+	var scopes []string
+	for _, scope := range strings.Split(getenvDefault("OIDC_SCOPES", "profile,email"), ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+
+	provider := auth.NewOIDCProvider(auth.OIDCConfig{
+		Issuer:       issuer,
+		ClientID:     clientID,
+		ClientSecret: getenvDefault("OIDC_CLIENT_SECRET", ""),
+		RedirectURL:  getenvDefault("OIDC_REDIRECT_URL", "http://localhost:3000/"),
+		Scopes:       scopes,
+		Name:         getenvDefault("OIDC_NAME", "SSO"),
+	})
+	log.Printf("single sign-on through %s enabled", issuer)
+	// Linking by email only reaches accounts without a password, since local
+	// addresses are not verified.
+	return api.WithOIDC(provider, roles, getenvDefault("OIDC_LINK_BY_EMAIL", "false") == "true"), nil
+}
+
+// loadLDAP checks passwords against a directory when LDAP_URL is set. Local
+// accounts stay available as a fallback to the roles in
+// LDAP_LOCAL_FALLBACK_ROLES, admin by default, so an administrator can still
+// sign in when the directory is down; "*" allows every local account and
+// "none" disables the fallback.
+func loadLDAP(st store.Repository) (api.RouterOption, error) {
+	ldapURL := getenvDefault("LDAP_URL", "")
+	if ldapURL == "" {
+		return nil, nil
+	}
+	baseDN := getenvDefault("LDAP_BASE_DN", "")
+	if baseDN == "" {
+		return nil, fmt.Errorf("LDAP_BASE_DN is required with LDAP_URL")
+	}
+	roles, err := auth.ParseGroupRoleMapping(getenvDefault("LDAP_ROLE_MAPPING", ""))
+	if err != nil {
+		return nil, err
+	}
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if caFile := getenvDefault("LDAP_CA_FILE", ""); caFile != "" {
+		pem, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, err
+		}
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caFile)
+		}
+	}
+
+	directory := auth.NewLDAPAuthenticator(auth.LDAPConfig{
+		URL:               ldapURL,
+		StartTLS:          getenvDefault("LDAP_STARTTLS", "false") == "true",
+		TLSConfig:         tlsConfig,
+		BindDN:            getenvDefault("LDAP_BIND_DN", ""),
+		BindPassword:      getenvDefault("LDAP_BIND_PASSWORD", ""),
+		BaseDN:            baseDN,
+		UserFilter:        getenvDefault("LDAP_USER_FILTER", ""),
+		UsernameAttribute: getenvDefault("LDAP_USERNAME_ATTRIBUTE", ""),
+		EmailAttribute:    getenvDefault("LDAP_EMAIL_ATTRIBUTE", ""),
+		GroupBaseDN:       getenvDefault("LDAP_GROUP_BASE_DN", ""),
+		GroupFilter:       getenvDefault("LDAP_GROUP_FILTER", ""),
+		Roles:             roles,
+		Timeout:           time.Duration(getenvIntDefault("LDAP_TIMEOUT_SECONDS", 10)) * time.Second,
+	}, st)
+	log.Printf("checking passwords against %s", directory.Issuer())
+
+	fallback := getenvDefault("LDAP_LOCAL_FALLBACK_ROLES", "admin")
+	switch fallback {
+	case "none":
+		return api.WithAuthenticator(directory), nil
+	case "*":
+		return api.WithAuthenticator(auth.ChainAuthenticators(directory, st)), nil
+	default:
+		local := parseCSVEnv(fallback)
+		log.Printf("local accounts with roles %s can still sign in", strings.Join(local, ", "))
+		return api.WithAuthenticator(auth.ChainAuthenticators(directory, auth.RestrictRoles(st, local...))), nil
+	}
+}
+
+// loadThrottleConfig adjusts the default sign-in limits from LOGIN_* settings.
+func loadThrottleConfig() auth.ThrottleConfig {
+	cfg := auth.DefaultThrottleConfig()
+	lockout := time.Duration(getenvIntDefault("LOGIN_LOCKOUT_MINUTES", int(cfg.Username.LockoutDuration/time.Minute))) * time.Minute
+	cfg.Username.LockoutAfter = getenvIntDefault("LOGIN_MAX_FAILURES", cfg.Username.LockoutAfter)
+	cfg.Username.LockoutDuration = lockout
+	cfg.IP.LockoutAfter = getenvIntDefault("LOGIN_IP_MAX_FAILURES", cfg.IP.LockoutAfter)
+	cfg.IP.LockoutDuration = lockout
+	return cfg
+}
+
+// loadKeySet builds the token signing keys. Without JWT_KEY_DIR or
+// JWT_KEY_FILES tokens are signed with the HS256 JWT_SECRET as before.
+func loadKeySet(secret string) (*auth.KeySet, error) {
+	dir := getenvDefault("JWT_KEY_DIR", "")
+	var files []string
+	for _, f := range strings.Split(getenvDefault("JWT_KEY_FILES", ""), ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			files = append(files, f)
+		}
+	}
+	if dir == "" && len(files) == 0 {
+		return auth.NewHMACKeySet([]byte(secret)), nil
+	}
+
+	source := auth.KeySource{
+		Dir:         dir,
+		Files:       files,
+		ActiveKeyID: getenvDefault("JWT_ACTIVE_KEY_ID", ""),
+	}
+	// Only an explicitly configured secret keeps legacy HS256 tokens valid;
+	// the built-in default must never be trusted.
+	if _, ok := os.LookupEnv("JWT_SECRET"); ok {
+		source.LegacySecret = []byte(secret)
+	}
+	keys, err := auth.OpenKeySet(source)
+	if err != nil {
+		return nil, err
+	}
+	log.Printf("signing tokens with key '%s'", keys.Active().ID)
+
+	go watchKeySet(keys, time.Duration(getenvIntDefault("JWT_KEY_RELOAD_SECONDS", 60))*time.Second)
+	return keys, nil
+}
+
+// watchKeySet reloads keys on SIGHUP and, if interval is positive, periodically
+// so rotated key files take effect without a restart.
+func watchKeySet(keys *auth.KeySet, interval time.Duration) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	var tick <-chan time.Time
+	if interval > 0 {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-hup:
+		case <-tick:
+		}
+		previous := keys.Active().ID
+		if err := keys.Reload(); err != nil {
+			log.Printf("warning: failed to reload signing keys, keeping current set: %v", err)
+			continue
+		}
+		if active := keys.Active().ID; active != previous {
+			log.Printf("signing key rotated from '%s' to '%s'", previous, active)
+		}
+	}
+}
+
+// openStore builds the repository selected by STORE_DRIVER.
+func openStore(driver string) (store.Repository, error) {
+	switch strings.ToLower(strings.TrimSpace(driver)) {
+	case "", "memory":
+		dir := getenvDefault("STORE_JOURNAL_DIR", "")
+		if dir == "" {
+			log.Printf("using in-memory store; data is lost on restart")
+			return store.NewStore(), nil
+		}
+		log.Printf("using in-memory store journaled to %s", dir)
+		return store.OpenJournaledStore(store.JournalOptions{
+			Dir:          dir,
+			Sync:         store.SyncPolicy(getenvDefault("STORE_JOURNAL_SYNC", string(store.SyncAlways))),
+			SyncInterval: time.Duration(getenvIntDefault("STORE_JOURNAL_SYNC_INTERVAL_MS", 1000)) * time.Millisecond,
+			CompactEvery: getenvIntDefault("STORE_JOURNAL_COMPACT_EVERY", 1000),
+		})
+	case "sqlite":
+		path := getenvDefault("SQLITE_PATH", "assignment3.db")
+		log.Printf("using sqlite store at %s", path)
+		return store.OpenSQLite(path)
+	case "postgres":
+		log.Printf("using postgres store")
+		return store.OpenPostgres(os.Getenv("POSTGRES_DSN"), loadPoolConfig())
+	default:
+		return nil, fmt.Errorf("unsupported STORE_DRIVER %q", driver)
+	}
+}
+
 func getenvDefault(key, fallback string) string {
 	if value := os.Getenv(key); value != "" {
 		return value
@@ -66,6 +368,16 @@ func getenvIntDefault(key string, fallback int) int {
 	return fallback
 }
 
+// loadPoolConfig reads POSTGRES_* pool settings, falling back to the store defaults.
+func loadPoolConfig() store.PoolConfig {
+	pool := store.DefaultPoolConfig()
+	pool.MaxOpenConns = getenvIntDefault("POSTGRES_MAX_OPEN_CONNS", pool.MaxOpenConns)
+	pool.MaxIdleConns = getenvIntDefault("POSTGRES_MAX_IDLE_CONNS", pool.MaxIdleConns)
+	pool.ConnMaxLifetime = time.Duration(getenvIntDefault("POSTGRES_CONN_MAX_LIFETIME_MINUTES", int(pool.ConnMaxLifetime/time.Minute))) * time.Minute
+	pool.ConnMaxIdleTime = time.Duration(getenvIntDefault("POSTGRES_CONN_MAX_IDLE_MINUTES", int(pool.ConnMaxIdleTime/time.Minute))) * time.Minute
+	return pool
+}
+
 func parseCSVEnv(value string) []string {
 	if value == "" {
 		return nil
@@ -0,0 +1,123 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type accessTokenRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+	// ExpiresInDays is optional; tokens without it never expire.
+	ExpiresInDays *int `json:"expires_in_days"`
+}
+
+// accessTokenResponse carries the token itself, which is shown only once.
+type accessTokenResponse struct {
+	models.PersonalAccessToken
+	Token string `json:"token"`
+}
+
+// ListAccessTokens returns the caller's personal access tokens.
+func (h *Handler) ListAccessTokens(c *gin.Context) {
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	tokens, err := h.store.ListAccessTokens(current.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list access tokens"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"tokens": tokens})
+}
+
+// CreateAccessToken issues a personal access token limited to the requested
+// scopes, which must be permissions the caller's role grants.
+func (h *Handler) CreateAccessToken(c *gin.Context) {
+	var req accessTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	policy := h.store.Policy()
+	for _, scope := range req.Scopes {
+		perm := rbac.Permission(scope)
+		if !rbac.ValidPermission(perm) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("unknown scope %q", scope)})
+			return
+		}
+		if !policy.Can(current.Role, perm) {
+			c.JSON(http.StatusForbidden, gin.H{"error": fmt.Sprintf("your role does not grant %q", scope)})
+			return
+		}
+	}
+
+	var expiresAt *time.Time
+	if req.ExpiresInDays != nil {
+		if *req.ExpiresInDays <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must be positive"})
+			return
+		}
+		expiry := time.Now().AddDate(0, 0, *req.ExpiresInDays)
+		expiresAt = &expiry
+	}
+
+	token, prefix, err := auth.GenerateAccessToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	pat, err := h.store.CreateAccessToken(current.ID, req.Name, prefix, auth.HashToken(token), req.Scopes, expiresAt)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrAccessTokenExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "you already have a token with this name"})
+		case errors.Is(err, store.ErrUserNotFound):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "account no longer exists"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, accessTokenResponse{PersonalAccessToken: pat, Token: token})
+}
+
+// DeleteAccessToken revokes one of the caller's personal access tokens.
+func (h *Handler) DeleteAccessToken(c *gin.Context) {
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	if err := h.store.DeleteAccessToken(current.ID, c.Param("id")); err != nil {
+		if errors.Is(err, store.ErrAccessTokenNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "access token not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke access token"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,35 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"assignment3/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestPersonalAccessTokenRoutes(t *testing.T) {
+	s := newTestServer(t)
+	_, token := s.signIn("alice", "user")
+
+	s.expect(http.StatusBadRequest, http.MethodPost, "/api/me/tokens", token, gin.H{"name": "ci", "scopes": []string{"items:fly"}}, nil)
+	s.expect(http.StatusForbidden, http.MethodPost, "/api/me/tokens", token, gin.H{"name": "ci", "scopes": []string{"items:delete"}}, nil)
+	id, pat := s.accessToken(token, "ci", "items:read")
+
+	s.expect(http.StatusOK, http.MethodGet, "/api/items", pat, nil, nil)
+	s.expect(http.StatusForbidden, http.MethodPost, "/api/items", pat, gin.H{"title": "From CI"}, nil)
+	// Tokens cannot manage the account that issued them.
+	s.expect(http.StatusForbidden, http.MethodGet, "/api/me/tokens", pat, nil, nil)
+
+	var list struct {
+		Tokens []models.PersonalAccessToken `json:"tokens"`
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/api/me/tokens", token, nil, &list)
+	if len(list.Tokens) != 1 || list.Tokens[0].ID != id || list.Tokens[0].LastUsedAt == nil {
+		t.Fatalf("expected one used token, got %+v", list.Tokens)
+	}
+
+	s.expect(http.StatusNoContent, http.MethodDelete, "/api/me/tokens/"+id, token, nil, nil)
+	s.expect(http.StatusUnauthorized, http.MethodGet, "/api/items", pat, nil, nil)
+}
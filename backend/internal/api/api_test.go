@@ -0,0 +1,121 @@
+package api_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"assignment3/backend/internal/api"
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type testServer struct {
+	t      *testing.T
+	store  *store.Store
+	router http.Handler
+}
+
+func newTestServer(t *testing.T) *testServer {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	st := store.NewStore()
+	jwt := auth.NewJWTService("test-secret-test-secret-test-secret", "test", time.Minute, time.Hour)
+	return &testServer{t: t, store: st, router: api.SetupRouter(st, jwt, nil, true)}
+}
+
+// do sends a JSON request with token as the bearer credential, if any.
+func (s *testServer) do(method, path, token string, body any) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var payload bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&payload).Encode(body); err != nil {
+			s.t.Fatalf("failed to encode request: %v", err)
+		}
+	}
+	req := httptest.NewRequest(method, path, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	s.router.ServeHTTP(rec, req)
+	return rec
+}
+
+// expect sends a request and fails the test unless it is answered with status,
+// decoding the response into out if given.
+func (s *testServer) expect(status int, method, path, token string, body, out any) {
+	s.t.Helper()
+	rec := s.do(method, path, token, body)
+	if rec.Code != status {
+		s.t.Fatalf("%s %s: expected %d, got %d: %s", method, path, status, rec.Code, rec.Body.String())
+	}
+	if out != nil {
+		if err := json.Unmarshal(rec.Body.Bytes(), out); err != nil {
+			s.t.Fatalf("%s %s: failed to decode response: %v", method, path, err)
+		}
+	}
+}
+
+// signIn creates an account and returns it with an access token.
+func (s *testServer) signIn(username, role string) (models.User, string) {
+	s.t.Helper()
+	user, err := s.store.CreateUser(username, "password123", role)
+	if err != nil {
+		s.t.Fatalf("CreateUser returned error: %v", err)
+	}
+	var login struct {
+		Token string `json:"token"`
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/api/login", "", gin.H{"username": username, "password": "password123"}, &login)
+	return user, login.Token
+}
+
+// accessToken issues a personal access token named name, limited to scopes.
+func (s *testServer) accessToken(token, name string, scopes ...string) (string, string) {
+	s.t.Helper()
+	var pat struct {
+		ID    string `json:"id"`
+		Token string `json:"token"`
+	}
+	s.expect(http.StatusCreated, http.MethodPost, "/api/me/tokens", token, gin.H{"name": name, "scopes": scopes}, &pat)
+	return pat.ID, pat.Token
+}
+
+func TestDeleteUserSignsOutOnlyOnSuccess(t *testing.T) {
+	s := newTestServer(t)
+	_, adminToken := s.signIn("root", "admin")
+	bob, bobToken := s.signIn("bob", "user")
+
+	s.expect(http.StatusBadRequest, http.MethodDelete, "/api/users/"+bob.ID+"?items=reassign&reassign_to="+bob.ID, adminToken, nil, nil)
+	s.expect(http.StatusOK, http.MethodGet, "/api/items", bobToken, nil, nil)
+
+	s.expect(http.StatusOK, http.MethodDelete, "/api/users/"+bob.ID, adminToken, nil, nil)
+	s.expect(http.StatusUnauthorized, http.MethodGet, "/api/items", bobToken, nil, nil)
+	if revoked, err := s.store.IsAccessTokenRevoked("", bob.ID, time.Now().Add(-time.Second)); err != nil || !revoked {
+		t.Fatalf("expected bob's access tokens to be revoked, got %v, %v", revoked, err)
+	}
+}
+
+func TestRevokingAllTokensKeepsTheNextSignIn(t *testing.T) {
+	s := newTestServer(t)
+	bob, oldToken := s.signIn("bob", "user")
+
+	if err := s.store.RevokeUserAccessTokens(bob.ID, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeUserAccessTokens returned error: %v", err)
+	}
+	var login struct {
+		Token string `json:"token"`
+	}
+	s.expect(http.StatusOK, http.MethodPost, "/api/login", "", gin.H{"username": "bob", "password": "password123"}, &login)
+
+	s.expect(http.StatusUnauthorized, http.MethodGet, "/api/items", oldToken, nil, nil)
+	s.expect(http.StatusOK, http.MethodGet, "/api/items", login.Token, nil, nil)
+}
@@ -2,12 +2,15 @@ package api
 
 import (
 	"errors"
+	"log"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
 	"assignment3/backend/internal/auth"
 	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
 	"assignment3/backend/internal/store"
 
 	"github.com/gin-gonic/gin"
@@ -15,37 +18,85 @@ import (
 
 // Handler bundles dependencies required by HTTP handlers.
 type Handler struct {
-	store *store.Store
+	store store.Repository
 	jwt   *auth.JWTService
+	// authenticator checks the passwords of sign-ins; the store by default.
+	authenticator auth.Authenticator
+	// users caches the accounts AuthMiddleware re-validates tokens against;
+	// nil in stateless mode.
+	users *auth.CachedUserResolver
+	// resets configures self-service password resets; nil disables them.
+	resets *passwordResetConfig
+	// throttle slows down repeated failed sign-ins; nil disables it.
+	throttle *auth.LoginThrottle
+	// mfaIssuer names the service in authenticator apps.
+	mfaIssuer string
+	// oidc configures single sign-on; nil disables it.
+	oidc *oidcConfig
+	// cookies keeps browser sessions in cookies instead of response bodies;
+	// nil returns the tokens.
+	cookies *auth.CookieConfig
+	// transferTTL is how long a proposed item transfer can be accepted.
+	transferTTL time.Duration
 }
 
 // NewHandler creates a handler instance.
-func NewHandler(store *store.Store, jwt *auth.JWTService) *Handler {
+func NewHandler(store store.Repository, jwt *auth.JWTService) *Handler {
 	return &Handler{
-		store: store,
-		jwt:   jwt,
+		store:         store,
+		jwt:           jwt,
+		authenticator: store,
 	}
 }
 
 type userResponse struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Role      string    `json:"role"`
-	CreatedAt time.Time `json:"created_at"`
+	ID                 string    `json:"id"`
+	Username           string    `json:"username"`
+	Email              string    `json:"email,omitempty"`
+	Role               string    `json:"role"`
+	CreatedAt          time.Time `json:"created_at"`
+	MustChangePassword bool      `json:"must_change_password"`
+	MFAEnabled         bool      `json:"mfa_enabled"`
+	ServiceAccount     bool      `json:"service_account"`
+	ClientID           string    `json:"client_id,omitempty"`
+	Scopes             []string  `json:"scopes,omitempty"`
+
+	Status         models.UserStatus `json:"status"`
+	StatusReason   string            `json:"status_reason,omitempty"`
+	SuspendedUntil *time.Time        `json:"suspended_until,omitempty"`
 }
 
 type loginResponse struct {
-	Token string       `json:"token"`
-	User  userResponse `json:"user"`
+	Token            string       `json:"token,omitempty"`
+	RefreshToken     string       `json:"refresh_token,omitempty"`
+	RefreshExpiresAt time.Time    `json:"refresh_expires_at"`
+	User             userResponse `json:"user"`
+	// Session is "cookie" when the tokens were set as cookies instead, and
+	// CSRFToken then has to be sent in the X-CSRF-Token header.
+	Session   string `json:"session,omitempty"`
+	CSRFToken string `json:"csrf_token,omitempty"`
 }
 
 func newUserResponse(user models.User) userResponse {
-	return userResponse{
-		ID:        user.ID,
-		Username:  user.Username,
-		Role:      user.Role,
-		CreatedAt: user.CreatedAt,
+	resp := userResponse{
+		ID:                 user.ID,
+		Username:           user.Username,
+		Email:              user.Email,
+		Role:               user.Role,
+		CreatedAt:          user.CreatedAt,
+		MustChangePassword: user.MustChangePassword,
+		MFAEnabled:         user.MFAEnabled,
+		ServiceAccount:     user.ServiceAccount,
+		ClientID:           user.ClientID,
+		Scopes:             user.Scopes,
+		Status:             user.StatusAt(time.Now()),
 	}
+	// A lapsed suspension is reported as the active account it now is.
+	if resp.Status == user.Status {
+		resp.StatusReason = user.StatusReason
+		resp.SuspendedUntil = user.SuspendedUntil
+	}
+	return resp
 }
 
 // Health returns a basic status payload.
@@ -56,12 +107,21 @@ func (h *Handler) Health(c *gin.Context) {
 	})
 }
 
+// JWKS publishes the public keys that verify access tokens so other services
+// can validate them without sharing a secret.
+func (h *Handler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.jwt.Keys().JWKS())
+}
+
 type registerRequest struct {
 	Username string `json:"username" binding:"required,min=3"`
 	Password string `json:"password" binding:"required,min=6"`
+	// Email is optional and only used for password reset links.
+	Email string `json:"email"`
 }
 
-// Register creates a new user account with role `user`.
+// Register creates a new user account with the policy's default role.
 func (h *Handler) Register(c *gin.Context) {
 	var req registerRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -78,8 +138,13 @@ func (h *Handler) Register(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 6 characters"})
 		return
 	}
+	email, err := store.NormalizeEmail(req.Email)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email address"})
+		return
+	}
 
-	user, err := h.store.CreateUser(req.Username, req.Password, "user")
+	user, err := h.store.RegisterUser(req.Username, req.Password, email)
 	if err != nil {
 		status := http.StatusInternalServerError
 		message := "failed to create user"
@@ -105,7 +170,7 @@ type loginRequest struct {
 	Password string `json:"password" binding:"required"`
 }
 
-// Login authenticates a user and returns a JWT.
+// Login authenticates a user and returns a JWT plus a refresh token.
 func (h *Handler) Login(c *gin.Context) {
 	var req loginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -113,37 +178,272 @@ func (h *Handler) Login(c *gin.Context) {
 		return
 	}
 
-	user, err := h.store.Authenticate(req.Username, req.Password)
+	attempt, ok := h.checkThrottle(c, req.Username)
+	if !ok {
+		return
+	}
+	defer attempt.Release()
+
+	user, err := h.authenticator.Authenticate(req.Username, req.Password)
 	if err != nil {
-		if errors.Is(err, store.ErrInvalidCredentials) {
+		if respondAccountStatus(c, err) {
+			return
+		}
+		switch {
+		case errors.Is(err, store.ErrInvalidCredentials):
+			attempt.Failure()
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid username or password"})
+		case errors.Is(err, auth.ErrDirectoryUnavailable):
+			log.Printf("login: %v", err)
+			c.JSON(http.StatusServiceUnavailable, gin.H{"error": "directory is unavailable, please try again later"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "authentication failed"})
+		}
+		return
+	}
+	// Directory accounts are not checked by the store's Authenticate.
+	if !checkAccountStatus(c, user) {
+		return
+	}
+
+	if user.MFAEnabled {
+		// The password alone does not clear the throttle, or it could be
+		// used to reset it between guesses at the second factor.
+		h.startMFAChallenge(c, user)
+		return
+	}
+	attempt.Success()
+	h.startSession(c, user)
+}
+
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a rotated
+// refresh token. Replaying a spent refresh token revokes its whole family. In
+// cookie mode the refresh token may come from its cookie instead.
+func (h *Handler) RefreshToken(c *gin.Context) {
+	var req refreshRequest
+	if token := h.refreshCookie(c); token != "" {
+		if !h.cookies.CheckCSRF(c) {
+			auth.AbortCSRF(c)
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "authentication failed"})
+		req.RefreshToken = token
+	} else if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
 		return
 	}
 
-	token, err := h.jwt.GenerateToken(user)
+	nextToken, err := auth.GenerateOpaqueToken()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
 		return
 	}
 
-	c.JSON(http.StatusOK, loginResponse{
-		Token: token,
-		User:  newUserResponse(user),
-	})
+	refresh, err := h.store.RotateRefreshToken(auth.HashToken(req.RefreshToken), auth.HashToken(nextToken), time.Now().UTC().Add(h.jwt.RefreshExpiry()))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrRefreshTokenReused):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "refresh token reuse detected, please sign in again"})
+		case errors.Is(err, store.ErrRefreshTokenInvalid):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		}
+		return
+	}
+
+	user, err := h.store.GetUser(refresh.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired refresh token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+	if !checkAccountStatus(c, user) {
+		return
+	}
+
+	// Families issued before sessions were tracked get one on their next refresh.
+	if _, err := h.store.CreateSession(user.ID, refresh.FamilyID, c.Request.UserAgent(), c.ClientIP(), refresh.ExpiresAt); err != nil && !errors.Is(err, store.ErrSessionExists) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to refresh token"})
+		return
+	}
+
+	h.respondWithTokens(c, user, nextToken, refresh)
+}
+
+type logoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// Logout revokes the access token used for the request and ends its session,
+// including the refresh token family it was issued with.
+func (h *Handler) Logout(c *gin.Context) {
+	user, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	var req logoutRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+			return
+		}
+	}
+	if req.RefreshToken == "" {
+		req.RefreshToken = h.refreshCookie(c)
+	}
+
+	if err := h.store.RevokeAccessToken(user.TokenID, user.ID, user.ExpiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign out"})
+		return
+	}
+	if user.SessionID != "" {
+		err := h.store.RevokeSession(user.ID, user.SessionID)
+		if err != nil && !errors.Is(err, store.ErrSessionNotFound) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign out"})
+			return
+		}
+	}
+	if req.RefreshToken != "" {
+		err := h.store.RevokeRefreshTokenFamily(auth.HashToken(req.RefreshToken))
+		if err != nil && !errors.Is(err, store.ErrRefreshTokenInvalid) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign out"})
+			return
+		}
+	}
+
+	if h.cookies != nil {
+		h.cookies.ClearSession(c)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// refreshCookie returns the refresh token cookie in cookie mode, or "".
+func (h *Handler) refreshCookie(c *gin.Context) string {
+	if h.cookies == nil {
+		return ""
+	}
+	token, err := c.Cookie(h.cookies.RefreshName)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// startSession opens a new refresh token family for user and responds with it
+// and a fresh access token.
+func (h *Handler) startSession(c *gin.Context, user models.User) {
+	resp, err := h.newSession(c, user)
+	if err == nil {
+		resp, err = h.deliverSession(c, resp, false)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// newSession opens a new refresh token family for user, records the device
+// that requested it and signs an access token to go with it.
+func (h *Handler) newSession(c *gin.Context, user models.User) (loginResponse, error) {
+	refreshToken, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		return loginResponse{}, err
+	}
+	refresh, err := h.store.CreateRefreshToken(user.ID, auth.HashToken(refreshToken), time.Now().UTC().Add(h.jwt.RefreshExpiry()))
+	if err != nil {
+		return loginResponse{}, err
+	}
+	if _, err := h.store.CreateSession(user.ID, refresh.FamilyID, c.Request.UserAgent(), c.ClientIP(), refresh.ExpiresAt); err != nil {
+		return loginResponse{}, err
+	}
+	return h.newLoginResponse(user, refreshToken, refresh)
+}
+
+// respondWithTokens signs an access token for user and writes it together
+// with the already persisted refresh token.
+func (h *Handler) respondWithTokens(c *gin.Context, user models.User, refreshToken string, refresh models.RefreshToken) {
+	resp, err := h.newLoginResponse(user, refreshToken, refresh)
+	if err == nil {
+		resp, err = h.deliverSession(c, resp, true)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// deliverSession moves the tokens of resp into cookies in cookie mode. A new
+// CSRF token is issued unless keepCSRF asks to carry on with the request's.
+func (h *Handler) deliverSession(c *gin.Context, resp loginResponse, keepCSRF bool) (loginResponse, error) {
+	if h.cookies == nil {
+		return resp, nil
+	}
+	csrf := ""
+	if keepCSRF {
+		csrf = h.cookies.CSRFToken(c)
+	}
+	if csrf == "" {
+		var err error
+		if csrf, err = auth.GenerateOpaqueToken(); err != nil {
+			return loginResponse{}, err
+		}
+	}
+
+	h.cookies.SetSession(c, resp.Token, time.Now().Add(h.jwt.Expiry()), resp.RefreshToken, resp.RefreshExpiresAt, csrf)
+	resp.Token, resp.RefreshToken = "", ""
+	resp.Session, resp.CSRFToken = "cookie", csrf
+	return resp, nil
 }
 
-// ListItems returns all items.
+func (h *Handler) newLoginResponse(user models.User, refreshToken string, refresh models.RefreshToken) (loginResponse, error) {
+	token, err := h.jwt.GenerateSessionToken(user, refresh.FamilyID)
+	if err != nil {
+		return loginResponse{}, err
+	}
+	return loginResponse{
+		Token:            token,
+		RefreshToken:     refreshToken,
+		RefreshExpiresAt: refresh.ExpiresAt,
+		User:             newUserResponse(user),
+	}, nil
+}
+
+// ListItems returns the items the authenticated user may see.
 func (h *Handler) ListItems(c *gin.Context) {
-	items := h.store.ListItems()
+	user, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	items, err := h.store.ListItems(user.ID, user.Role)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list items"})
+		return
+	}
 	c.JSON(http.StatusOK, gin.H{"items": items})
 }
 
-// GetItem returns a single item by ID.
+// GetItem returns a single item by ID if the authenticated user may see it.
 func (h *Handler) GetItem(c *gin.Context) {
-	item, err := h.store.GetItem(c.Param("id"))
+	user, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	item, err := h.store.GetItem(c.Param("id"), user.ID, user.Role)
 	if err != nil {
 		if errors.Is(err, store.ErrItemNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "item not found"})
@@ -159,6 +459,9 @@ func (h *Handler) GetItem(c *gin.Context) {
 type itemRequest struct {
 	Title       string `json:"title" binding:"required"`
 	Description string `json:"description"`
+	// Visibility is set when the item is created and defaults to public;
+	// PUT /items/:id/visibility changes it later.
+	Visibility models.ItemVisibility `json:"visibility"`
 }
 
 // CreateItem inserts a new item belonging to the authenticated user.
@@ -175,7 +478,7 @@ func (h *Handler) CreateItem(c *gin.Context) {
 		return
 	}
 
-	item, err := h.store.CreateItem(user.Username, req.Title, req.Description)
+	item, err := h.store.CreateItem(user.ID, req.Title, req.Description, req.Visibility)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -184,7 +487,7 @@ func (h *Handler) CreateItem(c *gin.Context) {
 	c.JSON(http.StatusCreated, item)
 }
 
-// UpdateItem updates an item owned by the authenticated user or an admin.
+// UpdateItem updates an item the authenticated user may edit under the policy.
 func (h *Handler) UpdateItem(c *gin.Context) {
 	var req itemRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -198,7 +501,11 @@ func (h *Handler) UpdateItem(c *gin.Context) {
 		return
 	}
 
-	item, err := h.store.UpdateItem(c.Param("id"), user.Username, strings.EqualFold(user.Role, "admin"), req.Title, req.Description)
+	if !h.updateScopeAllows(c, user) {
+		return
+	}
+
+	item, err := h.store.UpdateItem(c.Param("id"), user.ID, user.Role, req.Title, req.Description)
 	if err != nil {
 		switch {
 		case errors.Is(err, store.ErrItemNotFound):
@@ -214,7 +521,32 @@ func (h *Handler) UpdateItem(c *gin.Context) {
 	c.JSON(http.StatusOK, item)
 }
 
-// DeleteItem removes an item; route-level middleware ensures the caller is admin.
+// updateScopeAllows applies a personal access token's scopes to an item
+// update, which the store otherwise checks against the role alone. Editors
+// need the same scope as owners.
+func (h *Handler) updateScopeAllows(c *gin.Context, user auth.ContextUser) bool {
+	if user.HasScope(rbac.ItemsUpdateAny) {
+		return true
+	}
+	if user.HasScope(rbac.ItemsUpdateOwn) {
+		item, err := h.store.GetItem(c.Param("id"), user.ID, user.Role)
+		if errors.Is(err, store.ErrItemNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "item not found"})
+			return false
+		}
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load item"})
+			return false
+		}
+		if item.Access == models.ItemOwner || item.Access == models.ItemEditor {
+			return true
+		}
+	}
+	c.JSON(http.StatusForbidden, gin.H{"error": "access token lacks the scope to update this item"})
+	return false
+}
+
+// DeleteItem removes an item; route-level middleware ensures the caller holds items:delete.
 func (h *Handler) DeleteItem(c *gin.Context) {
 	if err := h.store.DeleteItem(c.Param("id")); err != nil {
 		if errors.Is(err, store.ErrItemNotFound) {
@@ -230,7 +562,11 @@ func (h *Handler) DeleteItem(c *gin.Context) {
 
 // ListUsers returns all users; route-level middleware ensures the caller is admin.
 func (h *Handler) ListUsers(c *gin.Context) {
-	users := h.store.ListUsers()
+	users, err := h.store.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list users"})
+		return
+	}
 	response := make([]userResponse, len(users))
 	for i, user := range users {
 		response[i] = newUserResponse(user)
@@ -238,7 +574,46 @@ func (h *Handler) ListUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"users": response})
 }
 
+// RevokeUserSessions invalidates every access and refresh token of a user;
+// route-level middleware ensures the caller is admin.
+func (h *Handler) RevokeUserSessions(c *gin.Context) {
+	userID := c.Param("id")
+	if _, err := h.store.GetUser(userID); err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	if err := h.revokeUserSessions(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke sessions"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// revokeUserSessions denylists the user's outstanding access tokens and
+// revokes their refresh tokens.
+func (h *Handler) revokeUserSessions(userID string) error {
+	if err := h.store.RevokeUserRefreshTokens(userID); err != nil {
+		return err
+	}
+	return h.store.RevokeUserAccessTokens(userID, time.Now().UTC().Add(h.jwt.Expiry()))
+}
+
+// invalidateUser makes the next request of userID re-read the account.
+func (h *Handler) invalidateUser(userID string) {
+	if h.users != nil {
+		h.users.Invalidate(userID)
+	}
+}
+
 // DeleteUser removes a user; route-level middleware ensures the caller is admin.
+// The items query parameter selects what happens to the user's items:
+// tombstone (the default), cascade, or reassign to the user in reassign_to.
 func (h *Handler) DeleteUser(c *gin.Context) {
 	userID := c.Param("id")
 	
@@ -249,14 +624,117 @@ func (h *Handler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.store.DeleteUser(userID); err != nil {
+	opts := store.UserDeletion{
+		Items:      store.ItemPolicy(c.DefaultQuery("items", string(store.ItemsTombstone))),
+		ReassignTo: c.Query("reassign_to"),
+	}
+	if !opts.Items.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "items must be cascade, reassign or tombstone"})
+		return
+	}
+	affected, err := h.store.DeleteUser(userID, opts)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		case errors.Is(err, store.ErrLastAdmin):
+			c.JSON(http.StatusConflict, gin.H{"error": "cannot delete the last user who can manage users"})
+		case errors.Is(err, store.ErrInvalidReassignTarget):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "reassign_to must be the id of another user"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
+		}
+		return
+	}
+	h.invalidateUser(userID)
+
+	// Tokens are stateless, so deleting the account alone would leave them
+	// valid. Revoking only once the deletion went through keeps a refused
+	// deletion from signing the user out.
+	if err := h.revokeUserSessions(userID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "user deleted but failed to revoke their sessions"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"items": opts.Items, "items_affected": affected})
+}
+
+type userRoleRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// SetUserRole assigns a new role to a user. The change is audited and takes
+// effect on the user's next request: re-validating middleware sees it once the
+// cached account is dropped, stateless deployments revoke the access tokens.
+func (h *Handler) SetUserRole(c *gin.Context) {
+	var req userRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	actor, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	before, err := h.store.GetUser(c.Param("id"))
+	if err != nil {
 		if errors.Is(err, store.ErrUserNotFound) {
 			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete user"})
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update role"})
 		return
 	}
 
-	c.Status(http.StatusNoContent)
+	user, err := h.store.SetUserRole(actor.ID, before.ID, req.Role)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		case errors.Is(err, store.ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role"})
+		case errors.Is(err, store.ErrLastAdmin):
+			c.JSON(http.StatusConflict, gin.H{"error": "cannot remove the last user who can manage users"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update role"})
+		}
+		return
+	}
+
+	if user.Role != before.Role {
+		if h.users != nil {
+			h.invalidateUser(user.ID)
+		} else if err := h.store.RevokeUserAccessTokens(user.ID, time.Now().UTC().Add(h.jwt.Expiry())); err != nil {
+			// Stateless tokens carry the old role until they expire. Refreshing
+			// re-reads it from the store, so revoking access tokens suffices.
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "role updated but failed to revoke existing tokens"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// ListAuditEvents returns the most recent audit events, newest first. The
+// optional limit query parameter defaults to 100.
+func (h *Handler) ListAuditEvents(c *gin.Context) {
+	limit := 100
+	if raw := c.Query("limit"); raw != "" {
+		parsed, err := strconv.Atoi(raw)
+		if err != nil || parsed <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive integer"})
+			return
+		}
+		limit = parsed
+	}
+
+	events, err := h.store.ListAuditEvents(limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list audit events"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"events": events})
 }
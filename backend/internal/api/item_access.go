@@ -0,0 +1,168 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type visibilityRequest struct {
+	Visibility models.ItemVisibility `json:"visibility" binding:"required"`
+}
+
+type grantRequest struct {
+	// User is the username to grant access to; Role a role name. Exactly one
+	// of them is set.
+	User   string            `json:"user"`
+	Role   string            `json:"role"`
+	Access models.ItemAccess `json:"access" binding:"required"`
+}
+
+// SetItemVisibility makes an item private, shared or public. The store checks
+// that the caller owns the item or may update any item.
+func (h *Handler) SetItemVisibility(c *gin.Context) {
+	var req visibilityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	user, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if !h.updateScopeAllows(c, user) {
+		return
+	}
+
+	item, err := h.store.SetItemVisibility(c.Param("id"), user.ID, user.Role, req.Visibility)
+	if err != nil {
+		respondItemAccessError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, item)
+}
+
+// ListItemGrants returns who an item was shared with. Like changing them, it
+// needs an update scope when called with an access token.
+func (h *Handler) ListItemGrants(c *gin.Context) {
+	user, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if !h.updateScopeAllows(c, user) {
+		return
+	}
+
+	grants, err := h.store.ListItemGrants(c.Param("id"), user.ID, user.Role)
+	if err != nil {
+		respondItemAccessError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"grants": grants})
+}
+
+// GrantItemAccess makes a user or every holder of a role a viewer or editor of
+// an item.
+func (h *Handler) GrantItemAccess(c *gin.Context) {
+	var req grantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+	req.User, req.Role = strings.TrimSpace(req.User), strings.TrimSpace(req.Role)
+	if (req.User == "") == (req.Role == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "specify either a user or a role"})
+		return
+	}
+
+	user, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if !h.updateScopeAllows(c, user) {
+		return
+	}
+
+	subjectType, subject := models.GrantToRole, req.Role
+	if req.User != "" {
+		grantee, err := h.store.GetUserByUsername(req.User)
+		if err != nil {
+			if errors.Is(err, store.ErrUserNotFound) {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "user not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load user"})
+			return
+		}
+		subjectType, subject = models.GrantToUser, grantee.ID
+	}
+
+	grant, err := h.store.GrantItemAccess(c.Param("id"), user.ID, user.Role, subjectType, subject, req.Access)
+	if err != nil {
+		respondItemAccessError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, grant)
+}
+
+// RevokeItemAccess removes a grant. The subject is a user ID or a role name,
+// depending on the type in the path.
+func (h *Handler) RevokeItemAccess(c *gin.Context) {
+	user, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if !h.updateScopeAllows(c, user) {
+		return
+	}
+
+	subjectType := models.GrantSubject(c.Param("type"))
+	if subjectType != models.GrantToUser && subjectType != models.GrantToRole {
+		c.JSON(http.StatusNotFound, gin.H{"error": "grant not found"})
+		return
+	}
+	if err := h.store.RevokeItemAccess(c.Param("id"), user.ID, user.Role, subjectType, c.Param("subject")); err != nil {
+		respondItemAccessError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ItemAccess lists every user who can see an item, what they may do and why;
+// route-level middleware ensures the caller is admin.
+func (h *Handler) ItemAccess(c *gin.Context) {
+	access, err := h.store.EffectiveItemAccess(c.Param("id"))
+	if err != nil {
+		respondItemAccessError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"access": access})
+}
+
+func respondItemAccessError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, store.ErrItemNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "item not found"})
+	case errors.Is(err, store.ErrGrantNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "grant not found"})
+	case errors.Is(err, store.ErrForbidden):
+		c.JSON(http.StatusForbidden, gin.H{"error": "you do not have permission to share this item"})
+	case errors.Is(err, store.ErrInvalidVisibility):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, store.ErrInvalidGrant):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "access must be viewer or editor, for an existing user other than the owner or an existing role"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update item access"})
+	}
+}
@@ -0,0 +1,74 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"assignment3/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestItemSharingRoutes(t *testing.T) {
+	s := newTestServer(t)
+	_, aliceToken := s.signIn("alice", "user")
+	bob, bobToken := s.signIn("bob", "user")
+	_, adminToken := s.signIn("root", "admin")
+
+	var item models.Item
+	s.expect(http.StatusCreated, http.MethodPost, "/api/items", aliceToken, gin.H{"title": "Plans", "visibility": "shared"}, &item)
+	path := "/api/items/" + item.ID
+
+	// Items the caller cannot see do not exist for them.
+	s.expect(http.StatusNotFound, http.MethodGet, path, bobToken, nil, nil)
+	s.expect(http.StatusNotFound, http.MethodPut, path, bobToken, gin.H{"title": "Mine"}, nil)
+	s.expect(http.StatusNotFound, http.MethodGet, path+"/grants", bobToken, nil, nil)
+
+	s.expect(http.StatusBadRequest, http.MethodPut, path+"/grants", aliceToken, gin.H{"user": "bob", "role": "user", "access": "viewer"}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPut, path+"/grants", aliceToken, gin.H{"user": "nobody", "access": "viewer"}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPut, path+"/grants", aliceToken, gin.H{"user": "bob", "access": "owner"}, nil)
+	s.expect(http.StatusOK, http.MethodPut, path+"/grants", aliceToken, gin.H{"user": "bob", "access": "editor"}, nil)
+
+	var got models.Item
+	s.expect(http.StatusOK, http.MethodGet, path, bobToken, nil, &got)
+	if got.Access != models.ItemEditor {
+		t.Fatalf("expected bob to be an editor, got %q", got.Access)
+	}
+	s.expect(http.StatusOK, http.MethodPut, path, bobToken, gin.H{"title": "Plans v2"}, nil)
+	// Editors edit, but only the owner and admins decide who else may.
+	s.expect(http.StatusForbidden, http.MethodGet, path+"/grants", bobToken, nil, nil)
+	s.expect(http.StatusForbidden, http.MethodPut, path+"/visibility", bobToken, gin.H{"visibility": "public"}, nil)
+	s.expect(http.StatusForbidden, http.MethodPost, path+"/transfer", bobToken, gin.H{"to": "bob"}, nil)
+
+	var grants struct {
+		Grants []models.ItemGrant `json:"grants"`
+	}
+	s.expect(http.StatusOK, http.MethodGet, path+"/grants", aliceToken, nil, &grants)
+	if len(grants.Grants) != 1 || grants.Grants[0].SubjectName != "bob" || grants.Grants[0].Access != models.ItemEditor {
+		t.Fatalf("unexpected grants: %+v", grants.Grants)
+	}
+
+	s.expect(http.StatusForbidden, http.MethodGet, path+"/access", aliceToken, nil, nil)
+	var access struct {
+		Access []models.EffectiveAccess `json:"access"`
+	}
+	s.expect(http.StatusOK, http.MethodGet, path+"/access", adminToken, nil, &access)
+	if len(access.Access) != 3 || access.Access[1].Username != "bob" || access.Access[1].Source != models.AccessFromUserGrant {
+		t.Fatalf("unexpected effective access: %+v", access.Access)
+	}
+
+	// Access tokens need an update scope to manage sharing.
+	_, readOnly := s.accessToken(aliceToken, "read-only", "items:read")
+	s.expect(http.StatusForbidden, http.MethodPut, path+"/visibility", readOnly, gin.H{"visibility": "private"}, nil)
+	s.expect(http.StatusForbidden, http.MethodPut, path+"/grants", readOnly, gin.H{"role": "user", "access": "viewer"}, nil)
+	s.expect(http.StatusForbidden, http.MethodGet, path+"/grants", readOnly, nil, nil)
+	_, writer := s.accessToken(aliceToken, "writer", "items:read", "items:update:own")
+	s.expect(http.StatusOK, http.MethodGet, path+"/grants", writer, nil, nil)
+	s.expect(http.StatusBadRequest, http.MethodPut, path+"/visibility", writer, gin.H{"visibility": "hidden"}, nil)
+	s.expect(http.StatusOK, http.MethodPut, path+"/visibility", writer, gin.H{"visibility": "private"}, nil)
+	s.expect(http.StatusNotFound, http.MethodGet, path, bobToken, nil, nil)
+
+	s.expect(http.StatusNotFound, http.MethodDelete, path+"/grants/group/bob", aliceToken, nil, nil)
+	s.expect(http.StatusNoContent, http.MethodDelete, path+"/grants/user/"+bob.ID, aliceToken, nil, nil)
+	s.expect(http.StatusNotFound, http.MethodDelete, path+"/grants/user/"+bob.ID, aliceToken, nil, nil)
+}
@@ -0,0 +1,63 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"assignment3/backend/internal/auth"
+
+	"github.com/gin-gonic/gin"
+)
+
+// checkThrottle rejects the request with 429 if sign-ins for username or from
+// the client's address are currently throttled. Otherwise it returns the
+// reserved attempt, which the caller settles once the credentials are checked;
+// the attempt is nil if throttling is disabled.
+func (h *Handler) checkThrottle(c *gin.Context, username string) (*auth.LoginAttempt, bool) {
+	if h.throttle == nil {
+		return nil, true
+	}
+	attempt, wait, ok := h.throttle.Check(username, c.ClientIP())
+	if ok {
+		return attempt, true
+	}
+	seconds := int(math.Ceil(wait.Seconds()))
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.JSON(http.StatusTooManyRequests, gin.H{
+		"error":       "too many failed sign-in attempts, try again later",
+		"retry_after": seconds,
+	})
+	return nil, false
+}
+
+// ListLockouts returns the usernames and addresses with recent failed
+// sign-ins, including those currently locked out.
+func (h *Handler) ListLockouts(c *gin.Context) {
+	lockouts := []auth.Lockout{}
+	if h.throttle != nil {
+		lockouts = h.throttle.Lockouts()
+	}
+	c.JSON(http.StatusOK, gin.H{"lockouts": lockouts})
+}
+
+// ClearLockout forgets the failed sign-ins of a username or address, lifting
+// any lockout on it.
+func (h *Handler) ClearLockout(c *gin.Context) {
+	kind := c.Param("kind")
+	if kind != auth.ThrottleUsername && kind != auth.ThrottleIP {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be username or ip"})
+		return
+	}
+	key := strings.TrimPrefix(c.Param("key"), "/")
+	if key == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "key is required"})
+		return
+	}
+	if h.throttle == nil || !h.throttle.Clear(kind, key) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no failed sign-ins recorded for this key"})
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,367 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type mfaChallengeResponse struct {
+	MFARequired bool      `json:"mfa_required"`
+	MFAToken    string    `json:"mfa_token"`
+	ExpiresAt   time.Time `json:"mfa_expires_at"`
+}
+
+// startMFAChallenge answers a correct password for an account with two-factor
+// authentication by asking for the second factor instead of signing in.
+func (h *Handler) startMFAChallenge(c *gin.Context, user models.User) {
+	token, expiresAt, err := h.jwt.GenerateMFAChallenge(user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	c.JSON(http.StatusOK, mfaChallengeResponse{MFARequired: true, MFAToken: token, ExpiresAt: expiresAt})
+}
+
+// verifySecondFactor checks code as a TOTP code and otherwise as a recovery
+// code, spending whichever matched. Invalid and reused codes report false.
+func (h *Handler) verifySecondFactor(user models.User, code string) (bool, error) {
+	var err error
+	if counter, ok := auth.ValidateTOTP(user.MFASecret, code, time.Now()); ok {
+		err = h.store.UseMFACode(user.ID, counter)
+	} else {
+		_, err = h.store.UseRecoveryCode(user.ID, auth.HashRecoveryCode(code))
+	}
+	switch {
+	case err == nil:
+		return true, nil
+	case errors.Is(err, store.ErrMFACodeReused), errors.Is(err, store.ErrRecoveryCodeInvalid), errors.Is(err, store.ErrMFANotEnrolled):
+		return false, nil
+	default:
+		return false, err
+	}
+}
+
+type mfaLoginRequest struct {
+	MFAToken string `json:"mfa_token" binding:"required"`
+	Code     string `json:"code" binding:"required"`
+}
+
+// LoginMFA completes a sign-in that Login answered with an MFA challenge. The
+// code is a current TOTP code or one of the user's recovery codes.
+func (h *Handler) LoginMFA(c *gin.Context) {
+	var req mfaLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	claims, err := h.jwt.ParseMFAChallenge(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "sign-in expired, please enter your password again"})
+		return
+	}
+	attempt, ok := h.checkThrottle(c, claims.Username)
+	if !ok {
+		return
+	}
+	defer attempt.Release()
+
+	user, err := h.store.GetUser(claims.UserID)
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "account no longer exists"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "authentication failed"})
+		return
+	}
+	if !checkAccountStatus(c, user) {
+		return
+	}
+
+	ok, err = h.verifySecondFactor(user, req.Code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "authentication failed"})
+		return
+	}
+	if !ok {
+		attempt.Failure()
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid verification code"})
+		return
+	}
+
+	attempt.Success()
+	h.startSession(c, user)
+}
+
+type mfaStatusResponse struct {
+	Enabled bool `json:"enabled"`
+	// Pending is set between enrolling and confirming an authenticator.
+	Pending                bool `json:"pending"`
+	Required               bool `json:"required"`
+	RecoveryCodesRemaining int  `json:"recovery_codes_remaining"`
+}
+
+// currentUser loads the account behind the request.
+func (h *Handler) currentUser(c *gin.Context) (models.User, bool) {
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return models.User{}, false
+	}
+	user, err := h.store.GetUser(current.ID)
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "account no longer exists"})
+			return models.User{}, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load account"})
+		return models.User{}, false
+	}
+	return user, true
+}
+
+// MFAStatus reports the caller's two-factor authentication settings.
+func (h *Handler) MFAStatus(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, mfaStatusResponse{
+		Enabled:                user.MFAEnabled,
+		Pending:                !user.MFAEnabled && user.MFASecret != "",
+		Required:               h.store.Policy().RequiresMFA(user.Role),
+		RecoveryCodesRemaining: len(user.RecoveryCodes),
+	})
+}
+
+// EnrollMFA starts setting up an authenticator app by generating a secret.
+// It takes effect once ConfirmMFA receives a code produced from it.
+func (h *Handler) EnrollMFA(c *gin.Context) {
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start enrollment"})
+		return
+	}
+	if _, err := h.store.BeginMFAEnrollment(user.ID, secret); err != nil {
+		if errors.Is(err, store.ErrMFAAlreadyEnabled) {
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start enrollment"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"secret":      secret,
+		"otpauth_uri": auth.TOTPURI(h.mfaIssuer, user.Username, secret),
+	})
+}
+
+type mfaCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+type mfaConfirmResponse struct {
+	loginResponse
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// ConfirmMFA turns on two-factor authentication once the caller proves their
+// authenticator works. Other sessions are signed out, and a fresh session is
+// returned with the recovery codes, which are shown only this once.
+func (h *Handler) ConfirmMFA(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+	current, _ := auth.GetContextUser(c)
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if user.MFAEnabled {
+		c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+		return
+	}
+	if user.MFASecret == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "start enrollment first"})
+		return
+	}
+	counter, valid := auth.ValidateTOTP(user.MFASecret, req.Code, time.Now())
+	if !valid {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid verification code"})
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable two-factor authentication"})
+		return
+	}
+	user, err = h.store.EnableMFA(user.ID, counter, hashes)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrMFAAlreadyEnabled):
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is already enabled"})
+		case errors.Is(err, store.ErrMFANotEnrolled):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "start enrollment first"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enable two-factor authentication"})
+		}
+		return
+	}
+
+	if err := h.revokeUserSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "two-factor authentication enabled but failed to revoke existing sessions"})
+		return
+	}
+	if err := h.store.RevokeAccessToken(current.TokenID, user.ID, current.ExpiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "two-factor authentication enabled but failed to revoke existing sessions"})
+		return
+	}
+	h.invalidateUser(user.ID)
+
+	session, err := h.newSession(c, user)
+	if err == nil {
+		session, err = h.deliverSession(c, session, false)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to issue token"})
+		return
+	}
+	c.JSON(http.StatusOK, mfaConfirmResponse{loginResponse: session, RecoveryCodes: codes})
+}
+
+func newRecoveryCodes() ([]string, []string, error) {
+	codes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		return nil, nil, err
+	}
+	hashes := make([]string, len(codes))
+	for i, code := range codes {
+		hashes[i] = auth.HashRecoveryCode(code)
+	}
+	return codes, hashes, nil
+}
+
+// checkSecondFactor verifies a code for an account with MFA enabled, counting
+// failures like failed sign-ins.
+func (h *Handler) checkSecondFactor(c *gin.Context, user models.User, code string) bool {
+	if !user.MFAEnabled {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "two-factor authentication is not enabled"})
+		return false
+	}
+	attempt, ok := h.checkThrottle(c, user.Username)
+	if !ok {
+		return false
+	}
+	defer attempt.Release()
+	ok, err := h.verifySecondFactor(user, code)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to verify code"})
+		return false
+	}
+	if !ok {
+		attempt.Failure()
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid verification code"})
+		return false
+	}
+	return true
+}
+
+// RegenerateRecoveryCodes replaces the caller's recovery codes after checking
+// a current code.
+func (h *Handler) RegenerateRecoveryCodes(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+	user, ok := h.currentUser(c)
+	if !ok || !h.checkSecondFactor(c, user, req.Code) {
+		return
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate recovery codes"})
+		return
+	}
+	if _, err := h.store.ReplaceRecoveryCodes(user.ID, hashes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate recovery codes"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"recovery_codes": codes})
+}
+
+// DisableMFA turns off the caller's two-factor authentication after checking
+// a current code. Roles that require MFA cannot turn it off.
+func (h *Handler) DisableMFA(c *gin.Context) {
+	var req mfaCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+	user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+	if h.store.Policy().RequiresMFA(user.Role) {
+		c.JSON(http.StatusConflict, gin.H{"error": "your role requires two-factor authentication"})
+		return
+	}
+	if !h.checkSecondFactor(c, user, req.Code) {
+		return
+	}
+
+	if _, err := h.store.DisableMFA(user.ID, user.ID); err != nil && !errors.Is(err, store.ErrMFANotEnrolled) {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to disable two-factor authentication"})
+		return
+	}
+	h.invalidateUser(user.ID)
+	c.Status(http.StatusNoContent)
+}
+
+// ResetUserMFA removes a user's authenticator, e.g. after they lost it and
+// their recovery codes. The user is signed out everywhere and, if their role
+// requires MFA, must set it up again on the next sign-in.
+func (h *Handler) ResetUserMFA(c *gin.Context) {
+	actor, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	user, err := h.store.DisableMFA(actor.ID, c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		case errors.Is(err, store.ErrMFANotEnrolled):
+			c.JSON(http.StatusConflict, gin.H{"error": "two-factor authentication is not set up for this user"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset two-factor authentication"})
+		}
+		return
+	}
+
+	if err := h.revokeUserSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "two-factor authentication reset but failed to revoke existing sessions"})
+		return
+	}
+	h.invalidateUser(user.ID)
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
@@ -0,0 +1,273 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// oidcConfig configures single sign-on through an OpenID Connect provider.
+type oidcConfig struct {
+	provider *auth.OIDCProvider
+	// roles picks the role of provisioned users; unmatched users get the
+	// policy's default role.
+	roles auth.ClaimRoleMapping
+	// linkByEmail signs a new identity in to the only existing account with
+	// the same address, if the provider verified it and the account has no
+	// password. Local addresses are not verified, so an account with a
+	// password could have claimed someone else's.
+	linkByEmail bool
+}
+
+type oidcStartResponse struct {
+	AuthorizationURL string    `json:"authorization_url"`
+	Ticket           string    `json:"ticket"`
+	ExpiresAt        time.Time `json:"expires_at"`
+}
+
+type oidcCallbackRequest struct {
+	Code   string `json:"code" binding:"required"`
+	State  string `json:"state" binding:"required"`
+	Ticket string `json:"ticket" binding:"required"`
+}
+
+// OIDCInfo tells the frontend which identity provider it can offer.
+func (h *Handler) OIDCInfo(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"name": h.oidc.provider.Name()})
+}
+
+// StartOIDCLogin returns the provider URL to send the browser to, and a ticket
+// the client must present with the code the provider returns.
+func (h *Handler) StartOIDCLogin(c *gin.Context) {
+	h.startOIDC(c, "")
+}
+
+// OIDCCallback completes a sign-in at the identity provider. Users seen for
+// the first time are linked by email or provisioned.
+func (h *Handler) OIDCCallback(c *gin.Context) {
+	login, identity, ok := h.finishOIDC(c)
+	if !ok {
+		return
+	}
+	if login.LinkUserID != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sign-in attempt is invalid or expired, please try again"})
+		return
+	}
+
+	user, err := h.oidcUser(identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidRole):
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "single sign-on maps to an unknown role"})
+		default:
+			log.Printf("oidc: failed to resolve user for %s: %v", identity.Subject, err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to sign in"})
+		}
+		return
+	}
+	if !checkAccountStatus(c, user) {
+		return
+	}
+
+	if user.MFAEnabled {
+		h.startMFAChallenge(c, user)
+		return
+	}
+	h.startSession(c, user)
+}
+
+// oidcUser returns the account for a verified identity, linking or creating
+// one when the identity is new.
+func (h *Handler) oidcUser(identity *auth.IDTokenClaims) (models.User, error) {
+	user, err := h.store.GetUserByIdentity(identity.Issuer, identity.Subject)
+	if !errors.Is(err, store.ErrIdentityNotFound) {
+		return user, err
+	}
+
+	if h.oidc.linkByEmail && identity.EmailVerified && identity.Email != "" {
+		user, err := h.store.GetUserByEmail(identity.Email)
+		switch {
+		case err == nil && !user.ServiceAccount && user.PasswordHash == "":
+			_, err := h.store.LinkIdentity(user.ID, identity.Issuer, identity.Subject, identity.Email)
+			if errors.Is(err, store.ErrIdentityExists) {
+				return h.store.GetUserByIdentity(identity.Issuer, identity.Subject)
+			}
+			return user, err
+		case err == nil && !user.ServiceAccount:
+			// The owner of an account with a password links the identity
+			// from their settings instead.
+			log.Printf("oidc: not linking %s to %s, which has a password", identity.Subject, user.Username)
+		case err != nil && !errors.Is(err, store.ErrUserNotFound):
+			return models.User{}, err
+		}
+	}
+
+	role := h.oidc.roles.Role(identity)
+	username := oidcUsername(identity)
+	user, err = h.store.CreateExternalUser(username, identity.Email, role, identity.Issuer, identity.Subject)
+	if errors.Is(err, store.ErrUserExists) {
+		// The name belongs to someone else; a suffix derived from the
+		// subject keeps it stable across retries.
+		username += "-" + auth.HashToken(identity.Issuer + " " + identity.Subject)[:6]
+		user, err = h.store.CreateExternalUser(username, identity.Email, role, identity.Issuer, identity.Subject)
+	}
+	if errors.Is(err, store.ErrIdentityExists) {
+		// A concurrent sign-in provisioned the account first.
+		return h.store.GetUserByIdentity(identity.Issuer, identity.Subject)
+	}
+	return user, err
+}
+
+// oidcUsername picks a username for a provisioned account from the claims.
+func oidcUsername(identity *auth.IDTokenClaims) string {
+	for _, candidate := range []string{identity.PreferredUsername, identity.Email, identity.Name} {
+		if candidate = strings.TrimSpace(candidate); candidate != "" {
+			return candidate
+		}
+	}
+	return "user-" + auth.HashToken(identity.Issuer + " " + identity.Subject)[:8]
+}
+
+// ListIdentities returns the external identities linked to the caller.
+func (h *Handler) ListIdentities(c *gin.Context) {
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	identities, err := h.store.ListIdentities(current.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list identities"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"identities": identities})
+}
+
+// StartIdentityLink starts a sign-in at the identity provider whose identity
+// is then linked to the caller.
+func (h *Handler) StartIdentityLink(c *gin.Context) {
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	h.startOIDC(c, current.ID)
+}
+
+// CompleteIdentityLink links the identity the caller signed in with at the
+// provider to their account.
+func (h *Handler) CompleteIdentityLink(c *gin.Context) {
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	login, identity, ok := h.finishOIDC(c)
+	if !ok {
+		return
+	}
+	if login.LinkUserID != current.ID {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sign-in attempt is invalid or expired, please try again"})
+		return
+	}
+
+	linked, err := h.store.LinkIdentity(current.ID, identity.Issuer, identity.Subject, identity.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrIdentityExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "this identity is already linked to an account"})
+		case errors.Is(err, store.ErrServiceAccount):
+			c.JSON(http.StatusConflict, gin.H{"error": "service accounts cannot link identities"})
+		case errors.Is(err, store.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to link identity"})
+		}
+		return
+	}
+	c.JSON(http.StatusCreated, linked)
+}
+
+// UnlinkIdentity removes one of the caller's external identities.
+func (h *Handler) UnlinkIdentity(c *gin.Context) {
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	if err := h.store.UnlinkIdentity(current.ID, c.Param("id")); err != nil {
+		switch {
+		case errors.Is(err, store.ErrIdentityNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "identity not found"})
+		case errors.Is(err, store.ErrLastSignInMethod):
+			c.JSON(http.StatusConflict, gin.H{"error": "set a password before removing your only sign-in method"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to unlink identity"})
+		}
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) startOIDC(c *gin.Context, linkUserID string) {
+	login, err := auth.NewOIDCLogin(linkUserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sign-in"})
+		return
+	}
+	authURL, err := h.oidc.provider.AuthCodeURL(login)
+	if err != nil {
+		log.Printf("oidc: %v", err)
+		c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider is unavailable"})
+		return
+	}
+	ticket, err := h.jwt.GenerateOIDCTicket(login)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to start sign-in"})
+		return
+	}
+	c.JSON(http.StatusOK, oidcStartResponse{
+		AuthorizationURL: authURL,
+		Ticket:           ticket,
+		ExpiresAt:        time.Now().UTC().Add(auth.OIDCLoginExpiry),
+	})
+}
+
+// finishOIDC checks the callback against its ticket and redeems the code. It
+// responds itself and reports false on failure.
+func (h *Handler) finishOIDC(c *gin.Context) (auth.OIDCLogin, *auth.IDTokenClaims, bool) {
+	var req oidcCallbackRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return auth.OIDCLogin{}, nil, false
+	}
+
+	login, err := h.jwt.ParseOIDCTicket(req.Ticket, req.State)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sign-in attempt is invalid or expired, please try again"})
+		return auth.OIDCLogin{}, nil, false
+	}
+
+	identity, err := h.oidc.provider.Exchange(req.Code, login)
+	if err != nil {
+		log.Printf("oidc: %v", err)
+		if errors.Is(err, auth.ErrOIDCUnavailable) {
+			c.JSON(http.StatusBadGateway, gin.H{"error": "identity provider is unavailable"})
+		} else {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "single sign-on failed"})
+		}
+		return auth.OIDCLogin{}, nil, false
+	}
+	return login, identity, true
+}
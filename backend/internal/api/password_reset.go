@@ -0,0 +1,128 @@
+package api
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/mail"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// passwordResetConfig configures self-service password resets.
+type passwordResetConfig struct {
+	mailer mail.Mailer
+	// linkBase is the frontend page that accepts the token as ?reset_token=.
+	linkBase string
+	ttl      time.Duration
+}
+
+type forgotPasswordRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// ForgotPassword emails a single-use reset link to the account's address. The
+// response is the same whether or not the account exists or has an email
+// address, and the work happens after responding so timing reveals nothing
+// either.
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req forgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	go h.sendPasswordReset(req.Username)
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the account exists and has an email address, a reset link has been sent"})
+}
+
+// sendPasswordReset issues a reset token for username and mails the link.
+// Failures are only logged since the caller has already been answered.
+func (h *Handler) sendPasswordReset(username string) {
+	user, err := h.store.GetUserByUsername(username)
+	if err != nil {
+		if !errors.Is(err, store.ErrUserNotFound) {
+			log.Printf("password reset: failed to look up user: %v", err)
+		}
+		return
+	}
+	if user.Email == "" {
+		return
+	}
+
+	token, err := auth.GenerateOpaqueToken()
+	if err != nil {
+		log.Printf("password reset: failed to generate token: %v", err)
+		return
+	}
+	expiresAt := time.Now().UTC().Add(h.resets.ttl)
+	if _, err := h.store.CreatePasswordResetToken(user.ID, auth.HashToken(token), expiresAt); err != nil {
+		log.Printf("password reset: failed to store token: %v", err)
+		return
+	}
+
+	link, err := url.Parse(h.resets.linkBase)
+	if err != nil {
+		log.Printf("password reset: invalid link base %q: %v", h.resets.linkBase, err)
+		return
+	}
+	query := link.Query()
+	query.Set("reset_token", token)
+	link.RawQuery = query.Encode()
+
+	msg := mail.Message{
+		To:      user.Email,
+		Subject: "Reset your password",
+		Body: "Hello " + user.Username + ",\n\n" +
+			"Someone asked to reset the password of your account. Open this link to choose a new one:\n\n" +
+			link.String() + "\n\n" +
+			"The link can be used once and expires at " + expiresAt.Format(time.RFC1123) + ".\n" +
+			"If you did not ask for this, you can ignore this email.\n",
+	}
+	if err := h.resets.mailer.Send(msg); err != nil {
+		log.Printf("password reset: failed to send mail to user %s: %v", user.ID, err)
+	}
+}
+
+type completePasswordResetRequest struct {
+	Token       string `json:"token" binding:"required"`
+	NewPassword string `json:"new_password" binding:"required"`
+}
+
+// CompletePasswordReset spends a reset token and sets the new password. The
+// user is signed out everywhere and has to sign in with the new password.
+func (h *Handler) CompletePasswordReset(c *gin.Context) {
+	var req completePasswordResetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+	if len(req.NewPassword) < 6 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 6 characters"})
+		return
+	}
+
+	user, err := h.store.ResetPasswordWithToken(auth.HashToken(req.Token), req.NewPassword)
+	if err != nil {
+		if errors.Is(err, store.ErrResetTokenInvalid) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid or expired reset token"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		return
+	}
+
+	if err := h.revokeUserSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "password reset but failed to revoke existing sessions"})
+		return
+	}
+	h.invalidateUser(user.ID)
+
+	c.Status(http.StatusNoContent)
+}
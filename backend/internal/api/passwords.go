@@ -0,0 +1,159 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type changePasswordRequest struct {
+	CurrentPassword string `json:"current_password" binding:"required"`
+	NewPassword     string `json:"new_password" binding:"required"`
+}
+
+// ChangePassword replaces the caller's password. Every existing session is
+// revoked and a fresh one is returned, which also lifts a pending forced
+// password change.
+func (h *Handler) ChangePassword(c *gin.Context) {
+	var req changePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+	if len(req.NewPassword) < 6 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 6 characters"})
+		return
+	}
+
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	attempt, ok := h.checkThrottle(c, current.Username)
+	if !ok {
+		return
+	}
+	defer attempt.Release()
+
+	user, err := h.store.ChangePassword(current.ID, req.CurrentPassword, req.NewPassword)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidCredentials):
+			attempt.Failure()
+			// Not 401: the session itself is fine, only the confirmation failed.
+			c.JSON(http.StatusBadRequest, gin.H{"error": "current password is incorrect"})
+		case errors.Is(err, store.ErrPasswordUnchanged):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "new password must differ from the current one"})
+		case errors.Is(err, store.ErrUserNotFound):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "account no longer exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change password"})
+		}
+		return
+	}
+	attempt.Success()
+
+	if err := h.revokeUserSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "password changed but failed to revoke existing sessions"})
+		return
+	}
+	// Tokens minted in the same second as the user-wide revocation stay
+	// valid, so drop the one used for this request explicitly.
+	if err := h.store.RevokeAccessToken(current.TokenID, user.ID, current.ExpiresAt); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "password changed but failed to revoke existing sessions"})
+		return
+	}
+	h.invalidateUser(user.ID)
+
+	h.startSession(c, user)
+}
+
+type resetPasswordRequest struct {
+	TemporaryPassword string `json:"temporary_password" binding:"required"`
+}
+
+// ResetPassword sets a temporary password for a user, who must replace it on
+// their next sign-in. The reset is audited and signs the user out everywhere;
+// route-level middleware ensures the caller can manage users.
+func (h *Handler) ResetPassword(c *gin.Context) {
+	var req resetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+	if len(req.TemporaryPassword) < 6 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "password must be at least 6 characters"})
+		return
+	}
+
+	actor, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	user, err := h.store.ResetPassword(actor.ID, c.Param("id"), req.TemporaryPassword)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		case errors.Is(err, store.ErrPasswordUnchanged):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "temporary password must differ from the current one"})
+		case errors.Is(err, store.ErrServiceAccount):
+			c.JSON(http.StatusConflict, gin.H{"error": "service accounts have no password; rotate their secret instead"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to reset password"})
+		}
+		return
+	}
+
+	if err := h.revokeUserSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "password reset but failed to revoke existing sessions"})
+		return
+	}
+	h.invalidateUser(user.ID)
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+type emailRequest struct {
+	Email string `json:"email"`
+}
+
+// SetEmail replaces the caller's email address, used for password reset
+// links. An empty address removes it.
+func (h *Handler) SetEmail(c *gin.Context) {
+	var req emailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	user, err := h.store.SetUserEmail(current.ID, req.Email)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrInvalidEmail):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid email address"})
+		case errors.Is(err, store.ErrUserNotFound):
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "account no longer exists"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update email"})
+		}
+		return
+	}
+	h.invalidateUser(user.ID)
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
@@ -0,0 +1,119 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type roleRequest struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+// respondRoleError maps role store errors onto HTTP responses.
+func respondRoleError(c *gin.Context, err error, fallback string) {
+	switch {
+	case errors.Is(err, store.ErrRoleNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "role not found"})
+	case errors.Is(err, store.ErrRoleExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "role already exists"})
+	case errors.Is(err, store.ErrRoleInUse):
+		c.JSON(http.StatusConflict, gin.H{"error": "role is assigned to users or is the default role"})
+	case errors.Is(err, store.ErrLastManagerRole):
+		c.JSON(http.StatusConflict, gin.H{"error": "at least one role must keep users:manage"})
+	case errors.Is(err, store.ErrInvalidRole):
+		c.JSON(http.StatusBadRequest, gin.H{"error": "role names must start with a letter and contain only a-z, 0-9, '-' or '_'"})
+	case errors.Is(err, store.ErrInvalidPermission):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fallback})
+	}
+}
+
+// ListRoles returns every role with its permissions.
+func (h *Handler) ListRoles(c *gin.Context) {
+	roles, err := h.store.ListRoles()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list roles"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+// GetRole returns a single role.
+func (h *Handler) GetRole(c *gin.Context) {
+	role, err := h.store.GetRole(c.Param("name"))
+	if err != nil {
+		respondRoleError(c, err, "failed to load role")
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
+
+// CreateRole defines a new role.
+func (h *Handler) CreateRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	role, err := h.store.CreateRole(req.Name, req.Permissions)
+	if err != nil {
+		respondRoleError(c, err, "failed to create role")
+		return
+	}
+	c.JSON(http.StatusCreated, role)
+}
+
+// UpdateRole replaces the permissions of a role. Users holding it are affected
+// on their next request.
+func (h *Handler) UpdateRole(c *gin.Context) {
+	var req roleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	role, err := h.store.UpdateRole(c.Param("name"), req.Permissions)
+	if err != nil {
+		respondRoleError(c, err, "failed to update role")
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
+
+type roleMFARequest struct {
+	Required *bool `json:"required" binding:"required"`
+}
+
+// SetRoleMFA sets whether users holding a role must use two-factor
+// authentication. Users without it are limited to setting it up from their
+// next request on.
+func (h *Handler) SetRoleMFA(c *gin.Context) {
+	var req roleMFARequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	role, err := h.store.SetRoleMFARequired(c.Param("name"), *req.Required)
+	if err != nil {
+		respondRoleError(c, err, "failed to update role")
+		return
+	}
+	c.JSON(http.StatusOK, role)
+}
+
+// DeleteRole removes a role that is no longer assigned to anyone.
+func (h *Handler) DeleteRole(c *gin.Context) {
+	if err := h.store.DeleteRole(c.Param("name")); err != nil {
+		respondRoleError(c, err, "failed to delete role")
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
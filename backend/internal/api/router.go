@@ -5,22 +5,129 @@ import (
 	"time"
 
 	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/mail"
+	"assignment3/backend/internal/rbac"
 	"assignment3/backend/internal/store"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
 )
 
+// RouterOption customises SetupRouter.
+type RouterOption func(*routerConfig)
+
+type routerConfig struct {
+	revalidateUsers bool
+	userCacheTTL    time.Duration
+	passwordReset   *passwordResetConfig
+	loginThrottle   *auth.ThrottleConfig
+	mfaIssuer       string
+	oidc            *oidcConfig
+	authenticator   auth.Authenticator
+	cookies         *auth.CookieConfig
+	transferTTL     time.Duration
+}
+
+// WithUserRevalidation makes every authenticated request check the token's
+// subject against the store, caching lookups for ttl. Without it tokens are
+// trusted until they expire or are revoked.
+func WithUserRevalidation(ttl time.Duration) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.revalidateUsers = true
+		cfg.userCacheTTL = ttl
+	}
+}
+
+// WithPasswordReset enables self-service password resets. Reset links are
+// sent through mailer, point at linkBase with a reset_token query parameter,
+// and expire after ttl.
+func WithPasswordReset(mailer mail.Mailer, linkBase string, ttl time.Duration) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.passwordReset = &passwordResetConfig{mailer: mailer, linkBase: linkBase, ttl: ttl}
+	}
+}
+
+// WithLoginThrottle replaces the default limits on failed sign-ins.
+func WithLoginThrottle(throttle auth.ThrottleConfig) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.loginThrottle = &throttle
+	}
+}
+
+// DefaultMFAIssuer is the service name used in authenticator apps unless
+// WithMFAIssuer overrides it.
+const DefaultMFAIssuer = "Assignment 3"
+
+// WithMFAIssuer sets the service name authenticator apps show next to the
+// account. It defaults to DefaultMFAIssuer.
+func WithMFAIssuer(issuer string) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.mfaIssuer = issuer
+	}
+}
+
+// WithOIDC enables single sign-on through an OpenID Connect provider. Users
+// signing in for the first time get an account with the role roles maps
+// their claims to, or the default role. With linkByEmail they are signed in
+// to an existing account instead if it is the only one with the address the
+// provider verified. Accounts with a password are never linked this way:
+// their addresses are set without verification, so anyone could register
+// with a colleague's address and take over that colleague's first sign-in.
+func WithOIDC(provider *auth.OIDCProvider, roles auth.ClaimRoleMapping, linkByEmail bool) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.oidc = &oidcConfig{provider: provider, roles: roles, linkByEmail: linkByEmail}
+	}
+}
+
+// WithAuthenticator replaces the store as the checker of passwords at
+// /api/login, e.g. with a directory chained to the store's local accounts.
+func WithAuthenticator(authenticator auth.Authenticator) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.authenticator = authenticator
+	}
+}
+
+// WithCookieSessions makes sign-ins set the session's tokens as HttpOnly
+// cookies instead of returning them, for browser clients. Requests are then
+// authenticated by the cookie when they carry no Authorization header, and
+// state-changing ones must repeat the CSRF cookie in the X-CSRF-Token header.
+// Bearer tokens keep working for other clients.
+func WithCookieSessions(cookies auth.CookieConfig) RouterOption {
+	return func(cfg *routerConfig) {
+		cfg.cookies = &cookies
+	}
+}
+
+// DefaultTransferTTL is how long an item transfer waits for an answer unless
+// WithItemTransfers overrides it.
+const DefaultTransferTTL = 72 * time.Hour
+
+// WithItemTransfers sets how long a proposed item transfer can be accepted
+// before it expires. It defaults to DefaultTransferTTL, which also replaces a
+// ttl that is not positive.
+func WithItemTransfers(ttl time.Duration) RouterOption {
+	return func(cfg *routerConfig) {
+		if ttl > 0 {
+			cfg.transferTTL = ttl
+		}
+	}
+}
+
 // SetupRouter configures the Gin router with all routes and middleware.
-func SetupRouter(store *store.Store, jwtService *auth.JWTService, allowedOrigins []string, allowAll bool) *gin.Engine {
+func SetupRouter(store store.Repository, jwtService *auth.JWTService, allowedOrigins []string, allowAll bool, opts ...RouterOption) *gin.Engine {
+	cfg := routerConfig{mfaIssuer: DefaultMFAIssuer, transferTTL: DefaultTransferTTL}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	router := gin.New()
 	router.Use(gin.Logger(), gin.Recovery())
 	_ = router.SetTrustedProxies(nil)
 
 	corsConfig := cors.Config{
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowHeaders:     []string{"Authorization", "Content-Type"},
-		ExposeHeaders:    []string{"Authorization"},
+		AllowHeaders:     []string{"Authorization", "Content-Type", auth.CSRFHeader},
+		ExposeHeaders:    []string{"Authorization", "Retry-After"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}
@@ -50,28 +157,144 @@ func SetupRouter(store *store.Store, jwtService *auth.JWTService, allowedOrigins
 	router.Use(cors.New(corsConfig))
 
 	handler := NewHandler(store, jwtService)
+	handler.resets = cfg.passwordReset
+	throttleConfig := auth.DefaultThrottleConfig()
+	if cfg.loginThrottle != nil {
+		throttleConfig = *cfg.loginThrottle
+	}
+	handler.throttle = auth.NewLoginThrottle(throttleConfig)
+	handler.mfaIssuer = cfg.mfaIssuer
+	handler.oidc = cfg.oidc
+	if cfg.authenticator != nil {
+		handler.authenticator = cfg.authenticator
+	}
+	handler.cookies = cfg.cookies
+	handler.transferTTL = cfg.transferTTL
+	middlewareOpts := []auth.MiddlewareOption{auth.WithDenylist(store), auth.WithSessions(store), auth.WithMFAPolicy(store), auth.WithAccessTokens(store)}
+	if cfg.cookies != nil {
+		middlewareOpts = append(middlewareOpts, auth.WithSessionCookies(*cfg.cookies))
+	}
+	if cfg.revalidateUsers {
+		handler.users = auth.NewCachedUserResolver(store, cfg.userCacheTTL)
+		middlewareOpts = append(middlewareOpts, auth.WithUserResolver(handler.users))
+	}
+	authMiddleware := auth.AuthMiddleware(jwtService, middlewareOpts...)
+	// Accounts that must set up MFA can reach only these routes, and accounts
+	// that must change their password only the password ones. A password
+	// change comes first when both are pending.
+	mfaPendingMiddleware := auth.AuthMiddleware(jwtService, append(middlewareOpts, auth.AllowPendingMFASetup())...)
+	passwordPendingMiddleware := auth.AuthMiddleware(jwtService, append(middlewareOpts, auth.AllowPendingMFASetup(), auth.AllowPendingPasswordChange())...)
+	// Personal access tokens work everywhere except on routes that manage the
+	// account and its credentials.
+	sessionOnly := auth.RequireSession()
+
+	router.GET("/.well-known/jwks.json", handler.JWKS)
 
 	apiGroup := router.Group("/api")
 	{
 		apiGroup.GET("/health", handler.Health)
 		apiGroup.POST("/register", handler.Register)
 		apiGroup.POST("/login", handler.Login)
+		apiGroup.POST("/login/mfa", handler.LoginMFA)
+		apiGroup.POST("/token/refresh", handler.RefreshToken)
+		apiGroup.POST("/oauth/token", handler.ClientCredentialsToken)
+		apiGroup.POST("/logout", passwordPendingMiddleware, sessionOnly, handler.Logout)
+		apiGroup.POST("/me/password", passwordPendingMiddleware, sessionOnly, handler.ChangePassword)
+		apiGroup.PUT("/me/email", authMiddleware, sessionOnly, handler.SetEmail)
+		apiGroup.PUT("/me/username", authMiddleware, sessionOnly, handler.ChangeUsername)
+		apiGroup.GET("/me/mfa", mfaPendingMiddleware, sessionOnly, handler.MFAStatus)
+		apiGroup.POST("/me/mfa/enroll", mfaPendingMiddleware, sessionOnly, handler.EnrollMFA)
+		apiGroup.POST("/me/mfa/confirm", mfaPendingMiddleware, sessionOnly, handler.ConfirmMFA)
+		apiGroup.POST("/me/mfa/recovery-codes", authMiddleware, sessionOnly, handler.RegenerateRecoveryCodes)
+		apiGroup.POST("/me/mfa/disable", authMiddleware, sessionOnly, handler.DisableMFA)
+		apiGroup.GET("/me/tokens", authMiddleware, sessionOnly, handler.ListAccessTokens)
+		apiGroup.POST("/me/tokens", authMiddleware, sessionOnly, handler.CreateAccessToken)
+		apiGroup.DELETE("/me/tokens/:id", authMiddleware, sessionOnly, handler.DeleteAccessToken)
+		apiGroup.GET("/me/sessions", authMiddleware, sessionOnly, handler.ListSessions)
+		apiGroup.DELETE("/me/sessions/:id", authMiddleware, sessionOnly, handler.RevokeSession)
+		if handler.resets != nil {
+			apiGroup.POST("/password/forgot", handler.ForgotPassword)
+			apiGroup.POST("/password/reset", handler.CompletePasswordReset)
+		}
+		if handler.oidc != nil {
+			apiGroup.GET("/oidc", handler.OIDCInfo)
+			apiGroup.POST("/oidc/login", handler.StartOIDCLogin)
+			apiGroup.POST("/oidc/callback", handler.OIDCCallback)
+			apiGroup.GET("/me/identities", authMiddleware, sessionOnly, handler.ListIdentities)
+			apiGroup.POST("/me/identities", authMiddleware, sessionOnly, handler.StartIdentityLink)
+			apiGroup.POST("/me/identities/callback", authMiddleware, sessionOnly, handler.CompleteIdentityLink)
+			apiGroup.DELETE("/me/identities/:id", authMiddleware, sessionOnly, handler.UnlinkIdentity)
+		}
 
 		items := apiGroup.Group("/items")
-		items.Use(auth.AuthMiddleware(jwtService))
+		items.Use(authMiddleware)
 		{
-			items.GET("", handler.ListItems)
-			items.POST("", handler.CreateItem)
-			items.GET("/:id", handler.GetItem)
+			items.GET("", auth.RequirePermission(store, rbac.ItemsRead), handler.ListItems)
+			items.POST("", auth.RequirePermission(store, rbac.ItemsCreate), handler.CreateItem)
+			items.GET("/:id", auth.RequirePermission(store, rbac.ItemsRead), handler.GetItem)
+			// Ownership is checked by the store against the same policy.
 			items.PUT("/:id", handler.UpdateItem)
-			items.DELETE("/:id", auth.RequireRoles("admin"), handler.DeleteItem)
+			items.DELETE("/:id", auth.RequirePermission(store, rbac.ItemsDelete), handler.DeleteItem)
+			items.POST("/:id/transfer", handler.TransferItem)
+			// Like updates, sharing is checked by the store.
+			items.PUT("/:id/visibility", handler.SetItemVisibility)
+			items.GET("/:id/grants", handler.ListItemGrants)
+			items.PUT("/:id/grants", handler.GrantItemAccess)
+			items.DELETE("/:id/grants/:type/:subject", handler.RevokeItemAccess)
+			items.GET("/:id/access", auth.RequirePermission(store, rbac.UsersManage), handler.ItemAccess)
+		}
+
+		transfers := apiGroup.Group("/transfers")
+		transfers.Use(authMiddleware, auth.RequirePermission(store, rbac.ItemsRead))
+		{
+			transfers.GET("", handler.ListTransfers)
+			transfers.POST("/:id/accept", handler.AcceptTransfer)
+			transfers.POST("/:id/decline", handler.DeclineTransfer)
+			transfers.DELETE("/:id", handler.CancelTransfer)
 		}
 
 		users := apiGroup.Group("/users")
-		users.Use(auth.AuthMiddleware(jwtService), auth.RequireRoles("admin"))
+		users.Use(authMiddleware, auth.RequirePermission(store, rbac.UsersManage))
 		{
 			users.GET("", handler.ListUsers)
 			users.DELETE("/:id", handler.DeleteUser)
+			users.GET("/:id/sessions", handler.ListUserSessions)
+			users.DELETE("/:id/sessions", handler.RevokeUserSessions)
+			users.DELETE("/:id/sessions/:sessionId", handler.RevokeUserSession)
+			users.PUT("/:id/role", handler.SetUserRole)
+			users.PUT("/:id/status", handler.SetUserStatus)
+			users.PUT("/:id/username", handler.SetUsername)
+			users.POST("/:id/password", handler.ResetPassword)
+			users.DELETE("/:id/mfa", handler.ResetUserMFA)
+		}
+
+		serviceAccounts := apiGroup.Group("/service-accounts")
+		serviceAccounts.Use(authMiddleware, auth.RequirePermission(store, rbac.UsersManage))
+		{
+			serviceAccounts.GET("", handler.ListServiceAccounts)
+			serviceAccounts.POST("", handler.CreateServiceAccount)
+			serviceAccounts.POST("/:id/secret", handler.RotateClientSecret)
+		}
+
+		apiGroup.GET("/audit", authMiddleware, auth.RequirePermission(store, rbac.UsersManage), handler.ListAuditEvents)
+
+		lockouts := apiGroup.Group("/lockouts")
+		lockouts.Use(authMiddleware, auth.RequirePermission(store, rbac.UsersManage))
+		{
+			lockouts.GET("", handler.ListLockouts)
+			// Usernames may contain slashes, hence the catch-all.
+			lockouts.DELETE("/:kind/*key", handler.ClearLockout)
+		}
+
+		roles := apiGroup.Group("/roles")
+		roles.Use(authMiddleware, auth.RequirePermission(store, rbac.UsersManage))
+		{
+			roles.GET("", handler.ListRoles)
+			roles.POST("", handler.CreateRole)
+			roles.GET("/:name", handler.GetRole)
+			roles.PUT("/:name", handler.UpdateRole)
+			roles.PUT("/:name/mfa", handler.SetRoleMFA)
+			roles.DELETE("/:name", handler.DeleteRole)
 		}
 	}
 
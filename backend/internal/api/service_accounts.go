@@ -0,0 +1,194 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type serviceAccountRequest struct {
+	Name   string   `json:"name" binding:"required"`
+	Role   string   `json:"role" binding:"required"`
+	Scopes []string `json:"scopes" binding:"required"`
+}
+
+// clientSecretResponse carries a service account's secret, which is shown
+// only when it is created or rotated.
+type clientSecretResponse struct {
+	userResponse
+	ClientSecret string `json:"client_secret"`
+}
+
+// ListServiceAccounts returns every service account.
+func (h *Handler) ListServiceAccounts(c *gin.Context) {
+	users, err := h.store.ListUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list service accounts"})
+		return
+	}
+
+	accounts := make([]userResponse, 0)
+	for _, user := range users {
+		if user.ServiceAccount {
+			accounts = append(accounts, newUserResponse(user))
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"service_accounts": accounts})
+}
+
+// CreateServiceAccount adds a service account with an explicit role and
+// scopes, and returns its client credentials.
+func (h *Handler) CreateServiceAccount(c *gin.Context) {
+	var req serviceAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	actor, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	clientID, secret, err := auth.GenerateClientCredentials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate credentials"})
+		return
+	}
+	user, err := h.store.CreateServiceAccount(actor.ID, req.Name, req.Role, req.Scopes, clientID, auth.HashToken(secret))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUserExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "username already taken"})
+		case errors.Is(err, store.ErrInvalidRole):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown role"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	c.JSON(http.StatusCreated, clientSecretResponse{userResponse: newUserResponse(user), ClientSecret: secret})
+}
+
+// RotateClientSecret issues a new secret for a service account. The old one
+// stops working at once, and tokens issued with it are revoked.
+func (h *Handler) RotateClientSecret(c *gin.Context) {
+	actor, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	_, secret, err := auth.GenerateClientCredentials()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate credentials"})
+		return
+	}
+	user, err := h.store.RotateClientSecret(actor.ID, c.Param("id"), auth.HashToken(secret))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUserNotFound), errors.Is(err, store.ErrNotServiceAccount):
+			c.JSON(http.StatusNotFound, gin.H{"error": "service account not found"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to rotate secret"})
+		}
+		return
+	}
+	if err := h.revokeUserSessions(user.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "secret rotated but failed to revoke existing tokens"})
+		return
+	}
+
+	c.JSON(http.StatusOK, clientSecretResponse{userResponse: newUserResponse(user), ClientSecret: secret})
+}
+
+// oauthError answers a token request with an OAuth 2.0 error (RFC 6749
+// section 5.2) rather than the API's usual error body.
+func oauthError(c *gin.Context, status int, code, description string) {
+	c.JSON(status, gin.H{"error": code, "error_description": description})
+}
+
+// ClientCredentialsToken implements the OAuth 2.0 client credentials grant
+// (RFC 6749 section 4.4) for service accounts. Clients authenticate with HTTP
+// Basic or client_id and client_secret form fields, and may narrow the token
+// with a space separated scope. No refresh token is issued.
+func (h *Handler) ClientCredentialsToken(c *gin.Context) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Pragma", "no-cache")
+
+	switch c.PostForm("grant_type") {
+	case "client_credentials":
+	case "":
+		oauthError(c, http.StatusBadRequest, "invalid_request", "grant_type is required")
+		return
+	default:
+		oauthError(c, http.StatusBadRequest, "unsupported_grant_type", "only client_credentials is supported")
+		return
+	}
+
+	clientID, secret, basic := c.Request.BasicAuth()
+	if !basic {
+		clientID, secret = c.PostForm("client_id"), c.PostForm("client_secret")
+	}
+	if clientID == "" || secret == "" {
+		oauthError(c, http.StatusUnauthorized, "invalid_client", "client credentials are required")
+		return
+	}
+	attempt, ok := h.checkThrottle(c, clientID)
+	if !ok {
+		return
+	}
+	defer attempt.Release()
+
+	user, err := h.store.AuthenticateClient(clientID, auth.HashToken(secret))
+	if err != nil {
+		if errors.Is(err, store.ErrInvalidCredentials) {
+			attempt.Failure()
+			if basic {
+				c.Header("WWW-Authenticate", `Basic realm="token"`)
+			}
+			oauthError(c, http.StatusUnauthorized, "invalid_client", "invalid client credentials")
+			return
+		}
+		if errors.Is(err, store.ErrAccountSuspended) || errors.Is(err, store.ErrAccountInactive) {
+			oauthError(c, http.StatusUnauthorized, "invalid_client", "client account is disabled")
+			return
+		}
+		oauthError(c, http.StatusInternalServerError, "server_error", "authentication failed")
+		return
+	}
+	attempt.Success()
+
+	if requested := strings.Fields(c.PostForm("scope")); len(requested) > 0 {
+		granted := make(map[string]struct{}, len(user.Scopes))
+		for _, scope := range user.Scopes {
+			granted[scope] = struct{}{}
+		}
+		for _, scope := range requested {
+			if _, ok := granted[scope]; !ok {
+				oauthError(c, http.StatusBadRequest, "invalid_scope", "scope "+scope+" is not granted to this client")
+				return
+			}
+		}
+		user.Scopes = requested
+	}
+
+	token, err := h.jwt.GenerateToken(user)
+	if err != nil {
+		oauthError(c, http.StatusInternalServerError, "server_error", "failed to issue token")
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"access_token": token,
+		"token_type":   "Bearer",
+		"expires_in":   int(h.jwt.Expiry().Seconds()),
+		"scope":        strings.Join(user.Scopes, " "),
+	})
+}
@@ -0,0 +1,89 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sessionResponse marks the session that made the request, so clients can
+// tell "this device" apart from the others.
+type sessionResponse struct {
+	models.Session
+	Current bool `json:"current"`
+}
+
+// ListSessions returns the devices the caller is signed in on.
+func (h *Handler) ListSessions(c *gin.Context) {
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	h.respondWithSessions(c, current.ID, current.SessionID)
+}
+
+// RevokeSession signs the caller out on one of their devices. Access tokens
+// issued to it stop working with the next request.
+func (h *Handler) RevokeSession(c *gin.Context) {
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	h.revokeSession(c, current.ID, c.Param("id"))
+}
+
+// ListUserSessions returns the devices a user is signed in on; route-level
+// middleware ensures the caller is admin.
+func (h *Handler) ListUserSessions(c *gin.Context) {
+	userID := c.Param("id")
+	if _, err := h.store.GetUser(userID); err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+
+	current, _ := auth.GetContextUser(c)
+	h.respondWithSessions(c, userID, current.SessionID)
+}
+
+// RevokeUserSession signs a user out on one device; route-level middleware
+// ensures the caller is admin.
+func (h *Handler) RevokeUserSession(c *gin.Context) {
+	h.revokeSession(c, c.Param("id"), c.Param("sessionId"))
+}
+
+func (h *Handler) respondWithSessions(c *gin.Context, userID, currentID string) {
+	sessions, err := h.store.ListSessions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list sessions"})
+		return
+	}
+	response := make([]sessionResponse, len(sessions))
+	for i, session := range sessions {
+		response[i] = sessionResponse{Session: session, Current: session.ID == currentID}
+	}
+	c.JSON(http.StatusOK, gin.H{"sessions": response})
+}
+
+func (h *Handler) revokeSession(c *gin.Context, userID, id string) {
+	if err := h.store.RevokeSession(userID, id); err != nil {
+		if errors.Is(err, store.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "session not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke session"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
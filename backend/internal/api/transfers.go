@@ -0,0 +1,169 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type transferRequest struct {
+	// To is the username of the new owner.
+	To string `json:"to" binding:"required"`
+	// Force hands the item over without waiting for an answer; it needs
+	// items:update:any.
+	Force bool `json:"force"`
+}
+
+// TransferItem offers an item to another user, who becomes its owner once
+// they accept. The store checks that the caller may edit the item.
+func (h *Handler) TransferItem(c *gin.Context) {
+	var req transferRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	user, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if req.Force {
+		if !user.HasScope(rbac.ItemsUpdateAny) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "access token lacks the scope to force transfers"})
+			return
+		}
+	} else if !h.updateScopeAllows(c, user) {
+		return
+	}
+
+	recipient, err := h.store.GetUserByUsername(strings.TrimSpace(req.To))
+	if err != nil {
+		if errors.Is(err, store.ErrUserNotFound) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "recipient not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to load recipient"})
+		return
+	}
+
+	transfer, err := h.store.ProposeTransfer(c.Param("id"), user.ID, user.Role, recipient.ID, req.Force, time.Now().Add(h.transferTTL))
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrItemNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "item not found"})
+		case errors.Is(err, store.ErrForbidden):
+			c.JSON(http.StatusForbidden, gin.H{"error": "you do not have permission to transfer this item"})
+		case errors.Is(err, store.ErrInvalidTransferTarget):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "recipient already owns this item"})
+		case errors.Is(err, store.ErrTransferPending):
+			c.JSON(http.StatusConflict, gin.H{"error": "a transfer of this item is already pending"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to transfer item"})
+		}
+		return
+	}
+
+	status := http.StatusCreated
+	if transfer.Status == models.TransferAccepted {
+		status = http.StatusOK
+	}
+	c.JSON(status, transfer)
+}
+
+// ListTransfers returns the pending transfers the caller sent or was offered.
+// With ?all=true users who may edit any item see everyone's.
+func (h *Handler) ListTransfers(c *gin.Context) {
+	user, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+
+	userID := user.ID
+	if c.Query("all") == "true" {
+		if !h.store.Policy().Can(user.Role, rbac.ItemsUpdateAny) || !user.HasScope(rbac.ItemsUpdateAny) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
+		userID = ""
+	}
+
+	transfers, err := h.store.ListTransfers(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to list transfers"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"transfers": transfers})
+}
+
+// AcceptTransfer makes the caller the owner of an item offered to them.
+func (h *Handler) AcceptTransfer(c *gin.Context) {
+	h.respondToTransfer(c, true)
+}
+
+// DeclineTransfer turns down an item offered to the caller.
+func (h *Handler) DeclineTransfer(c *gin.Context) {
+	h.respondToTransfer(c, false)
+}
+
+func (h *Handler) respondToTransfer(c *gin.Context, accept bool) {
+	user, ok := transferUser(c)
+	if !ok {
+		return
+	}
+
+	transfer, err := h.store.RespondToTransfer(c.Param("id"), user.ID, accept)
+	if err != nil {
+		respondTransferError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, transfer)
+}
+
+// CancelTransfer withdraws a pending transfer. Its sender can cancel it, and
+// so can users who may edit any item.
+func (h *Handler) CancelTransfer(c *gin.Context) {
+	user, ok := transferUser(c)
+	if !ok {
+		return
+	}
+
+	transfer, err := h.store.CancelTransfer(c.Param("id"), user.ID, user.Role)
+	if err != nil {
+		respondTransferError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, transfer)
+}
+
+// transferUser returns the caller if its credential may change the owner of
+// items, answering otherwise.
+func transferUser(c *gin.Context) (auth.ContextUser, bool) {
+	user, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return auth.ContextUser{}, false
+	}
+	if !user.HasScope(rbac.ItemsUpdateOwn) && !user.HasScope(rbac.ItemsUpdateAny) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "access token lacks the scope to transfer items"})
+		return auth.ContextUser{}, false
+	}
+	return user, true
+}
+
+func respondTransferError(c *gin.Context, err error) {
+	if errors.Is(err, store.ErrTransferNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "transfer not found"})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update transfer"})
+}
@@ -0,0 +1,56 @@
+package api_test
+
+import (
+	"net/http"
+	"testing"
+
+	"assignment3/backend/internal/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestTransferRoutes(t *testing.T) {
+	s := newTestServer(t)
+	_, aliceToken := s.signIn("alice", "user")
+	bob, bobToken := s.signIn("bob", "user")
+	_, carolToken := s.signIn("carol", "user")
+
+	var item, hidden models.Item
+	s.expect(http.StatusCreated, http.MethodPost, "/api/items", aliceToken, gin.H{"title": "Laptop"}, &item)
+	s.expect(http.StatusCreated, http.MethodPost, "/api/items", aliceToken, gin.H{"title": "Diary", "visibility": "private"}, &hidden)
+
+	// Items hidden from the caller cannot be told apart from missing ones.
+	s.expect(http.StatusNotFound, http.MethodPost, "/api/items/"+hidden.ID+"/transfer", carolToken, gin.H{"to": "carol"}, nil)
+	s.expect(http.StatusForbidden, http.MethodPost, "/api/items/"+item.ID+"/transfer", carolToken, gin.H{"to": "carol"}, nil)
+	s.expect(http.StatusBadRequest, http.MethodPost, "/api/items/"+item.ID+"/transfer", aliceToken, gin.H{"to": "nobody"}, nil)
+	s.expect(http.StatusForbidden, http.MethodPost, "/api/items/"+item.ID+"/transfer", aliceToken, gin.H{"to": "bob", "force": true}, nil)
+
+	var declined models.ItemTransfer
+	s.expect(http.StatusCreated, http.MethodPost, "/api/items/"+item.ID+"/transfer", aliceToken, gin.H{"to": "bob"}, &declined)
+	s.expect(http.StatusConflict, http.MethodPost, "/api/items/"+item.ID+"/transfer", aliceToken, gin.H{"to": "carol"}, nil)
+	s.expect(http.StatusNotFound, http.MethodPost, "/api/transfers/"+declined.ID+"/accept", carolToken, nil, nil)
+	s.expect(http.StatusOK, http.MethodPost, "/api/transfers/"+declined.ID+"/decline", bobToken, nil, nil)
+	s.expect(http.StatusNotFound, http.MethodPost, "/api/transfers/"+declined.ID+"/accept", bobToken, nil, nil)
+
+	var offer models.ItemTransfer
+	s.expect(http.StatusCreated, http.MethodPost, "/api/items/"+item.ID+"/transfer", aliceToken, gin.H{"to": "bob"}, &offer)
+	var pending struct {
+		Transfers []models.ItemTransfer `json:"transfers"`
+	}
+	s.expect(http.StatusOK, http.MethodGet, "/api/transfers", bobToken, nil, &pending)
+	if len(pending.Transfers) != 1 || pending.Transfers[0].ID != offer.ID || pending.Transfers[0].From != "alice" {
+		t.Fatalf("unexpected pending transfers for bob: %+v", pending.Transfers)
+	}
+	s.expect(http.StatusForbidden, http.MethodGet, "/api/transfers?all=true", bobToken, nil, nil)
+
+	var accepted models.ItemTransfer
+	s.expect(http.StatusOK, http.MethodPost, "/api/transfers/"+offer.ID+"/accept", bobToken, nil, &accepted)
+	if accepted.Status != models.TransferAccepted {
+		t.Fatalf("expected an accepted transfer, got %+v", accepted)
+	}
+	var got models.Item
+	s.expect(http.StatusOK, http.MethodGet, "/api/items/"+item.ID, bobToken, nil, &got)
+	if got.OwnerID != bob.ID || got.Access != models.ItemOwner {
+		t.Fatalf("expected bob to own the item, got %+v", got)
+	}
+}
@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type userStatusRequest struct {
+	Status string `json:"status" binding:"required"`
+	Reason string `json:"reason"`
+	// Until is optional and ends a suspension automatically.
+	Until *time.Time `json:"until"`
+}
+
+// SetUserStatus suspends, deactivates or reactivates a user; route-level
+// middleware ensures the caller is admin. Accounts that may no longer sign in
+// are signed out everywhere.
+func (h *Handler) SetUserStatus(c *gin.Context) {
+	var req userStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+
+	actor, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	if actor.ID == c.Param("id") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cannot change the status of your own account"})
+		return
+	}
+
+	user, err := h.store.SetUserStatus(actor.ID, c.Param("id"), models.UserStatus(req.Status), req.Reason, req.Until)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		case errors.Is(err, store.ErrInvalidStatus):
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be active, suspended, pending or deactivated"})
+		case errors.Is(err, store.ErrLastAdmin):
+			c.JSON(http.StatusConflict, gin.H{"error": "cannot remove the last user who can manage users"})
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		}
+		return
+	}
+
+	if user.Status != models.UserStatusActive {
+		if err := h.revokeUserSessions(user.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "status updated but failed to revoke existing sessions"})
+			return
+		}
+	}
+	h.invalidateUser(user.ID)
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// checkAccountStatus reports whether user may sign in, answering 403 if its
+// status forbids it.
+func checkAccountStatus(c *gin.Context, user models.User) bool {
+	return !respondAccountStatus(c, store.CheckAccountStatus(user))
+}
+
+// respondAccountStatus answers the errors of store.CheckAccountStatus and
+// reports whether err was one of them.
+func respondAccountStatus(c *gin.Context, err error) bool {
+	switch {
+	case errors.Is(err, store.ErrAccountSuspended):
+		c.JSON(http.StatusForbidden, gin.H{"error": "account is suspended", "code": "account_suspended"})
+	case errors.Is(err, store.ErrAccountInactive):
+		c.JSON(http.StatusForbidden, gin.H{"error": "account is not active", "code": "account_inactive"})
+	default:
+		return false
+	}
+	return true
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type usernameRequest struct {
+	Username string `json:"username" binding:"required"`
+}
+
+// ChangeUsername renames the caller. Items are owned by user ID, so they stay
+// with the account and the old name can be registered again without gaining
+// access to them.
+func (h *Handler) ChangeUsername(c *gin.Context) {
+	current, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	h.renameUser(c, current.ID, current.ID)
+}
+
+// SetUsername renames a user; route-level middleware ensures the caller is
+// admin.
+func (h *Handler) SetUsername(c *gin.Context) {
+	actor, ok := auth.GetContextUser(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+		return
+	}
+	h.renameUser(c, actor.ID, c.Param("id"))
+}
+
+func (h *Handler) renameUser(c *gin.Context, actorID, userID string) {
+	var req usernameRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid request payload"})
+		return
+	}
+	req.Username = strings.TrimSpace(req.Username)
+	if len(req.Username) < 3 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "username must be at least 3 characters"})
+		return
+	}
+
+	user, err := h.store.ChangeUsername(actorID, userID, req.Username)
+	if err != nil {
+		switch {
+		case errors.Is(err, store.ErrUserNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		case errors.Is(err, store.ErrUserExists):
+			c.JSON(http.StatusConflict, gin.H{"error": "username already taken"})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to change username"})
+		}
+		return
+	}
+	h.invalidateUser(user.ID)
+
+	c.JSON(http.StatusOK, newUserResponse(user))
+}
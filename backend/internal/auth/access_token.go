@@ -0,0 +1,48 @@
+package auth
+
+import "strings"
+
+// AccessTokenPrefix starts every personal access token so the middleware can
+// tell them apart from JWTs, and secret scanners can recognise leaked ones.
+const AccessTokenPrefix = "pat_"
+
+// accessTokenDisplayLength is how many leading characters of a personal
+// access token are stored in the clear to identify it.
+const accessTokenDisplayLength = len(AccessTokenPrefix) + 8
+
+// GenerateAccessToken returns a new personal access token together with the
+// prefix under which it is listed.
+func GenerateAccessToken() (token, prefix string, err error) {
+	secret, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	token = AccessTokenPrefix + secret
+	return token, token[:accessTokenDisplayLength], nil
+}
+
+// IsAccessToken reports whether a bearer token is a personal access token.
+func IsAccessToken(token string) bool {
+	return strings.HasPrefix(token, AccessTokenPrefix)
+}
+
+// ClientIDPrefix and ClientSecretPrefix start the credentials of service
+// accounts.
+const (
+	ClientIDPrefix     = "svc_"
+	ClientSecretPrefix = "svcsecret_"
+)
+
+// GenerateClientCredentials returns a new client ID and secret for a service
+// account. Only the secret needs to be kept confidential.
+func GenerateClientCredentials() (clientID, secret string, err error) {
+	id, err := GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	secret, err = GenerateOpaqueToken()
+	if err != nil {
+		return "", "", err
+	}
+	return ClientIDPrefix + id[:16], ClientSecretPrefix + secret, nil
+}
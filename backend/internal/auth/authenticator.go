@@ -0,0 +1,68 @@
+package auth
+
+import (
+	"errors"
+	"slices"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/store"
+)
+
+// Authenticator checks a username and password. It reports
+// store.ErrInvalidCredentials when it rejects them, and any other error when
+// it could not decide. The store itself is the authenticator for local
+// accounts.
+type Authenticator interface {
+	Authenticate(username, password string) (models.User, error)
+}
+
+type chainAuthenticator []Authenticator
+
+// ChainAuthenticators tries each authenticator in turn and returns the first
+// user one accepts. An authenticator that fails for another reason than the
+// credentials, such as an unreachable directory, does not stop the chain; its
+// error is returned only if no later authenticator accepts them either.
+func ChainAuthenticators(authenticators ...Authenticator) Authenticator {
+	return chainAuthenticator(authenticators)
+}
+
+func (c chainAuthenticator) Authenticate(username, password string) (models.User, error) {
+	var failure error
+	for _, authenticator := range c {
+		user, err := authenticator.Authenticate(username, password)
+		if err == nil {
+			return user, nil
+		}
+		if !errors.Is(err, store.ErrInvalidCredentials) && failure == nil {
+			failure = err
+		}
+	}
+	if failure != nil {
+		return models.User{}, failure
+	}
+	return models.User{}, store.ErrInvalidCredentials
+}
+
+type roleRestrictedAuthenticator struct {
+	next  Authenticator
+	roles []string
+}
+
+// RestrictRoles lets only users holding one of roles sign in through next,
+// e.g. to keep local accounts for break-glass admins once a directory is the
+// primary source of users. Everyone else is rejected as if their password
+// were wrong.
+func RestrictRoles(next Authenticator, roles ...string) Authenticator {
+	return roleRestrictedAuthenticator{next: next, roles: roles}
+}
+
+func (a roleRestrictedAuthenticator) Authenticate(username, password string) (models.User, error) {
+	user, err := a.next.Authenticate(username, password)
+	if err != nil {
+		return models.User{}, err
+	}
+	if !slices.Contains(a.roles, user.Role) {
+		return models.User{}, store.ErrInvalidCredentials
+	}
+	return user, nil
+}
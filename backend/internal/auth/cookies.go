@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CSRFHeader carries the double-submit token on state-changing requests that
+// a session cookie authenticates.
+const CSRFHeader = "X-CSRF-Token"
+
+// CookieConfig describes the cookies of browser sessions. The access and
+// refresh tokens live in HttpOnly cookies that scripts cannot read. The CSRF
+// token is readable so the client can echo it in CSRFHeader, which a
+// cross-site request cannot do.
+type CookieConfig struct {
+	AccessName  string
+	RefreshName string
+	CSRFName    string
+	// Domain shares the cookies with subdomains; empty keeps them on the API
+	// host.
+	Domain   string
+	Path     string
+	Secure   bool
+	SameSite http.SameSite
+}
+
+// DefaultCookieConfig returns Secure, SameSite=Strict cookies for the API
+// host.
+func DefaultCookieConfig() CookieConfig {
+	return CookieConfig{
+		AccessName:  "access_token",
+		RefreshName: "refresh_token",
+		CSRFName:    "csrf_token",
+		Path:        "/",
+		Secure:      true,
+		SameSite:    http.SameSiteStrictMode,
+	}
+}
+
+// SetSession stores a session's tokens in cookies that expire with them.
+func (cfg CookieConfig) SetSession(c *gin.Context, accessToken string, accessExpiresAt time.Time, refreshToken string, refreshExpiresAt time.Time, csrfToken string) {
+	cfg.set(c, cfg.AccessName, accessToken, accessExpiresAt, true)
+	cfg.set(c, cfg.RefreshName, refreshToken, refreshExpiresAt, true)
+	cfg.set(c, cfg.CSRFName, csrfToken, refreshExpiresAt, false)
+}
+
+// ClearSession removes the session cookies.
+func (cfg CookieConfig) ClearSession(c *gin.Context) {
+	for _, name := range []string{cfg.AccessName, cfg.RefreshName, cfg.CSRFName} {
+		cfg.set(c, name, "", time.Unix(0, 0), name != cfg.CSRFName)
+	}
+}
+
+func (cfg CookieConfig) set(c *gin.Context, name, value string, expiresAt time.Time, httpOnly bool) {
+	maxAge := int(time.Until(expiresAt) / time.Second)
+	if maxAge <= 0 {
+		maxAge = -1
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     name,
+		Value:    value,
+		Path:     cfg.Path,
+		Domain:   cfg.Domain,
+		Expires:  expiresAt,
+		MaxAge:   maxAge,
+		Secure:   cfg.Secure,
+		HttpOnly: httpOnly,
+		SameSite: cfg.SameSite,
+	})
+}
+
+// CSRFToken returns the request's CSRF cookie, or "" without one.
+func (cfg CookieConfig) CSRFToken(c *gin.Context) string {
+	token, err := c.Cookie(cfg.CSRFName)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// CheckCSRF reports whether a request may act on its session cookie: safe
+// methods always may, others only if CSRFHeader repeats the CSRF cookie.
+func (cfg CookieConfig) CheckCSRF(c *gin.Context) bool {
+	switch c.Request.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	cookie, header := cfg.CSRFToken(c), c.GetHeader(CSRFHeader)
+	return cookie != "" && subtle.ConstantTimeCompare([]byte(cookie), []byte(header)) == 1
+}
+
+// AbortCSRF rejects a request that failed CheckCSRF.
+func AbortCSRF(c *gin.Context) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"error": "missing or invalid CSRF token",
+		"code":  "csrf_token_invalid",
+	})
+}
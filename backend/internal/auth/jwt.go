@@ -1,77 +1,220 @@
 package auth
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"assignment3/backend/internal/models"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
-// Claims wraps the user identity information included in JWT tokens.
+// Claims wraps the user identity information included in JWT tokens. The
+// embedded RegisteredClaims.ID carries the token's unique jti.
 type Claims struct {
 	UserID   string `json:"user_id"`
 	Username string `json:"username"`
 	Role     string `json:"role"`
+	// MustChangePassword limits the token to changing the password.
+	MustChangePassword bool `json:"must_change_password,omitempty"`
+	// MFAEnabled records whether the user had two-factor authentication set
+	// up when the token was issued.
+	MFAEnabled bool `json:"mfa_enabled,omitempty"`
+	// Purpose marks tokens that are not access tokens, such as MFA
+	// challenges, so that they are never accepted as one.
+	Purpose string `json:"purpose,omitempty"`
+	// ServiceAccount marks tokens issued to a service account. Their Scope
+	// lists the permissions they may use, space separated as in OAuth 2.0.
+	ServiceAccount bool   `json:"service_account,omitempty"`
+	Scope          string `json:"scope,omitempty"`
+	// SessionID names the sign-in session the token was issued to, so that
+	// signing the session out also rejects its outstanding access tokens.
+	SessionID string `json:"sid,omitempty"`
+	// IssuedAtNanos repeats IssuedAt in nanoseconds since the epoch. iat has
+	// second precision, too coarse to order a token against a revocation of
+	// all the user's tokens made in the same second.
+	IssuedAtNanos int64 `json:"iat_ns,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// purposeMFAChallenge marks the tokens issued by GenerateMFAChallenge.
+const purposeMFAChallenge = "mfa_challenge"
+
+// MFAChallengeExpiry bounds how long a user has to enter their second factor
+// after the password was accepted.
+const MFAChallengeExpiry = 5 * time.Minute
+
+// ErrWrongTokenPurpose is returned when a token issued for one purpose is
+// presented for another.
+var ErrWrongTokenPurpose = errors.New("token was issued for a different purpose")
+
 // JWTService manages token generation and verification.
 type JWTService struct {
-	secret []byte
-	issuer string
-	expiry time.Duration
+	keys          *KeySet
+	issuer        string
+	expiry        time.Duration
+	refreshExpiry time.Duration
 }
 
-// NewJWTService constructs a JWT service instance.
-func NewJWTService(secret, issuer string, expiry time.Duration) *JWTService {
+// NewJWTService constructs a JWT service signing with a shared HS256 secret.
+// expiry bounds access tokens; refreshExpiry bounds the opaque refresh tokens
+// issued alongside them.
+func NewJWTService(secret, issuer string, expiry, refreshExpiry time.Duration) *JWTService {
+	return NewJWTServiceWithKeys(NewHMACKeySet([]byte(secret)), issuer, expiry, refreshExpiry)
+}
+
+// NewJWTServiceWithKeys constructs a JWT service that signs with the active key
+// of keys and verifies against any key in the set.
+func NewJWTServiceWithKeys(keys *KeySet, issuer string, expiry, refreshExpiry time.Duration) *JWTService {
 	return &JWTService{
-		secret: []byte(secret),
-		issuer: issuer,
-		expiry: expiry,
+		keys:          keys,
+		issuer:        issuer,
+		expiry:        expiry,
+		refreshExpiry: refreshExpiry,
 	}
 }
 
-// GenerateToken creates a signed JWT for the provided user.
+// Keys returns the key set used to sign and verify tokens.
+func (j *JWTService) Keys() *KeySet {
+	return j.keys
+}
+
+// Expiry returns the lifetime of access tokens.
+func (j *JWTService) Expiry() time.Duration {
+	return j.expiry
+}
+
+// RefreshExpiry returns the lifetime of refresh tokens.
+func (j *JWTService) RefreshExpiry() time.Duration {
+	return j.refreshExpiry
+}
+
+// GenerateToken creates a signed JWT for the provided user. Tokens of service
+// accounts are limited to the user's Scopes.
 func (j *JWTService) GenerateToken(user models.User) (string, error) {
+	return j.GenerateSessionToken(user, "")
+}
+
+// GenerateSessionToken creates a signed JWT like GenerateToken that belongs to
+// the sign-in session sessionID.
+func (j *JWTService) GenerateSessionToken(user models.User, sessionID string) (string, error) {
 	now := time.Now().UTC()
-	claims := Claims{
+	var scope string
+	if user.ServiceAccount {
+		scope = strings.Join(user.Scopes, " ")
+	}
+	return j.sign(Claims{
+		UserID:             user.ID,
+		Username:           user.Username,
+		Role:               user.Role,
+		MustChangePassword: user.MustChangePassword,
+		MFAEnabled:         user.MFAEnabled,
+		ServiceAccount:     user.ServiceAccount,
+		Scope:              scope,
+		SessionID:          sessionID,
+		IssuedAtNanos:      now.UnixNano(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    j.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(j.expiry)),
+			Subject:   user.ID,
+		},
+	})
+}
+
+// GenerateMFAChallenge creates a short-lived token proving that user passed
+// the password check. It is exchanged together with a second factor for an
+// access token and is rejected everywhere else.
+func (j *JWTService) GenerateMFAChallenge(user models.User) (string, time.Time, error) {
+	now := time.Now().UTC()
+	expiresAt := now.Add(MFAChallengeExpiry)
+	token, err := j.sign(Claims{
 		UserID:   user.ID,
 		Username: user.Username,
-		Role:     user.Role,
+		Purpose:  purposeMFAChallenge,
 		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
 			Issuer:    j.issuer,
 			IssuedAt:  jwt.NewNumericDate(now),
-			ExpiresAt: jwt.NewNumericDate(now.Add(j.expiry)),
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
 			Subject:   user.ID,
 		},
+	})
+	if err != nil {
+		return "", time.Time{}, err
 	}
+	return token, expiresAt, nil
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	signed, err := token.SignedString(j.secret)
+func (j *JWTService) sign(claims jwt.Claims) (string, error) {
+	key := j.keys.Active()
+	token := jwt.NewWithClaims(key.Method, claims)
+	if key.ID != "" {
+		token.Header["kid"] = key.ID
+	}
+	signed, err := token.SignedString(key.signKey)
 	if err != nil {
 		return "", fmt.Errorf("failed to sign token: %w", err)
 	}
 	return signed, nil
 }
 
-// ParseToken validates and parses a JWT string.
+// ParseToken validates and parses an access token. The kid header selects the
+// verification key, and the token's algorithm must match that key's.
 func (j *JWTService) ParseToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return j.secret, nil
-	})
+	claims, err := j.parse(tokenString)
 	if err != nil {
-		return nil, fmt.Errorf("failed to parse token: %w", err)
+		return nil, err
+	}
+	if claims.Purpose != "" {
+		return nil, ErrWrongTokenPurpose
 	}
+	return claims, nil
+}
 
-	claims, ok := token.Claims.(*Claims)
-	if !ok || !token.Valid {
-		return nil, fmt.Errorf("invalid token claims")
+// ParseMFAChallenge validates a token issued by GenerateMFAChallenge.
+func (j *JWTService) ParseMFAChallenge(tokenString string) (*Claims, error) {
+	claims, err := j.parse(tokenString)
+	if err != nil {
+		return nil, err
+	}
+	if claims.Purpose != purposeMFAChallenge {
+		return nil, ErrWrongTokenPurpose
 	}
+	return claims, nil
+}
 
+func (j *JWTService) parse(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := j.parseInto(tokenString, claims); err != nil {
+		return nil, err
+	}
 	return claims, nil
 }
+
+// parseInto verifies tokenString against the key set and decodes its claims
+// into claims.
+func (j *JWTService) parseInto(tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := j.keys.Lookup(kid)
+		if !ok {
+			return nil, fmt.Errorf("unknown signing key: %q", kid)
+		}
+		if token.Method.Alg() != key.Method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.verifyKey, nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to parse token: %w", err)
+	}
+	if !token.Valid {
+		return fmt.Errorf("invalid token claims")
+	}
+	return nil
+}
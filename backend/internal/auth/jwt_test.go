@@ -1,6 +1,7 @@
 package auth_test
 
 import (
+	"errors"
 	"testing"
 	"time"
 
@@ -9,7 +10,7 @@ import (
 )
 
 func TestJWTServiceRoundTrip(t *testing.T) {
-	service := auth.NewJWTService("secret", "issuer", time.Minute)
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
 
 	user := models.User{
 		ID:       "user-1",
@@ -30,4 +31,54 @@ func TestJWTServiceRoundTrip(t *testing.T) {
 	if claims.Username != user.Username || claims.UserID != user.ID || claims.Role != user.Role {
 		t.Fatalf("unexpected claims: %+v", claims)
 	}
+	if claims.ID == "" {
+		t.Fatalf("expected token to carry a jti")
+	}
+
+	other, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	otherClaims, err := service.ParseToken(other)
+	if err != nil {
+		t.Fatalf("ParseToken returned error: %v", err)
+	}
+	if otherClaims.ID == claims.ID {
+		t.Fatalf("expected every token to get a unique jti")
+	}
+}
+
+func TestMFAChallengeIsNotAnAccessToken(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	user := models.User{ID: "user-1", Username: "alice", Role: "admin", MFAEnabled: true}
+
+	challenge, expiresAt, err := service.GenerateMFAChallenge(user)
+	if err != nil {
+		t.Fatalf("GenerateMFAChallenge returned error: %v", err)
+	}
+	if until := time.Until(expiresAt); until <= 0 || until > auth.MFAChallengeExpiry {
+		t.Fatalf("unexpected challenge expiry %v", expiresAt)
+	}
+	claims, err := service.ParseMFAChallenge(challenge)
+	if err != nil {
+		t.Fatalf("ParseMFAChallenge returned error: %v", err)
+	}
+	if claims.UserID != user.ID || claims.Username != user.Username || claims.Role != "" {
+		t.Fatalf("unexpected challenge claims: %+v", claims)
+	}
+	if _, err := service.ParseToken(challenge); !errors.Is(err, auth.ErrWrongTokenPurpose) {
+		t.Fatalf("expected the challenge to be refused as an access token, got %v", err)
+	}
+
+	access, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	if _, err := service.ParseMFAChallenge(access); !errors.Is(err, auth.ErrWrongTokenPurpose) {
+		t.Fatalf("expected an access token to be refused as a challenge, got %v", err)
+	}
+	accessClaims, err := service.ParseToken(access)
+	if err != nil || !accessClaims.MFAEnabled {
+		t.Fatalf("expected the access token to record mfa, got %+v, %v", accessClaims, err)
+	}
 }
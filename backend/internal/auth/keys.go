@@ -0,0 +1,250 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// SigningKey is a named key used to sign and/or verify tokens. Keys loaded from
+// a public PEM block are verification-only: they keep tokens signed by a
+// retired key valid without retaining its private half.
+type SigningKey struct {
+	ID     string
+	Method jwt.SigningMethod
+
+	signKey   any
+	verifyKey any
+}
+
+// CanSign reports whether the key holds private material.
+func (k SigningKey) CanSign() bool {
+	return k.signKey != nil
+}
+
+// KeySource describes where a KeySet loads its keys from. Keys in Dir and
+// Files are PEM encoded and identified by their file name without extension.
+type KeySource struct {
+	// Dir is scanned for *.pem files on every (re)load.
+	Dir string
+	// Files lists additional PEM files.
+	Files []string
+	// ActiveKeyID selects the signing key. When empty the signable key whose ID
+	// sorts last is used, so date-stamped names rotate naturally.
+	ActiveKeyID string
+	// LegacySecret, if set, keeps HS256 tokens without a kid header verifiable
+	// while clients migrate to the asymmetric keys.
+	LegacySecret []byte
+}
+
+// KeySet holds the active signing key plus retired keys that are still
+// accepted for verification. It is safe for concurrent use and can be
+// reloaded in place to rotate keys without a restart.
+type KeySet struct {
+	mu     sync.RWMutex
+	source *KeySource
+	active string
+	keys   map[string]SigningKey
+}
+
+// NewHMACKeySet returns a key set with a single HS256 secret. Tokens signed
+// with it carry no kid header.
+func NewHMACKeySet(secret []byte) *KeySet {
+	return &KeySet{
+		keys: map[string]SigningKey{
+			"": {Method: jwt.SigningMethodHS256, signKey: secret, verifyKey: secret},
+		},
+	}
+}
+
+// OpenKeySet loads the keys described by source.
+func OpenKeySet(source KeySource) (*KeySet, error) {
+	ks := &KeySet{source: &source}
+	if err := ks.Reload(); err != nil {
+		return nil, err
+	}
+	return ks, nil
+}
+
+// Reload re-reads the key source. On failure the previous keys stay in use.
+func (ks *KeySet) Reload() error {
+	if ks.source == nil {
+		return nil
+	}
+	keys, active, err := loadKeys(*ks.source)
+	if err != nil {
+		return err
+	}
+
+	ks.mu.Lock()
+	ks.keys = keys
+	ks.active = active
+	ks.mu.Unlock()
+	return nil
+}
+
+// Active returns the key new tokens are signed with.
+func (ks *KeySet) Active() SigningKey {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	return ks.keys[ks.active]
+}
+
+// Lookup returns the key identified by kid.
+func (ks *KeySet) Lookup(kid string) (SigningKey, bool) {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+	key, ok := ks.keys[kid]
+	return key, ok
+}
+
+// JWK is the public JSON Web Key representation of a signing key.
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid"`
+	Use       string `json:"use"`
+	Algorithm string `json:"alg"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+}
+
+// JWKS is a JSON Web Key Set document.
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// JWKS publishes the public halves of all asymmetric keys, active and retired,
+// ordered by key ID. Shared HMAC secrets are never included.
+func (ks *KeySet) JWKS() JWKS {
+	ks.mu.RLock()
+	defer ks.mu.RUnlock()
+
+	set := JWKS{Keys: make([]JWK, 0, len(ks.keys))}
+	for _, key := range ks.keys {
+		switch pub := key.verifyKey.(type) {
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "RSA",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				N:         base64.RawURLEncoding.EncodeToString(pub.N.Bytes()),
+				E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+			})
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, JWK{
+				KeyType:   "OKP",
+				KeyID:     key.ID,
+				Use:       "sig",
+				Algorithm: key.Method.Alg(),
+				Curve:     "Ed25519",
+				X:         base64.RawURLEncoding.EncodeToString(pub),
+			})
+		}
+	}
+	sort.Slice(set.Keys, func(i, j int) bool {
+		return set.Keys[i].KeyID < set.Keys[j].KeyID
+	})
+	return set
+}
+
+func loadKeys(source KeySource) (map[string]SigningKey, string, error) {
+	paths := append([]string(nil), source.Files...)
+	if source.Dir != "" {
+		matches, err := filepath.Glob(filepath.Join(source.Dir, "*.pem"))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to list key directory: %w", err)
+		}
+		paths = append(paths, matches...)
+	}
+
+	keys := make(map[string]SigningKey, len(paths)+1)
+	for _, path := range paths {
+		key, err := loadPEMKey(path)
+		if err != nil {
+			return nil, "", err
+		}
+		if _, exists := keys[key.ID]; exists {
+			return nil, "", fmt.Errorf("duplicate key id %q", key.ID)
+		}
+		keys[key.ID] = key
+	}
+
+	active := source.ActiveKeyID
+	if active == "" {
+		for id, key := range keys {
+			if key.CanSign() && id > active {
+				active = id
+			}
+		}
+	}
+	if key, ok := keys[active]; !ok || !key.CanSign() {
+		if active == "" {
+			return nil, "", fmt.Errorf("no private signing key found")
+		}
+		return nil, "", fmt.Errorf("active key %q not found or has no private key", active)
+	}
+
+	if len(source.LegacySecret) > 0 {
+		keys[""] = SigningKey{Method: jwt.SigningMethodHS256, verifyKey: source.LegacySecret}
+	}
+	return keys, active, nil
+}
+
+// loadPEMKey parses an RSA or Ed25519 key in PKCS#8, PKCS#1 or PKIX form.
+func loadPEMKey(path string) (SigningKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("failed to read key %s: %w", path, err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return SigningKey{}, fmt.Errorf("key %s is not PEM encoded", path)
+	}
+
+	key := SigningKey{ID: strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))}
+
+	var parsed any
+	switch block.Type {
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PUBLIC KEY":
+		parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+	default:
+		return SigningKey{}, fmt.Errorf("key %s has unsupported PEM type %q", path, block.Type)
+	}
+	if err != nil {
+		return SigningKey{}, fmt.Errorf("failed to parse key %s: %w", path, err)
+	}
+
+	switch k := parsed.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, k, k.Public().(crypto.PublicKey)
+	case ed25519.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodEdDSA, k
+	default:
+		return SigningKey{}, fmt.Errorf("key %s has unsupported type %T", path, parsed)
+	}
+	return key, nil
+}
@@ -0,0 +1,206 @@
+package auth_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func writePEM(t *testing.T, path, blockType string, der []byte) {
+	t.Helper()
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+}
+
+func writeRSAKey(t *testing.T, path string) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate rsa key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatalf("failed to marshal rsa key: %v", err)
+	}
+	writePEM(t, path, "PRIVATE KEY", der)
+}
+
+func writeEd25519Key(t *testing.T, path string) ed25519.PublicKey {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate ed25519 key: %v", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("failed to marshal ed25519 key: %v", err)
+	}
+	writePEM(t, path, "PRIVATE KEY", der)
+	return pub
+}
+
+func tokenKeyID(t *testing.T, token string) (string, string) {
+	t.Helper()
+	parsed, _, err := jwt.NewParser().ParseUnverified(token, &auth.Claims{})
+	if err != nil {
+		t.Fatalf("failed to decode token: %v", err)
+	}
+	kid, _ := parsed.Header["kid"].(string)
+	return kid, parsed.Method.Alg()
+}
+
+func TestKeySetRotation(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, filepath.Join(dir, "2026-01.pem"))
+
+	keys, err := auth.OpenKeySet(auth.KeySource{Dir: dir})
+	if err != nil {
+		t.Fatalf("OpenKeySet returned error: %v", err)
+	}
+	service := auth.NewJWTServiceWithKeys(keys, "issuer", time.Minute, time.Hour)
+	user := models.User{ID: "user-1", Username: "alice", Role: "user"}
+
+	oldToken, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	if kid, alg := tokenKeyID(t, oldToken); kid != "2026-01" || alg != "RS256" {
+		t.Fatalf("expected RS256 token with kid 2026-01, got %s/%s", alg, kid)
+	}
+
+	// Rotate: the newer key becomes active, the old one stays for verification.
+	writeEd25519Key(t, filepath.Join(dir, "2026-02.pem"))
+	if err := keys.Reload(); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+
+	newToken, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	if kid, alg := tokenKeyID(t, newToken); kid != "2026-02" || alg != "EdDSA" {
+		t.Fatalf("expected EdDSA token with kid 2026-02, got %s/%s", alg, kid)
+	}
+	for _, token := range []string{oldToken, newToken} {
+		if _, err := service.ParseToken(token); err != nil {
+			t.Fatalf("expected token to verify after rotation: %v", err)
+		}
+	}
+
+	// Retiring the old key for good invalidates tokens it signed.
+	if err := os.Remove(filepath.Join(dir, "2026-01.pem")); err != nil {
+		t.Fatalf("failed to remove key: %v", err)
+	}
+	if err := keys.Reload(); err != nil {
+		t.Fatalf("Reload returned error: %v", err)
+	}
+	if _, err := service.ParseToken(oldToken); err == nil {
+		t.Fatalf("expected token signed by a removed key to be rejected")
+	}
+	if _, err := service.ParseToken(newToken); err != nil {
+		t.Fatalf("expected active key token to verify: %v", err)
+	}
+}
+
+func TestKeySetReloadFailureKeepsKeys(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, filepath.Join(dir, "a.pem"))
+
+	keys, err := auth.OpenKeySet(auth.KeySource{Dir: dir})
+	if err != nil {
+		t.Fatalf("OpenKeySet returned error: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "b.pem"), []byte("garbage"), 0o600); err != nil {
+		t.Fatalf("failed to write key: %v", err)
+	}
+	if err := keys.Reload(); err == nil {
+		t.Fatalf("expected reload of a malformed key to fail")
+	}
+	if keys.Active().ID != "a" {
+		t.Fatalf("expected previous keys to stay active, got %q", keys.Active().ID)
+	}
+}
+
+func TestKeySetLegacySecretAndAlgorithmPinning(t *testing.T) {
+	dir := t.TempDir()
+	writeEd25519Key(t, filepath.Join(dir, "current.pem"))
+
+	legacy := auth.NewJWTService("old-secret", "issuer", time.Minute, time.Hour)
+	user := models.User{ID: "user-1", Username: "alice", Role: "user"}
+	legacyToken, err := legacy.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+
+	withoutLegacy, err := auth.OpenKeySet(auth.KeySource{Dir: dir})
+	if err != nil {
+		t.Fatalf("OpenKeySet returned error: %v", err)
+	}
+	if _, err := auth.NewJWTServiceWithKeys(withoutLegacy, "issuer", time.Minute, time.Hour).ParseToken(legacyToken); err == nil {
+		t.Fatalf("expected HS256 token to be rejected without a legacy secret")
+	}
+
+	withLegacy, err := auth.OpenKeySet(auth.KeySource{Dir: dir, LegacySecret: []byte("old-secret")})
+	if err != nil {
+		t.Fatalf("OpenKeySet returned error: %v", err)
+	}
+	service := auth.NewJWTServiceWithKeys(withLegacy, "issuer", time.Minute, time.Hour)
+	if _, err := service.ParseToken(legacyToken); err != nil {
+		t.Fatalf("expected legacy HS256 token to verify: %v", err)
+	}
+
+	// An HS256 token naming the asymmetric key must not be accepted.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, auth.Claims{UserID: "user-1"})
+	forged.Header["kid"] = "current"
+	signed, err := forged.SignedString([]byte("anything"))
+	if err != nil {
+		t.Fatalf("failed to sign forged token: %v", err)
+	}
+	if _, err := service.ParseToken(signed); err == nil || !strings.Contains(err.Error(), "unexpected signing method") {
+		t.Fatalf("expected algorithm mismatch to be rejected, got %v", err)
+	}
+}
+
+func TestKeySetJWKS(t *testing.T) {
+	dir := t.TempDir()
+	writeRSAKey(t, filepath.Join(dir, "rsa.pem"))
+	pub := writeEd25519Key(t, filepath.Join(dir, "ed.pem"))
+
+	keys, err := auth.OpenKeySet(auth.KeySource{Dir: dir, ActiveKeyID: "rsa", LegacySecret: []byte("secret")})
+	if err != nil {
+		t.Fatalf("OpenKeySet returned error: %v", err)
+	}
+	if keys.Active().ID != "rsa" {
+		t.Fatalf("expected configured active key, got %q", keys.Active().ID)
+	}
+
+	set := keys.JWKS()
+	if len(set.Keys) != 2 {
+		t.Fatalf("expected two public keys without the HMAC secret, got %+v", set.Keys)
+	}
+	ed, rsaKey := set.Keys[0], set.Keys[1]
+	if ed.KeyID != "ed" || ed.KeyType != "OKP" || ed.Curve != "Ed25519" || ed.Algorithm != "EdDSA" || ed.X == "" {
+		t.Fatalf("unexpected Ed25519 JWK: %+v", ed)
+	}
+	if ed.X != base64.RawURLEncoding.EncodeToString(pub) {
+		t.Fatalf("expected JWK to carry the Ed25519 public key")
+	}
+	if rsaKey.KeyID != "rsa" || rsaKey.KeyType != "RSA" || rsaKey.Algorithm != "RS256" || rsaKey.N == "" || rsaKey.E != "AQAB" {
+		t.Fatalf("unexpected RSA JWK: %+v", rsaKey)
+	}
+}
@@ -0,0 +1,348 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+	"assignment3/backend/internal/store"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// ErrDirectoryUnavailable is returned when the directory cannot be reached or
+// fails a request for another reason than the user's credentials.
+var ErrDirectoryUnavailable = errors.New("directory is unavailable")
+
+// LDAPConfig points an LDAPAuthenticator at a directory.
+type LDAPConfig struct {
+	// URL is the directory's ldap:// or ldaps:// address.
+	URL string
+	// StartTLS upgrades an ldap:// connection before any credentials are
+	// sent.
+	StartTLS bool
+	// TLSConfig verifies the directory's certificate for ldaps:// and
+	// StartTLS; the system roots by default.
+	TLSConfig *tls.Config
+	// BindDN and BindPassword are the service account that looks users and
+	// groups up. Without them the lookups are made anonymously.
+	BindDN       string
+	BindPassword string
+	// BaseDN is searched, with its whole subtree, for users.
+	BaseDN string
+	// UserFilter finds the entry of a username, which replaces %s; by default
+	// (uid=%s). Exactly one entry must match.
+	UserFilter string
+	// UsernameAttribute and EmailAttribute name the attributes copied to
+	// provisioned accounts; uid and mail by default.
+	UsernameAttribute string
+	EmailAttribute    string
+	// GroupBaseDN, if set, is searched for the groups whose GroupFilter
+	// matches the user's DN, which replaces %s; by default (member=%s).
+	// Otherwise groups are read from the user's memberOf attribute.
+	GroupBaseDN string
+	GroupFilter string
+	// Roles assigns roles by group. When it has rules, a directory user's
+	// role follows their groups on every sign-in.
+	Roles GroupRoleMapping
+	// Timeout bounds connecting and each request; ten seconds by default.
+	Timeout time.Duration
+}
+
+// DirectoryUserStore keeps the accounts of directory users.
+type DirectoryUserStore interface {
+	GetUserByIdentity(issuer, subject string) (models.User, error)
+	CreateExternalUser(username, email, role, issuer, subject string) (models.User, error)
+	SetUserRole(actorID, userID, role string) (models.User, error)
+	Policy() *rbac.Policy
+}
+
+// LDAPAuthenticator checks passwords by binding to a directory as the user.
+// Users signing in for the first time get an account linked to their entry's
+// DN, with the role their groups map to.
+type LDAPAuthenticator struct {
+	cfg   LDAPConfig
+	users DirectoryUserStore
+}
+
+// NewLDAPAuthenticator fills in the defaults of cfg.
+func NewLDAPAuthenticator(cfg LDAPConfig, users DirectoryUserStore) *LDAPAuthenticator {
+	if cfg.UserFilter == "" {
+		cfg.UserFilter = "(uid=%s)"
+	}
+	if cfg.UsernameAttribute == "" {
+		cfg.UsernameAttribute = "uid"
+	}
+	if cfg.EmailAttribute == "" {
+		cfg.EmailAttribute = "mail"
+	}
+	if cfg.GroupFilter == "" {
+		cfg.GroupFilter = "(member=%s)"
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = 10 * time.Second
+	}
+	return &LDAPAuthenticator{cfg: cfg, users: users}
+}
+
+// Issuer identifies the directory in the identities of its users.
+func (a *LDAPAuthenticator) Issuer() string {
+	return strings.TrimRight(a.cfg.URL, "/")
+}
+
+type ldapEntry struct {
+	dn       string
+	username string
+	email    string
+	groups   []string
+}
+
+// Authenticate binds as the directory entry of username and returns its
+// account, provisioning it on first sign-in.
+func (a *LDAPAuthenticator) Authenticate(username, password string) (models.User, error) {
+	// An empty password would make an unauthenticated bind, which
+	// directories accept for any DN.
+	if strings.TrimSpace(username) == "" || password == "" {
+		return models.User{}, store.ErrInvalidCredentials
+	}
+
+	entry, err := a.lookup(username, password)
+	if err != nil {
+		return models.User{}, err
+	}
+	return a.account(entry)
+}
+
+// lookup verifies the password and reads the user's entry and groups.
+func (a *LDAPAuthenticator) lookup(username, password string) (ldapEntry, error) {
+	conn, err := a.dial()
+	if err != nil {
+		return ldapEntry{}, err
+	}
+	defer conn.Close()
+
+	if err := a.serviceBind(conn); err != nil {
+		return ldapEntry{}, err
+	}
+
+	attributes := []string{a.cfg.UsernameAttribute, a.cfg.EmailAttribute}
+	if a.cfg.GroupBaseDN == "" {
+		attributes = append(attributes, "memberOf")
+	}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.cfg.BaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 2, a.timeLimit(), false,
+		fmt.Sprintf(a.cfg.UserFilter, ldap.EscapeFilter(username)), attributes, nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return ldapEntry{}, fmt.Errorf("%w: user search: %v", ErrDirectoryUnavailable, err)
+	}
+	if result == nil || len(result.Entries) != 1 {
+		if result != nil && len(result.Entries) > 1 {
+			log.Printf("ldap: username %q matches more than one entry", username)
+		}
+		return ldapEntry{}, store.ErrInvalidCredentials
+	}
+	found := result.Entries[0]
+
+	if err := conn.Bind(found.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return ldapEntry{}, store.ErrInvalidCredentials
+		}
+		return ldapEntry{}, fmt.Errorf("%w: bind: %v", ErrDirectoryUnavailable, err)
+	}
+
+	entry := ldapEntry{
+		dn:       found.DN,
+		username: found.GetEqualFoldAttributeValue(a.cfg.UsernameAttribute),
+		email:    found.GetEqualFoldAttributeValue(a.cfg.EmailAttribute),
+		groups:   found.GetEqualFoldAttributeValues("memberOf"),
+	}
+	if entry.username == "" {
+		entry.username = strings.TrimSpace(username)
+	}
+	if a.cfg.GroupBaseDN != "" {
+		// Users may not be allowed to read groups themselves.
+		if err := a.serviceBind(conn); err != nil {
+			return ldapEntry{}, err
+		}
+		groups, err := conn.Search(ldap.NewSearchRequest(
+			a.cfg.GroupBaseDN, ldap.ScopeWholeSubtree, ldap.NeverDerefAliases, 0, a.timeLimit(), false,
+			fmt.Sprintf(a.cfg.GroupFilter, ldap.EscapeFilter(found.DN)), []string{"1.1"}, nil,
+		))
+		if err != nil {
+			return ldapEntry{}, fmt.Errorf("%w: group search: %v", ErrDirectoryUnavailable, err)
+		}
+		entry.groups = entry.groups[:0]
+		for _, group := range groups.Entries {
+			entry.groups = append(entry.groups, group.DN)
+		}
+	}
+	return entry, nil
+}
+
+func (a *LDAPAuthenticator) dial() (*ldap.Conn, error) {
+	conn, err := ldap.DialURL(a.cfg.URL,
+		ldap.DialWithDialer(&net.Dialer{Timeout: a.cfg.Timeout}),
+		ldap.DialWithTLSConfig(a.tlsConfig()),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrDirectoryUnavailable, err)
+	}
+	conn.SetTimeout(a.cfg.Timeout)
+	if a.cfg.StartTLS {
+		if err := conn.StartTLS(a.tlsConfig()); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("%w: starttls: %v", ErrDirectoryUnavailable, err)
+		}
+	}
+	return conn, nil
+}
+
+// tlsConfig verifies the certificate against the directory's host name unless
+// the configuration names another.
+func (a *LDAPAuthenticator) tlsConfig() *tls.Config {
+	cfg := &tls.Config{MinVersion: tls.VersionTLS12}
+	if a.cfg.TLSConfig != nil {
+		cfg = a.cfg.TLSConfig.Clone()
+	}
+	if cfg.ServerName == "" {
+		if u, err := url.Parse(a.cfg.URL); err == nil {
+			cfg.ServerName = u.Hostname()
+		}
+	}
+	return cfg
+}
+
+// serviceBind authenticates as the service account, or anonymously without
+// one.
+func (a *LDAPAuthenticator) serviceBind(conn *ldap.Conn) error {
+	var err error
+	if a.cfg.BindDN == "" {
+		err = conn.UnauthenticatedBind("")
+	} else {
+		err = conn.Bind(a.cfg.BindDN, a.cfg.BindPassword)
+	}
+	if err != nil {
+		return fmt.Errorf("%w: service bind: %v", ErrDirectoryUnavailable, err)
+	}
+	return nil
+}
+
+func (a *LDAPAuthenticator) timeLimit() int {
+	return int(a.cfg.Timeout / time.Second)
+}
+
+// account returns the user linked to entry, provisioning it or bringing its
+// role in line with the entry's groups.
+func (a *LDAPAuthenticator) account(entry ldapEntry) (models.User, error) {
+	issuer := a.Issuer()
+	role := a.cfg.Roles.Role(entry.groups)
+	if role == "" && len(a.cfg.Roles.Rules) > 0 {
+		role = a.users.Policy().DefaultRole()
+	}
+
+	user, err := a.users.GetUserByIdentity(issuer, entry.dn)
+	if err == nil {
+		if role != "" && role != user.Role {
+			updated, err := a.users.SetUserRole(store.AuditActorLDAP, user.ID, role)
+			if err != nil {
+				log.Printf("ldap: keeping role %q of %s: %v", user.Role, user.Username, err)
+				return user, nil
+			}
+			return updated, nil
+		}
+		return user, nil
+	}
+	if !errors.Is(err, store.ErrIdentityNotFound) {
+		return models.User{}, err
+	}
+
+	username := entry.username
+	user, err = a.users.CreateExternalUser(username, entry.email, role, issuer, entry.dn)
+	if errors.Is(err, store.ErrUserExists) {
+		// A local account has the name; directory users never take one
+		// over, so this one gets a suffix derived from its DN.
+		username += "-" + HashToken(issuer + " " + entry.dn)[:6]
+		user, err = a.users.CreateExternalUser(username, entry.email, role, issuer, entry.dn)
+	}
+	if errors.Is(err, store.ErrIdentityExists) {
+		// A concurrent sign-in provisioned the account first.
+		return a.users.GetUserByIdentity(issuer, entry.dn)
+	}
+	return user, err
+}
+
+// GroupRoleMapping derives a role from a directory user's groups. The first
+// rule naming one of the groups decides the role.
+type GroupRoleMapping struct {
+	Rules []GroupRoleRule
+}
+
+// GroupRoleRule grants Role to members of Group, which is either a group's
+// full DN or the value of its first RDN, e.g. its cn.
+type GroupRoleRule struct {
+	Group string
+	Role  string
+}
+
+// ParseGroupRoleMapping reads rules written as group=role pairs separated by
+// semicolons, since DNs contain commas, e.g.
+// "cn=admins,ou=groups,dc=example,dc=org=admin;engineering=editor". The role
+// follows the last equals sign.
+func ParseGroupRoleMapping(rules string) (GroupRoleMapping, error) {
+	var mapping GroupRoleMapping
+	for _, rule := range strings.Split(rules, ";") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		i := strings.LastIndex(rule, "=")
+		if i < 0 {
+			return GroupRoleMapping{}, fmt.Errorf("invalid role mapping %q, expected group=role", rule)
+		}
+		group, role := strings.TrimSpace(rule[:i]), strings.TrimSpace(rule[i+1:])
+		if group == "" || role == "" {
+			return GroupRoleMapping{}, fmt.Errorf("invalid role mapping %q, expected group=role", rule)
+		}
+		if strings.Contains(group, "=") {
+			if _, err := ldap.ParseDN(group); err != nil {
+				return GroupRoleMapping{}, fmt.Errorf("invalid group DN %q: %v", group, err)
+			}
+		}
+		mapping.Rules = append(mapping.Rules, GroupRoleRule{Group: group, Role: role})
+	}
+	return mapping, nil
+}
+
+// Role returns the role the groups map to, or "" if no rule matches.
+func (m GroupRoleMapping) Role(groups []string) string {
+	parsed := make([]*ldap.DN, 0, len(groups))
+	for _, group := range groups {
+		if dn, err := ldap.ParseDN(group); err == nil && len(dn.RDNs) > 0 {
+			parsed = append(parsed, dn)
+		}
+	}
+	for _, rule := range m.Rules {
+		ruleDN, _ := ldap.ParseDN(rule.Group)
+		for _, dn := range parsed {
+			if strings.Contains(rule.Group, "=") {
+				if ruleDN != nil && ruleDN.EqualFold(dn) {
+					return rule.Role
+				}
+				continue
+			}
+			for _, attr := range dn.RDNs[0].Attributes {
+				if strings.EqualFold(attr.Value, rule.Group) {
+					return rule.Role
+				}
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,488 @@
+package auth_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"math/big"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/store"
+
+	ber "github.com/go-asn1-ber/asn1-ber"
+)
+
+const (
+	ldapReaderDN       = "cn=reader,dc=example,dc=org"
+	ldapReaderPassword = "reader-secret"
+	ldapAdminsDN       = "cn=admins,ou=groups,dc=example,dc=org"
+	ldapStaffDN        = "cn=staff,ou=groups,dc=example,dc=org"
+)
+
+// mockDirectory is a minimal LDAP server. It answers simple binds, searches
+// with and, or, equality and presence filters, and StartTLS. Only the reader
+// service account may search.
+type mockDirectory struct {
+	t        *testing.T
+	listener net.Listener
+	// tls enables StartTLS; with requireTLS binds over plain connections
+	// are refused.
+	tls        *tls.Config
+	requireTLS bool
+
+	mu      sync.Mutex
+	entries []*mockEntry
+}
+
+type mockEntry struct {
+	dn         string
+	password   string
+	attributes map[string][]string
+}
+
+func newMockDirectory(t *testing.T) *mockDirectory {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	d := &mockDirectory{t: t, listener: listener}
+	d.add(ldapReaderDN, ldapReaderPassword, nil)
+	d.add("uid=alice,ou=people,dc=example,dc=org", "alice-pass", map[string][]string{
+		"uid": {"alice"}, "mail": {"alice@example.org"}, "memberOf": {ldapAdminsDN},
+	})
+	d.add("uid=bob,ou=people,dc=example,dc=org", "bob-pass", map[string][]string{
+		"uid": {"bob"}, "memberOf": {ldapStaffDN},
+	})
+	// Carol's membership is only recorded on the group.
+	d.add("uid=carol,ou=people,dc=example,dc=org", "carol-pass", map[string][]string{"uid": {"carol"}})
+	d.add("uid=twin,ou=people,dc=example,dc=org", "twin-pass", map[string][]string{"uid": {"twin"}})
+	d.add("uid=twin,ou=contractors,dc=example,dc=org", "twin-pass", map[string][]string{"uid": {"twin"}})
+	d.add(ldapAdminsDN, "", map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"member":      {"uid=alice,ou=people,dc=example,dc=org", "uid=carol,ou=people,dc=example,dc=org"},
+	})
+	d.add(ldapStaffDN, "", map[string][]string{
+		"objectClass": {"groupOfNames"},
+		"member":      {"uid=bob,ou=people,dc=example,dc=org"},
+	})
+
+	go d.serve()
+	t.Cleanup(func() { listener.Close() })
+	return d
+}
+
+func (d *mockDirectory) url() string {
+	return "ldap://" + d.listener.Addr().String()
+}
+
+func (d *mockDirectory) add(dn, password string, attributes map[string][]string) {
+	d.entries = append(d.entries, &mockEntry{dn: dn, password: password, attributes: attributes})
+}
+
+func (d *mockDirectory) setAttribute(dn, name string, values ...string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, entry := range d.entries {
+		if strings.EqualFold(entry.dn, dn) {
+			entry.attributes[name] = values
+		}
+	}
+}
+
+func (d *mockDirectory) serve() {
+	for {
+		conn, err := d.listener.Accept()
+		if err != nil {
+			return
+		}
+		go d.handle(conn)
+	}
+}
+
+func (d *mockDirectory) handle(conn net.Conn) {
+	defer func() { conn.Close() }()
+	encrypted := false
+	bound := ""
+	for {
+		packet, err := ber.ReadPacket(conn)
+		if err != nil || len(packet.Children) < 2 {
+			return
+		}
+		id := packet.Children[0].Value.(int64)
+		op := packet.Children[1]
+
+		switch op.Tag {
+		case 0: // bind
+			if d.requireTLS && !encrypted {
+				d.reply(conn, id, 1, 13, "bind requires StartTLS")
+				continue
+			}
+			name, password := op.Children[1].Data.String(), op.Children[2].Data.String()
+			bound = ""
+			switch entry := d.entry(name); {
+			case name == "" && password == "":
+				d.reply(conn, id, 1, 0, "")
+			case entry != nil && entry.password != "" && entry.password == password:
+				bound = entry.dn
+				d.reply(conn, id, 1, 0, "")
+			default:
+				d.reply(conn, id, 1, 49, "invalid credentials")
+			}
+		case 2: // unbind
+			return
+		case 3: // search
+			if !strings.EqualFold(bound, ldapReaderDN) {
+				d.reply(conn, id, 5, 50, "insufficient access")
+				continue
+			}
+			d.search(conn, id, op)
+		case 23: // extended
+			if op.Children[0].Data.String() != "1.3.6.1.4.1.1466.20037" || d.tls == nil || encrypted {
+				d.reply(conn, id, 24, 2, "unsupported operation")
+				continue
+			}
+			d.reply(conn, id, 24, 0, "")
+			tlsConn := tls.Server(conn, d.tls)
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn, encrypted = tlsConn, true
+		}
+	}
+}
+
+func (d *mockDirectory) entry(dn string) *mockEntry {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for _, entry := range d.entries {
+		if strings.EqualFold(entry.dn, dn) {
+			return entry
+		}
+	}
+	return nil
+}
+
+func (d *mockDirectory) search(conn net.Conn, id int64, op *ber.Packet) {
+	base := strings.ToLower(op.Children[0].Data.String())
+	sizeLimit := op.Children[3].Value.(int64)
+	filter := op.Children[6]
+	noAttributes := len(op.Children[7].Children) == 1 && op.Children[7].Children[0].Data.String() == "1.1"
+
+	d.mu.Lock()
+	var matches []*mockEntry
+	for _, entry := range d.entries {
+		if strings.HasSuffix(strings.ToLower(entry.dn), ","+base) && matchFilter(filter, entry) {
+			matches = append(matches, entry)
+		}
+	}
+	d.mu.Unlock()
+
+	for i, entry := range matches {
+		if sizeLimit > 0 && int64(i) == sizeLimit {
+			d.reply(conn, id, 5, 4, "size limit exceeded")
+			return
+		}
+		response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, 4, nil, "")
+		response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, entry.dn, ""))
+		attributes := ber.NewSequence("")
+		if !noAttributes {
+			for name, values := range entry.attributes {
+				attribute := ber.NewSequence("")
+				attribute.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, name, ""))
+				set := ber.Encode(ber.ClassUniversal, ber.TypeConstructed, ber.TagSet, nil, "")
+				for _, value := range values {
+					set.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, value, ""))
+				}
+				attribute.AppendChild(set)
+				attributes.AppendChild(attribute)
+			}
+		}
+		response.AppendChild(attributes)
+		d.send(conn, id, response)
+	}
+	d.reply(conn, id, 5, 0, "")
+}
+
+func matchFilter(filter *ber.Packet, entry *mockEntry) bool {
+	switch filter.Tag {
+	case 0: // and
+		for _, child := range filter.Children {
+			if !matchFilter(child, entry) {
+				return false
+			}
+		}
+		return true
+	case 1: // or
+		for _, child := range filter.Children {
+			if matchFilter(child, entry) {
+				return true
+			}
+		}
+		return false
+	case 3: // equality
+		name, want := filter.Children[0].Data.String(), filter.Children[1].Data.String()
+		for attribute, values := range entry.attributes {
+			if !strings.EqualFold(attribute, name) {
+				continue
+			}
+			for _, value := range values {
+				if strings.EqualFold(value, want) {
+					return true
+				}
+			}
+		}
+		return false
+	case 7: // present
+		_, ok := entry.attributes[filter.Data.String()]
+		return ok || strings.EqualFold(filter.Data.String(), "objectClass")
+	default:
+		return false
+	}
+}
+
+// reply sends an LDAPResult with the given operation tag and result code.
+func (d *mockDirectory) reply(conn net.Conn, id int64, tag ber.Tag, code int, message string) {
+	response := ber.Encode(ber.ClassApplication, ber.TypeConstructed, tag, nil, "")
+	response.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagEnumerated, code, ""))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, "", ""))
+	response.AppendChild(ber.NewString(ber.ClassUniversal, ber.TypePrimitive, ber.TagOctetString, message, ""))
+	d.send(conn, id, response)
+}
+
+func (d *mockDirectory) send(conn net.Conn, id int64, op *ber.Packet) {
+	packet := ber.NewSequence("")
+	packet.AppendChild(ber.NewInteger(ber.ClassUniversal, ber.TypePrimitive, ber.TagInteger, id, ""))
+	packet.AppendChild(op)
+	_, _ = conn.Write(packet.Bytes())
+}
+
+// enableTLS makes the directory require StartTLS and returns a client
+// configuration trusting its certificate.
+func (d *mockDirectory) enableTLS() *tls.Config {
+	d.t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		d.t.Fatalf("failed to generate key: %v", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "directory"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		d.t.Fatalf("failed to create certificate: %v", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		d.t.Fatalf("failed to parse certificate: %v", err)
+	}
+
+	d.tls = &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	d.requireTLS = true
+	roots := x509.NewCertPool()
+	roots.AddCert(cert)
+	return &tls.Config{RootCAs: roots}
+}
+
+func (d *mockDirectory) config(t *testing.T, rules string) auth.LDAPConfig {
+	t.Helper()
+	roles, err := auth.ParseGroupRoleMapping(rules)
+	if err != nil {
+		t.Fatalf("ParseGroupRoleMapping returned error: %v", err)
+	}
+	return auth.LDAPConfig{
+		URL:          d.url(),
+		BindDN:       ldapReaderDN,
+		BindPassword: ldapReaderPassword,
+		BaseDN:       "dc=example,dc=org",
+		Roles:        roles,
+		Timeout:      5 * time.Second,
+	}
+}
+
+func TestLDAPAuthenticatorProvisionsDirectoryUsers(t *testing.T) {
+	directory := newMockDirectory(t)
+	st := store.NewStore()
+	if _, _, err := st.EnsureAdminUser("root", "root-password"); err != nil {
+		t.Fatalf("EnsureAdminUser returned error: %v", err)
+	}
+	authenticator := auth.NewLDAPAuthenticator(directory.config(t, ldapAdminsDN+"=admin;staff=user"), st)
+
+	alice, err := authenticator.Authenticate("alice", "alice-pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	if alice.Username != "alice" || alice.Email != "alice@example.org" || alice.Role != "admin" {
+		t.Fatalf("unexpected provisioned user %+v", alice)
+	}
+	if _, err := st.Authenticate("alice", "alice-pass"); !errors.Is(err, store.ErrInvalidCredentials) {
+		t.Fatalf("expected directory password to stay out of the store, got %v", err)
+	}
+	again, err := authenticator.Authenticate("ALICE", "alice-pass")
+	if err != nil || again.ID != alice.ID {
+		t.Fatalf("expected the same account on the next sign-in, got %+v, %v", again, err)
+	}
+
+	for _, tc := range []struct{ username, password string }{
+		{"alice", "wrong"},
+		{"alice", ""},
+		{"nobody", "alice-pass"},
+		{"twin", "twin-pass"},
+		{"alice)(uid=*", "alice-pass"},
+	} {
+		if _, err := authenticator.Authenticate(tc.username, tc.password); !errors.Is(err, store.ErrInvalidCredentials) {
+			t.Fatalf("expected %q/%q to be rejected, got %v", tc.username, tc.password, err)
+		}
+	}
+
+	// Roles follow the groups on every sign-in.
+	directory.setAttribute("uid=alice,ou=people,dc=example,dc=org", "memberOf", ldapStaffDN)
+	alice, err = authenticator.Authenticate("alice", "alice-pass")
+	if err != nil || alice.Role != "user" {
+		t.Fatalf("expected alice to be demoted to user, got %+v, %v", alice, err)
+	}
+	events, err := st.ListAuditEvents(1)
+	if err != nil || len(events) != 1 || events[0].Action != store.AuditUserRoleChanged || events[0].ActorID != store.AuditActorLDAP {
+		t.Fatalf("expected the directory to be the actor of the role change, got %+v, %v", events, err)
+	}
+
+	// Directory users never take over local accounts.
+	local, err := st.CreateUser("bob", "local-password", "user")
+	if err != nil {
+		t.Fatalf("CreateUser returned error: %v", err)
+	}
+	bob, err := authenticator.Authenticate("bob", "bob-pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	if bob.ID == local.ID || !strings.HasPrefix(bob.Username, "bob-") {
+		t.Fatalf("expected a separate account for the directory bob, got %+v", bob)
+	}
+}
+
+func TestLDAPAuthenticatorGroupSearchOverStartTLS(t *testing.T) {
+	directory := newMockDirectory(t)
+	clientTLS := directory.enableTLS()
+	st := store.NewStore()
+
+	cfg := directory.config(t, "admins=admin")
+	cfg.GroupBaseDN = "ou=groups,dc=example,dc=org"
+	if _, err := auth.NewLDAPAuthenticator(cfg, st).Authenticate("carol", "carol-pass"); !errors.Is(err, auth.ErrDirectoryUnavailable) {
+		t.Fatalf("expected binds without StartTLS to fail, got %v", err)
+	}
+
+	cfg.StartTLS = true
+	cfg.TLSConfig = clientTLS
+	carol, err := auth.NewLDAPAuthenticator(cfg, st).Authenticate("carol", "carol-pass")
+	if err != nil {
+		t.Fatalf("Authenticate returned error: %v", err)
+	}
+	if carol.Role != "admin" {
+		t.Fatalf("expected role from group search, got %+v", carol)
+	}
+
+	cfg.TLSConfig = &tls.Config{}
+	if _, err := auth.NewLDAPAuthenticator(cfg, st).Authenticate("carol", "carol-pass"); !errors.Is(err, auth.ErrDirectoryUnavailable) {
+		t.Fatalf("expected an untrusted certificate to be refused, got %v", err)
+	}
+}
+
+func TestChainAuthenticatorsFallBackToLocalAdmins(t *testing.T) {
+	directory := newMockDirectory(t)
+	st := store.NewStore()
+	if _, _, err := st.EnsureAdminUser("root", "root-password"); err != nil {
+		t.Fatalf("EnsureAdminUser returned error: %v", err)
+	}
+	if _, err := st.CreateUser("local", "local-password", "user"); err != nil {
+		t.Fatalf("CreateUser returned error: %v", err)
+	}
+
+	chain := auth.ChainAuthenticators(
+		auth.NewLDAPAuthenticator(directory.config(t, ""), st),
+		auth.RestrictRoles(st, "admin"),
+	)
+	if user, err := chain.Authenticate("alice", "alice-pass"); err != nil || user.Username != "alice" {
+		t.Fatalf("expected directory sign-in, got %+v, %v", user, err)
+	}
+	if user, err := chain.Authenticate("root", "root-password"); err != nil || user.Username != "root" {
+		t.Fatalf("expected break-glass sign-in, got %+v, %v", user, err)
+	}
+	if _, err := chain.Authenticate("local", "local-password"); !errors.Is(err, store.ErrInvalidCredentials) {
+		t.Fatalf("expected local non-admins to be rejected, got %v", err)
+	}
+	if _, err := chain.Authenticate("root", "wrong"); !errors.Is(err, store.ErrInvalidCredentials) {
+		t.Fatalf("expected wrong password to be rejected, got %v", err)
+	}
+
+	// With the directory down, admins can still sign in locally and everyone
+	// else learns the directory is unavailable.
+	cfg := directory.config(t, "")
+	cfg.URL = "ldap://" + closedAddr(t)
+	chain = auth.ChainAuthenticators(auth.NewLDAPAuthenticator(cfg, st), auth.RestrictRoles(st, "admin"))
+	if user, err := chain.Authenticate("root", "root-password"); err != nil || user.Username != "root" {
+		t.Fatalf("expected break-glass sign-in while the directory is down, got %+v, %v", user, err)
+	}
+	if _, err := chain.Authenticate("alice", "alice-pass"); !errors.Is(err, auth.ErrDirectoryUnavailable) {
+		t.Fatalf("expected ErrDirectoryUnavailable, got %v", err)
+	}
+}
+
+// closedAddr returns an address nothing listens on.
+func closedAddr(t *testing.T) string {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	addr := listener.Addr().String()
+	listener.Close()
+	return addr
+}
+
+func TestGroupRoleMapping(t *testing.T) {
+	mapping, err := auth.ParseGroupRoleMapping(" cn=admins,ou=groups,dc=example,dc=org=admin ; engineering = editor ;")
+	if err != nil {
+		t.Fatalf("ParseGroupRoleMapping returned error: %v", err)
+	}
+	if len(mapping.Rules) != 2 || mapping.Rules[0].Role != "admin" || mapping.Rules[1].Group != "engineering" {
+		t.Fatalf("unexpected rules %+v", mapping.Rules)
+	}
+
+	cases := []struct {
+		groups []string
+		want   string
+	}{
+		{[]string{"CN=Admins, OU=Groups, DC=example, DC=org"}, "admin"},
+		{[]string{"cn=Engineering,ou=teams,dc=example,dc=org", "cn=admins,ou=groups,dc=example,dc=org"}, "admin"},
+		{[]string{"cn=engineering,ou=teams,dc=example,dc=org"}, "editor"},
+		{[]string{"cn=admins,ou=other,dc=example,dc=org"}, ""},
+		{[]string{"not a dn"}, ""},
+		{nil, ""},
+	}
+	for _, tc := range cases {
+		if got := mapping.Role(tc.groups); got != tc.want {
+			t.Fatalf("Role(%v) = %q, want %q", tc.groups, got, tc.want)
+		}
+	}
+
+	for _, invalid := range []string{"admins", "=admin", "admins="} {
+		if _, err := auth.ParseGroupRoleMapping(invalid); err == nil {
+			t.Fatalf("expected %q to be rejected", invalid)
+		}
+	}
+}
@@ -1,8 +1,14 @@
 package auth
 
 import (
+	"errors"
 	"net/http"
 	"strings"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+	"assignment3/backend/internal/store"
 
 	"github.com/gin-gonic/gin"
 )
@@ -12,40 +18,368 @@ type ContextUser struct {
 	ID       string
 	Username string
 	Role     string
+	// MustChangePassword is set while the account may only change its password.
+	MustChangePassword bool
+	// MFAEnabled reports whether the user has two-factor authentication set up.
+	MFAEnabled bool
+
+	// TokenID, IssuedAt and ExpiresAt describe the access token that
+	// authenticated the request.
+	TokenID   string
+	IssuedAt  time.Time
+	ExpiresAt time.Time
+	// SessionID names the sign-in session the access token belongs to.
+	SessionID string
+
+	// AccessTokenID is set instead when a personal access token authenticated
+	// the request, and ServiceAccount when a service account's token did.
+	// Such requests may only use the permissions in Scopes.
+	AccessTokenID  string
+	ServiceAccount bool
+	Scopes         []rbac.Permission
+}
+
+// HasScope reports whether the credential that authenticated the request may
+// use perm. Sign-in sessions are not scoped; the role alone decides.
+func (u ContextUser) HasScope(perm rbac.Permission) bool {
+	if !u.Scoped() {
+		return true
+	}
+	for _, scope := range u.Scopes {
+		if scope == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// Scoped reports whether the request was authenticated by a credential other
+// than a user's sign-in session.
+func (u ContextUser) Scoped() bool {
+	return u.AccessTokenID != "" || u.ServiceAccount
 }
 
 const contextUserKey = "auth.user"
 
-// AuthMiddleware validates JWT tokens and injects the authenticated user into the context.
-func AuthMiddleware(jwtService *JWTService) gin.HandlerFunc {
+// Denylist reports whether an otherwise valid access token has been revoked.
+type Denylist interface {
+	IsAccessTokenRevoked(jti, userID string, issuedAt time.Time) (bool, error)
+}
+
+// MiddlewareOption customises AuthMiddleware.
+type MiddlewareOption func(*middlewareConfig)
+
+// AccessTokenStore resolves personal access tokens and their owners.
+type AccessTokenStore interface {
+	// UseAccessToken returns store.ErrAccessTokenInvalid for unknown or
+	// expired tokens.
+	UseAccessToken(tokenHash string) (models.PersonalAccessToken, error)
+	GetUser(id string) (models.User, error)
+}
+
+// SessionStore resolves the sign-in sessions that access tokens belong to.
+type SessionStore interface {
+	// UseSession returns store.ErrSessionInvalid for unknown, expired or
+	// revoked sessions.
+	UseSession(id, ip string) (models.Session, error)
+}
+
+type middlewareConfig struct {
+	denylist             Denylist
+	sessions             SessionStore
+	accessTokens         AccessTokenStore
+	users                UserResolver
+	allowPasswordPending bool
+	mfaPolicy            PolicySource
+	allowMFAPending      bool
+	cookies              *CookieConfig
+}
+
+// WithDenylist rejects tokens that the denylist reports as revoked.
+func WithDenylist(denylist Denylist) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.denylist = denylist
+	}
+}
+
+// WithSessions rejects access tokens whose sign-in session was signed out,
+// and records when and from where each session was last seen.
+func WithSessions(sessions SessionStore) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.sessions = sessions
+	}
+}
+
+// WithAccessTokens accepts personal access tokens, recognised by
+// AccessTokenPrefix, as bearer tokens alongside JWTs. Their owner is always
+// looked up, so role changes and deleted accounts take effect immediately.
+func WithAccessTokens(tokens AccessTokenStore) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.accessTokens = tokens
+	}
+}
+
+// WithUserResolver re-validates the token subject on every request: tokens of
+// deleted or suspended accounts are rejected and the current role replaces the
+// one baked into the token. Without it the middleware trusts the claims (stateless mode).
+func WithUserResolver(users UserResolver) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.users = users
+	}
+}
+
+// AllowPendingPasswordChange lets accounts that must change their password
+// through. Every other route rejects them with 403 until the password is
+// changed, so use it only for the password change itself and signing out.
+func AllowPendingPasswordChange() MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.allowPasswordPending = true
+	}
+}
+
+// WithMFAPolicy rejects users whose role requires two-factor authentication
+// until they have set it up. Like AllowPendingPasswordChange, routes needed to
+// set it up opt out with AllowPendingMFASetup.
+func WithMFAPolicy(policies PolicySource) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.mfaPolicy = policies
+	}
+}
+
+// AllowPendingMFASetup lets users through whose role requires two-factor
+// authentication they have not set up yet.
+func AllowPendingMFASetup() MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.allowMFAPending = true
+	}
+}
+
+// WithSessionCookies also accepts access tokens from the session cookie of
+// cookies when a request has no Authorization header. Such requests must pass
+// CheckCSRF unless their method is safe.
+func WithSessionCookies(cookies CookieConfig) MiddlewareOption {
+	return func(cfg *middlewareConfig) {
+		cfg.cookies = &cookies
+	}
+}
+
+// AuthMiddleware validates JWT tokens, and personal access tokens if enabled
+// with WithAccessTokens, and injects the authenticated user into the context.
+func AuthMiddleware(jwtService *JWTService, opts ...MiddlewareOption) gin.HandlerFunc {
+	var cfg middlewareConfig
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
 	return func(c *gin.Context) {
+		var (
+			user ContextUser
+			ok   bool
+		)
 		header := strings.TrimSpace(c.GetHeader("Authorization"))
-		if header == "" {
+		switch cookie := sessionCookie(c, cfg.cookies); {
+		case header == "" && cookie != "":
+			// Browsers attach the cookie to cross-site requests too.
+			if !cfg.cookies.CheckCSRF(c) {
+				AbortCSRF(c)
+				return
+			}
+			user, ok = authenticateJWT(c, jwtService, &cfg, cookie)
+		case header == "":
 			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header required"})
 			return
+		default:
+			parts := strings.SplitN(header, " ", 2)
+			if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
+				c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header must be in format 'Bearer <token>'"})
+				return
+			}
+			if token := strings.TrimSpace(parts[1]); cfg.accessTokens != nil && IsAccessToken(token) {
+				user, ok = authenticateAccessToken(c, cfg.accessTokens, token)
+			} else {
+				user, ok = authenticateJWT(c, jwtService, &cfg, token)
+			}
+		}
+		if !ok {
+			return
 		}
 
-		parts := strings.SplitN(header, " ", 2)
-		if len(parts) != 2 || !strings.EqualFold(parts[0], "Bearer") {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authorization header must be in format 'Bearer <token>'"})
+		if user.MustChangePassword && !cfg.allowPasswordPending {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "password change required",
+				"code":  "password_change_required",
+			})
 			return
 		}
 
-		claims, err := jwtService.ParseToken(strings.TrimSpace(parts[1]))
-		if err != nil {
-			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		// Service accounts authenticate with a secret and cannot set up MFA.
+		if cfg.mfaPolicy != nil && !user.MFAEnabled && !user.ServiceAccount && !cfg.allowMFAPending && cfg.mfaPolicy.Policy().RequiresMFA(user.Role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"error": "two-factor authentication must be set up",
+				"code":  "mfa_setup_required",
+			})
 			return
 		}
 
-		c.Set(contextUserKey, ContextUser{
-			ID:       claims.UserID,
-			Username: claims.Username,
-			Role:     claims.Role,
-		})
+		c.Set(contextUserKey, user)
 		c.Next()
 	}
 }
 
+// sessionCookie returns the access token in the request's session cookie, or
+// "" if there is none or cookies are not accepted.
+func sessionCookie(c *gin.Context, cookies *CookieConfig) string {
+	if cookies == nil {
+		return ""
+	}
+	token, err := c.Cookie(cookies.AccessName)
+	if err != nil {
+		return ""
+	}
+	return token
+}
+
+// authenticateJWT validates an access JWT, aborting the request if it is
+// invalid, revoked or belongs to a deleted account.
+func authenticateJWT(c *gin.Context, jwtService *JWTService, cfg *middlewareConfig, token string) (ContextUser, bool) {
+	claims, err := jwtService.ParseToken(token)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		return ContextUser{}, false
+	}
+
+	user := ContextUser{
+		ID:                 claims.UserID,
+		Username:           claims.Username,
+		Role:               claims.Role,
+		TokenID:            claims.ID,
+		SessionID:          claims.SessionID,
+		MustChangePassword: claims.MustChangePassword,
+		MFAEnabled:         claims.MFAEnabled,
+		ServiceAccount:     claims.ServiceAccount,
+	}
+	if claims.ServiceAccount {
+		user.Scopes = make([]rbac.Permission, 0)
+		for _, scope := range strings.Fields(claims.Scope) {
+			user.Scopes = append(user.Scopes, rbac.Permission(scope))
+		}
+	}
+	if claims.IssuedAtNanos != 0 {
+		user.IssuedAt = time.Unix(0, claims.IssuedAtNanos).UTC()
+	} else if claims.IssuedAt != nil {
+		user.IssuedAt = claims.IssuedAt.Time
+	}
+	if claims.ExpiresAt != nil {
+		user.ExpiresAt = claims.ExpiresAt.Time
+	}
+
+	if cfg.denylist != nil {
+		revoked, err := cfg.denylist.IsAccessTokenRevoked(user.TokenID, user.ID, user.IssuedAt)
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify token"})
+			return ContextUser{}, false
+		}
+		if revoked {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "token has been revoked"})
+			return ContextUser{}, false
+		}
+	}
+
+	if cfg.sessions != nil && user.SessionID != "" {
+		_, err := cfg.sessions.UseSession(user.SessionID, c.ClientIP())
+		if errors.Is(err, store.ErrSessionInvalid) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "session has been revoked"})
+			return ContextUser{}, false
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify token"})
+			return ContextUser{}, false
+		}
+	}
+
+	if cfg.users != nil {
+		current, err := cfg.users.GetUser(user.ID)
+		if errors.Is(err, store.ErrUserNotFound) {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "account no longer exists"})
+			return ContextUser{}, false
+		}
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify token"})
+			return ContextUser{}, false
+		}
+		if !checkAccountStatus(c, current) {
+			return ContextUser{}, false
+		}
+		user.Username = current.Username
+		user.Role = current.Role
+		user.MustChangePassword = current.MustChangePassword
+		user.MFAEnabled = current.MFAEnabled
+	}
+
+	return user, true
+}
+
+// authenticateAccessToken resolves a personal access token and its owner,
+// aborting the request if either is gone.
+func authenticateAccessToken(c *gin.Context, tokens AccessTokenStore, token string) (ContextUser, bool) {
+	pat, err := tokens.UseAccessToken(HashToken(token))
+	if errors.Is(err, store.ErrAccessTokenInvalid) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid or expired token"})
+		return ContextUser{}, false
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify token"})
+		return ContextUser{}, false
+	}
+
+	owner, err := tokens.GetUser(pat.UserID)
+	if errors.Is(err, store.ErrUserNotFound) {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "account no longer exists"})
+		return ContextUser{}, false
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "failed to verify token"})
+		return ContextUser{}, false
+	}
+	// Personal access tokens survive sign-outs, so suspending the owner does
+	// not revoke them.
+	if !checkAccountStatus(c, owner) {
+		return ContextUser{}, false
+	}
+
+	user := ContextUser{
+		ID:                 owner.ID,
+		Username:           owner.Username,
+		Role:               owner.Role,
+		MustChangePassword: owner.MustChangePassword,
+		MFAEnabled:         owner.MFAEnabled,
+		AccessTokenID:      pat.ID,
+		Scopes:             make([]rbac.Permission, 0, len(pat.Scopes)),
+	}
+	for _, scope := range pat.Scopes {
+		user.Scopes = append(user.Scopes, rbac.Permission(scope))
+	}
+	if pat.ExpiresAt != nil {
+		user.ExpiresAt = *pat.ExpiresAt
+	}
+
+	return user, true
+}
+
+// checkAccountStatus reports whether user may still sign in, aborting the
+// request with 403 if their account was suspended or deactivated.
+func checkAccountStatus(c *gin.Context, user models.User) bool {
+	switch err := store.CheckAccountStatus(user); {
+	case err == nil:
+		return true
+	case errors.Is(err, store.ErrAccountSuspended):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account is suspended", "code": "account_suspended"})
+	default:
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "account is not active", "code": "account_inactive"})
+	}
+	return false
+}
+
 // GetContextUser extracts the authenticated user from the Gin context.
 func GetContextUser(c *gin.Context) (ContextUser, bool) {
 	value, ok := c.Get(contextUserKey)
@@ -78,3 +412,51 @@ func RequireRoles(roles ...string) gin.HandlerFunc {
 		c.Next()
 	}
 }
+
+// PolicySource provides the policy RequirePermission evaluates. It is consulted
+// on every request so policy changes apply without rebuilding the router.
+type PolicySource interface {
+	Policy() *rbac.Policy
+}
+
+// RequirePermission ensures the authenticated user's role grants perm.
+func RequirePermission(policies PolicySource, perm rbac.Permission) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetContextUser(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+
+		if !policies.Policy().Can(user.Role, perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "insufficient permissions"})
+			return
+		}
+		if !user.HasScope(perm) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "access token lacks the " + string(perm) + " scope"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireSession rejects requests authenticated with a personal access token
+// or as a service account. Routes that manage the account itself use it, so a
+// leaked token cannot be used to mint more tokens or take over the account.
+func RequireSession() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		user, ok := GetContextUser(c)
+		if !ok {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "authentication required"})
+			return
+		}
+
+		if user.Scoped() {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this route requires signing in as a user"})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,545 @@
+package auth_test
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+	"assignment3/backend/internal/store"
+
+	"github.com/gin-gonic/gin"
+)
+
+type fakeDenylist map[string]bool
+
+func (d fakeDenylist) IsAccessTokenRevoked(jti, userID string, issuedAt time.Time) (bool, error) {
+	return d[jti] || d["user:"+userID], nil
+}
+
+func performAuthenticated(t *testing.T, handler gin.HandlerFunc, token string) *httptest.ResponseRecorder {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/protected", handler, func(c *gin.Context) {
+		user, _ := auth.GetContextUser(c)
+		c.JSON(http.StatusOK, gin.H{"jti": user.TokenID})
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/protected", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAuthMiddlewareRejectsRevokedTokens(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	user := models.User{ID: "user-1", Username: "alice", Role: "user"}
+
+	token, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	claims, err := service.ParseToken(token)
+	if err != nil {
+		t.Fatalf("ParseToken returned error: %v", err)
+	}
+
+	denylist := fakeDenylist{}
+	middleware := auth.AuthMiddleware(service, auth.WithDenylist(denylist))
+
+	if rec := performAuthenticated(t, middleware, token); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 before revocation, got %d", rec.Code)
+	}
+
+	denylist[claims.ID] = true
+	if rec := performAuthenticated(t, middleware, token); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after revoking the jti, got %d", rec.Code)
+	}
+
+	delete(denylist, claims.ID)
+	denylist["user:"+user.ID] = true
+	if rec := performAuthenticated(t, middleware, token); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 after revoking the user's tokens, got %d", rec.Code)
+	}
+}
+
+type fakeUsers struct {
+	users   map[string]models.User
+	lookups int
+}
+
+func (f *fakeUsers) GetUser(id string) (models.User, error) {
+	f.lookups++
+	user, ok := f.users[id]
+	if !ok {
+		return models.User{}, store.ErrUserNotFound
+	}
+	return user, nil
+}
+
+func TestAuthMiddlewareRevalidatesSubject(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	user := models.User{ID: "user-1", Username: "alice", Role: "admin"}
+
+	token, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+
+	users := &fakeUsers{users: map[string]models.User{user.ID: {ID: user.ID, Username: "alice", Role: "user"}}}
+	middleware := auth.AuthMiddleware(service, auth.WithUserResolver(users))
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin", middleware, auth.RequireRoles("admin"), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	req := httptest.NewRequest(http.MethodGet, "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected demoted admin to be refused, got %d", rec.Code)
+	}
+
+	delete(users.users, user.ID)
+	if rec := performAuthenticated(t, middleware, token); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 for a deleted account, got %d", rec.Code)
+	}
+
+	// Stateless mode keeps trusting the claims.
+	if rec := performAuthenticated(t, auth.AuthMiddleware(service), token); rec.Code != http.StatusOK {
+		t.Fatalf("expected stateless middleware to accept the token, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddlewareRejectsSuspendedAccounts(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	user := models.User{ID: "user-1", Username: "alice", Role: "user"}
+
+	token, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+
+	until := time.Now().Add(time.Hour)
+	suspended := user
+	suspended.Status = models.UserStatusSuspended
+	suspended.SuspendedUntil = &until
+	users := &fakeUsers{users: map[string]models.User{user.ID: suspended}}
+	middleware := auth.AuthMiddleware(service, auth.WithUserResolver(users))
+
+	rec := performAuthenticated(t, middleware, token)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "account_suspended") {
+		t.Fatalf("expected 403 account_suspended, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	lapsed := time.Now().Add(-time.Second)
+	suspended.SuspendedUntil = &lapsed
+	users.users[user.ID] = suspended
+	if rec := performAuthenticated(t, middleware, token); rec.Code != http.StatusOK {
+		t.Fatalf("expected a lapsed suspension to let the user in, got %d", rec.Code)
+	}
+
+	deactivated := user
+	deactivated.Status = models.UserStatusDeactivated
+	users.users[user.ID] = deactivated
+	rec = performAuthenticated(t, middleware, token)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "account_inactive") {
+		t.Fatalf("expected 403 account_inactive, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestAuthMiddlewareEnforcesPasswordChange(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	user := models.User{ID: "user-1", Username: "alice", Role: "user", MustChangePassword: true}
+
+	token, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+
+	rec := performAuthenticated(t, auth.AuthMiddleware(service), token)
+	if rec.Code != http.StatusForbidden {
+		t.Fatalf("expected 403 while a password change is pending, got %d", rec.Code)
+	}
+	if rec := performAuthenticated(t, auth.AuthMiddleware(service, auth.AllowPendingPasswordChange()), token); rec.Code != http.StatusOK {
+		t.Fatalf("expected the exempt middleware to accept the token, got %d", rec.Code)
+	}
+
+	// With re-validation the stored flag wins over the claim in both directions.
+	users := &fakeUsers{users: map[string]models.User{user.ID: {ID: user.ID, Username: "alice", Role: "user"}}}
+	if rec := performAuthenticated(t, auth.AuthMiddleware(service, auth.WithUserResolver(users)), token); rec.Code != http.StatusOK {
+		t.Fatalf("expected a cleared flag to lift the restriction, got %d", rec.Code)
+	}
+	user.MustChangePassword = false
+	clean, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	users.users[user.ID] = models.User{ID: user.ID, Username: "alice", Role: "user", MustChangePassword: true}
+	if rec := performAuthenticated(t, auth.AuthMiddleware(service, auth.WithUserResolver(users)), clean); rec.Code != http.StatusForbidden {
+		t.Fatalf("expected a reset password to restrict existing tokens, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddlewareEnforcesMFASetup(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	policy, err := rbac.DefaultPolicy().WithMFARequired("admin")
+	if err != nil {
+		t.Fatalf("WithMFARequired returned error: %v", err)
+	}
+	policies := staticPolicy{policy}
+	admin := models.User{ID: "admin-1", Username: "root", Role: "admin"}
+
+	token, err := service.GenerateToken(admin)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	rec := performAuthenticated(t, auth.AuthMiddleware(service, auth.WithMFAPolicy(policies)), token)
+	if rec.Code != http.StatusForbidden || !strings.Contains(rec.Body.String(), "mfa_setup_required") {
+		t.Fatalf("expected 403 mfa_setup_required, got %d %s", rec.Code, rec.Body.String())
+	}
+	if rec := performAuthenticated(t, auth.AuthMiddleware(service, auth.WithMFAPolicy(policies), auth.AllowPendingMFASetup()), token); rec.Code != http.StatusOK {
+		t.Fatalf("expected the exempt middleware to accept the token, got %d", rec.Code)
+	}
+
+	// Roles without the requirement are unaffected.
+	userToken, err := service.GenerateToken(models.User{ID: "user-1", Username: "alice", Role: "user"})
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	if rec := performAuthenticated(t, auth.AuthMiddleware(service, auth.WithMFAPolicy(policies)), userToken); rec.Code != http.StatusOK {
+		t.Fatalf("expected users to pass, got %d", rec.Code)
+	}
+
+	// Once enrolled, the stored flag or the claim lets the admin through.
+	users := &fakeUsers{users: map[string]models.User{admin.ID: {ID: admin.ID, Username: "root", Role: "admin", MFAEnabled: true}}}
+	if rec := performAuthenticated(t, auth.AuthMiddleware(service, auth.WithMFAPolicy(policies), auth.WithUserResolver(users)), token); rec.Code != http.StatusOK {
+		t.Fatalf("expected an enrolled admin to pass, got %d", rec.Code)
+	}
+	admin.MFAEnabled = true
+	enrolled, err := service.GenerateToken(admin)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	if rec := performAuthenticated(t, auth.AuthMiddleware(service, auth.WithMFAPolicy(policies)), enrolled); rec.Code != http.StatusOK {
+		t.Fatalf("expected the mfa claim to be trusted in stateless mode, got %d", rec.Code)
+	}
+}
+
+func TestAuthMiddlewareAcceptsPersonalAccessTokens(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	st := store.NewStore()
+	alice, err := st.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatalf("CreateUser returned error: %v", err)
+	}
+	token, prefix, err := auth.GenerateAccessToken()
+	if err != nil {
+		t.Fatalf("GenerateAccessToken returned error: %v", err)
+	}
+	if !auth.IsAccessToken(token) || !strings.HasPrefix(token, prefix) {
+		t.Fatalf("expected a pat_ token starting with its prefix, got %q / %q", token, prefix)
+	}
+	pat, err := st.CreateAccessToken(alice.ID, "ci", prefix, auth.HashToken(token), []string{"items:read"}, nil)
+	if err != nil {
+		t.Fatalf("CreateAccessToken returned error: %v", err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	middleware := auth.AuthMiddleware(service, auth.WithAccessTokens(st))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/read", middleware, auth.RequirePermission(st, rbac.ItemsRead), ok)
+	router.GET("/create", middleware, auth.RequirePermission(st, rbac.ItemsCreate), ok)
+	router.GET("/account", middleware, auth.RequireSession(), ok)
+	perform := func(path, bearer string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+bearer)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	if code := perform("/read", token); code != http.StatusOK {
+		t.Fatalf("expected the token to be accepted, got %d", code)
+	}
+	if code := perform("/create", token); code != http.StatusForbidden {
+		t.Fatalf("expected a permission outside the scopes to be refused, got %d", code)
+	}
+	if code := perform("/account", token); code != http.StatusForbidden {
+		t.Fatalf("expected session-only routes to refuse the token, got %d", code)
+	}
+	if code := perform("/read", auth.AccessTokenPrefix+"unknown"); code != http.StatusUnauthorized {
+		t.Fatalf("expected an unknown token to be rejected, got %d", code)
+	}
+
+	// JWTs keep working alongside access tokens.
+	jwt, err := service.GenerateToken(alice)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	if code := perform("/create", jwt); code != http.StatusOK {
+		t.Fatalf("expected a session token to carry every role permission, got %d", code)
+	}
+	if code := perform("/account", jwt); code != http.StatusOK {
+		t.Fatalf("expected a session token on session-only routes, got %d", code)
+	}
+
+	if err := st.DeleteAccessToken(alice.ID, pat.ID); err != nil {
+		t.Fatalf("DeleteAccessToken returned error: %v", err)
+	}
+	if code := perform("/read", token); code != http.StatusUnauthorized {
+		t.Fatalf("expected a revoked token to be rejected, got %d", code)
+	}
+}
+
+func TestAuthMiddlewareScopesServiceAccountTokens(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	policy, err := rbac.DefaultPolicy().WithMFARequired("admin")
+	if err != nil {
+		t.Fatalf("WithMFARequired returned error: %v", err)
+	}
+	policies := staticPolicy{policy}
+	bot := models.User{ID: "svc-1", Username: "deploy-bot", Role: "admin", ServiceAccount: true, Scopes: []string{"items:read"}}
+	token, err := service.GenerateToken(bot)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	claims, err := service.ParseToken(token)
+	if err != nil || !claims.ServiceAccount || claims.Scope != "items:read" {
+		t.Fatalf("expected service account claims, got %+v (err %v)", claims, err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	middleware := auth.AuthMiddleware(service, auth.WithMFAPolicy(policies))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	router.GET("/read", middleware, auth.RequirePermission(policies, rbac.ItemsRead), ok)
+	router.GET("/delete", middleware, auth.RequirePermission(policies, rbac.ItemsDelete), ok)
+	router.GET("/account", middleware, auth.RequireSession(), ok)
+	perform := func(path string) int {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// The admin role's MFA requirement does not apply to service accounts.
+	if code := perform("/read"); code != http.StatusOK {
+		t.Fatalf("expected a granted scope to pass, got %d", code)
+	}
+	if code := perform("/delete"); code != http.StatusForbidden {
+		t.Fatalf("expected a role permission outside the scopes to be refused, got %d", code)
+	}
+	if code := perform("/account"); code != http.StatusForbidden {
+		t.Fatalf("expected session-only routes to refuse service accounts, got %d", code)
+	}
+}
+
+func TestAuthMiddlewareAcceptsSessionCookies(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	token, err := service.GenerateToken(models.User{ID: "user-1", Username: "alice", Role: "user"})
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	cookies := auth.DefaultCookieConfig()
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(auth.AuthMiddleware(service, auth.WithSessionCookies(cookies)))
+	ok := func(c *gin.Context) { c.Status(http.StatusNoContent) }
+	router.GET("/items", ok)
+	router.POST("/items", ok)
+
+	perform := func(method, sessionCookie, csrfCookie, csrfHeader string) int {
+		req := httptest.NewRequest(method, "/items", nil)
+		if sessionCookie != "" {
+			req.AddCookie(&http.Cookie{Name: cookies.AccessName, Value: sessionCookie})
+		}
+		if csrfCookie != "" {
+			req.AddCookie(&http.Cookie{Name: cookies.CSRFName, Value: csrfCookie})
+		}
+		if csrfHeader != "" {
+			req.Header.Set(auth.CSRFHeader, csrfHeader)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	cases := []struct {
+		name                            string
+		method, session, cookie, header string
+		want                            int
+	}{
+		{"safe method needs no CSRF token", http.MethodGet, token, "", "", http.StatusNoContent},
+		{"matching CSRF token", http.MethodPost, token, "csrf-1", "csrf-1", http.StatusNoContent},
+		{"missing CSRF header", http.MethodPost, token, "csrf-1", "", http.StatusForbidden},
+		{"missing CSRF cookie", http.MethodPost, token, "", "csrf-1", http.StatusForbidden},
+		{"mismatched CSRF token", http.MethodPost, token, "csrf-1", "csrf-2", http.StatusForbidden},
+		{"invalid session cookie", http.MethodGet, "not-a-token", "", "", http.StatusUnauthorized},
+		{"no credentials", http.MethodGet, "", "", "", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		if got := perform(tc.method, tc.session, tc.cookie, tc.header); got != tc.want {
+			t.Fatalf("%s: expected %d, got %d", tc.name, tc.want, got)
+		}
+	}
+
+	// Bearer tokens take precedence and need no CSRF token.
+	req := httptest.NewRequest(http.MethodPost, "/items", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	req.AddCookie(&http.Cookie{Name: cookies.AccessName, Value: "not-a-token"})
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNoContent {
+		t.Fatalf("expected bearer token to be used, got %d", rec.Code)
+	}
+
+	// Without the option the cookie is ignored.
+	plain := gin.New()
+	plain.GET("/items", auth.AuthMiddleware(service), ok)
+	req = httptest.NewRequest(http.MethodGet, "/items", nil)
+	req.AddCookie(&http.Cookie{Name: cookies.AccessName, Value: token})
+	rec = httptest.NewRecorder()
+	plain.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected cookie to be ignored without WithSessionCookies, got %d", rec.Code)
+	}
+}
+
+type fakeSessions map[string]models.Session
+
+func (f fakeSessions) UseSession(id, ip string) (models.Session, error) {
+	session, ok := f[id]
+	if !ok || session.RevokedAt != nil {
+		return models.Session{}, store.ErrSessionInvalid
+	}
+	session.IP = ip
+	f[id] = session
+	return session, nil
+}
+
+func TestAuthMiddlewareRejectsSignedOutSessions(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	user := models.User{ID: "user-1", Username: "alice", Role: "user"}
+
+	token, err := service.GenerateSessionToken(user, "session-1")
+	if err != nil {
+		t.Fatalf("GenerateSessionToken returned error: %v", err)
+	}
+	claims, err := service.ParseToken(token)
+	if err != nil {
+		t.Fatalf("ParseToken returned error: %v", err)
+	}
+	if claims.SessionID != "session-1" {
+		t.Fatalf("expected the token to carry its session, got %q", claims.SessionID)
+	}
+
+	sessions := fakeSessions{"session-1": {ID: "session-1", UserID: user.ID}}
+	middleware := auth.AuthMiddleware(service, auth.WithSessions(sessions))
+
+	if rec := performAuthenticated(t, middleware, token); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for an active session, got %d", rec.Code)
+	}
+	if sessions["session-1"].IP == "" {
+		t.Fatalf("expected the client address to be recorded")
+	}
+
+	// Tokens issued without a session, e.g. to service accounts, are not
+	// looked up.
+	plain, err := service.GenerateToken(user)
+	if err != nil {
+		t.Fatalf("GenerateToken returned error: %v", err)
+	}
+	if rec := performAuthenticated(t, middleware, plain); rec.Code != http.StatusOK {
+		t.Fatalf("expected 200 for a token without a session, got %d", rec.Code)
+	}
+
+	now := time.Now()
+	session := sessions["session-1"]
+	session.RevokedAt = &now
+	sessions["session-1"] = session
+	if rec := performAuthenticated(t, middleware, token); rec.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401 once the session is signed out, got %d", rec.Code)
+	}
+}
+
+func TestCachedUserResolver(t *testing.T) {
+	users := &fakeUsers{users: map[string]models.User{"user-1": {ID: "user-1", Role: "admin"}}}
+	cache := auth.NewCachedUserResolver(users, time.Minute)
+
+	for i := 0; i < 3; i++ {
+		if _, err := cache.GetUser("user-1"); err != nil {
+			t.Fatalf("GetUser returned error: %v", err)
+		}
+	}
+	if users.lookups != 1 {
+		t.Fatalf("expected a single store lookup within the TTL, got %d", users.lookups)
+	}
+
+	users.users["user-1"] = models.User{ID: "user-1", Role: "user"}
+	cache.Invalidate("user-1")
+	got, err := cache.GetUser("user-1")
+	if err != nil {
+		t.Fatalf("GetUser returned error: %v", err)
+	}
+	if got.Role != "user" || users.lookups != 2 {
+		t.Fatalf("expected invalidation to reload the user, got %+v after %d lookups", got, users.lookups)
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := cache.GetUser("missing"); !errors.Is(err, store.ErrUserNotFound) {
+			t.Fatalf("expected ErrUserNotFound, got %v", err)
+		}
+	}
+	if users.lookups != 3 {
+		t.Fatalf("expected missing users to be cached too, got %d lookups", users.lookups)
+	}
+
+	uncached := auth.NewCachedUserResolver(users, 0)
+	_, _ = uncached.GetUser("user-1")
+	_, _ = uncached.GetUser("user-1")
+	if users.lookups != 5 {
+		t.Fatalf("expected a zero TTL to disable caching, got %d lookups", users.lookups)
+	}
+}
+
+type staticPolicy struct{ policy *rbac.Policy }
+
+func (s staticPolicy) Policy() *rbac.Policy { return s.policy }
+
+func TestRequirePermission(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	guard := auth.RequirePermission(staticPolicy{rbac.DefaultPolicy()}, rbac.ItemsDelete)
+
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.DELETE("/items", auth.AuthMiddleware(service), guard, func(c *gin.Context) {
+		c.Status(http.StatusNoContent)
+	})
+
+	for role, want := range map[string]int{"admin": http.StatusNoContent, "user": http.StatusForbidden} {
+		token, err := service.GenerateToken(models.User{ID: role, Username: role, Role: role})
+		if err != nil {
+			t.Fatalf("GenerateToken returned error: %v", err)
+		}
+		req := httptest.NewRequest(http.MethodDelete, "/items", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Fatalf("%s: expected %d, got %d", role, want, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,568 @@
+package auth
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCLoginExpiry bounds how long a user has to sign in at the identity
+// provider before the login ticket expires.
+const OIDCLoginExpiry = 10 * time.Minute
+
+// oidcKeyRefreshInterval limits how often an unknown key ID triggers a new
+// fetch of the provider's signing keys.
+const oidcKeyRefreshInterval = time.Minute
+
+// oidcMaxResponseBytes caps the size of documents read from the provider.
+const oidcMaxResponseBytes = 1 << 20
+
+// purposeOIDCLogin marks the tickets issued by GenerateOIDCTicket.
+const purposeOIDCLogin = "oidc_login"
+
+var (
+	// ErrOIDCUnavailable is returned when the identity provider cannot be
+	// reached or answers with a server error.
+	ErrOIDCUnavailable = errors.New("identity provider is unavailable")
+	// ErrOIDCRejected is returned when the provider refuses to exchange an
+	// authorization code, e.g. because it expired or was already used.
+	ErrOIDCRejected = errors.New("identity provider rejected the sign-in")
+	// ErrInvalidIDToken is returned for ID tokens that fail verification.
+	ErrInvalidIDToken = errors.New("invalid ID token")
+	// ErrOIDCStateMismatch is returned when the state the provider sent back
+	// is not the one the login ticket was issued for.
+	ErrOIDCStateMismatch = errors.New("sign-in state does not match")
+)
+
+// OIDCConfig registers this service as a client of an OpenID Connect
+// provider.
+type OIDCConfig struct {
+	// Issuer is the provider's issuer URL. Its discovery document is read
+	// from Issuer/.well-known/openid-configuration.
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	// RedirectURL receives the authorization code and must be registered
+	// with the provider.
+	RedirectURL string
+	// Scopes are requested in addition to openid; profile and email by
+	// default.
+	Scopes []string
+	// Name is shown to users on the sign-in button.
+	Name string
+	// HTTPClient makes the requests to the provider; a client with a ten
+	// second timeout by default.
+	HTTPClient *http.Client
+}
+
+type oidcDiscovery struct {
+	Issuer                string   `json:"issuer"`
+	AuthorizationEndpoint string   `json:"authorization_endpoint"`
+	TokenEndpoint         string   `json:"token_endpoint"`
+	JWKSURI               string   `json:"jwks_uri"`
+	TokenAuthMethods      []string `json:"token_endpoint_auth_methods_supported"`
+}
+
+// OIDCProvider signs users in with the authorization code flow and PKCE.
+// Discovery happens on first use and is retried until it succeeds. Signing
+// keys are fetched again when a token names a key that is not known yet, so
+// key rotation at the provider needs no restart.
+type OIDCProvider struct {
+	cfg    OIDCConfig
+	client *http.Client
+
+	mu            sync.Mutex
+	discovery     *oidcDiscovery
+	keys          map[string]any
+	keysFetchedAt time.Time
+}
+
+// NewOIDCProvider returns a provider for cfg without contacting it.
+func NewOIDCProvider(cfg OIDCConfig) *OIDCProvider {
+	if len(cfg.Scopes) == 0 {
+		cfg.Scopes = []string{"profile", "email"}
+	}
+	if cfg.Name == "" {
+		cfg.Name = "SSO"
+	}
+	client := cfg.HTTPClient
+	if client == nil {
+		client = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &OIDCProvider{cfg: cfg, client: client}
+}
+
+// Name returns the display name of the provider.
+func (p *OIDCProvider) Name() string {
+	return p.cfg.Name
+}
+
+// Issuer returns the provider's issuer URL.
+func (p *OIDCProvider) Issuer() string {
+	return p.cfg.Issuer
+}
+
+// OIDCLogin holds the secrets of one sign-in attempt. State and Nonce bind
+// the provider's response to the attempt, and Verifier is the PKCE code
+// verifier. LinkUserID is set when the attempt links an identity to a user
+// who is already signed in.
+type OIDCLogin struct {
+	State      string
+	Nonce      string
+	Verifier   string
+	LinkUserID string
+}
+
+// NewOIDCLogin generates the secrets for a sign-in attempt.
+func NewOIDCLogin(linkUserID string) (OIDCLogin, error) {
+	login := OIDCLogin{LinkUserID: linkUserID}
+	for _, field := range []*string{&login.State, &login.Nonce, &login.Verifier} {
+		value, err := GenerateOpaqueToken()
+		if err != nil {
+			return OIDCLogin{}, err
+		}
+		*field = value
+	}
+	return login, nil
+}
+
+// AuthCodeURL returns the provider URL the browser is sent to for login.
+func (p *OIDCProvider) AuthCodeURL(login OIDCLogin) (string, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return "", err
+	}
+	endpoint, err := url.Parse(discovery.AuthorizationEndpoint)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid authorization endpoint", ErrOIDCUnavailable)
+	}
+
+	challenge := sha256.Sum256([]byte(login.Verifier))
+	query := endpoint.Query()
+	query.Set("response_type", "code")
+	query.Set("client_id", p.cfg.ClientID)
+	query.Set("redirect_uri", p.cfg.RedirectURL)
+	query.Set("scope", strings.Join(append([]string{"openid"}, p.cfg.Scopes...), " "))
+	query.Set("state", login.State)
+	query.Set("nonce", login.Nonce)
+	query.Set("code_challenge", base64.RawURLEncoding.EncodeToString(challenge[:]))
+	query.Set("code_challenge_method", "S256")
+	endpoint.RawQuery = query.Encode()
+	return endpoint.String(), nil
+}
+
+// Exchange redeems an authorization code for the user's verified identity.
+func (p *OIDCProvider) Exchange(code string, login OIDCLogin) (*IDTokenClaims, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.cfg.RedirectURL},
+		"code_verifier": {login.Verifier},
+		"client_id":     {p.cfg.ClientID},
+	}
+	// client_secret_basic is the default unless the provider only supports
+	// sending the secret in the form.
+	postSecret := p.cfg.ClientSecret != "" && len(discovery.TokenAuthMethods) > 0 &&
+		!slices.Contains(discovery.TokenAuthMethods, "client_secret_basic") &&
+		slices.Contains(discovery.TokenAuthMethods, "client_secret_post")
+	if postSecret {
+		form.Set("client_secret", p.cfg.ClientSecret)
+	}
+	req, err := http.NewRequest(http.MethodPost, discovery.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCUnavailable, err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	if p.cfg.ClientSecret != "" && !postSecret {
+		req.SetBasicAuth(url.QueryEscape(p.cfg.ClientID), url.QueryEscape(p.cfg.ClientSecret))
+	}
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &body)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		if body.Error == "" {
+			body.Error = http.StatusText(status)
+		}
+		return nil, fmt.Errorf("%w: %s %s", ErrOIDCRejected, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return nil, fmt.Errorf("%w: token response has no id_token", ErrOIDCRejected)
+	}
+	return p.VerifyIDToken(body.IDToken, login.Nonce)
+}
+
+// IDTokenClaims is the identity asserted by a verified ID token. Claims holds
+// every claim of the token, for mapping provider attributes to roles.
+type IDTokenClaims struct {
+	Issuer            string
+	Subject           string
+	Email             string
+	EmailVerified     bool
+	PreferredUsername string
+	Name              string
+	Claims            map[string]any
+}
+
+// VerifyIDToken checks an ID token's signature, issuer, audience, lifetime
+// and nonce.
+func (p *OIDCProvider) VerifyIDToken(raw, nonce string) (*IDTokenClaims, error) {
+	discovery, err := p.discover()
+	if err != nil {
+		return nil, err
+	}
+
+	claims := jwt.MapClaims{}
+	_, err = jwt.ParseWithClaims(raw, claims, p.keyFunc,
+		jwt.WithValidMethods([]string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512", "EdDSA"}),
+		jwt.WithIssuer(discovery.Issuer),
+		jwt.WithAudience(p.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		if errors.Is(err, ErrOIDCUnavailable) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+
+	if got, _ := claims["nonce"].(string); subtle.ConstantTimeCompare([]byte(got), []byte(nonce)) != 1 {
+		return nil, fmt.Errorf("%w: nonce does not match", ErrInvalidIDToken)
+	}
+	// A token issued to several clients must name this one as its holder.
+	if audience, _ := claims.GetAudience(); len(audience) > 1 {
+		if azp, _ := claims["azp"].(string); azp != p.cfg.ClientID {
+			return nil, fmt.Errorf("%w: token was issued to another client", ErrInvalidIDToken)
+		}
+	}
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: subject is missing", ErrInvalidIDToken)
+	}
+
+	identity := &IDTokenClaims{Issuer: discovery.Issuer, Subject: subject, Claims: claims}
+	identity.Email, _ = claims["email"].(string)
+	identity.PreferredUsername, _ = claims["preferred_username"].(string)
+	identity.Name, _ = claims["name"].(string)
+	// Some providers send email_verified as a string.
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.EmailVerified = verified
+	case string:
+		identity.EmailVerified = verified == "true"
+	}
+	return identity, nil
+}
+
+func (p *OIDCProvider) keyFunc(token *jwt.Token) (any, error) {
+	kid, _ := token.Header["kid"].(string)
+	return p.signingKey(kid)
+}
+
+// signingKey returns the provider key with kid, refetching the key set if it
+// is unknown. Tokens without kid are accepted while the provider has a single
+// key.
+func (p *OIDCProvider) signingKey(kid string) (any, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if key, ok := p.lookupKeyLocked(kid); ok {
+		return key, nil
+	}
+	if p.keys != nil && time.Since(p.keysFetchedAt) < oidcKeyRefreshInterval {
+		return nil, fmt.Errorf("unknown signing key: %q", kid)
+	}
+	if err := p.fetchKeysLocked(); err != nil {
+		return nil, err
+	}
+	if key, ok := p.lookupKeyLocked(kid); ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key: %q", kid)
+}
+
+func (p *OIDCProvider) lookupKeyLocked(kid string) (any, bool) {
+	if kid == "" && len(p.keys) == 1 {
+		for _, key := range p.keys {
+			return key, true
+		}
+	}
+	key, ok := p.keys[kid]
+	return key, ok
+}
+
+func (p *OIDCProvider) fetchKeysLocked() error {
+	req, err := http.NewRequest(http.MethodGet, p.discovery.JWKSURI, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrOIDCUnavailable, err)
+	}
+	var set JWKS
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return err
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("%w: key set request returned %d", ErrOIDCUnavailable, status)
+	}
+
+	keys := make(map[string]any, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped rather than failing the set.
+		if key, err := jwk.PublicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+	p.keys = keys
+	p.keysFetchedAt = time.Now()
+	return nil
+}
+
+// discover returns the provider metadata, fetching it on first use.
+func (p *OIDCProvider) discover() (*oidcDiscovery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.discovery != nil {
+		return p.discovery, nil
+	}
+	req, err := http.NewRequest(http.MethodGet, strings.TrimSuffix(p.cfg.Issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCUnavailable, err)
+	}
+	var discovery oidcDiscovery
+	status, err := p.doJSON(req, &discovery)
+	if err != nil {
+		return nil, err
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("%w: discovery returned %d", ErrOIDCUnavailable, status)
+	}
+	// The issuer must match exactly, or tokens from another tenant of the
+	// same provider could be accepted.
+	if discovery.Issuer != p.cfg.Issuer {
+		return nil, fmt.Errorf("%w: discovery issuer %q does not match %q", ErrOIDCUnavailable, discovery.Issuer, p.cfg.Issuer)
+	}
+	if discovery.AuthorizationEndpoint == "" || discovery.TokenEndpoint == "" || discovery.JWKSURI == "" {
+		return nil, fmt.Errorf("%w: discovery document is incomplete", ErrOIDCUnavailable)
+	}
+	p.discovery = &discovery
+	return p.discovery, nil
+}
+
+// doJSON performs req and decodes a JSON body into v. Server errors and
+// network failures are reported as ErrOIDCUnavailable; other statuses are
+// returned to the caller.
+func (p *OIDCProvider) doJSON(req *http.Request, v any) (int, error) {
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrOIDCUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= http.StatusInternalServerError {
+		return resp.StatusCode, fmt.Errorf("%w: %s returned %d", ErrOIDCUnavailable, req.URL.Path, resp.StatusCode)
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, oidcMaxResponseBytes)).Decode(v); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("%w: malformed response from %s: %v", ErrOIDCUnavailable, req.URL.Path, err)
+	}
+	return resp.StatusCode, nil
+}
+
+// PublicKey decodes the verification key described by a JWK.
+func (k JWK) PublicKey() (any, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil || len(n) == 0 {
+			return nil, fmt.Errorf("invalid RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) == 0 || len(e) > 4 {
+			return nil, fmt.Errorf("invalid RSA exponent")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, errX := base64.RawURLEncoding.DecodeString(k.X)
+		y, errY := base64.RawURLEncoding.DecodeString(k.Y)
+		if errX != nil || errY != nil {
+			return nil, fmt.Errorf("invalid EC point")
+		}
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("invalid EC point")
+		}
+		return key, nil
+	case "OKP":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if k.Curve != "Ed25519" || err != nil || len(x) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("invalid Ed25519 key")
+		}
+		return ed25519.PublicKey(x), nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
+
+// oidcTicketClaims carry an OIDCLogin between starting the sign-in and the
+// provider's redirect back. The subject is the user linking an identity.
+type oidcTicketClaims struct {
+	Purpose  string `json:"purpose"`
+	State    string `json:"state"`
+	Nonce    string `json:"nonce"`
+	Verifier string `json:"verifier"`
+	jwt.RegisteredClaims
+}
+
+// GenerateOIDCTicket signs login into a short-lived ticket that the client
+// keeps until the provider redirects back, so that no server-side state is
+// needed. It is rejected as an access token.
+func (j *JWTService) GenerateOIDCTicket(login OIDCLogin) (string, error) {
+	now := time.Now().UTC()
+	return j.sign(oidcTicketClaims{
+		Purpose:  purposeOIDCLogin,
+		State:    login.State,
+		Nonce:    login.Nonce,
+		Verifier: login.Verifier,
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    j.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(OIDCLoginExpiry)),
+			Subject:   login.LinkUserID,
+		},
+	})
+}
+
+// ParseOIDCTicket validates a ticket issued by GenerateOIDCTicket and checks
+// that state is the one it was issued for.
+func (j *JWTService) ParseOIDCTicket(ticket, state string) (OIDCLogin, error) {
+	var claims oidcTicketClaims
+	if err := j.parseInto(ticket, &claims); err != nil {
+		return OIDCLogin{}, err
+	}
+	if claims.Purpose != purposeOIDCLogin {
+		return OIDCLogin{}, ErrWrongTokenPurpose
+	}
+	if subtle.ConstantTimeCompare([]byte(claims.State), []byte(state)) != 1 {
+		return OIDCLogin{}, ErrOIDCStateMismatch
+	}
+	return OIDCLogin{
+		State:      claims.State,
+		Nonce:      claims.Nonce,
+		Verifier:   claims.Verifier,
+		LinkUserID: claims.Subject,
+	}, nil
+}
+
+// ClaimRoleMapping derives a role from an ID token claim such as groups. The
+// claim may hold a string or a list of strings, and nested claims are named
+// with dots, e.g. realm_access.roles. The first rule whose value the claim
+// contains decides the role.
+type ClaimRoleMapping struct {
+	Claim string
+	Rules []ClaimRoleRule
+}
+
+// ClaimRoleRule grants Role to users whose claim contains Value.
+type ClaimRoleRule struct {
+	Value string
+	Role  string
+}
+
+// ParseClaimRoleMapping reads rules written as value=role pairs separated by
+// commas, e.g. "admins=admin,engineering=editor".
+func ParseClaimRoleMapping(claim, rules string) (ClaimRoleMapping, error) {
+	mapping := ClaimRoleMapping{Claim: strings.TrimSpace(claim)}
+	for _, rule := range strings.Split(rules, ",") {
+		if rule = strings.TrimSpace(rule); rule == "" {
+			continue
+		}
+		value, role, ok := strings.Cut(rule, "=")
+		value, role = strings.TrimSpace(value), strings.TrimSpace(role)
+		if !ok || value == "" || role == "" {
+			return ClaimRoleMapping{}, fmt.Errorf("invalid role mapping %q, expected value=role", rule)
+		}
+		mapping.Rules = append(mapping.Rules, ClaimRoleRule{Value: value, Role: role})
+	}
+	if len(mapping.Rules) > 0 && mapping.Claim == "" {
+		return ClaimRoleMapping{}, fmt.Errorf("role mapping needs a claim")
+	}
+	return mapping, nil
+}
+
+// Role returns the role the claims map to, or "" if no rule matches.
+func (m ClaimRoleMapping) Role(identity *IDTokenClaims) string {
+	if m.Claim == "" || identity == nil {
+		return ""
+	}
+	var value any = map[string]any(identity.Claims)
+	for _, part := range strings.Split(m.Claim, ".") {
+		object, ok := value.(map[string]any)
+		if !ok {
+			return ""
+		}
+		value = object[part]
+	}
+
+	var values []string
+	switch v := value.(type) {
+	case string:
+		values = []string{v}
+	case []any:
+		for _, entry := range v {
+			if s, ok := entry.(string); ok {
+				values = append(values, s)
+			}
+		}
+	}
+	for _, rule := range m.Rules {
+		if slices.Contains(values, rule.Value) {
+			return rule.Role
+		}
+	}
+	return ""
+}
@@ -0,0 +1,341 @@
+package auth_test
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"assignment3/backend/internal/auth"
+	"assignment3/backend/internal/models"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// mockIssuer is a minimal OpenID Connect provider. Authorize stands in for
+// the user signing in at the provider and returns the code it redirects with.
+type mockIssuer struct {
+	t      *testing.T
+	server *httptest.Server
+	key    *rsa.PrivateKey
+	kid    string
+
+	mu    sync.Mutex
+	codes map[string]mockGrant
+	// keyFetches counts requests for the key set.
+	keyFetches int
+}
+
+type mockGrant struct {
+	challenge string
+	nonce     string
+}
+
+func newMockIssuer(t *testing.T) *mockIssuer {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	m := &mockIssuer{t: t, key: key, kid: "key-1", codes: make(map[string]mockGrant)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"issuer":                 m.server.URL,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/keys",
+		})
+	})
+	mux.HandleFunc("/keys", func(w http.ResponseWriter, r *http.Request) {
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.keyFetches++
+		_ = json.NewEncoder(w).Encode(auth.JWKS{Keys: []auth.JWK{{
+			KeyType:   "RSA",
+			KeyID:     m.kid,
+			Use:       "sig",
+			Algorithm: "RS256",
+			N:         base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+			E:         base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+		}}})
+	})
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+func (m *mockIssuer) provider() *auth.OIDCProvider {
+	return auth.NewOIDCProvider(auth.OIDCConfig{
+		Issuer:       m.server.URL,
+		ClientID:     "app",
+		ClientSecret: "app-secret",
+		RedirectURL:  "http://localhost:3000/",
+	})
+}
+
+// authorize validates the authorization request like a provider would and
+// returns the code it issues.
+func (m *mockIssuer) authorize(authURL string) string {
+	m.t.Helper()
+	parsed, err := url.Parse(authURL)
+	if err != nil {
+		m.t.Fatalf("invalid authorization URL: %v", err)
+	}
+	query := parsed.Query()
+	if query.Get("response_type") != "code" || query.Get("client_id") != "app" || query.Get("code_challenge_method") != "S256" {
+		m.t.Fatalf("unexpected authorization request %s", authURL)
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code := "code-" + query.Get("state")[:8]
+	m.codes[code] = mockGrant{challenge: query.Get("code_challenge"), nonce: query.Get("nonce")}
+	return code
+}
+
+func (m *mockIssuer) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != "app" || secret != "app-secret" {
+		w.WriteHeader(http.StatusUnauthorized)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	m.mu.Lock()
+	grant, ok := m.codes[r.PostFormValue("code")]
+	delete(m.codes, r.PostFormValue("code"))
+	m.mu.Unlock()
+	sum := sha256.Sum256([]byte(r.PostFormValue("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != grant.challenge {
+		w.WriteHeader(http.StatusBadRequest)
+		_ = json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	_ = json.NewEncoder(w).Encode(map[string]string{
+		"access_token": "opaque",
+		"token_type":   "Bearer",
+		"id_token":     m.idToken(jwt.MapClaims{"nonce": grant.nonce}),
+	})
+}
+
+// idToken signs an ID token for alice, with claims overriding the defaults.
+func (m *mockIssuer) idToken(claims jwt.MapClaims) string {
+	m.t.Helper()
+	now := time.Now()
+	full := jwt.MapClaims{
+		"iss":                m.server.URL,
+		"aud":                "app",
+		"sub":                "alice-subject",
+		"iat":                now.Unix(),
+		"exp":                now.Add(time.Minute).Unix(),
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"preferred_username": "alice",
+		"groups":             []string{"staff", "engineering"},
+	}
+	m.mu.Lock()
+	key, kid := m.key, m.kid
+	m.mu.Unlock()
+	for k, v := range claims {
+		full[k] = v
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, full)
+	token.Header["kid"] = kid
+	signed, err := token.SignedString(key)
+	if err != nil {
+		m.t.Fatalf("failed to sign ID token: %v", err)
+	}
+	return signed
+}
+
+func TestOIDCAuthorizationCodeFlow(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := issuer.provider()
+
+	login, err := auth.NewOIDCLogin("")
+	if err != nil {
+		t.Fatalf("NewOIDCLogin returned error: %v", err)
+	}
+	authURL, err := provider.AuthCodeURL(login)
+	if err != nil {
+		t.Fatalf("AuthCodeURL returned error: %v", err)
+	}
+	query, _ := url.ParseQuery(authURL[len(issuer.server.URL+"/authorize?"):])
+	if query.Get("state") != login.State || query.Get("nonce") != login.Nonce || query.Get("scope") != "openid profile email" {
+		t.Fatalf("unexpected authorization parameters %v", query)
+	}
+	if query.Get("code_challenge") == login.Verifier {
+		t.Fatalf("expected the verifier itself to stay secret")
+	}
+
+	code := issuer.authorize(authURL)
+	identity, err := provider.Exchange(code, login)
+	if err != nil {
+		t.Fatalf("Exchange returned error: %v", err)
+	}
+	if identity.Issuer != issuer.server.URL || identity.Subject != "alice-subject" || identity.PreferredUsername != "alice" ||
+		identity.Email != "alice@example.com" || !identity.EmailVerified {
+		t.Fatalf("unexpected identity %+v", identity)
+	}
+
+	// Codes are single use, and only the verifier that matches the
+	// challenge redeems one.
+	if _, err := provider.Exchange(code, login); !errors.Is(err, auth.ErrOIDCRejected) {
+		t.Fatalf("expected a spent code to be rejected, got %v", err)
+	}
+	code = issuer.authorize(authURL)
+	stolen := login
+	stolen.Verifier = "guessed"
+	if _, err := provider.Exchange(code, stolen); !errors.Is(err, auth.ErrOIDCRejected) {
+		t.Fatalf("expected a wrong verifier to be rejected, got %v", err)
+	}
+}
+
+func TestOIDCVerifyIDToken(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := issuer.provider()
+
+	if _, err := provider.VerifyIDToken(issuer.idToken(jwt.MapClaims{"nonce": "n"}), "n"); err != nil {
+		t.Fatalf("expected a valid token to verify, got %v", err)
+	}
+
+	cases := map[string]jwt.MapClaims{
+		"wrong nonce":    {"nonce": "other"},
+		"wrong audience": {"nonce": "n", "aud": "another-app"},
+		"wrong issuer":   {"nonce": "n", "iss": "https://evil.example.com"},
+		"expired":        {"nonce": "n", "exp": time.Now().Add(-time.Hour).Unix()},
+		"missing expiry": {"nonce": "n", "exp": nil},
+		"foreign holder": {"nonce": "n", "aud": []string{"app", "another-app"}, "azp": "another-app"},
+		"no subject":     {"nonce": "n", "sub": ""},
+	}
+	for name, claims := range cases {
+		if _, err := provider.VerifyIDToken(issuer.idToken(claims), "n"); !errors.Is(err, auth.ErrInvalidIDToken) {
+			t.Errorf("%s: expected ErrInvalidIDToken, got %v", name, err)
+		}
+	}
+
+	// A token signed with a shared secret must not pass, whatever key it
+	// claims to use.
+	forged := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"iss": issuer.server.URL, "aud": "app", "sub": "alice-subject", "nonce": "n",
+		"exp": time.Now().Add(time.Minute).Unix(),
+	})
+	forged.Header["kid"] = issuer.kid
+	raw, _ := forged.SignedString([]byte("guess"))
+	if _, err := provider.VerifyIDToken(raw, "n"); !errors.Is(err, auth.ErrInvalidIDToken) {
+		t.Fatalf("expected an HMAC token to be rejected, got %v", err)
+	}
+}
+
+func TestOIDCKeyRefreshIsRateLimited(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := issuer.provider()
+	if _, err := provider.VerifyIDToken(issuer.idToken(jwt.MapClaims{"nonce": "n"}), "n"); err != nil {
+		t.Fatalf("VerifyIDToken returned error: %v", err)
+	}
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	issuer.mu.Lock()
+	issuer.key, issuer.kid = key, "key-2"
+	issuer.mu.Unlock()
+
+	// Tokens naming unknown keys must not make every request refetch the
+	// key set; the new key is picked up once the refresh interval passed.
+	for i := 0; i < 3; i++ {
+		if _, err := provider.VerifyIDToken(issuer.idToken(jwt.MapClaims{"nonce": "n"}), "n"); !errors.Is(err, auth.ErrInvalidIDToken) {
+			t.Fatalf("expected an unknown key to be refused within the refresh interval, got %v", err)
+		}
+	}
+	issuer.mu.Lock()
+	fetches := issuer.keyFetches
+	issuer.mu.Unlock()
+	if fetches != 1 {
+		t.Fatalf("expected a single key set fetch, got %d", fetches)
+	}
+
+	if _, err := issuer.provider().VerifyIDToken(issuer.idToken(jwt.MapClaims{"nonce": "n"}), "n"); err != nil {
+		t.Fatalf("expected the rotated key to verify after a fetch, got %v", err)
+	}
+}
+
+func TestOIDCDiscoveryRejectsIssuerMismatch(t *testing.T) {
+	issuer := newMockIssuer(t)
+	provider := auth.NewOIDCProvider(auth.OIDCConfig{Issuer: issuer.server.URL + "/", ClientID: "app"})
+	if _, err := provider.AuthCodeURL(auth.OIDCLogin{}); !errors.Is(err, auth.ErrOIDCUnavailable) {
+		t.Fatalf("expected ErrOIDCUnavailable, got %v", err)
+	}
+}
+
+func TestOIDCTicket(t *testing.T) {
+	service := auth.NewJWTService("secret", "issuer", time.Minute, time.Hour)
+	login, err := auth.NewOIDCLogin("user-1")
+	if err != nil {
+		t.Fatalf("NewOIDCLogin returned error: %v", err)
+	}
+	ticket, err := service.GenerateOIDCTicket(login)
+	if err != nil {
+		t.Fatalf("GenerateOIDCTicket returned error: %v", err)
+	}
+
+	parsed, err := service.ParseOIDCTicket(ticket, login.State)
+	if err != nil || parsed != login {
+		t.Fatalf("expected the ticket to round-trip, got %+v (err %v)", parsed, err)
+	}
+	if _, err := service.ParseOIDCTicket(ticket, "other-state"); !errors.Is(err, auth.ErrOIDCStateMismatch) {
+		t.Fatalf("expected ErrOIDCStateMismatch, got %v", err)
+	}
+	if _, err := service.ParseToken(ticket); err == nil {
+		t.Fatalf("expected a login ticket to be refused as an access token")
+	}
+	access, _ := service.GenerateToken(models.User{ID: "user-1", Username: "alice", Role: "user"})
+	if _, err := service.ParseOIDCTicket(access, ""); !errors.Is(err, auth.ErrWrongTokenPurpose) {
+		t.Fatalf("expected an access token to be refused as a ticket, got %v", err)
+	}
+}
+
+func TestClaimRoleMapping(t *testing.T) {
+	mapping, err := auth.ParseClaimRoleMapping("groups", "admins=admin, engineering=editor")
+	if err != nil {
+		t.Fatalf("ParseClaimRoleMapping returned error: %v", err)
+	}
+	identity := &auth.IDTokenClaims{Claims: map[string]any{"groups": []any{"staff", "engineering"}}}
+	if role := mapping.Role(identity); role != "editor" {
+		t.Fatalf("expected editor, got %q", role)
+	}
+	identity.Claims["groups"] = []any{"engineering", "admins"}
+	if role := mapping.Role(identity); role != "admin" {
+		t.Fatalf("expected the first matching rule to win, got %q", role)
+	}
+	identity.Claims["groups"] = "sales"
+	if role := mapping.Role(identity); role != "" {
+		t.Fatalf("expected no role, got %q", role)
+	}
+
+	nested, err := auth.ParseClaimRoleMapping("realm_access.roles", "app-admin=admin")
+	if err != nil {
+		t.Fatalf("ParseClaimRoleMapping returned error: %v", err)
+	}
+	identity.Claims["realm_access"] = map[string]any{"roles": []any{"app-admin"}}
+	if role := nested.Role(identity); role != "admin" {
+		t.Fatalf("expected nested claims to be followed, got %q", role)
+	}
+
+	if _, err := auth.ParseClaimRoleMapping("groups", "admins"); err == nil {
+		t.Fatalf("expected a rule without a role to be rejected")
+	}
+}
@@ -0,0 +1,82 @@
+package auth
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/store"
+)
+
+// UserResolver looks up the current state of a token's subject. It reports
+// store.ErrUserNotFound for accounts that no longer exist.
+type UserResolver interface {
+	GetUser(id string) (models.User, error)
+}
+
+type cachedUser struct {
+	user      models.User
+	err       error
+	expiresAt time.Time
+}
+
+// CachedUserResolver memoises lookups of another resolver for a short TTL so
+// per-request re-validation does not hit the store every time. Successful
+// lookups and ErrUserNotFound are cached; other errors are not.
+type CachedUserResolver struct {
+	next UserResolver
+	ttl  time.Duration
+	now  func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]cachedUser
+}
+
+// NewCachedUserResolver wraps next with a cache holding entries for ttl. A
+// non-positive ttl disables caching.
+func NewCachedUserResolver(next UserResolver, ttl time.Duration) *CachedUserResolver {
+	return &CachedUserResolver{
+		next:    next,
+		ttl:     ttl,
+		now:     time.Now,
+		entries: make(map[string]cachedUser),
+	}
+}
+
+// GetUser returns the cached user or resolves it through the wrapped resolver.
+func (r *CachedUserResolver) GetUser(id string) (models.User, error) {
+	if r.ttl <= 0 {
+		return r.next.GetUser(id)
+	}
+
+	now := r.now()
+	r.mu.Lock()
+	entry, ok := r.entries[id]
+	r.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.user, entry.err
+	}
+
+	user, err := r.next.GetUser(id)
+	if err != nil && !errors.Is(err, store.ErrUserNotFound) {
+		return models.User{}, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for key, cached := range r.entries {
+		if !now.Before(cached.expiresAt) {
+			delete(r.entries, key)
+		}
+	}
+	r.entries[id] = cachedUser{user: user, err: err, expiresAt: now.Add(r.ttl)}
+	return user, err
+}
+
+// Invalidate drops the cached entry for id so the next request re-reads it.
+func (r *CachedUserResolver) Invalidate(id string) {
+	r.mu.Lock()
+	delete(r.entries, id)
+	r.mu.Unlock()
+}
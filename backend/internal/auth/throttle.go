@@ -0,0 +1,349 @@
+package auth
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of key a LoginThrottle tracks.
+const (
+	ThrottleUsername = "username"
+	ThrottleIP       = "ip"
+)
+
+// ThrottleLimits bounds failed sign-ins for one kind of key. After
+// FreeAttempts failures each further attempt must wait BaseDelay, doubling per
+// failure up to MaxDelay. LockoutAfter failures lock the key for
+// LockoutDuration.
+type ThrottleLimits struct {
+	FreeAttempts    int
+	BaseDelay       time.Duration
+	MaxDelay        time.Duration
+	LockoutAfter    int
+	LockoutDuration time.Duration
+}
+
+// ThrottleConfig configures a LoginThrottle.
+type ThrottleConfig struct {
+	Username ThrottleLimits
+	IP       ThrottleLimits
+	// ResetAfter forgets the failures of a key that has been quiet this long.
+	ResetAfter time.Duration
+	// Now overrides the clock; nil uses time.Now.
+	Now func() time.Time
+}
+
+// DefaultThrottleConfig returns limits suited to interactive sign-in. A single
+// address gets more room than a single account since it may be shared.
+func DefaultThrottleConfig() ThrottleConfig {
+	return ThrottleConfig{
+		Username: ThrottleLimits{
+			FreeAttempts:    3,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			LockoutAfter:    10,
+			LockoutDuration: 15 * time.Minute,
+		},
+		IP: ThrottleLimits{
+			FreeAttempts:    10,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Minute,
+			LockoutAfter:    50,
+			LockoutDuration: 15 * time.Minute,
+		},
+		ResetAfter: 15 * time.Minute,
+	}
+}
+
+// Lockout describes a throttled key.
+type Lockout struct {
+	Kind        string    `json:"kind"`
+	Key         string    `json:"key"`
+	Failures    int       `json:"failures"`
+	LastFailure time.Time `json:"last_failure"`
+	// BlockedUntil is when the next attempt is allowed; nil if it already is.
+	BlockedUntil *time.Time `json:"blocked_until,omitempty"`
+	Locked       bool       `json:"locked"`
+}
+
+type throttleEntry struct {
+	failures     int
+	lastFailure  time.Time
+	blockedUntil time.Time
+	// pending counts the attempts that passed Check and are not settled yet.
+	pending int
+}
+
+// LoginThrottle tracks failed sign-ins per username and per client address
+// and slows down or locks out keys that keep failing. State is kept in memory,
+// so each server process throttles independently and restarts forget it.
+type LoginThrottle struct {
+	cfg ThrottleConfig
+	now func() time.Time
+
+	mu      sync.Mutex
+	entries map[string]*throttleEntry // keyed by kind + "\x00" + key
+}
+
+// NewLoginThrottle returns a throttle enforcing cfg.
+func NewLoginThrottle(cfg ThrottleConfig) *LoginThrottle {
+	now := cfg.Now
+	if now == nil {
+		now = time.Now
+	}
+	return &LoginThrottle{cfg: cfg, now: now, entries: make(map[string]*throttleEntry)}
+}
+
+func throttleKey(kind, key string) string {
+	if kind == ThrottleUsername {
+		key = strings.ToLower(strings.TrimSpace(key))
+	}
+	return kind + "\x00" + key
+}
+
+func (t *LoginThrottle) limits(kind string) ThrottleLimits {
+	if kind == ThrottleIP {
+		return t.cfg.IP
+	}
+	return t.cfg.Username
+}
+
+// entryLocked returns the live entry for kind/key, dropping it if it went
+// quiet or its lockout has run out.
+func (t *LoginThrottle) entryLocked(kind, key string, now time.Time) *throttleEntry {
+	k := throttleKey(kind, key)
+	entry, ok := t.entries[k]
+	if !ok {
+		return nil
+	}
+	if t.staleLocked(kind, entry, now) && t.forgetLocked(k, entry) {
+		return nil
+	}
+	return entry
+}
+
+// forgetLocked drops the failures of the entry stored under k and reports
+// whether the entry itself was removed. Entries with attempts in flight are
+// kept so that those can still be settled.
+func (t *LoginThrottle) forgetLocked(k string, entry *throttleEntry) bool {
+	if entry.pending > 0 {
+		entry.failures, entry.lastFailure, entry.blockedUntil = 0, time.Time{}, time.Time{}
+		return false
+	}
+	delete(t.entries, k)
+	return true
+}
+
+func (t *LoginThrottle) staleLocked(kind string, entry *throttleEntry, now time.Time) bool {
+	if now.Before(entry.blockedUntil) {
+		return false
+	}
+	limits := t.limits(kind)
+	if limits.LockoutAfter > 0 && entry.failures >= limits.LockoutAfter {
+		// A served lockout starts over with a clean slate.
+		return true
+	}
+	return t.cfg.ResetAfter > 0 && now.Sub(entry.lastFailure) >= t.cfg.ResetAfter
+}
+
+// LoginAttempt is a sign-in admitted by Check. Until it is settled with
+// Success or Failure, or given back with Release, it counts against its
+// username and address as if it had failed, so that concurrent guesses
+// cannot all pass Check before the first of them is recorded. Only the first
+// of these calls has an effect, and all of them are no-ops on a nil attempt.
+type LoginAttempt struct {
+	throttle *LoginThrottle
+	username string
+	ip       string
+	settled  bool
+}
+
+// throttleKeys lists the kind and key pairs a sign-in for username from ip
+// is tracked under.
+func throttleKeys(username, ip string) [][2]string {
+	return [][2]string{{ThrottleUsername, username}, {ThrottleIP, ip}}
+}
+
+// Check reports whether a sign-in for username from ip may proceed, and if
+// not, how long the caller has to wait. An admitted sign-in is reserved
+// under the same lock and returned as an attempt the caller must settle.
+func (t *LoginThrottle) Check(username, ip string) (*LoginAttempt, time.Duration, bool) {
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	var wait time.Duration
+	for _, k := range throttleKeys(username, ip) {
+		entry := t.entryLocked(k[0], k[1], now)
+		if entry == nil {
+			continue
+		}
+		if d := entry.blockedUntil.Sub(now); d > wait {
+			wait = d
+		}
+		// Attempts in flight are counted as failures: another one is only
+		// admitted if it would be even if all of them failed.
+		if entry.pending > 0 {
+			if d := t.limits(k[0]).delay(entry.failures + entry.pending); d > wait {
+				wait = d
+			}
+		}
+	}
+	if wait > 0 {
+		return nil, wait, false
+	}
+
+	for _, k := range throttleKeys(username, ip) {
+		entry := t.entryLocked(k[0], k[1], now)
+		if entry == nil {
+			entry = &throttleEntry{}
+			t.entries[throttleKey(k[0], k[1])] = entry
+		}
+		entry.pending++
+	}
+	return &LoginAttempt{throttle: t, username: username, ip: ip}, 0, true
+}
+
+// Failure records the attempt as a failed sign-in.
+func (a *LoginAttempt) Failure() {
+	if a == nil || a.settled {
+		return
+	}
+	a.settled = true
+	t := a.throttle
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pruneLocked(now)
+	for _, k := range throttleKeys(a.username, a.ip) {
+		entry := t.entryLocked(k[0], k[1], now)
+		if entry == nil {
+			entry = &throttleEntry{}
+			t.entries[throttleKey(k[0], k[1])] = entry
+		} else if entry.pending > 0 {
+			entry.pending--
+		}
+		entry.failures++
+		entry.lastFailure = now
+		entry.blockedUntil = now.Add(t.limits(k[0]).delay(entry.failures))
+	}
+}
+
+// Success records the attempt as a successful sign-in, which forgets the
+// failures of its username. The address keeps its count so that one valid
+// account cannot be used to reset it between guesses.
+func (a *LoginAttempt) Success() {
+	a.settle(true)
+}
+
+// Release gives the attempt back without counting it either way, e.g. when
+// the credentials could not be checked or a second factor is still due.
+func (a *LoginAttempt) Release() {
+	a.settle(false)
+}
+
+func (a *LoginAttempt) settle(success bool) {
+	if a == nil || a.settled {
+		return
+	}
+	a.settled = true
+	t := a.throttle
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, k := range throttleKeys(a.username, a.ip) {
+		key := throttleKey(k[0], k[1])
+		entry := t.entryLocked(k[0], k[1], now)
+		if entry == nil {
+			continue
+		}
+		if entry.pending > 0 {
+			entry.pending--
+		}
+		if (success && k[0] == ThrottleUsername) || entry.failures == 0 {
+			t.forgetLocked(key, entry)
+		}
+	}
+}
+
+// delay returns how long to block after the given number of failures.
+func (l ThrottleLimits) delay(failures int) time.Duration {
+	if l.LockoutAfter > 0 && failures >= l.LockoutAfter {
+		return l.LockoutDuration
+	}
+	if failures <= l.FreeAttempts || l.BaseDelay <= 0 {
+		return 0
+	}
+	delay := l.BaseDelay
+	for i := l.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if l.MaxDelay > 0 && delay >= l.MaxDelay {
+			return l.MaxDelay
+		}
+	}
+	if l.MaxDelay > 0 && delay > l.MaxDelay {
+		return l.MaxDelay
+	}
+	return delay
+}
+
+// Lockouts returns the keys with recorded failures, most recent first.
+func (t *LoginThrottle) Lockouts() []Lockout {
+	now := t.now()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.pruneLocked(now)
+	lockouts := make([]Lockout, 0, len(t.entries))
+	for k, entry := range t.entries {
+		if entry.failures == 0 {
+			// Only attempts in flight, nothing has failed yet.
+			continue
+		}
+		kind, key, _ := strings.Cut(k, "\x00")
+		lockout := Lockout{
+			Kind:        kind,
+			Key:         key,
+			Failures:    entry.failures,
+			LastFailure: entry.lastFailure,
+		}
+		if now.Before(entry.blockedUntil) {
+			blockedUntil := entry.blockedUntil
+			lockout.BlockedUntil = &blockedUntil
+			limits := t.limits(kind)
+			lockout.Locked = limits.LockoutAfter > 0 && entry.failures >= limits.LockoutAfter
+		}
+		lockouts = append(lockouts, lockout)
+	}
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].LastFailure.After(lockouts[j].LastFailure)
+	})
+	return lockouts
+}
+
+// Clear forgets the failures of one key and reports whether it had any.
+func (t *LoginThrottle) Clear(kind, key string) bool {
+	k := throttleKey(kind, key)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	entry, ok := t.entries[k]
+	if !ok {
+		return false
+	}
+	hadFailures := entry.failures > 0
+	t.forgetLocked(k, entry)
+	return hadFailures
+}
+
+func (t *LoginThrottle) pruneLocked(now time.Time) {
+	for k, entry := range t.entries {
+		kind, _, _ := strings.Cut(k, "\x00")
+		if t.staleLocked(kind, entry, now) {
+			t.forgetLocked(k, entry)
+		}
+	}
+}
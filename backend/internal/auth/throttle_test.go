@@ -0,0 +1,183 @@
+package auth_test
+
+import (
+	"testing"
+	"time"
+
+	"assignment3/backend/internal/auth"
+)
+
+func newTestThrottle(now *time.Time) *auth.LoginThrottle {
+	return auth.NewLoginThrottle(auth.ThrottleConfig{
+		Username: auth.ThrottleLimits{
+			FreeAttempts:    2,
+			BaseDelay:       time.Second,
+			MaxDelay:        4 * time.Second,
+			LockoutAfter:    6,
+			LockoutDuration: time.Minute,
+		},
+		IP: auth.ThrottleLimits{
+			FreeAttempts:    4,
+			BaseDelay:       time.Second,
+			MaxDelay:        time.Second,
+			LockoutAfter:    20,
+			LockoutDuration: time.Minute,
+		},
+		ResetAfter: 10 * time.Minute,
+		Now:        func() time.Time { return *now },
+	})
+}
+
+// fail records a failed sign-in for username from ip.
+func fail(t *testing.T, throttle *auth.LoginThrottle, username, ip string) {
+	t.Helper()
+	attempt, wait, ok := throttle.Check(username, ip)
+	if !ok {
+		t.Fatalf("expected a sign-in for %s from %s to be allowed, got wait %v", username, ip, wait)
+	}
+	attempt.Failure()
+}
+
+// probe reports whether a sign-in for username from ip would be allowed,
+// without attempting one.
+func probe(throttle *auth.LoginThrottle, username, ip string) (time.Duration, bool) {
+	attempt, wait, ok := throttle.Check(username, ip)
+	attempt.Release()
+	return wait, ok
+}
+
+func TestLoginThrottleBackoffAndLockout(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(&now)
+
+	// Waits double after the free attempts and are capped at MaxDelay.
+	wants := []time.Duration{0, 0, time.Second, 2 * time.Second, 4 * time.Second}
+	for i, want := range wants {
+		fail(t, throttle, "Alice", "10.0.0.1")
+		wait, ok := probe(throttle, "alice", "10.0.0.2")
+		if want == 0 {
+			if !ok {
+				t.Fatalf("after %d failures: expected no wait, got %v", i+1, wait)
+			}
+			continue
+		}
+		if ok || wait != want {
+			t.Fatalf("after %d failures: expected wait %v, got %v (ok=%v)", i+1, want, wait, ok)
+		}
+		now = now.Add(wait)
+	}
+
+	// The sixth failure locks the username for LockoutDuration.
+	fail(t, throttle, "alice", "10.0.0.1")
+	if wait, ok := probe(throttle, "alice", "10.0.0.3"); ok || wait != time.Minute {
+		t.Fatalf("expected a one minute lockout, got %v (ok=%v)", wait, ok)
+	}
+	lockouts := throttle.Lockouts()
+	var found bool
+	for _, lockout := range lockouts {
+		if lockout.Kind == auth.ThrottleUsername && lockout.Key == "alice" {
+			found = true
+			if !lockout.Locked || lockout.Failures != 6 || lockout.BlockedUntil == nil || !lockout.BlockedUntil.Equal(now.Add(time.Minute)) {
+				t.Fatalf("unexpected lockout %+v", lockout)
+			}
+		}
+	}
+	if !found {
+		t.Fatalf("expected a lockout for alice, got %+v", lockouts)
+	}
+
+	// A served lockout starts over.
+	now = now.Add(time.Minute)
+	fail(t, throttle, "alice", "10.0.0.3")
+	if _, ok := probe(throttle, "alice", "10.0.0.3"); !ok {
+		t.Fatal("expected the counter to restart after the lockout ended")
+	}
+}
+
+func TestLoginThrottleTracksAddresses(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(&now)
+
+	// Spraying different usernames from one address is throttled by address.
+	for i := 0; i < 5; i++ {
+		fail(t, throttle, string(rune('a'+i)), "10.0.0.1")
+	}
+	if wait, ok := probe(throttle, "zed", "10.0.0.1"); ok || wait != time.Second {
+		t.Fatalf("expected the address to wait a second, got %v (ok=%v)", wait, ok)
+	}
+	if _, ok := probe(throttle, "zed", "10.0.0.2"); !ok {
+		t.Fatal("expected other addresses to be unaffected")
+	}
+
+	// Signing in successfully clears the username but not the address.
+	attempt, _, ok := throttle.Check("a", "10.0.0.2")
+	if !ok {
+		t.Fatal("expected a sign-in from another address to be allowed")
+	}
+	attempt.Success()
+	if _, ok := probe(throttle, "a", "10.0.0.1"); ok {
+		t.Fatal("expected the address to stay throttled after a success")
+	}
+
+	if !throttle.Clear(auth.ThrottleIP, "10.0.0.1") {
+		t.Fatal("expected clearing the address to report recorded failures")
+	}
+	if _, ok := probe(throttle, "zed", "10.0.0.1"); !ok {
+		t.Fatal("expected the address to be allowed after clearing it")
+	}
+	if throttle.Clear(auth.ThrottleIP, "10.0.0.1") {
+		t.Fatal("expected a second clear to report nothing recorded")
+	}
+}
+
+func TestLoginThrottleForgetsQuietKeys(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(&now)
+
+	for i := 0; i < 3; i++ {
+		fail(t, throttle, "bob", "10.0.0.1")
+	}
+	now = now.Add(10 * time.Minute)
+	if got := throttle.Lockouts(); len(got) != 0 {
+		t.Fatalf("expected quiet keys to be forgotten, got %+v", got)
+	}
+	fail(t, throttle, "bob", "10.0.0.1")
+	if _, ok := probe(throttle, "bob", "10.0.0.1"); !ok {
+		t.Fatal("expected a fresh count after ResetAfter")
+	}
+}
+
+func TestLoginThrottleReservesConcurrentAttempts(t *testing.T) {
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	throttle := newTestThrottle(&now)
+
+	// Attempts in flight count as failures: after the free attempts and the
+	// one they allow, the next has to wait for them to be settled.
+	var attempts []*auth.LoginAttempt
+	for i := 0; i < 3; i++ {
+		attempt, _, ok := throttle.Check("carol", "10.0.0.1")
+		if !ok {
+			t.Fatalf("attempt %d: expected to be allowed", i+1)
+		}
+		attempts = append(attempts, attempt)
+	}
+	if wait, ok := probe(throttle, "carol", "10.0.0.2"); ok || wait != time.Second {
+		t.Fatalf("expected a fourth concurrent attempt to wait a second, got %v (ok=%v)", wait, ok)
+	}
+	if got := throttle.Lockouts(); len(got) != 0 {
+		t.Fatalf("expected attempts in flight not to be listed, got %+v", got)
+	}
+
+	// Giving an attempt back frees its slot; settling one only once counts.
+	attempts[0].Release()
+	attempts[0].Failure()
+	if _, ok := probe(throttle, "carol", "10.0.0.2"); !ok {
+		t.Fatal("expected a released attempt to free its slot")
+	}
+	attempts[1].Failure()
+	attempts[2].Failure()
+	lockouts := throttle.Lockouts()
+	if len(lockouts) != 2 || lockouts[0].Failures != 2 || lockouts[1].Failures != 2 {
+		t.Fatalf("expected two failures per key, got %+v", lockouts)
+	}
+}
@@ -0,0 +1,101 @@
+package auth_test
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"assignment3/backend/internal/auth"
+)
+
+// rfcSecret is the SHA-1 seed of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeMatchesRFC6238(t *testing.T) {
+	// The RFC lists eight digit codes; six digit codes are their last six.
+	cases := map[int64]string{
+		59:         "287082",
+		1111111109: "081804",
+		1111111111: "050471",
+		1234567890: "005924",
+		2000000000: "279037",
+	}
+	for unix, want := range cases {
+		got, err := auth.TOTPCode(rfcSecret, auth.TOTPCounter(time.Unix(unix, 0)))
+		if err != nil {
+			t.Fatalf("TOTPCode returned error: %v", err)
+		}
+		if got != want {
+			t.Errorf("code at %d = %s, want %s", unix, got, want)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		t.Fatalf("GenerateTOTPSecret returned error: %v", err)
+	}
+	now := time.Unix(1700000000, 0)
+	counter := auth.TOTPCounter(now)
+
+	for _, offset := range []int64{-1, 0, 1} {
+		code, err := auth.TOTPCode(secret, counter+offset)
+		if err != nil {
+			t.Fatalf("TOTPCode returned error: %v", err)
+		}
+		got, ok := auth.ValidateTOTP(secret, code[:3]+" "+code[3:], now)
+		if !ok || got != counter+offset {
+			t.Fatalf("expected the code for step %+d to match step %d, got %d (ok=%v)", offset, counter+offset, got, ok)
+		}
+	}
+
+	stale, _ := auth.TOTPCode(secret, counter-2)
+	if _, ok := auth.ValidateTOTP(secret, stale, now); ok {
+		t.Fatal("expected codes two steps old to be rejected")
+	}
+	if _, ok := auth.ValidateTOTP(secret, "12345", now); ok {
+		t.Fatal("expected short codes to be rejected")
+	}
+}
+
+func TestTOTPURI(t *testing.T) {
+	uri, err := url.Parse(auth.TOTPURI("Example App", "alice", "JBSWY3DPEHPK3PXP"))
+	if err != nil {
+		t.Fatalf("invalid uri: %v", err)
+	}
+	if uri.Scheme != "otpauth" || uri.Host != "totp" || uri.Path != "/Example App:alice" {
+		t.Fatalf("unexpected uri %s", uri)
+	}
+	query := uri.Query()
+	if query.Get("secret") != "JBSWY3DPEHPK3PXP" || query.Get("issuer") != "Example App" || query.Get("digits") != "6" || query.Get("period") != "30" {
+		t.Fatalf("unexpected parameters %v", query)
+	}
+}
+
+func TestRecoveryCodes(t *testing.T) {
+	codes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatalf("GenerateRecoveryCodes returned error: %v", err)
+	}
+	if len(codes) != auth.RecoveryCodeCount {
+		t.Fatalf("expected %d codes, got %d", auth.RecoveryCodeCount, len(codes))
+	}
+	seen := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		if len(code) != 9 || code[4] != '-' || seen[code] {
+			t.Fatalf("unexpected code %q in %v", code, codes)
+		}
+		seen[code] = true
+	}
+
+	loose := strings.ToUpper(strings.ReplaceAll(codes[0], "-", " "))
+	if auth.HashRecoveryCode(loose) != auth.HashRecoveryCode(codes[0]) {
+		t.Fatal("expected case, spaces and dashes to be ignored")
+	}
+	if auth.HashRecoveryCode(codes[0]) == auth.HashRecoveryCode(codes[1]) {
+		t.Fatal("expected distinct codes to hash differently")
+	}
+}
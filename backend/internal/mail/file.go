@@ -0,0 +1,47 @@
+package mail
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// FileMailer is a development mailer that appends each message to a file, or
+// writes it to the standard logger when no path is set. Nothing is delivered.
+type FileMailer struct {
+	mu   sync.Mutex
+	path string
+	from string
+}
+
+// NewFileMailer returns a mailer that records messages in path, or logs them
+// when path is empty.
+func NewFileMailer(path, from string) *FileMailer {
+	return &FileMailer{path: path, from: from}
+}
+
+// Send records msg.
+func (m *FileMailer) Send(msg Message) error {
+	data, err := render(m.from, msg, time.Now())
+	if err != nil {
+		return err
+	}
+	if m.path == "" {
+		log.Printf("mail (not sent):\n%s", data)
+		return nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	f, err := os.OpenFile(m.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open mail file: %w", err)
+	}
+	defer f.Close()
+	if _, err := f.Write(append(data, "\r\n"...)); err != nil {
+		return fmt.Errorf("failed to write mail file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+// Package mail delivers transactional email such as password reset links.
+package mail
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Message is a plain-text email to a single recipient.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// Mailer delivers messages.
+type Mailer interface {
+	Send(msg Message) error
+}
+
+// ErrInvalidMessage is returned for messages that cannot be sent safely, such
+// as a recipient or subject containing line breaks.
+var ErrInvalidMessage = errors.New("invalid mail message")
+
+// render formats msg as an RFC 5322 message with CRLF line endings.
+func render(from string, msg Message, now time.Time) ([]byte, error) {
+	if msg.To == "" || strings.ContainsAny(msg.To+msg.Subject+from, "\r\n") {
+		return nil, ErrInvalidMessage
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from)
+	fmt.Fprintf(&buf, "To: %s\r\n", msg.To)
+	fmt.Fprintf(&buf, "Subject: %s\r\n", msg.Subject)
+	fmt.Fprintf(&buf, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("\r\n")
+	body := strings.ReplaceAll(msg.Body, "\r\n", "\n")
+	for _, line := range strings.Split(body, "\n") {
+		buf.WriteString(line)
+		buf.WriteString("\r\n")
+	}
+	return buf.Bytes(), nil
+}
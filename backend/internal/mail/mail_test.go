@@ -0,0 +1,141 @@
+package mail_test
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"assignment3/backend/internal/mail"
+)
+
+// fakeSMTP is a minimal SMTP relay in the spirit of MailHog: it accepts one
+// message and reports the envelope recipient and the raw data.
+type fakeSMTP struct {
+	addr     string
+	received chan string
+}
+
+func startFakeSMTP(t *testing.T) *fakeSMTP {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	t.Cleanup(func() { _ = ln.Close() })
+
+	srv := &fakeSMTP{addr: ln.Addr().String(), received: make(chan string, 1)}
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { _, _ = conn.Write([]byte(line + "\r\n")) }
+
+		reply("220 fake ESMTP")
+		var rcpt string
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			cmd := strings.ToUpper(strings.TrimSpace(line))
+			switch {
+			case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+				reply("250 fake")
+			case strings.HasPrefix(cmd, "MAIL FROM"):
+				reply("250 ok")
+			case strings.HasPrefix(cmd, "RCPT TO"):
+				rcpt = strings.TrimSpace(line[len("RCPT TO:"):])
+				reply("250 ok")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					l, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if l == ".\r\n" {
+						break
+					}
+					data.WriteString(l)
+				}
+				srv.received <- rcpt + "\n" + data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("502 unsupported")
+			}
+		}
+	}()
+	return srv
+}
+
+func TestSMTPMailerDelivers(t *testing.T) {
+	srv := startFakeSMTP(t)
+	mailer := mail.NewSMTPMailer(mail.SMTPConfig{Addr: srv.addr, From: "no-reply@example.com"})
+
+	err := mailer.Send(mail.Message{To: "alice@example.com", Subject: "Reset", Body: "Open the link:\nhttps://example.com/?t=1"})
+	if err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+
+	got := <-srv.received
+	for _, want := range []string{"<alice@example.com>", "Subject: Reset\r\n", "From: no-reply@example.com\r\n", "https://example.com/?t=1\r\n"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected delivered message to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestMailersRejectHeaderInjection(t *testing.T) {
+	mailer := mail.NewFileMailer(filepath.Join(t.TempDir(), "mail.log"), "no-reply@example.com")
+	err := mailer.Send(mail.Message{To: "alice@example.com\r\nBcc: eve@example.com", Subject: "Reset"})
+	if !errors.Is(err, mail.ErrInvalidMessage) {
+		t.Fatalf("expected ErrInvalidMessage, got %v", err)
+	}
+}
+
+func TestFileMailerAppends(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "mail.log")
+	mailer := mail.NewFileMailer(path, "no-reply@example.com")
+	for _, to := range []string{"alice@example.com", "bob@example.com"} {
+		if err := mailer.Send(mail.Message{To: to, Subject: "Hi", Body: "hello"}); err != nil {
+			t.Fatalf("Send returned error: %v", err)
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read mail file: %v", err)
+	}
+	if !strings.Contains(string(data), "To: alice@example.com") || !strings.Contains(string(data), "To: bob@example.com") {
+		t.Fatalf("expected both messages in the file, got:\n%s", data)
+	}
+}
+
+// TestSMTPMailerAgainstMailHog sends through a real relay named by
+// SMTP_TEST_ADDR, e.g. a local MailHog started with:
+//
+//	docker run --rm -p 1025:1025 -p 8025:8025 mailhog/mailhog
+//	SMTP_TEST_ADDR=localhost:1025
+//
+// The message then shows up in the MailHog UI on port 8025.
+func TestSMTPMailerAgainstMailHog(t *testing.T) {
+	addr := os.Getenv("SMTP_TEST_ADDR")
+	if addr == "" {
+		t.Skip("SMTP_TEST_ADDR not set")
+	}
+	mailer := mail.NewSMTPMailer(mail.SMTPConfig{Addr: addr, From: "no-reply@example.com"})
+	if err := mailer.Send(mail.Message{To: "alice@example.com", Subject: "Mailer test", Body: "It works."}); err != nil {
+		t.Fatalf("Send returned error: %v", err)
+	}
+}
@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"fmt"
+	"net"
+	"net/smtp"
+	"time"
+)
+
+// SMTPConfig describes the relay SMTPMailer delivers through.
+type SMTPConfig struct {
+	// Addr is the relay's host:port, e.g. "localhost:1025" for MailHog.
+	Addr string
+	// From is the envelope and header sender.
+	From string
+	// Username and Password enable PLAIN authentication when set. Go only
+	// sends them over TLS or to localhost.
+	Username string
+	Password string
+}
+
+// SMTPMailer sends mail through an SMTP relay, upgrading the connection with
+// STARTTLS when the server offers it.
+type SMTPMailer struct {
+	cfg SMTPConfig
+}
+
+// NewSMTPMailer returns a mailer for the relay described by cfg.
+func NewSMTPMailer(cfg SMTPConfig) *SMTPMailer {
+	return &SMTPMailer{cfg: cfg}
+}
+
+// Send delivers msg through the relay.
+func (m *SMTPMailer) Send(msg Message) error {
+	data, err := render(m.cfg.From, msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	var auth smtp.Auth
+	if m.cfg.Username != "" {
+		host, _, err := net.SplitHostPort(m.cfg.Addr)
+		if err != nil {
+			return fmt.Errorf("invalid smtp address %q: %w", m.cfg.Addr, err)
+		}
+		auth = smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, host)
+	}
+	if err := smtp.SendMail(m.cfg.Addr, auth, m.cfg.From, []string{msg.To}, data); err != nil {
+		return fmt.Errorf("failed to send mail: %w", err)
+	}
+	return nil
+}
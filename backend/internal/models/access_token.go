@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// PersonalAccessToken is a long-lived credential a user creates for scripts
+// and CI jobs. Only a hash of the token is stored; Prefix keeps its first few
+// characters so users can tell their tokens apart. Scopes limit the token to a
+// subset of the permissions the user's role grants.
+type PersonalAccessToken struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	TokenHash  string     `json:"-"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+}
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// AuditEvent records an administrative action: who did what to which record.
+type AuditEvent struct {
+	ID        string            `json:"id"`
+	ActorID   string            `json:"actor_id"`
+	Action    string            `json:"action"`
+	TargetID  string            `json:"target_id"`
+	Details   map[string]string `json:"details,omitempty"`
+	CreatedAt time.Time         `json:"created_at"`
+}
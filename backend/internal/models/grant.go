@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+// ItemAccess is a level of access to an item.
+type ItemAccess string
+
+// Access levels, from least to most. Grants give viewer or editor access;
+// owner access comes with owning the item.
+const (
+	ItemViewer ItemAccess = "viewer"
+	ItemEditor ItemAccess = "editor"
+	ItemOwner  ItemAccess = "owner"
+)
+
+// GrantSubject is the kind of principal an ItemGrant is given to.
+type GrantSubject string
+
+// Grant subjects.
+const (
+	GrantToUser GrantSubject = "user"
+	GrantToRole GrantSubject = "role"
+)
+
+// ItemGrant gives a user or everyone holding a role access to an item that
+// is not private.
+type ItemGrant struct {
+	ItemID      string       `json:"item_id"`
+	SubjectType GrantSubject `json:"subject_type"`
+	// Subject is a user ID or a role name.
+	Subject string `json:"subject"`
+	// SubjectName is the user's current username or the role name, resolved
+	// when the grant is read.
+	SubjectName string     `json:"subject_name"`
+	Access      ItemAccess `json:"access"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// AccessSource explains where a user's access to an item comes from.
+type AccessSource string
+
+// Access sources.
+const (
+	AccessFromOwnership AccessSource = "owner"
+	AccessFromRole      AccessSource = "role_permission"
+	AccessFromPublic    AccessSource = "public"
+	AccessFromUserGrant AccessSource = "user_grant"
+	AccessFromRoleGrant AccessSource = "role_grant"
+)
+
+// EffectiveAccess is what one user may do with an item, and why.
+type EffectiveAccess struct {
+	UserID   string       `json:"user_id"`
+	Username string       `json:"username"`
+	Role     string       `json:"role"`
+	Access   ItemAccess   `json:"access"`
+	Source   AccessSource `json:"source"`
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// ExternalIdentity links a user to an account at an OpenID Connect provider,
+// identified by the provider's issuer URL and the subject it assigned.
+type ExternalIdentity struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	Issuer    string    `json:"issuer"`
+	Subject   string    `json:"subject"`
+	Email     string    `json:"email,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
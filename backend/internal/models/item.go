@@ -2,12 +2,41 @@ package models
 
 import "time"
 
+// ItemVisibility decides who besides the owner can see an item.
+type ItemVisibility string
+
+// Item visibilities. Private items are seen by their owner only and shared
+// ones also by the users and roles they were granted to. Public items are seen
+// by everyone, and grants can still make users editors.
+const (
+	ItemPrivate ItemVisibility = "private"
+	ItemShared  ItemVisibility = "shared"
+	ItemPublic  ItemVisibility = "public"
+)
+
+// Valid reports whether v is a known visibility.
+func (v ItemVisibility) Valid() bool {
+	switch v {
+	case ItemPrivate, ItemShared, ItemPublic:
+		return true
+	}
+	return false
+}
+
 // Item represents an entity managed through the REST API.
 type Item struct {
-	ID          string    `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Owner       string    `json:"owner"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
+	ID          string `json:"id"`
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	// OwnerID identifies the owning user; it is empty once the owner was
+	// deleted and the item tombstoned.
+	OwnerID string `json:"owner_id"`
+	// Owner is the owner's current username, resolved when the item is read.
+	Owner      string         `json:"owner"`
+	Visibility ItemVisibility `json:"visibility"`
+	// Access is what the reading user may do with the item, resolved when the
+	// item is read for someone.
+	Access    ItemAccess `json:"access,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
 }
@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// PasswordResetToken is the persisted record of a self-service password reset
+// link. Only a hash of the token is stored; it is deleted once used.
+type PasswordResetToken struct {
+	ID        string    `json:"id"`
+	UserID    string    `json:"user_id"`
+	TokenHash string    `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
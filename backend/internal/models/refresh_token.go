@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// RefreshToken is the persisted record of an opaque refresh token. Only a hash
+// of the token value is stored. Tokens issued by rotating one another share a
+// FamilyID so that replaying a spent token can revoke the whole chain.
+type RefreshToken struct {
+	ID        string     `json:"id"`
+	UserID    string     `json:"user_id"`
+	FamilyID  string     `json:"family_id"`
+	TokenHash string     `json:"-"`
+	CreatedAt time.Time  `json:"created_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+}
@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+// RevokedToken is an access token denylist entry. It either matches a single
+// token by its JWT ID, or, when JTI is empty, every token of UserID issued at or
+// before RevokedAt. Entries are kept only until ExpiresAt, after which the
+// tokens they match would have expired anyway.
+type RevokedToken struct {
+	ID        string    `json:"id"`
+	JTI       string    `json:"jti,omitempty"`
+	UserID    string    `json:"user_id"`
+	RevokedAt time.Time `json:"revoked_at"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
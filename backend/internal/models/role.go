@@ -0,0 +1,13 @@
+package models
+
+import "time"
+
+// Role is a named set of permissions that can be assigned to users.
+type Role struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+	// RequireMFA makes users holding the role set up two-factor authentication.
+	RequireMFA bool      `json:"require_mfa"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
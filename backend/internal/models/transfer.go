@@ -0,0 +1,33 @@
+package models
+
+import "time"
+
+// TransferStatus is the state of an ItemTransfer.
+type TransferStatus string
+
+// Transfer states. Only pending transfers are stored; the others describe how
+// one was resolved.
+const (
+	TransferPending   TransferStatus = "pending"
+	TransferAccepted  TransferStatus = "accepted"
+	TransferDeclined  TransferStatus = "declined"
+	TransferCancelled TransferStatus = "cancelled"
+)
+
+// ItemTransfer is an offer to hand an item to another user, who may accept or
+// decline it until ExpiresAt.
+type ItemTransfer struct {
+	ID     string `json:"id"`
+	ItemID string `json:"item_id"`
+	// FromID is the item's owner when the transfer was proposed; it is empty
+	// for tombstoned items.
+	FromID string `json:"from_id"`
+	ToID   string `json:"to_id"`
+	// ItemTitle, From and To are resolved when the transfer is read.
+	ItemTitle string         `json:"item_title"`
+	From      string         `json:"from"`
+	To        string         `json:"to"`
+	Status    TransferStatus `json:"status"`
+	CreatedAt time.Time      `json:"created_at"`
+	ExpiresAt time.Time      `json:"expires_at"`
+}
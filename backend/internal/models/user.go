@@ -2,11 +2,79 @@ package models
 
 import "time"
 
-// User represents an authenticated account in the system.
+// User represents an authenticated account in the system. Email is optional
+// and only used to deliver password reset links.
 type User struct {
 	ID           string    `json:"id"`
 	Username     string    `json:"username"`
+	Email        string    `json:"email,omitempty"`
 	PasswordHash string    `json:"-"`
 	Role         string    `json:"role"`
 	CreatedAt    time.Time `json:"created_at"`
+	// MustChangePassword restricts the account to changing its password,
+	// e.g. after an administrator set a temporary one.
+	MustChangePassword bool `json:"must_change_password"`
+	// MFAEnabled is set once the user confirmed a TOTP authenticator; from
+	// then on signing in needs a code from it or a recovery code.
+	MFAEnabled bool `json:"mfa_enabled"`
+	// MFASecret is the base32 TOTP secret, stored from enrollment on.
+	MFASecret string `json:"-"`
+	// MFALastCounter is the time step of the last accepted code, so that no
+	// code is accepted twice.
+	MFALastCounter int64 `json:"-"`
+	// RecoveryCodes holds the hashes of the unused recovery codes.
+	RecoveryCodes []string `json:"-"`
+
+	// ServiceAccount marks a non-human identity used by integrations. It has
+	// no password and obtains tokens with ClientID and its client secret.
+	ServiceAccount   bool   `json:"service_account"`
+	ClientID         string `json:"client_id,omitempty"`
+	ClientSecretHash string `json:"-"`
+	// Scopes limits a service account to these permissions of its role.
+	Scopes []string `json:"scopes,omitempty"`
+
+	// Status says whether the account may sign in. StatusReason records why
+	// an administrator last changed it, and SuspendedUntil ends a timed
+	// suspension; use StatusAt to account for it.
+	Status         UserStatus `json:"status"`
+	StatusReason   string     `json:"status_reason,omitempty"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+// UserStatus is the lifecycle state of an account.
+type UserStatus string
+
+const (
+	// UserStatusActive accounts can sign in. Accounts stored before statuses
+	// existed have an empty status and count as active.
+	UserStatusActive UserStatus = "active"
+	// UserStatusSuspended accounts are blocked by an administrator, either
+	// until further notice or until SuspendedUntil.
+	UserStatusSuspended UserStatus = "suspended"
+	// UserStatusPending accounts await approval before their first sign-in.
+	UserStatusPending UserStatus = "pending"
+	// UserStatusDeactivated accounts are closed but kept, e.g. for the items
+	// and audit events that refer to them.
+	UserStatusDeactivated UserStatus = "deactivated"
+)
+
+// Valid reports whether s is one of the known statuses.
+func (s UserStatus) Valid() bool {
+	switch s {
+	case UserStatusActive, UserStatusSuspended, UserStatusPending, UserStatusDeactivated:
+		return true
+	}
+	return false
+}
+
+// StatusAt returns the account's status at now. A timed suspension that has
+// run out counts as active again without anyone having to lift it.
+func (u User) StatusAt(now time.Time) UserStatus {
+	switch {
+	case u.Status == "":
+		return UserStatusActive
+	case u.Status == UserStatusSuspended && u.SuspendedUntil != nil && !now.Before(*u.SuspendedUntil):
+		return UserStatusActive
+	}
+	return u.Status
 }
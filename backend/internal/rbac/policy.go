@@ -0,0 +1,202 @@
+// Package rbac maps roles to the permissions they grant.
+package rbac
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Permission names an action a role may perform.
+type Permission string
+
+// Permissions understood by the API.
+const (
+	ItemsRead      Permission = "items:read"
+	ItemsCreate    Permission = "items:create"
+	ItemsUpdateOwn Permission = "items:update:own"
+	ItemsUpdateAny Permission = "items:update:any"
+	ItemsDelete    Permission = "items:delete"
+	UsersManage    Permission = "users:manage"
+)
+
+// AllPermissions lists every known permission.
+var AllPermissions = []Permission{
+	ItemsRead,
+	ItemsCreate,
+	ItemsUpdateOwn,
+	ItemsUpdateAny,
+	ItemsDelete,
+	UsersManage,
+}
+
+// ValidPermission reports whether p is a known permission.
+func ValidPermission(p Permission) bool {
+	for _, known := range AllPermissions {
+		if p == known {
+			return true
+		}
+	}
+	return false
+}
+
+// Policy maps role names to permission sets. A Policy is immutable once built
+// and safe for concurrent use.
+type Policy struct {
+	defaultRole string
+	roles       map[string]map[Permission]struct{}
+	// mfaRoles holds the roles whose users must use two-factor authentication.
+	mfaRoles map[string]struct{}
+}
+
+// policyFile is the on-disk representation of a policy:
+//
+//	{"default_role": "user", "roles": {"user": ["items:read", ...]}, "require_mfa": ["admin"]}
+type policyFile struct {
+	DefaultRole string                  `json:"default_role"`
+	Roles       map[string][]Permission `json:"roles"`
+	RequireMFA  []string                `json:"require_mfa"`
+}
+
+// NewPolicy builds a policy from a role to permission mapping. defaultRole is
+// assigned to users registered without an explicit role and must exist.
+func NewPolicy(defaultRole string, roles map[string][]Permission) (*Policy, error) {
+	p := &Policy{
+		defaultRole: NormalizeRole(defaultRole),
+		roles:       make(map[string]map[Permission]struct{}, len(roles)),
+	}
+	for role, perms := range roles {
+		name := NormalizeRole(role)
+		if name == "" {
+			return nil, fmt.Errorf("role name cannot be empty")
+		}
+		if _, exists := p.roles[name]; exists {
+			return nil, fmt.Errorf("duplicate role %q", name)
+		}
+		set := make(map[Permission]struct{}, len(perms))
+		for _, perm := range perms {
+			if !ValidPermission(perm) {
+				return nil, fmt.Errorf("role %q has unknown permission %q", name, perm)
+			}
+			set[perm] = struct{}{}
+		}
+		p.roles[name] = set
+	}
+	if _, ok := p.roles[p.defaultRole]; !ok {
+		return nil, fmt.Errorf("default role %q is not defined", p.defaultRole)
+	}
+	return p, nil
+}
+
+// DefaultPolicy reproduces the built-in behaviour: users read, create and edit
+// their own items; admins may do everything.
+func DefaultPolicy() *Policy {
+	p, err := NewPolicy("user", map[string][]Permission{
+		"user":  {ItemsRead, ItemsCreate, ItemsUpdateOwn},
+		"admin": AllPermissions,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// LoadPolicy reads a JSON policy file.
+func LoadPolicy(path string) (*Policy, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read policy: %w", err)
+	}
+	var file policyFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse policy: %w", err)
+	}
+	if file.DefaultRole == "" {
+		file.DefaultRole = "user"
+	}
+	p, err := NewPolicy(file.DefaultRole, file.Roles)
+	if err != nil {
+		return nil, err
+	}
+	return p.WithMFARequired(file.RequireMFA...)
+}
+
+// NormalizeRole canonicalises a role name.
+func NormalizeRole(role string) string {
+	return strings.ToLower(strings.TrimSpace(role))
+}
+
+// DefaultRole returns the role given to users registered without one.
+func (p *Policy) DefaultRole() string {
+	return p.defaultRole
+}
+
+// HasRole reports whether the policy defines role.
+func (p *Policy) HasRole(role string) bool {
+	_, ok := p.roles[NormalizeRole(role)]
+	return ok
+}
+
+// Roles returns the defined role names in sorted order.
+func (p *Policy) Roles() []string {
+	roles := make([]string, 0, len(p.roles))
+	for role := range p.roles {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// Permissions returns the permissions granted to role in canonical order.
+func (p *Policy) Permissions(role string) []Permission {
+	set := p.roles[NormalizeRole(role)]
+	perms := make([]Permission, 0, len(set))
+	for _, perm := range AllPermissions {
+		if _, ok := set[perm]; ok {
+			perms = append(perms, perm)
+		}
+	}
+	return perms
+}
+
+// Can reports whether role grants perm. Unknown roles grant nothing.
+func (p *Policy) Can(role string, perm Permission) bool {
+	_, ok := p.roles[NormalizeRole(role)][perm]
+	return ok
+}
+
+// WithMFARequired returns a copy of the policy in which users holding any of
+// roles must use two-factor authentication. The roles must exist.
+func (p *Policy) WithMFARequired(roles ...string) (*Policy, error) {
+	next := &Policy{
+		defaultRole: p.defaultRole,
+		roles:       p.roles,
+		mfaRoles:    make(map[string]struct{}, len(p.mfaRoles)+len(roles)),
+	}
+	for role := range p.mfaRoles {
+		next.mfaRoles[role] = struct{}{}
+	}
+	for _, role := range roles {
+		name := NormalizeRole(role)
+		if !p.HasRole(name) {
+			return nil, fmt.Errorf("role %q requiring mfa is not defined", name)
+		}
+		next.mfaRoles[name] = struct{}{}
+	}
+	return next, nil
+}
+
+// RequiresMFA reports whether users holding role must use two-factor
+// authentication.
+func (p *Policy) RequiresMFA(role string) bool {
+	_, ok := p.mfaRoles[NormalizeRole(role)]
+	return ok
+}
+
+// CanUpdateItem reports whether role may edit an item, given whether the
+// requester owns it.
+func (p *Policy) CanUpdateItem(role string, isOwner bool) bool {
+	return p.Can(role, ItemsUpdateAny) || (isOwner && p.Can(role, ItemsUpdateOwn))
+}
@@ -0,0 +1,82 @@
+package rbac_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"assignment3/backend/internal/rbac"
+)
+
+func TestDefaultPolicyMatchesBuiltInRoles(t *testing.T) {
+	policy := rbac.DefaultPolicy()
+
+	if policy.DefaultRole() != "user" {
+		t.Fatalf("expected default role user, got %s", policy.DefaultRole())
+	}
+	cases := []struct {
+		role string
+		perm rbac.Permission
+		want bool
+	}{
+		{"user", rbac.ItemsRead, true},
+		{"user", rbac.ItemsCreate, true},
+		{"user", rbac.ItemsUpdateOwn, true},
+		{"user", rbac.ItemsUpdateAny, false},
+		{"user", rbac.ItemsDelete, false},
+		{"user", rbac.UsersManage, false},
+		{"Admin", rbac.ItemsDelete, true},
+		{"admin", rbac.UsersManage, true},
+		{"ghost", rbac.ItemsRead, false},
+	}
+	for _, tc := range cases {
+		if got := policy.Can(tc.role, tc.perm); got != tc.want {
+			t.Errorf("Can(%s, %s) = %v, want %v", tc.role, tc.perm, got, tc.want)
+		}
+	}
+
+	if !policy.CanUpdateItem("user", true) || policy.CanUpdateItem("user", false) {
+		t.Fatalf("expected users to edit only their own items")
+	}
+	if !policy.CanUpdateItem("admin", false) {
+		t.Fatalf("expected admins to edit any item")
+	}
+}
+
+func TestLoadPolicy(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "policy.json")
+	data := `{"default_role": "viewer", "roles": {"viewer": ["items:read"], "moderator": ["items:read", "items:delete"]}, "require_mfa": ["Moderator"]}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatalf("failed to write policy: %v", err)
+	}
+
+	policy, err := rbac.LoadPolicy(path)
+	if err != nil {
+		t.Fatalf("LoadPolicy returned error: %v", err)
+	}
+	if got := policy.Roles(); len(got) != 2 || got[0] != "moderator" || got[1] != "viewer" {
+		t.Fatalf("unexpected roles: %v", got)
+	}
+	if policy.DefaultRole() != "viewer" || !policy.Can("moderator", rbac.ItemsDelete) || policy.Can("viewer", rbac.ItemsDelete) {
+		t.Fatalf("policy file not applied: %+v", policy.Permissions("moderator"))
+	}
+	if !policy.RequiresMFA("moderator") || policy.RequiresMFA("viewer") {
+		t.Fatalf("expected only moderators to require mfa")
+	}
+
+	invalid := map[string]string{
+		"unknown permission": `{"roles": {"user": ["items:fly"]}}`,
+		"missing default":    `{"default_role": "nobody", "roles": {"user": []}}`,
+		"malformed":          `{"roles": [`,
+		"unknown mfa role":   `{"roles": {"user": []}, "require_mfa": ["admin"]}`,
+	}
+	for name, data := range invalid {
+		if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+			t.Fatalf("failed to write policy: %v", err)
+		}
+		if _, err := rbac.LoadPolicy(path); err == nil {
+			t.Errorf("%s: expected LoadPolicy to fail", name)
+		}
+	}
+}
@@ -0,0 +1,136 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// accessTokenTouchInterval bounds how often a token's LastUsedAt is written,
+// so that scripts polling the API do not cause a write per request.
+const accessTokenTouchInterval = time.Minute
+
+// newAccessToken validates the name and scopes of a personal access token
+// before it is stored.
+func newAccessToken(userID, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (models.PersonalAccessToken, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.PersonalAccessToken{}, fmt.Errorf("token name cannot be empty")
+	}
+	if len(scopes) == 0 {
+		return models.PersonalAccessToken{}, fmt.Errorf("token needs at least one scope")
+	}
+	scopes, err := normalizePermissions(scopes)
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	token := models.PersonalAccessToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Name:      name,
+		Prefix:    prefix,
+		TokenHash: tokenHash,
+		Scopes:    scopes,
+		CreatedAt: time.Now().UTC(),
+	}
+	if expiresAt != nil {
+		utc := expiresAt.UTC()
+		if !utc.After(token.CreatedAt) {
+			return models.PersonalAccessToken{}, fmt.Errorf("token expiry must be in the future")
+		}
+		token.ExpiresAt = &utc
+	}
+	return token, nil
+}
+
+// accessTokenExpired reports whether token can no longer be used at now.
+func accessTokenExpired(token models.PersonalAccessToken, now time.Time) bool {
+	return token.ExpiresAt != nil && !now.Before(*token.ExpiresAt)
+}
+
+// accessTokenNeedsTouch reports whether LastUsedAt is stale enough to record
+// a use at now.
+func accessTokenNeedsTouch(token models.PersonalAccessToken, now time.Time) bool {
+	return token.LastUsedAt == nil || now.Sub(*token.LastUsedAt) >= accessTokenTouchInterval
+}
+
+// CreateAccessToken stores the hash of a new personal access token. Names are
+// unique per user.
+func (s *Store) CreateAccessToken(userID, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (models.PersonalAccessToken, error) {
+	token, err := newAccessToken(userID, name, prefix, tokenHash, scopes, expiresAt)
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.userExistsLocked(userID) {
+		return models.PersonalAccessToken{}, ErrUserNotFound
+	}
+	for _, existing := range s.accessTokens {
+		if existing.UserID == userID && strings.EqualFold(existing.Name, token.Name) {
+			return models.PersonalAccessToken{}, ErrAccessTokenExists
+		}
+	}
+	if err := s.commit(journalRecord{Op: opPutAccessToken, AccessToken: &token}); err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	return token, nil
+}
+
+// ListAccessTokens returns a user's personal access tokens, newest first.
+// Expired tokens are included until they are deleted.
+func (s *Store) ListAccessTokens(userID string) ([]models.PersonalAccessToken, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	tokens := make([]models.PersonalAccessToken, 0)
+	for _, token := range s.accessTokens {
+		if token.UserID == userID {
+			tokens = append(tokens, token)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// DeleteAccessToken revokes one of a user's personal access tokens.
+func (s *Store) DeleteAccessToken(userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, token := range s.accessTokens {
+		if token.UserID == userID && token.ID == id {
+			return s.commit(journalRecord{Op: opDeleteAccessToken, AccessToken: &token})
+		}
+	}
+	return ErrAccessTokenNotFound
+}
+
+// UseAccessToken looks up the personal access token identified by tokenHash
+// and records that it was used.
+func (s *Store) UseAccessToken(tokenHash string) (models.PersonalAccessToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.accessTokens[tokenHash]
+	now := time.Now().UTC()
+	if !ok || accessTokenExpired(token, now) {
+		return models.PersonalAccessToken{}, ErrAccessTokenInvalid
+	}
+	if accessTokenNeedsTouch(token, now) {
+		token.LastUsedAt = &now
+		if err := s.commit(journalRecord{Op: opPutAccessToken, AccessToken: &token}); err != nil {
+			return models.PersonalAccessToken{}, err
+		}
+	}
+	return token, nil
+}
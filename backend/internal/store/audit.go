@@ -0,0 +1,64 @@
+package store
+
+import (
+	"sort"
+	"time"
+
+	"assignment3/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// Audit actions recorded by the store.
+const (
+	AuditUserRoleChanged   = "user.role_changed"
+	AuditUserPasswordReset = "user.password_reset"
+	AuditUserMFADisabled   = "user.mfa_disabled"
+
+	AuditServiceAccountCreated = "user.service_account_created"
+	AuditClientSecretRotated   = "user.client_secret_rotated"
+
+	AuditUserProvisioned  = "user.provisioned"
+	AuditIdentityLinked   = "user.identity_linked"
+	AuditIdentityUnlinked = "user.identity_unlinked"
+
+	AuditUserStatusChanged = "user.status_changed"
+	AuditUserRenamed       = "user.renamed"
+)
+
+// AuditActorLDAP is the actor of changes the directory made rather than a
+// user, such as roles that follow LDAP groups.
+const AuditActorLDAP = "ldap"
+
+func newAuditEvent(actorID, action, targetID string, details map[string]string) models.AuditEvent {
+	return models.AuditEvent{
+		ID:        uuid.NewString(),
+		ActorID:   actorID,
+		Action:    action,
+		TargetID:  targetID,
+		Details:   details,
+		CreatedAt: time.Now().UTC(),
+	}
+}
+
+// ListAuditEvents returns up to limit audit events, newest first. A
+// non-positive limit returns every event.
+func (s *Store) ListAuditEvents(limit int) ([]models.AuditEvent, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	events := make([]models.AuditEvent, 0, len(s.auditEvents))
+	for _, event := range s.auditEvents {
+		events = append(events, event)
+	}
+	sort.Slice(events, func(i, j int) bool {
+		if !events[i].CreatedAt.Equal(events[j].CreatedAt) {
+			return events[i].CreatedAt.After(events[j].CreatedAt)
+		}
+		return events[i].ID > events[j].ID
+	})
+	if limit > 0 && len(events) > limit {
+		events = events[:limit]
+	}
+	return events, nil
+}
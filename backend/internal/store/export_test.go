@@ -0,0 +1,41 @@
+package store
+
+import (
+	"io"
+	"os"
+)
+
+// StoredGrantCount returns how many grants the store still holds for an item,
+// whether or not the item itself exists.
+func StoredGrantCount(r Repository, itemID string) (int, error) {
+	switch s := r.(type) {
+	case *Store:
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return len(s.grants[itemID]), nil
+	case *SQLStore:
+		var n int
+		err := s.queryRow(s.db, "SELECT COUNT(*) FROM item_grants WHERE item_id = ?", itemID).Scan(&n)
+		return n, err
+	}
+	return 0, nil
+}
+
+// BreakJournalWrites swaps the WAL of a journaled store for a read-only handle
+// on the same file, so that appends and their rollback fail.
+func BreakJournalWrites(s *Store) error {
+	s.journal.mu.Lock()
+	defer s.journal.mu.Unlock()
+
+	file, err := os.Open(s.journal.file.Name())
+	if err != nil {
+		return err
+	}
+	if _, err := file.Seek(0, io.SeekEnd); err != nil {
+		file.Close()
+		return err
+	}
+	s.journal.file.Close()
+	s.journal.file = file
+	return nil
+}
@@ -0,0 +1,209 @@
+package store
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+
+	"github.com/google/uuid"
+)
+
+// newIdentity validates an external identity for userID.
+func newIdentity(userID, issuer, subject, email string) (models.ExternalIdentity, error) {
+	if issuer == "" || subject == "" {
+		return models.ExternalIdentity{}, fmt.Errorf("identity needs an issuer and a subject")
+	}
+	return models.ExternalIdentity{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		Issuer:    issuer,
+		Subject:   subject,
+		Email:     strings.TrimSpace(email),
+		CreatedAt: time.Now().UTC(),
+	}, nil
+}
+
+// newExternalUser builds a passwordless account for a user signing in through
+// an identity provider. Malformed addresses from the provider are dropped.
+func newExternalUser(policy *rbac.Policy, username, email, role string) (models.User, error) {
+	username, err := normalizeUsername(username)
+	if err != nil {
+		return models.User{}, err
+	}
+	role, err = normalizeRole(policy, role)
+	if err != nil {
+		return models.User{}, err
+	}
+	if email, err = NormalizeEmail(email); err != nil {
+		email = ""
+	}
+	return models.User{
+		ID:        uuid.NewString(),
+		Username:  username,
+		Email:     email,
+		Role:      role,
+		CreatedAt: time.Now().UTC(),
+		Status:    models.UserStatusActive,
+	}, nil
+}
+
+func (s *Store) identityLocked(issuer, subject string) (models.ExternalIdentity, bool) {
+	for _, identity := range s.identities {
+		if identity.Issuer == issuer && identity.Subject == subject {
+			return identity, true
+		}
+	}
+	return models.ExternalIdentity{}, false
+}
+
+// GetUserByIdentity returns the user linked to the external identity.
+func (s *Store) GetUserByIdentity(issuer, subject string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identity, ok := s.identityLocked(issuer, subject)
+	if !ok {
+		return models.User{}, ErrIdentityNotFound
+	}
+	user, ok := s.userByIDLocked(identity.UserID)
+	if !ok {
+		return models.User{}, ErrIdentityNotFound
+	}
+	return user, nil
+}
+
+// GetUserByEmail returns the only account with email. Addresses are compared
+// case-insensitively.
+func (s *Store) GetUserByEmail(email string) (models.User, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return models.User{}, ErrUserNotFound
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var (
+		found   models.User
+		matches int
+	)
+	for _, user := range s.users {
+		if strings.EqualFold(user.Email, email) {
+			found = user
+			matches++
+		}
+	}
+	if matches != 1 {
+		return models.User{}, ErrUserNotFound
+	}
+	return found, nil
+}
+
+// CreateExternalUser provisions a passwordless account linked to the external
+// identity and records it in the audit log.
+func (s *Store) CreateExternalUser(username, email, role, issuer, subject string) (models.User, error) {
+	user, err := newExternalUser(s.Policy(), username, email, role)
+	if err != nil {
+		return models.User{}, err
+	}
+	identity, err := newIdentity(user.ID, issuer, subject, email)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[usernameKey(user.Username)]; exists {
+		return models.User{}, ErrUserExists
+	}
+	if _, exists := s.identityLocked(issuer, subject); exists {
+		return models.User{}, ErrIdentityExists
+	}
+	event := newAuditEvent(user.ID, AuditUserProvisioned, user.ID, map[string]string{"issuer": issuer, "role": user.Role})
+	// One record, so that replay never sees an account without the identity
+	// it was provisioned for.
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user, Identity: &identity, AuditEvent: &event}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// LinkIdentity links an external identity to an existing user.
+func (s *Store) LinkIdentity(userID, issuer, subject, email string) (models.ExternalIdentity, error) {
+	identity, err := newIdentity(userID, issuer, subject, email)
+	if err != nil {
+		return models.ExternalIdentity{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.ExternalIdentity{}, ErrUserNotFound
+	}
+	if user.ServiceAccount {
+		return models.ExternalIdentity{}, ErrServiceAccount
+	}
+	if _, exists := s.identityLocked(issuer, subject); exists {
+		return models.ExternalIdentity{}, ErrIdentityExists
+	}
+	event := newAuditEvent(userID, AuditIdentityLinked, userID, map[string]string{"issuer": issuer})
+	if err := s.commit(journalRecord{Op: opPutIdentity, Identity: &identity, AuditEvent: &event}); err != nil {
+		return models.ExternalIdentity{}, err
+	}
+	return identity, nil
+}
+
+// ListIdentities returns the external identities linked to a user, oldest
+// first.
+func (s *Store) ListIdentities(userID string) ([]models.ExternalIdentity, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	identities := make([]models.ExternalIdentity, 0)
+	for _, identity := range s.identities {
+		if identity.UserID == userID {
+			identities = append(identities, identity)
+		}
+	}
+	sort.Slice(identities, func(i, j int) bool {
+		return identities[i].CreatedAt.Before(identities[j].CreatedAt)
+	})
+	return identities, nil
+}
+
+// UnlinkIdentity removes one of a user's external identities, unless the
+// account has no password and no other identity to sign in with.
+func (s *Store) UnlinkIdentity(userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	identity, ok := s.identities[id]
+	if !ok || identity.UserID != userID {
+		return ErrIdentityNotFound
+	}
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return ErrIdentityNotFound
+	}
+	if user.PasswordHash == "" {
+		linked := 0
+		for _, other := range s.identities {
+			if other.UserID == userID {
+				linked++
+			}
+		}
+		if linked <= 1 {
+			return ErrLastSignInMethod
+		}
+	}
+
+	event := newAuditEvent(userID, AuditIdentityUnlinked, userID, map[string]string{"issuer": identity.Issuer})
+	return s.commit(journalRecord{Op: opDeleteIdentity, ID: id, AuditEvent: &event})
+}
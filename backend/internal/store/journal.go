@@ -0,0 +1,762 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"assignment3/backend/internal/models"
+)
+
+// SyncPolicy controls when journal writes are flushed to stable storage.
+type SyncPolicy string
+
+const (
+	// SyncAlways fsyncs after every record; no acknowledged write is ever lost.
+	SyncAlways SyncPolicy = "always"
+	// SyncInterval fsyncs in the background every JournalOptions.SyncInterval.
+	SyncInterval SyncPolicy = "interval"
+	// SyncNever leaves flushing to the operating system.
+	SyncNever SyncPolicy = "never"
+)
+
+const (
+	walFileName      = "store.wal"
+	snapshotFileName = "store.snapshot"
+
+	// frameHeaderSize is the length prefix plus the CRC-32 of each record.
+	frameHeaderSize = 8
+	// maxFrameSize guards replay against allocating for a garbage length prefix.
+	maxFrameSize = 16 << 20
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// JournalOptions configures the write-ahead log of a journaled Store.
+type JournalOptions struct {
+	// Dir holds the WAL and snapshot files; it is created if missing.
+	Dir string
+	// Sync selects the fsync policy. Defaults to SyncAlways.
+	Sync SyncPolicy
+	// SyncInterval is the flush period used with SyncInterval. Defaults to one second.
+	SyncInterval time.Duration
+	// CompactEvery folds the WAL into a fresh snapshot after this many records.
+	// Zero disables automatic compaction.
+	CompactEvery int
+}
+
+type journalOp uint8
+
+const (
+	opPutUser journalOp = iota + 1
+	opDeleteUser
+	opPutItem
+	opDeleteItem
+	opPutRefreshToken
+	opDeleteRefreshToken
+	opPutRevokedToken
+	opDeleteRevokedToken
+	opPutRole
+	opDeleteRole
+	opPutAuditEvent
+	opPutPasswordResetToken
+	opDeletePasswordResetToken
+	opPutAccessToken
+	opDeleteAccessToken
+	opPutIdentity
+	opDeleteIdentity
+	opPutSession
+	opDeleteSession
+	opPutTransfer
+	opDeleteTransfer
+	opPutGrant
+	opDeleteGrant
+	// opTransferItem replaces the transfers of the item rec.ID with
+	// rec.Transfer, if any, and stores rec.Item, if given, with its new owner.
+	opTransferItem
+	// opApplyPolicy stores rec.Roles and makes rec.ID the default role.
+	opApplyPolicy
+)
+
+// journalRecord describes the resulting state of a single mutation. Records
+// carry full entities rather than method arguments so that replaying one twice
+// (e.g. after a crash between snapshot and WAL truncation) is harmless.
+type journalRecord struct {
+	Op           journalOp
+	ID           string
+	User         *models.User
+	Item         *models.Item
+	RefreshToken *models.RefreshToken
+	RevokedToken *models.RevokedToken
+	Role         *models.Role
+	AuditEvent   *models.AuditEvent
+
+	PasswordResetToken *models.PasswordResetToken
+	AccessToken        *models.PersonalAccessToken
+	Identity           *models.ExternalIdentity
+	Session            *models.Session
+	Transfer           *models.ItemTransfer
+	Grant              *models.ItemGrant
+
+	// Items and ItemIDs carry the items a user deletion updates and removes.
+	Items   []models.Item
+	ItemIDs []string
+	// Roles carries the roles an applied policy defines.
+	Roles []models.Role
+}
+
+// snapshot is the compacted state written by Compact.
+type snapshot struct {
+	Users         []models.User
+	Items         []models.Item
+	RefreshTokens []models.RefreshToken
+	RevokedTokens []models.RevokedToken
+	Roles         []models.Role
+	AuditEvents   []models.AuditEvent
+	ResetTokens   []models.PasswordResetToken
+	AccessTokens  []models.PersonalAccessToken
+	Identities    []models.ExternalIdentity
+	Sessions      []models.Session
+	Transfers     []models.ItemTransfer
+	Grants        []models.ItemGrant
+	DefaultRole   string
+}
+
+// journal appends checksummed records to the WAL file.
+type journal struct {
+	mu      sync.Mutex
+	dir     string
+	file    *os.File
+	opts    JournalOptions
+	records int
+	dirty   bool
+	// broken is set when a failed append could not be rolled back or the WAL
+	// could not be synced. Records written after torn bytes would be lost on
+	// replay, so appends are refused until a compaction rewrites the WAL.
+	broken error
+	stop   chan struct{}
+	done   chan struct{}
+}
+
+// OpenJournaledStore restores an in-memory store from the snapshot and WAL in
+// opts.Dir and journals every subsequent mutation there.
+func OpenJournaledStore(opts JournalOptions) (*Store, error) {
+	if opts.Dir == "" {
+		return nil, fmt.Errorf("journal directory cannot be empty")
+	}
+	switch opts.Sync {
+	case "":
+		opts.Sync = SyncAlways
+	case SyncAlways, SyncInterval, SyncNever:
+	default:
+		return nil, fmt.Errorf("unsupported journal sync policy %q", opts.Sync)
+	}
+	if opts.SyncInterval <= 0 {
+		opts.SyncInterval = time.Second
+	}
+	if err := os.MkdirAll(opts.Dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create journal directory: %w", err)
+	}
+
+	s := NewStore()
+	if err := s.loadSnapshot(filepath.Join(opts.Dir, snapshotFileName)); err != nil {
+		return nil, err
+	}
+
+	file, err := os.OpenFile(filepath.Join(opts.Dir, walFileName), os.O_RDWR|os.O_CREATE, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open journal: %w", err)
+	}
+	records, err := s.replay(file)
+	if err != nil {
+		_ = file.Close()
+		return nil, err
+	}
+
+	j := &journal{dir: opts.Dir, file: file, opts: opts, records: records}
+	if opts.Sync == SyncInterval {
+		j.stop = make(chan struct{})
+		j.done = make(chan struct{})
+		go j.syncLoop()
+	}
+	s.journal = j
+	return s, nil
+}
+
+// commit journals rec, if journaling is enabled, and applies it to the maps.
+// Callers must hold s.mu for writing.
+func (s *Store) commit(rec journalRecord) error {
+	if s.journal != nil {
+		if err := s.journal.append(rec); err != nil {
+			return err
+		}
+	}
+	s.apply(rec)
+
+	if s.journal != nil && s.journal.dueForCompaction() {
+		if err := s.compactLocked(); err != nil {
+			// The record is already durable in the WAL; compaction can retry later.
+			log.Printf("warning: journal compaction failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// apply mutates the in-memory maps according to rec.
+func (s *Store) apply(rec journalRecord) {
+	switch rec.Op {
+	case opPutUser:
+		s.removeUserByID(rec.User.ID)
+		s.users[usernameKey(rec.User.Username)] = *rec.User
+		// Provisioning stores the account together with its identity.
+		if rec.Identity != nil {
+			s.identities[rec.Identity.ID] = *rec.Identity
+		}
+	case opDeleteUser:
+		s.removeUserByID(rec.ID)
+		for hash, token := range s.refreshTokens {
+			if token.UserID == rec.ID {
+				delete(s.refreshTokens, hash)
+			}
+		}
+		for hash, token := range s.resetTokens {
+			if token.UserID == rec.ID {
+				delete(s.resetTokens, hash)
+			}
+		}
+		for hash, token := range s.accessTokens {
+			if token.UserID == rec.ID {
+				delete(s.accessTokens, hash)
+			}
+		}
+		for id, identity := range s.identities {
+			if identity.UserID == rec.ID {
+				delete(s.identities, id)
+			}
+		}
+		for id, session := range s.sessions {
+			if session.UserID == rec.ID {
+				delete(s.sessions, id)
+			}
+		}
+		for id, transfer := range s.transfers {
+			if transfer.FromID == rec.ID || transfer.ToID == rec.ID {
+				delete(s.transfers, id)
+			}
+		}
+		s.removeGrantsLocked(models.GrantToUser, rec.ID)
+		for _, id := range rec.ItemIDs {
+			s.deleteItemLocked(id)
+		}
+		for _, item := range rec.Items {
+			s.putItemLocked(item)
+		}
+	case opPutItem:
+		s.putItemLocked(*rec.Item)
+	case opDeleteItem:
+		s.deleteItemLocked(rec.ID)
+	case opPutRefreshToken:
+		s.refreshTokens[rec.RefreshToken.TokenHash] = *rec.RefreshToken
+	case opDeleteRefreshToken:
+		delete(s.refreshTokens, rec.RefreshToken.TokenHash)
+	case opPutRevokedToken:
+		s.revokedTokens[rec.RevokedToken.ID] = *rec.RevokedToken
+	case opDeleteRevokedToken:
+		delete(s.revokedTokens, rec.RevokedToken.ID)
+	case opPutRole:
+		s.roles[rec.Role.Name] = *rec.Role
+		s.refreshPolicyLocked(s.Policy().DefaultRole())
+	case opDeleteRole:
+		delete(s.roles, rec.ID)
+		s.removeGrantsLocked(models.GrantToRole, rec.ID)
+		s.refreshPolicyLocked(s.Policy().DefaultRole())
+	case opApplyPolicy:
+		for _, role := range rec.Roles {
+			s.roles[role.Name] = role
+		}
+		s.refreshPolicyLocked(rec.ID)
+	case opPutAuditEvent:
+		// Stored below, like the events other records carry.
+	case opPutPasswordResetToken:
+		s.resetTokens[rec.PasswordResetToken.TokenHash] = *rec.PasswordResetToken
+	case opDeletePasswordResetToken:
+		delete(s.resetTokens, rec.PasswordResetToken.TokenHash)
+	case opPutAccessToken:
+		s.accessTokens[rec.AccessToken.TokenHash] = *rec.AccessToken
+	case opDeleteAccessToken:
+		delete(s.accessTokens, rec.AccessToken.TokenHash)
+	case opPutIdentity:
+		s.identities[rec.Identity.ID] = *rec.Identity
+	case opDeleteIdentity:
+		delete(s.identities, rec.ID)
+	case opPutSession:
+		s.sessions[rec.Session.ID] = *rec.Session
+	case opDeleteSession:
+		delete(s.sessions, rec.ID)
+	case opPutTransfer:
+		s.transfers[rec.Transfer.ID] = *rec.Transfer
+	case opDeleteTransfer:
+		delete(s.transfers, rec.ID)
+	case opTransferItem:
+		for id, transfer := range s.transfers {
+			if transfer.ItemID == rec.ID {
+				delete(s.transfers, id)
+			}
+		}
+		if rec.Item != nil {
+			s.putItemLocked(*rec.Item)
+		}
+		if rec.Transfer != nil {
+			s.transfers[rec.Transfer.ID] = *rec.Transfer
+		}
+	case opPutGrant:
+		grants := s.grants[rec.Grant.ItemID]
+		if i := grantIndex(grants, rec.Grant.SubjectType, rec.Grant.Subject); i >= 0 {
+			grants[i] = *rec.Grant
+		} else {
+			s.grants[rec.Grant.ItemID] = append(grants, *rec.Grant)
+		}
+	case opDeleteGrant:
+		grants := s.grants[rec.Grant.ItemID]
+		if i := grantIndex(grants, rec.Grant.SubjectType, rec.Grant.Subject); i >= 0 {
+			s.grants[rec.Grant.ItemID] = append(grants[:i:i], grants[i+1:]...)
+		}
+	}
+
+	// A change that must not happen unaudited carries its event along, so
+	// that the two are journaled together.
+	if rec.AuditEvent != nil {
+		s.auditEvents[rec.AuditEvent.ID] = *rec.AuditEvent
+	}
+}
+
+// removeGrantsLocked drops every grant to a deleted user or role.
+func (s *Store) removeGrantsLocked(subjectType models.GrantSubject, subject string) {
+	for itemID, grants := range s.grants {
+		if i := grantIndex(grants, subjectType, subject); i >= 0 {
+			s.grants[itemID] = append(grants[:i:i], grants[i+1:]...)
+		}
+	}
+}
+
+// deleteItemLocked removes an item together with its grants and pending
+// transfers, as the foreign keys do in the SQL store.
+func (s *Store) deleteItemLocked(id string) {
+	delete(s.items, id)
+	delete(s.grants, id)
+	for transferID, transfer := range s.transfers {
+		if transfer.ItemID == id {
+			delete(s.transfers, transferID)
+		}
+	}
+}
+
+// putItemLocked stores item without its resolved owner name. Records written
+// before items were owned by ID carry only the owner's username, which is
+// mapped to the user holding it at that point of the replay.
+func (s *Store) putItemLocked(item models.Item) {
+	if item.OwnerID == "" && item.Owner != "" {
+		if owner, ok := s.users[usernameKey(item.Owner)]; ok {
+			item.OwnerID = owner.ID
+		}
+	}
+	if item.Visibility == "" {
+		item.Visibility = models.ItemPublic
+	}
+	item.Owner = ""
+	item.Access = ""
+	s.items[item.ID] = item
+}
+
+func (s *Store) removeUserByID(id string) bool {
+	for key, user := range s.users {
+		if user.ID == id {
+			delete(s.users, key)
+			return true
+		}
+	}
+	return false
+}
+
+// Compact writes the current state to a snapshot and truncates the WAL. It is
+// a no-op when journaling is disabled.
+func (s *Store) Compact() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.journal == nil {
+		return nil
+	}
+	return s.compactLocked()
+}
+
+func (s *Store) compactLocked() error {
+	snap := snapshot{
+		Users:         make([]models.User, 0, len(s.users)),
+		Items:         make([]models.Item, 0, len(s.items)),
+		RefreshTokens: make([]models.RefreshToken, 0, len(s.refreshTokens)),
+		RevokedTokens: make([]models.RevokedToken, 0, len(s.revokedTokens)),
+		Roles:         s.rolesLocked(),
+		AuditEvents:   make([]models.AuditEvent, 0, len(s.auditEvents)),
+		ResetTokens:   make([]models.PasswordResetToken, 0, len(s.resetTokens)),
+		AccessTokens:  make([]models.PersonalAccessToken, 0, len(s.accessTokens)),
+		Identities:    make([]models.ExternalIdentity, 0, len(s.identities)),
+		Sessions:      make([]models.Session, 0, len(s.sessions)),
+		Transfers:     make([]models.ItemTransfer, 0, len(s.transfers)),
+		Grants:        make([]models.ItemGrant, 0, len(s.grants)),
+		DefaultRole:   s.Policy().DefaultRole(),
+	}
+	for _, user := range s.users {
+		snap.Users = append(snap.Users, user)
+	}
+	for _, item := range s.items {
+		snap.Items = append(snap.Items, item)
+	}
+	for _, token := range s.refreshTokens {
+		snap.RefreshTokens = append(snap.RefreshTokens, token)
+	}
+	for _, entry := range s.revokedTokens {
+		snap.RevokedTokens = append(snap.RevokedTokens, entry)
+	}
+	for _, event := range s.auditEvents {
+		snap.AuditEvents = append(snap.AuditEvents, event)
+	}
+	for _, token := range s.resetTokens {
+		snap.ResetTokens = append(snap.ResetTokens, token)
+	}
+	for _, token := range s.accessTokens {
+		snap.AccessTokens = append(snap.AccessTokens, token)
+	}
+	for _, identity := range s.identities {
+		snap.Identities = append(snap.Identities, identity)
+	}
+	for _, session := range s.sessions {
+		snap.Sessions = append(snap.Sessions, session)
+	}
+	for _, transfer := range s.transfers {
+		snap.Transfers = append(snap.Transfers, transfer)
+	}
+	for _, grants := range s.grants {
+		snap.Grants = append(snap.Grants, grants...)
+	}
+
+	payload, err := encodeGob(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode snapshot: %w", err)
+	}
+	if err := writeFileAtomic(filepath.Join(s.journal.dir, snapshotFileName), frame(payload)); err != nil {
+		return err
+	}
+	// A crash before the truncation below only means the WAL is replayed on top
+	// of a snapshot that already contains it, which records tolerate.
+	return s.journal.reset()
+}
+
+func (s *Store) loadSnapshot(path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	payload, n, err := readFrame(bytes.NewReader(data))
+	if err != nil || n != len(data) {
+		// Snapshots are replaced atomically, so damage here is not a torn write.
+		return fmt.Errorf("snapshot %s is corrupt", path)
+	}
+
+	var snap snapshot
+	if err := gob.NewDecoder(bytes.NewReader(payload)).Decode(&snap); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+	for i := range snap.Users {
+		s.apply(journalRecord{Op: opPutUser, User: &snap.Users[i]})
+	}
+	for i := range snap.Items {
+		s.apply(journalRecord{Op: opPutItem, Item: &snap.Items[i]})
+	}
+	for i := range snap.RefreshTokens {
+		s.apply(journalRecord{Op: opPutRefreshToken, RefreshToken: &snap.RefreshTokens[i]})
+	}
+	for i := range snap.RevokedTokens {
+		s.apply(journalRecord{Op: opPutRevokedToken, RevokedToken: &snap.RevokedTokens[i]})
+	}
+	for i := range snap.AuditEvents {
+		s.apply(journalRecord{Op: opPutAuditEvent, AuditEvent: &snap.AuditEvents[i]})
+	}
+	for i := range snap.ResetTokens {
+		s.apply(journalRecord{Op: opPutPasswordResetToken, PasswordResetToken: &snap.ResetTokens[i]})
+	}
+	for i := range snap.AccessTokens {
+		s.apply(journalRecord{Op: opPutAccessToken, AccessToken: &snap.AccessTokens[i]})
+	}
+	for i := range snap.Identities {
+		s.apply(journalRecord{Op: opPutIdentity, Identity: &snap.Identities[i]})
+	}
+	for i := range snap.Sessions {
+		s.apply(journalRecord{Op: opPutSession, Session: &snap.Sessions[i]})
+	}
+	for i := range snap.Transfers {
+		s.apply(journalRecord{Op: opPutTransfer, Transfer: &snap.Transfers[i]})
+	}
+	for i := range snap.Grants {
+		s.apply(journalRecord{Op: opPutGrant, Grant: &snap.Grants[i]})
+	}
+	// Snapshots written before roles were stored keep the seeded defaults.
+	if len(snap.Roles) > 0 {
+		s.roles = make(map[string]models.Role, len(snap.Roles))
+		for _, role := range snap.Roles {
+			s.roles[role.Name] = role
+		}
+		// So do snapshots written before the default role was stored.
+		defaultRole := snap.DefaultRole
+		if defaultRole == "" {
+			defaultRole = s.Policy().DefaultRole()
+		}
+		s.refreshPolicyLocked(defaultRole)
+	}
+	return nil
+}
+
+// replay applies every intact record in file and truncates a damaged tail.
+// It returns the number of records replayed.
+func (s *Store) replay(file *os.File) (int, error) {
+	reader := bufio.NewReader(file)
+	var (
+		offset  int64
+		records int
+	)
+	for {
+		payload, n, err := readFrame(reader)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		var rec journalRecord
+		if err == nil {
+			err = gob.NewDecoder(bytes.NewReader(payload)).Decode(&rec)
+		}
+		if err != nil {
+			log.Printf("warning: truncating journal at offset %d: %v", offset, err)
+			if err := file.Truncate(offset); err != nil {
+				return 0, fmt.Errorf("failed to truncate journal: %w", err)
+			}
+			break
+		}
+
+		s.apply(rec)
+		offset += int64(n)
+		records++
+	}
+
+	if _, err := file.Seek(offset, io.SeekStart); err != nil {
+		return 0, fmt.Errorf("failed to seek journal: %w", err)
+	}
+	return records, nil
+}
+
+func (j *journal) append(rec journalRecord) error {
+	payload, err := encodeGob(rec)
+	if err != nil {
+		return fmt.Errorf("failed to encode journal record: %w", err)
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if j.broken != nil {
+		return fmt.Errorf("journal is unusable after a failed append: %w", j.broken)
+	}
+	offset, err := j.file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return fmt.Errorf("failed to seek journal: %w", err)
+	}
+	if _, err := j.file.Write(frame(payload)); err != nil {
+		// Replay stops at the first bad frame, so a partial record must not
+		// stay in front of the ones that follow.
+		if rollbackErr := j.truncateLocked(offset); rollbackErr != nil {
+			j.broken = rollbackErr
+		}
+		return fmt.Errorf("failed to append to journal: %w", err)
+	}
+	j.dirty = true
+
+	if j.opts.Sync == SyncAlways {
+		if err := j.syncLocked(); err != nil {
+			// The caller does not apply the record, so it must not come back
+			// on replay either. After a failed fsync the file can no longer
+			// be trusted, hence no further appends until a compaction.
+			if rollbackErr := j.truncateLocked(offset); rollbackErr != nil {
+				err = errors.Join(err, rollbackErr)
+			}
+			j.broken = err
+			return err
+		}
+	}
+	j.records++
+	return nil
+}
+
+func (j *journal) dueForCompaction() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.opts.CompactEvery > 0 && j.records >= j.opts.CompactEvery
+}
+
+// reset empties the WAL after its contents were captured in a snapshot.
+func (j *journal) reset() error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if err := j.truncateLocked(0); err != nil {
+		return err
+	}
+	j.records = 0
+	j.dirty = true
+	j.broken = nil
+	return j.syncLocked()
+}
+
+// truncateLocked cuts the WAL at offset and continues writing from there.
+func (j *journal) truncateLocked(offset int64) error {
+	if err := j.file.Truncate(offset); err != nil {
+		return fmt.Errorf("failed to truncate journal: %w", err)
+	}
+	if _, err := j.file.Seek(offset, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to seek journal: %w", err)
+	}
+	return nil
+}
+
+func (j *journal) syncLocked() error {
+	if !j.dirty {
+		return nil
+	}
+	if err := j.file.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal: %w", err)
+	}
+	j.dirty = false
+	return nil
+}
+
+func (j *journal) syncLoop() {
+	defer close(j.done)
+	ticker := time.NewTicker(j.opts.SyncInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			j.mu.Lock()
+			if err := j.syncLocked(); err != nil {
+				log.Printf("warning: %v", err)
+			}
+			j.mu.Unlock()
+		case <-j.stop:
+			return
+		}
+	}
+}
+
+func (j *journal) close() error {
+	if j.stop != nil {
+		close(j.stop)
+		<-j.done
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	syncErr := j.syncLocked()
+	if err := j.file.Close(); err != nil {
+		return fmt.Errorf("failed to close journal: %w", err)
+	}
+	return syncErr
+}
+
+// frame prefixes payload with its length and CRC-32C checksum.
+func frame(payload []byte) []byte {
+	buf := make([]byte, frameHeaderSize+len(payload))
+	binary.LittleEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.LittleEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crcTable))
+	copy(buf[frameHeaderSize:], payload)
+	return buf
+}
+
+// readFrame reads one framed payload and reports the number of bytes consumed.
+// It returns io.EOF only at a clean record boundary.
+func readFrame(r io.Reader) ([]byte, int, error) {
+	var header [frameHeaderSize]byte
+	if _, err := io.ReadFull(r, header[:]); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, 0, io.EOF
+		}
+		return nil, 0, fmt.Errorf("torn record header: %w", err)
+	}
+
+	size := binary.LittleEndian.Uint32(header[0:4])
+	if size > maxFrameSize {
+		return nil, 0, fmt.Errorf("record length %d exceeds limit", size)
+	}
+	payload := make([]byte, size)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, 0, fmt.Errorf("torn record body: %w", err)
+	}
+	if crc32.Checksum(payload, crcTable) != binary.LittleEndian.Uint32(header[4:8]) {
+		return nil, 0, fmt.Errorf("record checksum mismatch")
+	}
+	return payload, frameHeaderSize + int(size), nil
+}
+
+func encodeGob(v any) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic replaces path with data via a synced temporary file.
+func writeFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create snapshot: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("failed to sync snapshot: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to close snapshot: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to install snapshot: %w", err)
+	}
+	return syncDir(filepath.Dir(path))
+}
+
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return fmt.Errorf("failed to open journal directory: %w", err)
+	}
+	defer d.Close()
+	if err := d.Sync(); err != nil {
+		return fmt.Errorf("failed to sync journal directory: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,680 @@
+package store_test
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+	"assignment3/backend/internal/store"
+)
+
+func openJournal(t *testing.T, dir string, compactEvery int) *store.Store {
+	t.Helper()
+	st, err := store.OpenJournaledStore(store.JournalOptions{Dir: dir, CompactEvery: compactEvery})
+	if err != nil {
+		t.Fatalf("OpenJournaledStore returned error: %v", err)
+	}
+	return st
+}
+
+func TestJournalReplaysMutations(t *testing.T) {
+	for _, compactEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		st := openJournal(t, dir, compactEvery)
+
+		// A pre-existing "admin" without the admin role exercises role repair.
+		if _, err := st.CreateUser("admin", "secret", "user"); err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		if _, _, err := st.EnsureAdminUser("admin", "secret"); err != nil {
+			t.Fatalf("EnsureAdminUser returned error: %v", err)
+		}
+		bob, err := st.CreateUser("bob", "password123", "user")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		kept, err := st.CreateItem("admin", "Kept", "", "")
+		if err != nil {
+			t.Fatalf("CreateItem returned error: %v", err)
+		}
+		if _, err := st.UpdateItem(kept.ID, "admin", "user", "Kept and edited", "desc"); err != nil {
+			t.Fatalf("UpdateItem returned error: %v", err)
+		}
+		dropped, err := st.CreateItem("bob", "Dropped", "", "")
+		if err != nil {
+			t.Fatalf("CreateItem returned error: %v", err)
+		}
+		if err := st.DeleteItem(dropped.ID); err != nil {
+			t.Fatalf("DeleteItem returned error: %v", err)
+		}
+		if _, err := st.DeleteUser(bob.ID, store.UserDeletion{}); err != nil {
+			t.Fatalf("DeleteUser returned error: %v", err)
+		}
+		if err := st.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+
+		reopened := openJournal(t, dir, compactEvery)
+		defer reopened.Close()
+
+		admin, err := reopened.Authenticate("admin", "secret")
+		if err != nil {
+			t.Fatalf("Authenticate after replay returned error: %v", err)
+		}
+		if admin.Role != "admin" {
+			t.Fatalf("expected repaired admin role to survive replay, got %q", admin.Role)
+		}
+		if _, err := reopened.GetUser(bob.ID); !errors.Is(err, store.ErrUserNotFound) {
+			t.Fatalf("expected deleted user to stay deleted, got %v", err)
+		}
+		items, err := reopened.ListItems("", "admin")
+		if err != nil {
+			t.Fatalf("ListItems returned error: %v", err)
+		}
+		if len(items) != 1 || items[0].Title != "Kept and edited" || items[0].Description != "desc" {
+			t.Fatalf("unexpected items after replay (compactEvery=%d): %+v", compactEvery, items)
+		}
+	}
+}
+
+func TestJournalCompactionWritesSnapshot(t *testing.T) {
+	dir := t.TempDir()
+	st := openJournal(t, dir, 0)
+
+	for _, title := range []string{"one", "two", "three"} {
+		if _, err := st.CreateItem("alice", title, "", ""); err != nil {
+			t.Fatalf("CreateItem returned error: %v", err)
+		}
+	}
+	if err := st.Compact(); err != nil {
+		t.Fatalf("Compact returned error: %v", err)
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "store.wal"))
+	if err != nil {
+		t.Fatalf("failed to stat wal: %v", err)
+	}
+	if info.Size() != 0 {
+		t.Fatalf("expected empty wal after compaction, got %d bytes", info.Size())
+	}
+
+	if _, err := st.CreateItem("alice", "four", "", ""); err != nil {
+		t.Fatalf("CreateItem returned error: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	reopened := openJournal(t, dir, 0)
+	defer reopened.Close()
+	items, err := reopened.ListItems("", "admin")
+	if err != nil {
+		t.Fatalf("ListItems returned error: %v", err)
+	}
+	if len(items) != 4 || items[0].Title != "one" || items[3].Title != "four" {
+		t.Fatalf("unexpected items after snapshot + wal replay: %+v", items)
+	}
+}
+
+func TestJournalTruncatesCorruptTail(t *testing.T) {
+	dir := t.TempDir()
+	st := openJournal(t, dir, 0)
+
+	if _, err := st.CreateItem("alice", "intact", "", ""); err != nil {
+		t.Fatalf("CreateItem returned error: %v", err)
+	}
+	walPath := filepath.Join(dir, "store.wal")
+	info, err := os.Stat(walPath)
+	if err != nil {
+		t.Fatalf("failed to stat wal: %v", err)
+	}
+	intactSize := info.Size()
+
+	if _, err := st.CreateItem("alice", "damaged", "", ""); err != nil {
+		t.Fatalf("CreateItem returned error: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	// Flip a byte inside the second record's payload.
+	data, err := os.ReadFile(walPath)
+	if err != nil {
+		t.Fatalf("failed to read wal: %v", err)
+	}
+	data[len(data)-2] ^= 0xFF
+	if err := os.WriteFile(walPath, data, 0o600); err != nil {
+		t.Fatalf("failed to corrupt wal: %v", err)
+	}
+
+	reopened := openJournal(t, dir, 0)
+	items, err := reopened.ListItems("", "admin")
+	if err != nil {
+		t.Fatalf("ListItems returned error: %v", err)
+	}
+	if len(items) != 1 || items[0].Title != "intact" {
+		t.Fatalf("expected only the intact record to survive, got %+v", items)
+	}
+	if info, err := os.Stat(walPath); err != nil || info.Size() != intactSize {
+		t.Fatalf("expected wal truncated to %d bytes, got %v (err %v)", intactSize, info.Size(), err)
+	}
+
+	// New writes after truncation must replay cleanly.
+	if _, err := reopened.CreateItem("alice", "after", "", ""); err != nil {
+		t.Fatalf("CreateItem returned error: %v", err)
+	}
+	if err := reopened.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+	again := openJournal(t, dir, 0)
+	defer again.Close()
+	if items, _ := again.ListItems("", "admin"); len(items) != 2 {
+		t.Fatalf("expected 2 items after reopening, got %+v", items)
+	}
+}
+
+func TestJournalTruncatesTornWrite(t *testing.T) {
+	dir := t.TempDir()
+	st := openJournal(t, dir, 0)
+	if _, err := st.CreateItem("alice", "intact", "", ""); err != nil {
+		t.Fatalf("CreateItem returned error: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	// Simulate a crash midway through writing a record header.
+	f, err := os.OpenFile(filepath.Join(dir, "store.wal"), os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatalf("failed to open wal: %v", err)
+	}
+	if _, err := f.Write([]byte{0x10, 0x00, 0x00}); err != nil {
+		t.Fatalf("failed to append torn header: %v", err)
+	}
+	_ = f.Close()
+
+	reopened := openJournal(t, dir, 0)
+	defer reopened.Close()
+	if items, _ := reopened.ListItems("", "admin"); len(items) != 1 {
+		t.Fatalf("expected the intact item to survive, got %+v", items)
+	}
+}
+
+func TestJournalRefusesAppendsAfterFailedWrite(t *testing.T) {
+	dir := t.TempDir()
+	st := openJournal(t, dir, 0)
+	if _, err := st.CreateItem("alice", "intact", "", ""); err != nil {
+		t.Fatalf("CreateItem returned error: %v", err)
+	}
+
+	if err := store.BreakJournalWrites(st); err != nil {
+		t.Fatalf("BreakJournalWrites returned error: %v", err)
+	}
+	for _, title := range []string{"lost", "refused"} {
+		if _, err := st.CreateItem("alice", title, "", ""); err == nil {
+			t.Fatalf("expected CreateItem(%q) to fail once the journal cannot be written", title)
+		}
+	}
+	if items, _ := st.ListItems("", "admin"); len(items) != 1 {
+		t.Fatalf("expected failed writes to leave the store unchanged, got %+v", items)
+	}
+	_ = st.Close()
+
+	reopened := openJournal(t, dir, 0)
+	defer reopened.Close()
+	if items, _ := reopened.ListItems("", "admin"); len(items) != 1 || items[0].Title != "intact" {
+		t.Fatalf("expected only the intact item after replay, got %+v", items)
+	}
+}
+
+func TestJournalPersistsAuditedUserChanges(t *testing.T) {
+	for _, compactEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		st := openJournal(t, dir, compactEvery)
+
+		admin, err := st.CreateUser("root", "password123", "admin")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		bob, err := st.CreateUser("bob", "password123", "user")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		if _, err := st.SetUserRole(admin.ID, bob.ID, "admin"); err != nil {
+			t.Fatalf("SetUserRole returned error: %v", err)
+		}
+		if _, err := st.ChangeUsername(admin.ID, bob.ID, "robert"); err != nil {
+			t.Fatalf("ChangeUsername returned error: %v", err)
+		}
+		if _, err := st.ResetPassword(admin.ID, bob.ID, "temporary123"); err != nil {
+			t.Fatalf("ResetPassword returned error: %v", err)
+		}
+		if _, err := st.SetUserStatus(admin.ID, bob.ID, models.UserStatusSuspended, "audit", nil); err != nil {
+			t.Fatalf("SetUserStatus returned error: %v", err)
+		}
+		if _, err := st.BeginMFAEnrollment(bob.ID, "JBSWY3DPEHPK3PXP"); err != nil {
+			t.Fatalf("BeginMFAEnrollment returned error: %v", err)
+		}
+		if _, err := st.DisableMFA(admin.ID, bob.ID); err != nil {
+			t.Fatalf("DisableMFA returned error: %v", err)
+		}
+		ci, err := st.CreateServiceAccount(admin.ID, "ci", "user", []string{"items:read"}, "client-ci", "secret-hash")
+		if err != nil {
+			t.Fatalf("CreateServiceAccount returned error: %v", err)
+		}
+		if _, err := st.RotateClientSecret(admin.ID, ci.ID, "rotated-hash"); err != nil {
+			t.Fatalf("RotateClientSecret returned error: %v", err)
+		}
+		want := []string{store.AuditUserRoleChanged, store.AuditUserRenamed, store.AuditUserPasswordReset, store.AuditUserStatusChanged, store.AuditUserMFADisabled,
+			store.AuditServiceAccountCreated, store.AuditClientSecretRotated}
+		if err := st.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+
+		reopened := openJournal(t, dir, compactEvery)
+		if got, err := reopened.GetUser(bob.ID); err != nil || got.Role != "admin" || got.Username != "robert" || got.Status != models.UserStatusSuspended {
+			t.Fatalf("compactEvery=%d: expected bob to stay a renamed, suspended admin, got %+v, %v", compactEvery, got, err)
+		}
+		events, err := reopened.ListAuditEvents(0)
+		if err != nil {
+			t.Fatalf("ListAuditEvents returned error: %v", err)
+		}
+		var actions []string
+		for _, event := range events {
+			actions = append(actions, event.Action+":"+event.ActorID)
+		}
+		for i := range want {
+			want[i] += ":" + admin.ID
+		}
+		sort.Strings(actions)
+		sort.Strings(want)
+		if got := strings.Join(actions, ","); got != strings.Join(want, ",") {
+			t.Fatalf("compactEvery=%d: unexpected audit events after replay: %v", compactEvery, got)
+		}
+		reopened.Close()
+	}
+}
+
+func TestJournalPersistsRoles(t *testing.T) {
+	for _, compactEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		st := openJournal(t, dir, compactEvery)
+
+		if _, err := st.CreateRole("auditor", []string{"items:read"}); err != nil {
+			t.Fatalf("CreateRole returned error: %v", err)
+		}
+		if _, err := st.CreateRole("temp", nil); err != nil {
+			t.Fatalf("CreateRole returned error: %v", err)
+		}
+		if err := st.DeleteRole("temp"); err != nil {
+			t.Fatalf("DeleteRole returned error: %v", err)
+		}
+		if err := st.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+
+		reopened := openJournal(t, dir, compactEvery)
+		roles, err := reopened.ListRoles()
+		if err != nil {
+			t.Fatalf("ListRoles returned error: %v", err)
+		}
+		if len(roles) != 3 || roles[1].Name != "auditor" {
+			t.Fatalf("compactEvery=%d: unexpected roles after reopen: %+v", compactEvery, roles)
+		}
+		if !reopened.Policy().Can("auditor", "items:read") || reopened.Policy().HasRole("temp") {
+			t.Fatalf("compactEvery=%d: policy not restored from the journal", compactEvery)
+		}
+		_ = reopened.Close()
+	}
+}
+
+func TestJournalPersistsDefaultRole(t *testing.T) {
+	for _, compactEvery := range []int{0, 1} {
+		dir := t.TempDir()
+		st := openJournal(t, dir, compactEvery)
+
+		policy, err := rbac.NewPolicy("reader", map[string][]rbac.Permission{
+			"reader": {rbac.ItemsRead},
+		})
+		if err != nil {
+			t.Fatalf("NewPolicy returned error: %v", err)
+		}
+		if err := st.ApplyPolicy(policy); err != nil {
+			t.Fatalf("ApplyPolicy returned error: %v", err)
+		}
+		if err := st.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+
+		// The policy is not applied again on reopen.
+		reopened := openJournal(t, dir, compactEvery)
+		if got := reopened.Policy().DefaultRole(); got != "reader" {
+			t.Fatalf("compactEvery=%d: expected default role reader after reopen, got %q", compactEvery, got)
+		}
+		user, err := reopened.CreateUser("rita", "password123", "")
+		if err != nil || user.Role != "reader" {
+			t.Fatalf("compactEvery=%d: expected the stored default role, got %+v (err %v)", compactEvery, user, err)
+		}
+		_ = reopened.Close()
+	}
+}
+
+func TestJournalPersistsPasswordResetTokens(t *testing.T) {
+	for _, compactEvery := range []int{0, 1} {
+		dir := t.TempDir()
+		st := openJournal(t, dir, compactEvery)
+
+		alice, err := st.CreateUser("alice", "password123", "user")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		if _, err := st.CreatePasswordResetToken(alice.ID, "hash-1", time.Now().Add(time.Hour)); err != nil {
+			t.Fatalf("CreatePasswordResetToken returned error: %v", err)
+		}
+		if err := st.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+
+		reopened := openJournal(t, dir, compactEvery)
+		if _, err := reopened.ResetPasswordWithToken("hash-1", "reset-pass"); err != nil {
+			t.Fatalf("compactEvery=%d: expected the token to survive a restart, got %v", compactEvery, err)
+		}
+		_ = reopened.Close()
+
+		again := openJournal(t, dir, compactEvery)
+		if _, err := again.ResetPasswordWithToken("hash-1", "other-pass"); !errors.Is(err, store.ErrResetTokenInvalid) {
+			t.Fatalf("compactEvery=%d: expected the spent token to stay spent, got %v", compactEvery, err)
+		}
+		_ = again.Close()
+	}
+}
+
+func TestJournalPersistsAccessTokens(t *testing.T) {
+	for _, compactEvery := range []int{0, 1} {
+		dir := t.TempDir()
+		st := openJournal(t, dir, compactEvery)
+
+		alice, err := st.CreateUser("alice", "password123", "user")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		if _, err := st.CreateAccessToken(alice.ID, "ci", "pat_1", "hash-1", []string{"items:read"}, nil); err != nil {
+			t.Fatalf("CreateAccessToken returned error: %v", err)
+		}
+		if _, err := st.UseAccessToken("hash-1"); err != nil {
+			t.Fatalf("UseAccessToken returned error: %v", err)
+		}
+		if err := st.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+
+		reopened := openJournal(t, dir, compactEvery)
+		token, err := reopened.UseAccessToken("hash-1")
+		if err != nil {
+			t.Fatalf("compactEvery=%d: expected the token to survive a restart, got %v", compactEvery, err)
+		}
+		if token.LastUsedAt == nil {
+			t.Fatalf("compactEvery=%d: expected the last use to survive a restart", compactEvery)
+		}
+		_ = reopened.Close()
+	}
+}
+
+func TestJournalPersistsIdentities(t *testing.T) {
+	for _, compactEvery := range []int{0, 1} {
+		dir := t.TempDir()
+		st := openJournal(t, dir, compactEvery)
+
+		alice, err := st.CreateExternalUser("alice", "alice@example.com", "", "https://idp", "alice-sub")
+		if err != nil {
+			t.Fatalf("CreateExternalUser returned error: %v", err)
+		}
+		bob, err := st.CreateUser("bob", "password123", "user")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		linked, err := st.LinkIdentity(bob.ID, "https://idp", "bob-sub", "")
+		if err != nil {
+			t.Fatalf("LinkIdentity returned error: %v", err)
+		}
+		if err := st.UnlinkIdentity(bob.ID, linked.ID); err != nil {
+			t.Fatalf("UnlinkIdentity returned error: %v", err)
+		}
+		if err := st.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+
+		reopened := openJournal(t, dir, compactEvery)
+		user, err := reopened.GetUserByIdentity("https://idp", "alice-sub")
+		if err != nil || user.ID != alice.ID {
+			t.Fatalf("compactEvery=%d: expected the identity to survive a restart, got %+v (err %v)", compactEvery, user, err)
+		}
+		if _, err := reopened.GetUserByIdentity("https://idp", "bob-sub"); !errors.Is(err, store.ErrIdentityNotFound) {
+			t.Fatalf("compactEvery=%d: expected the unlinked identity to stay gone, got %v", compactEvery, err)
+		}
+		events, err := reopened.ListAuditEvents(0)
+		if err != nil {
+			t.Fatalf("ListAuditEvents returned error: %v", err)
+		}
+		var actions []string
+		for _, event := range events {
+			actions = append(actions, event.Action)
+		}
+		sort.Strings(actions)
+		if got := strings.Join(actions, ","); got != store.AuditIdentityLinked+","+store.AuditIdentityUnlinked+","+store.AuditUserProvisioned {
+			t.Fatalf("compactEvery=%d: unexpected audit events after replay: %v", compactEvery, got)
+		}
+		_ = reopened.Close()
+	}
+}
+
+func TestJournalPersistsSessions(t *testing.T) {
+	for _, compactEvery := range []int{0, 1} {
+		dir := t.TempDir()
+		st := openJournal(t, dir, compactEvery)
+
+		alice, err := st.CreateUser("alice", "password123", "user")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		expiresAt := time.Now().Add(time.Hour)
+		kept, err := st.CreateSession(alice.ID, "family-1", "Firefox", "10.0.0.1", expiresAt)
+		if err != nil {
+			t.Fatalf("CreateSession returned error: %v", err)
+		}
+		revoked, err := st.CreateSession(alice.ID, "family-2", "Safari", "10.0.0.2", expiresAt)
+		if err != nil {
+			t.Fatalf("CreateSession returned error: %v", err)
+		}
+		if err := st.RevokeSession(alice.ID, revoked.ID); err != nil {
+			t.Fatalf("RevokeSession returned error: %v", err)
+		}
+		if err := st.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+
+		reopened := openJournal(t, dir, compactEvery)
+		sessions, err := reopened.ListSessions(alice.ID)
+		if err != nil {
+			t.Fatalf("ListSessions returned error: %v", err)
+		}
+		if len(sessions) != 1 || sessions[0].ID != kept.ID || sessions[0].UserAgent != "Firefox" {
+			t.Fatalf("compactEvery=%d: expected only the active session to survive a restart, got %+v", compactEvery, sessions)
+		}
+		if _, err := reopened.UseSession(revoked.ID, ""); !errors.Is(err, store.ErrSessionInvalid) {
+			t.Fatalf("compactEvery=%d: expected the revoked session to stay revoked, got %v", compactEvery, err)
+		}
+		_ = reopened.Close()
+	}
+}
+
+func TestJournalPersistsUserDeletion(t *testing.T) {
+	dir := t.TempDir()
+	st := openJournal(t, dir, 0)
+
+	alice, err := st.CreateUser("alice", "password123", "user")
+	if err != nil {
+		t.Fatalf("CreateUser returned error: %v", err)
+	}
+	bob, err := st.CreateUser("bob", "password123", "user")
+	if err != nil {
+		t.Fatalf("CreateUser returned error: %v", err)
+	}
+	if _, err := st.CreateItem(alice.ID, "moved", "", ""); err != nil {
+		t.Fatalf("CreateItem returned error: %v", err)
+	}
+	if _, err := st.CreateItem(bob.ID, "dropped", "", ""); err != nil {
+		t.Fatalf("CreateItem returned error: %v", err)
+	}
+	if _, err := st.DeleteUser(bob.ID, store.UserDeletion{Items: store.ItemsCascade}); err != nil {
+		t.Fatalf("DeleteUser returned error: %v", err)
+	}
+	carol, err := st.CreateUser("carol", "password123", "user")
+	if err != nil {
+		t.Fatalf("CreateUser returned error: %v", err)
+	}
+	if _, err := st.DeleteUser(alice.ID, store.UserDeletion{Items: store.ItemsReassign, ReassignTo: carol.ID}); err != nil {
+		t.Fatalf("DeleteUser returned error: %v", err)
+	}
+	if err := st.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	reopened := openJournal(t, dir, 0)
+	defer reopened.Close()
+
+	items, err := reopened.ListItems("", "admin")
+	if err != nil {
+		t.Fatalf("ListItems returned error: %v", err)
+	}
+	if len(items) != 1 || items[0].Title != "moved" || items[0].OwnerID != carol.ID || items[0].Owner != "carol" {
+		t.Fatalf("unexpected items after replay: %+v", items)
+	}
+}
+
+func TestJournalPersistsTransfers(t *testing.T) {
+	for _, compactEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		st := openJournal(t, dir, compactEvery)
+
+		alice, err := st.CreateUser("alice", "password123", "user")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		bob, err := st.CreateUser("bob", "password123", "user")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		kept, err := st.CreateItem(alice.ID, "kept", "", "")
+		if err != nil {
+			t.Fatalf("CreateItem returned error: %v", err)
+		}
+		given, err := st.CreateItem(alice.ID, "given", "", "")
+		if err != nil {
+			t.Fatalf("CreateItem returned error: %v", err)
+		}
+		expires := time.Now().Add(time.Hour)
+		pending, err := st.ProposeTransfer(kept.ID, alice.ID, "user", bob.ID, false, expires)
+		if err != nil {
+			t.Fatalf("ProposeTransfer returned error: %v", err)
+		}
+		offer, err := st.ProposeTransfer(given.ID, alice.ID, "user", bob.ID, false, expires)
+		if err != nil {
+			t.Fatalf("ProposeTransfer returned error: %v", err)
+		}
+		if _, err := st.RespondToTransfer(offer.ID, bob.ID, true); err != nil {
+			t.Fatalf("RespondToTransfer returned error: %v", err)
+		}
+		forced, err := st.CreateItem(alice.ID, "forced", "", "")
+		if err != nil {
+			t.Fatalf("CreateItem returned error: %v", err)
+		}
+		if _, err := st.ProposeTransfer(forced.ID, alice.ID, "user", bob.ID, false, expires); err != nil {
+			t.Fatalf("ProposeTransfer returned error: %v", err)
+		}
+		if _, err := st.ProposeTransfer(forced.ID, alice.ID, "admin", bob.ID, true, expires); err != nil {
+			t.Fatalf("forced ProposeTransfer returned error: %v", err)
+		}
+		if err := st.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+
+		reopened := openJournal(t, dir, compactEvery)
+		transfers, err := reopened.ListTransfers(bob.ID)
+		if err != nil {
+			t.Fatalf("ListTransfers returned error: %v", err)
+		}
+		if len(transfers) != 1 || transfers[0].ID != pending.ID || transfers[0].To != "bob" {
+			t.Fatalf("compactEvery=%d: unexpected transfers after replay: %+v", compactEvery, transfers)
+		}
+		got, err := reopened.GetItem(given.ID, "", "admin")
+		if err != nil {
+			t.Fatalf("GetItem returned error: %v", err)
+		}
+		if got.OwnerID != bob.ID {
+			t.Fatalf("compactEvery=%d: expected accepted transfer to persist, got owner %q", compactEvery, got.OwnerID)
+		}
+		if got, err = reopened.GetItem(forced.ID, "", "admin"); err != nil || got.OwnerID != bob.ID {
+			t.Fatalf("compactEvery=%d: expected forced transfer to persist, got %+v (err %v)", compactEvery, got, err)
+		}
+		reopened.Close()
+	}
+}
+
+func TestJournalPersistsGrants(t *testing.T) {
+	for _, compactEvery := range []int{0, 2} {
+		dir := t.TempDir()
+		st := openJournal(t, dir, compactEvery)
+
+		alice, err := st.CreateUser("alice", "password123", "user")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		bob, err := st.CreateUser("bob", "password123", "user")
+		if err != nil {
+			t.Fatalf("CreateUser returned error: %v", err)
+		}
+		item, err := st.CreateItem(alice.ID, "shared", "", models.ItemShared)
+		if err != nil {
+			t.Fatalf("CreateItem returned error: %v", err)
+		}
+		if _, err := st.GrantItemAccess(item.ID, alice.ID, alice.Role, models.GrantToUser, bob.ID, models.ItemViewer); err != nil {
+			t.Fatalf("GrantItemAccess returned error: %v", err)
+		}
+		if _, err := st.GrantItemAccess(item.ID, alice.ID, alice.Role, models.GrantToUser, bob.ID, models.ItemEditor); err != nil {
+			t.Fatalf("GrantItemAccess returned error: %v", err)
+		}
+		if _, err := st.GrantItemAccess(item.ID, alice.ID, alice.Role, models.GrantToRole, "admin", models.ItemViewer); err != nil {
+			t.Fatalf("GrantItemAccess returned error: %v", err)
+		}
+		if err := st.RevokeItemAccess(item.ID, alice.ID, alice.Role, models.GrantToRole, "admin"); err != nil {
+			t.Fatalf("RevokeItemAccess returned error: %v", err)
+		}
+		if err := st.Close(); err != nil {
+			t.Fatalf("Close returned error: %v", err)
+		}
+
+		reopened := openJournal(t, dir, compactEvery)
+		got, err := reopened.GetItem(item.ID, bob.ID, bob.Role)
+		if err != nil {
+			t.Fatalf("compactEvery=%d: GetItem returned error: %v", compactEvery, err)
+		}
+		if got.Visibility != models.ItemShared || got.Access != models.ItemEditor {
+			t.Fatalf("compactEvery=%d: unexpected item after replay: %+v", compactEvery, got)
+		}
+		grants, err := reopened.ListItemGrants(item.ID, alice.ID, alice.Role)
+		if err != nil {
+			t.Fatalf("ListItemGrants returned error: %v", err)
+		}
+		if len(grants) != 1 || grants[0].SubjectName != "bob" {
+			t.Fatalf("compactEvery=%d: unexpected grants after replay: %+v", compactEvery, grants)
+		}
+		reopened.Close()
+	}
+}
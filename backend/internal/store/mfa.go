@@ -0,0 +1,166 @@
+package store
+
+import (
+	"crypto/subtle"
+	"strings"
+
+	"assignment3/backend/internal/models"
+)
+
+// spendRecoveryCode returns codes without codeHash and whether it was there.
+func spendRecoveryCode(codes []string, codeHash string) ([]string, bool) {
+	remaining := make([]string, 0, len(codes))
+	found := false
+	for _, code := range codes {
+		if !found && subtle.ConstantTimeCompare([]byte(code), []byte(codeHash)) == 1 {
+			found = true
+			continue
+		}
+		remaining = append(remaining, code)
+	}
+	return remaining, found
+}
+
+func joinRecoveryCodes(codes []string) string {
+	return strings.Join(codes, " ")
+}
+
+func splitRecoveryCodes(codes string) []string {
+	return strings.Fields(codes)
+}
+
+// clearMFA removes a user's authenticator and recovery codes.
+func clearMFA(user *models.User) {
+	user.MFAEnabled = false
+	user.MFASecret = ""
+	user.MFALastCounter = 0
+	user.RecoveryCodes = nil
+}
+
+// BeginMFAEnrollment stores a new, unconfirmed TOTP secret for a user,
+// replacing any earlier unconfirmed one.
+func (s *Store) BeginMFAEnrollment(userID, secret string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	if user.MFAEnabled {
+		return models.User{}, ErrMFAAlreadyEnabled
+	}
+	user.MFASecret = secret
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// EnableMFA confirms a pending enrollment once a code for counter has been
+// verified against its secret, and stores the recovery code hashes.
+func (s *Store) EnableMFA(userID string, counter int64, recoveryCodeHashes []string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	if user.MFAEnabled {
+		return models.User{}, ErrMFAAlreadyEnabled
+	}
+	if user.MFASecret == "" {
+		return models.User{}, ErrMFANotEnrolled
+	}
+	user.MFAEnabled = true
+	user.MFALastCounter = counter
+	user.RecoveryCodes = append([]string(nil), recoveryCodeHashes...)
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// UseMFACode records that the code for counter was accepted. Codes for the
+// same or an earlier time step are refused with ErrMFACodeReused.
+func (s *Store) UseMFACode(userID string, counter int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return ErrUserNotFound
+	}
+	if !user.MFAEnabled {
+		return ErrMFANotEnrolled
+	}
+	if counter <= user.MFALastCounter {
+		return ErrMFACodeReused
+	}
+	user.MFALastCounter = counter
+	return s.commit(journalRecord{Op: opPutUser, User: &user})
+}
+
+// UseRecoveryCode spends one of a user's recovery codes.
+func (s *Store) UseRecoveryCode(userID, codeHash string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	if !user.MFAEnabled {
+		return models.User{}, ErrMFANotEnrolled
+	}
+	remaining, found := spendRecoveryCode(user.RecoveryCodes, codeHash)
+	if !found {
+		return models.User{}, ErrRecoveryCodeInvalid
+	}
+	user.RecoveryCodes = remaining
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// ReplaceRecoveryCodes swaps a user's recovery codes for new ones.
+func (s *Store) ReplaceRecoveryCodes(userID string, recoveryCodeHashes []string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	if !user.MFAEnabled {
+		return models.User{}, ErrMFANotEnrolled
+	}
+	user.RecoveryCodes = append([]string(nil), recoveryCodeHashes...)
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// DisableMFA removes a user's authenticator and recovery codes and records
+// the change in the audit log under actorID.
+func (s *Store) DisableMFA(actorID, userID string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	if !user.MFAEnabled && user.MFASecret == "" {
+		return models.User{}, ErrMFANotEnrolled
+	}
+	clearMFA(&user)
+	event := newAuditEvent(actorID, AuditUserMFADisabled, user.ID, nil)
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user, AuditEvent: &event}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
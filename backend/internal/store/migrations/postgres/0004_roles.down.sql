@@ -0,0 +1,2 @@
+DROP INDEX IF EXISTS users_role_idx;
+DROP TABLE IF EXISTS roles;
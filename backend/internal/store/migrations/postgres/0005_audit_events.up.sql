@@ -0,0 +1,10 @@
+CREATE TABLE audit_events (
+    id         TEXT PRIMARY KEY,
+    actor_id   TEXT NOT NULL,
+    action     TEXT NOT NULL,
+    target_id  TEXT NOT NULL,
+    details    TEXT NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX audit_events_created_at_idx ON audit_events (created_at);
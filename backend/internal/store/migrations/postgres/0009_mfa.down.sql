@@ -0,0 +1,6 @@
+ALTER TABLE roles DROP COLUMN require_mfa;
+
+ALTER TABLE users DROP COLUMN recovery_codes;
+ALTER TABLE users DROP COLUMN mfa_last_counter;
+ALTER TABLE users DROP COLUMN mfa_secret;
+ALTER TABLE users DROP COLUMN mfa_enabled;
@@ -0,0 +1,6 @@
+DROP INDEX IF EXISTS users_client_id_idx;
+
+ALTER TABLE users DROP COLUMN scopes;
+ALTER TABLE users DROP COLUMN client_secret_hash;
+ALTER TABLE users DROP COLUMN client_id;
+ALTER TABLE users DROP COLUMN service_account;
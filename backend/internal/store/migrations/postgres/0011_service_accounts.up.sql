@@ -0,0 +1,6 @@
+ALTER TABLE users ADD COLUMN service_account BOOLEAN NOT NULL DEFAULT FALSE;
+ALTER TABLE users ADD COLUMN client_id TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN client_secret_hash TEXT NOT NULL DEFAULT '';
+ALTER TABLE users ADD COLUMN scopes TEXT NOT NULL DEFAULT '';
+
+CREATE UNIQUE INDEX users_client_id_idx ON users (client_id) WHERE client_id <> '';
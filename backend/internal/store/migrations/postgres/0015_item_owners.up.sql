@@ -0,0 +1,3 @@
+UPDATE items SET owner = '' WHERE owner <> '' AND NOT EXISTS (SELECT 1 FROM users WHERE users.username = items.owner);
+
+CREATE INDEX items_owner_idx ON items (owner);
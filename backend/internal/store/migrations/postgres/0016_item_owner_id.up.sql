@@ -0,0 +1,8 @@
+ALTER TABLE items ADD COLUMN owner_id TEXT NOT NULL DEFAULT '';
+
+UPDATE items SET owner_id = COALESCE((SELECT users.id FROM users WHERE users.username = items.owner), '');
+
+DROP INDEX IF EXISTS items_owner_idx;
+ALTER TABLE items DROP COLUMN owner;
+
+CREATE INDEX items_owner_id_idx ON items (owner_id);
@@ -0,0 +1,11 @@
+CREATE TABLE item_transfers (
+    id           TEXT PRIMARY KEY,
+    item_id      TEXT NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+    from_user_id TEXT NOT NULL DEFAULT '',
+    to_user_id   TEXT NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    created_at   TIMESTAMPTZ NOT NULL,
+    expires_at   TIMESTAMPTZ NOT NULL
+);
+
+CREATE UNIQUE INDEX item_transfers_item_id_idx ON item_transfers (item_id);
+CREATE INDEX item_transfers_to_user_id_idx ON item_transfers (to_user_id);
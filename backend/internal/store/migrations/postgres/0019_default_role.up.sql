@@ -0,0 +1,3 @@
+ALTER TABLE roles ADD COLUMN is_default BOOLEAN NOT NULL DEFAULT FALSE;
+
+UPDATE roles SET is_default = TRUE WHERE name = 'user';
@@ -0,0 +1,9 @@
+CREATE TABLE revoked_tokens (
+    id         TEXT PRIMARY KEY,
+    jti        TEXT NOT NULL DEFAULT '',
+    user_id    TEXT NOT NULL,
+    revoked_at TIMESTAMP NOT NULL,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX revoked_tokens_expires_at_idx ON revoked_tokens (expires_at);
@@ -0,0 +1,12 @@
+CREATE TABLE roles (
+    name        TEXT PRIMARY KEY,
+    permissions TEXT NOT NULL,
+    created_at  TIMESTAMP NOT NULL,
+    updated_at  TIMESTAMP NOT NULL
+);
+
+INSERT INTO roles (name, permissions, created_at, updated_at) VALUES
+    ('user', 'items:read,items:create,items:update:own', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP),
+    ('admin', 'items:read,items:create,items:update:own,items:update:any,items:delete,users:manage', CURRENT_TIMESTAMP, CURRENT_TIMESTAMP);
+
+CREATE INDEX users_role_idx ON users (role);
@@ -0,0 +1,8 @@
+ALTER TABLE items ADD COLUMN owner TEXT NOT NULL DEFAULT '';
+
+UPDATE items SET owner = COALESCE((SELECT users.username FROM users WHERE users.id = items.owner_id), '');
+
+DROP INDEX IF EXISTS items_owner_id_idx;
+ALTER TABLE items DROP COLUMN owner_id;
+
+CREATE INDEX items_owner_idx ON items (owner);
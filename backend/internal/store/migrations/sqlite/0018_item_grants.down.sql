@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS item_grants;
+
+ALTER TABLE items DROP COLUMN visibility;
@@ -0,0 +1,12 @@
+ALTER TABLE items ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public';
+
+CREATE TABLE item_grants (
+    item_id      TEXT NOT NULL REFERENCES items (id) ON DELETE CASCADE,
+    subject_type TEXT NOT NULL,
+    subject      TEXT NOT NULL,
+    access       TEXT NOT NULL,
+    created_at   TIMESTAMP NOT NULL,
+    PRIMARY KEY (item_id, subject_type, subject)
+);
+
+CREATE INDEX item_grants_subject_idx ON item_grants (subject_type, subject);
@@ -0,0 +1,3 @@
+ALTER TABLE roles ADD COLUMN is_default BOOLEAN NOT NULL DEFAULT 0;
+
+UPDATE roles SET is_default = 1 WHERE name = 'user';
@@ -0,0 +1,130 @@
+package store
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// NormalizeEmail trims an email address and rejects malformed ones. Display
+// names such as "Alice <alice@example.com>" are not accepted.
+func NormalizeEmail(email string) (string, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return "", nil
+	}
+	addr, err := mail.ParseAddress(email)
+	if err != nil || addr.Address != email || addr.Name != "" {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+func newPasswordResetToken(userID, tokenHash string, expiresAt time.Time) models.PasswordResetToken {
+	return models.PasswordResetToken{
+		ID:        uuid.NewString(),
+		UserID:    userID,
+		TokenHash: tokenHash,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt.UTC(),
+	}
+}
+
+// SetUserEmail replaces a user's email address; an empty address removes it.
+func (s *Store) SetUserEmail(userID, email string) (models.User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	user.Email = email
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// CreatePasswordResetToken stores the hash of a self-service reset token,
+// replacing any the user requested before.
+func (s *Store) CreatePasswordResetToken(userID, tokenHash string, expiresAt time.Time) (models.PasswordResetToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.userExistsLocked(userID) {
+		return models.PasswordResetToken{}, ErrUserNotFound
+	}
+	if err := s.dropPasswordResetTokensLocked(userID); err != nil {
+		return models.PasswordResetToken{}, err
+	}
+	token := newPasswordResetToken(userID, tokenHash, expiresAt)
+	if err := s.commit(journalRecord{Op: opPutPasswordResetToken, PasswordResetToken: &token}); err != nil {
+		return models.PasswordResetToken{}, err
+	}
+	return token, nil
+}
+
+// ResetPasswordWithToken spends a reset token and sets newPassword, clearing
+// any pending forced password change.
+func (s *Store) ResetPasswordWithToken(tokenHash, newPassword string) (models.User, error) {
+	if newPassword == "" {
+		return models.User{}, fmt.Errorf("password cannot be empty")
+	}
+	hashed, err := hashPassword(newPassword)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.resetTokens[tokenHash]
+	if !ok || !time.Now().Before(token.ExpiresAt) {
+		return models.User{}, ErrResetTokenInvalid
+	}
+	user, ok := s.userByIDLocked(token.UserID)
+	if !ok {
+		return models.User{}, ErrResetTokenInvalid
+	}
+	user.PasswordHash = hashed
+	user.MustChangePassword = false
+	if err := s.setPasswordLocked(user, nil); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// setPasswordLocked stores a user whose password changed and invalidates their
+// outstanding reset tokens, which were issued for the old password. An audit
+// event, if given, is journaled in the same record as the user.
+func (s *Store) setPasswordLocked(user models.User, event *models.AuditEvent) error {
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user, AuditEvent: event}); err != nil {
+		return err
+	}
+	return s.dropPasswordResetTokensLocked(user.ID)
+}
+
+// dropPasswordResetTokensLocked deletes the reset tokens of userID along with
+// any expired ones.
+func (s *Store) dropPasswordResetTokensLocked(userID string) error {
+	now := time.Now()
+	for _, token := range s.resetTokens {
+		if token.UserID == userID || !now.Before(token.ExpiresAt) {
+			if err := s.commit(journalRecord{Op: opDeletePasswordResetToken, PasswordResetToken: &token}); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
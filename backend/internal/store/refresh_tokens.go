@@ -0,0 +1,146 @@
+package store
+
+import (
+	"time"
+
+	"assignment3/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+// CreateRefreshToken stores the hash of a refresh token that starts a new
+// family. Expired tokens of the same user are pruned along the way.
+func (s *Store) CreateRefreshToken(userID, tokenHash string, expiresAt time.Time) (models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.userExistsLocked(userID) {
+		return models.RefreshToken{}, ErrUserNotFound
+	}
+
+	now := time.Now().UTC()
+	for _, token := range s.refreshTokens {
+		if token.UserID == userID && now.After(token.ExpiresAt) {
+			if err := s.commit(journalRecord{Op: opDeleteRefreshToken, RefreshToken: &token}); err != nil {
+				return models.RefreshToken{}, err
+			}
+		}
+	}
+
+	id := uuid.NewString()
+	token := models.RefreshToken{
+		ID:        id,
+		UserID:    userID,
+		FamilyID:  id,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		ExpiresAt: expiresAt.UTC(),
+	}
+	if err := s.commit(journalRecord{Op: opPutRefreshToken, RefreshToken: &token}); err != nil {
+		return models.RefreshToken{}, err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken spends the token identified by tokenHash and stores its
+// successor in the same family. Presenting a token that was already rotated
+// revokes the family and returns ErrRefreshTokenReused.
+func (s *Store) RotateRefreshToken(tokenHash, newTokenHash string, expiresAt time.Time) (models.RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current, ok := s.refreshTokens[tokenHash]
+	if !ok {
+		return models.RefreshToken{}, ErrRefreshTokenInvalid
+	}
+
+	now := time.Now().UTC()
+	if current.RotatedAt != nil {
+		if err := s.revokeRefreshTokensLocked(now, func(t models.RefreshToken) bool {
+			return t.FamilyID == current.FamilyID
+		}); err != nil {
+			return models.RefreshToken{}, err
+		}
+		return models.RefreshToken{}, ErrRefreshTokenReused
+	}
+	if current.RevokedAt != nil || now.After(current.ExpiresAt) {
+		return models.RefreshToken{}, ErrRefreshTokenInvalid
+	}
+
+	current.RotatedAt = &now
+	if err := s.commit(journalRecord{Op: opPutRefreshToken, RefreshToken: &current}); err != nil {
+		return models.RefreshToken{}, err
+	}
+
+	next := models.RefreshToken{
+		ID:        uuid.NewString(),
+		UserID:    current.UserID,
+		FamilyID:  current.FamilyID,
+		TokenHash: newTokenHash,
+		CreatedAt: now,
+		ExpiresAt: expiresAt.UTC(),
+	}
+	if err := s.commit(journalRecord{Op: opPutRefreshToken, RefreshToken: &next}); err != nil {
+		return models.RefreshToken{}, err
+	}
+	if err := s.touchSessionLocked(next.FamilyID, now, next.ExpiresAt); err != nil {
+		return models.RefreshToken{}, err
+	}
+	return next, nil
+}
+
+// RevokeUserRefreshTokens revokes every outstanding refresh token of a user.
+func (s *Store) RevokeUserRefreshTokens(userID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.revokeRefreshTokensLocked(time.Now().UTC(), func(t models.RefreshToken) bool {
+		return t.UserID == userID
+	})
+}
+
+// revokeRefreshTokensLocked revokes the tokens that match together with the
+// sessions of their families.
+func (s *Store) revokeRefreshTokensLocked(now time.Time, match func(models.RefreshToken) bool) error {
+	families := make(map[string]bool)
+	for _, token := range s.refreshTokens {
+		if !match(token) {
+			continue
+		}
+		families[token.FamilyID] = true
+		if token.RevokedAt != nil {
+			continue
+		}
+		token.RevokedAt = &now
+		if err := s.commit(journalRecord{Op: opPutRefreshToken, RefreshToken: &token}); err != nil {
+			return err
+		}
+	}
+	return s.revokeSessionsLocked(now, func(session models.Session) bool {
+		return families[session.ID]
+	})
+}
+
+func (s *Store) userExistsLocked(id string) bool {
+	for _, user := range s.users {
+		if user.ID == id {
+			return true
+		}
+	}
+	return false
+}
+
+// RevokeRefreshTokenFamily revokes the token identified by tokenHash together
+// with every token rotated from the same login.
+func (s *Store) RevokeRefreshTokenFamily(tokenHash string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	token, ok := s.refreshTokens[tokenHash]
+	if !ok {
+		return ErrRefreshTokenInvalid
+	}
+	return s.revokeRefreshTokensLocked(time.Now().UTC(), func(t models.RefreshToken) bool {
+		return t.FamilyID == token.FamilyID
+	})
+}
@@ -0,0 +1,380 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Repository is the persistence contract used by the HTTP layer. The in-memory
+// Store and the SQL-backed stores all satisfy it.
+type Repository interface {
+	EnsureAdminUser(username, password string) (models.User, bool, error)
+	CreateUser(username, password, role string) (models.User, error)
+	// RegisterUser creates a self-service account with the policy's default
+	// role and the given email address, which may be empty. It returns
+	// ErrInvalidEmail for a malformed address.
+	RegisterUser(username, password, email string) (models.User, error)
+	Authenticate(username, password string) (models.User, error)
+	ListUsers() ([]models.User, error)
+	GetUser(id string) (models.User, error)
+	// GetUserByUsername looks a user up by case-insensitive username.
+	GetUserByUsername(username string) (models.User, error)
+	// SetUserEmail replaces a user's email address; an empty address removes it.
+	SetUserEmail(userID, email string) (models.User, error)
+	// ChangeUsername renames a user and records the change in the audit log
+	// under actorID. It returns ErrUserExists if another user has the name.
+	ChangeUsername(actorID, userID, username string) (models.User, error)
+	// DeleteUser removes a user and disposes of its items as opts selects,
+	// returning how many items were affected. Deleting the last user who can
+	// manage users fails with ErrLastAdmin.
+	DeleteUser(id string, opts UserDeletion) (int, error)
+	// SetUserRole assigns a role and records the change in the audit log.
+	// Demoting the last user who can manage users fails with ErrLastAdmin.
+	SetUserRole(actorID, userID, role string) (models.User, error)
+	// SetUserStatus moves a user to status and records the change in the
+	// audit log under actorID. Suspensions end at until, if given. Making the
+	// last active user who can manage users inactive fails with ErrLastAdmin.
+	SetUserStatus(actorID, userID string, status models.UserStatus, reason string, until *time.Time) (models.User, error)
+	// ChangePassword replaces a user's password after checking the current
+	// one and clears MustChangePassword. It returns ErrInvalidCredentials if
+	// currentPassword is wrong and ErrPasswordUnchanged if nothing would change.
+	ChangePassword(userID, currentPassword, newPassword string) (models.User, error)
+	// ResetPassword sets a temporary password that must be changed on the next
+	// sign-in and records the reset in the audit log under actorID.
+	ResetPassword(actorID, userID, temporaryPassword string) (models.User, error)
+	// CreatePasswordResetToken stores the hash of a self-service reset token,
+	// replacing any the user requested before.
+	CreatePasswordResetToken(userID, tokenHash string, expiresAt time.Time) (models.PasswordResetToken, error)
+	// ResetPasswordWithToken spends a reset token and sets newPassword. It
+	// returns ErrResetTokenInvalid for unknown, used or expired tokens.
+	ResetPasswordWithToken(tokenHash, newPassword string) (models.User, error)
+
+	// BeginMFAEnrollment stores a new, unconfirmed TOTP secret for a user. It
+	// returns ErrMFAAlreadyEnabled once the user has confirmed one.
+	BeginMFAEnrollment(userID, secret string) (models.User, error)
+	// EnableMFA confirms the pending enrollment after a code for counter was
+	// verified, and stores the hashes of the user's recovery codes.
+	EnableMFA(userID string, counter int64, recoveryCodeHashes []string) (models.User, error)
+	// UseMFACode records that the TOTP code for counter was accepted. It
+	// returns ErrMFACodeReused unless counter is newer than the last one.
+	UseMFACode(userID string, counter int64) error
+	// UseRecoveryCode spends a recovery code, returning ErrRecoveryCodeInvalid
+	// for unknown or spent ones.
+	UseRecoveryCode(userID, codeHash string) (models.User, error)
+	// ReplaceRecoveryCodes swaps a user's recovery codes for new ones.
+	ReplaceRecoveryCodes(userID string, recoveryCodeHashes []string) (models.User, error)
+	// DisableMFA removes a user's authenticator and recovery codes and records
+	// the change in the audit log under actorID.
+	DisableMFA(actorID, userID string) (models.User, error)
+
+	// CreateServiceAccount adds a service account holding role, limited to
+	// scopes the role grants, and records it in the audit log under actorID.
+	CreateServiceAccount(actorID, name, role string, scopes []string, clientID, secretHash string) (models.User, error)
+	// AuthenticateClient returns the service account with clientID if
+	// secretHash matches its secret, and ErrInvalidCredentials otherwise.
+	AuthenticateClient(clientID, secretHash string) (models.User, error)
+	// RotateClientSecret replaces a service account's secret and records the
+	// rotation in the audit log under actorID.
+	RotateClientSecret(actorID, userID, secretHash string) (models.User, error)
+
+	// GetUserByIdentity returns the user linked to an external identity, or
+	// ErrIdentityNotFound.
+	GetUserByIdentity(issuer, subject string) (models.User, error)
+	// GetUserByEmail returns the only account with email. It returns
+	// ErrUserNotFound when no account or more than one has the address.
+	GetUserByEmail(email string) (models.User, error)
+	// CreateExternalUser provisions a passwordless account linked to an
+	// external identity and records it in the audit log.
+	CreateExternalUser(username, email, role, issuer, subject string) (models.User, error)
+	// LinkIdentity links an external identity to a user. It returns
+	// ErrIdentityExists if the identity is linked already.
+	LinkIdentity(userID, issuer, subject, email string) (models.ExternalIdentity, error)
+	// ListIdentities returns the external identities linked to a user.
+	ListIdentities(userID string) ([]models.ExternalIdentity, error)
+	// UnlinkIdentity removes one of a user's external identities. It returns
+	// ErrLastSignInMethod if the account could no longer sign in.
+	UnlinkIdentity(userID, id string) error
+
+	// CreateAccessToken stores the hash of a new personal access token. It
+	// returns ErrAccessTokenExists if the user already has one named name.
+	CreateAccessToken(userID, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (models.PersonalAccessToken, error)
+	// ListAccessTokens returns a user's personal access tokens, newest first.
+	ListAccessTokens(userID string) ([]models.PersonalAccessToken, error)
+	// DeleteAccessToken revokes one of a user's personal access tokens.
+	DeleteAccessToken(userID, id string) error
+	// UseAccessToken looks up a personal access token by hash and records that
+	// it was used. It returns ErrAccessTokenInvalid for unknown or expired ones.
+	UseAccessToken(tokenHash string) (models.PersonalAccessToken, error)
+
+	// ListItems returns the items the user with requesterID and role may
+	// see, each with the access the user has to it.
+	ListItems(requesterID, role string) ([]models.Item, error)
+	// GetItem returns an item with the access the user with requesterID and
+	// role has to it. Items the user may not see are not found.
+	GetItem(id, requesterID, role string) (models.Item, error)
+	// CreateItem adds an item owned by the user with ownerID. An empty
+	// visibility makes it public.
+	CreateItem(ownerID, title, description string, visibility models.ItemVisibility) (models.Item, error)
+	// UpdateItem edits an item if the policy lets role update it, given
+	// whether the user with requesterID owns it or was made an editor;
+	// otherwise it returns ErrForbidden.
+	UpdateItem(id, requesterID, role, title, description string) (models.Item, error)
+	DeleteItem(id string) error
+	// SetItemVisibility changes who can see an item. Like grants, it is up
+	// to the owner and users who may update any item.
+	SetItemVisibility(id, requesterID, role string, visibility models.ItemVisibility) (models.Item, error)
+	// ListItemGrants returns the grants of an item the requester may manage,
+	// users first.
+	ListItemGrants(id, requesterID, role string) ([]models.ItemGrant, error)
+	// GrantItemAccess gives the user with ID subject, or everyone holding
+	// the role subject, viewer or editor access to an item, replacing an
+	// earlier grant to them. It returns ErrInvalidGrant for unknown
+	// subjects, the owner, and other access levels.
+	GrantItemAccess(id, requesterID, role string, subjectType models.GrantSubject, subject string, access models.ItemAccess) (models.ItemGrant, error)
+	// RevokeItemAccess removes a grant, returning ErrGrantNotFound if the
+	// item has none for the subject.
+	RevokeItemAccess(id, requesterID, role string, subjectType models.GrantSubject, subject string) error
+	// EffectiveItemAccess lists every user who can see an item, with what
+	// they may do and why, ordered by username.
+	EffectiveItemAccess(id string) ([]models.EffectiveAccess, error)
+
+	// ProposeTransfer offers an item to the user with toID until expiresAt.
+	// The requester must be allowed to edit the item; it returns
+	// ErrTransferPending if the item is already on offer. With force, which
+	// needs items:update:any, the item changes hands at once.
+	ProposeTransfer(itemID, requesterID, role, toID string, force bool, expiresAt time.Time) (models.ItemTransfer, error)
+	// ListTransfers returns the pending transfers sent or offered to a user,
+	// or all of them if userID is empty, newest first.
+	ListTransfers(userID string) ([]models.ItemTransfer, error)
+	// RespondToTransfer accepts or declines a transfer offered to the user
+	// with userID. It returns ErrTransferNotFound for unknown or expired ones.
+	RespondToTransfer(id, userID string, accept bool) (models.ItemTransfer, error)
+	// CancelTransfer withdraws a pending transfer on behalf of its sender or
+	// a user who may edit any item.
+	CancelTransfer(id, requesterID, role string) (models.ItemTransfer, error)
+
+	// CreateRefreshToken stores the hash of a refresh token that starts a new family.
+	CreateRefreshToken(userID, tokenHash string, expiresAt time.Time) (models.RefreshToken, error)
+	// RotateRefreshToken spends the token identified by tokenHash and stores its
+	// successor in the same family. Presenting a token that was already rotated
+	// revokes the family and returns ErrRefreshTokenReused.
+	RotateRefreshToken(tokenHash, newTokenHash string, expiresAt time.Time) (models.RefreshToken, error)
+	// RevokeRefreshTokenFamily revokes a refresh token and every token rotated
+	// from the same login.
+	RevokeRefreshTokenFamily(tokenHash string) error
+	// RevokeUserRefreshTokens revokes every outstanding refresh token of a user.
+	RevokeUserRefreshTokens(userID string) error
+
+	// CreateSession records the device a refresh token family was issued to.
+	// It returns ErrSessionExists if the family has a session already.
+	CreateSession(userID, familyID, userAgent, ip string, expiresAt time.Time) (models.Session, error)
+	// ListSessions returns a user's active sessions, most recently seen first.
+	ListSessions(userID string) ([]models.Session, error)
+	// UseSession looks up an active session and records that it was seen from
+	// ip. It returns ErrSessionInvalid for unknown, expired or revoked ones.
+	UseSession(id, ip string) (models.Session, error)
+	// RevokeSession signs a user out of one session and revokes its refresh
+	// tokens. It returns ErrSessionNotFound if the user has no such active
+	// session.
+	RevokeSession(userID, id string) error
+
+	// RevokeAccessToken denylists a single access token until it expires.
+	RevokeAccessToken(jti, userID string, expiresAt time.Time) error
+	// RevokeUserAccessTokens denylists every access token issued to a user so far.
+	RevokeUserAccessTokens(userID string, expiresAt time.Time) error
+	// IsAccessTokenRevoked reports whether an access token has been denylisted.
+	IsAccessTokenRevoked(jti, userID string, issuedAt time.Time) (bool, error)
+
+	ListRoles() ([]models.Role, error)
+	GetRole(name string) (models.Role, error)
+	CreateRole(name string, permissions []string) (models.Role, error)
+	// UpdateRole replaces the permissions of a role. It returns
+	// ErrLastManagerRole if no role would be left with users:manage, and
+	// ErrLastAdmin if no user would be left with it.
+	UpdateRole(name string, permissions []string) (models.Role, error)
+	// SetRoleMFARequired sets whether users holding a role must use
+	// two-factor authentication.
+	SetRoleMFARequired(name string, required bool) (models.Role, error)
+	// DeleteRole removes a role that is neither assigned to any user nor the
+	// default role, and is not the last role with users:manage.
+	DeleteRole(name string) error
+	// ApplyPolicy creates or overwrites the roles defined by policy, leaving
+	// other stored roles alone, and stores its default role.
+	ApplyPolicy(policy *rbac.Policy) error
+	// Policy returns the role to permission mapping built from the stored roles.
+	Policy() *rbac.Policy
+
+	// ListAuditEvents returns up to limit audit events, newest first.
+	ListAuditEvents(limit int) ([]models.AuditEvent, error)
+
+	// Close releases any resources held by the repository.
+	Close() error
+}
+
+var (
+	_ Repository = (*Store)(nil)
+	_ Repository = (*SQLStore)(nil)
+)
+
+// newUser validates the input and builds a user record with a hashed password.
+func newUser(username, password, role string) (models.User, error) {
+	username, err := normalizeUsername(username)
+	if err != nil {
+		return models.User{}, err
+	}
+	if password == "" {
+		return models.User{}, fmt.Errorf("password cannot be empty")
+	}
+
+	hashed, err := hashPassword(password)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	return models.User{
+		ID:           uuid.NewString(),
+		Username:     username,
+		PasswordHash: hashed,
+		Role:         role,
+		CreatedAt:    time.Now().UTC(),
+		Status:       models.UserStatusActive,
+	}, nil
+}
+
+// normalizeUsername trims a username and rejects empty ones.
+func normalizeUsername(username string) (string, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return "", fmt.Errorf("username cannot be empty")
+	}
+	return username, nil
+}
+
+func hashPassword(password string) (string, error) {
+	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return "", fmt.Errorf("failed to hash password: %w", err)
+	}
+	return string(hashed), nil
+}
+
+var (
+	dummyHashOnce sync.Once
+	dummyHash     []byte
+)
+
+// burnPasswordCheck spends the time of a bcrypt comparison for a sign-in by an
+// unknown user, so response times do not reveal which usernames exist.
+func burnPasswordCheck(password string) {
+	dummyHashOnce.Do(func() {
+		dummyHash, _ = bcrypt.GenerateFromPassword([]byte("dummy password"), bcrypt.DefaultCost)
+	})
+	_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+}
+
+// verifyPassword checks password against user's hash. Service accounts and
+// users provisioned by an identity provider have no password and take as long
+// to reject as a wrong one.
+func verifyPassword(user models.User, password string) error {
+	if user.ServiceAccount || user.PasswordHash == "" {
+		burnPasswordCheck(password)
+		return ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)) != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+// newPasswordHash checks a password change against the current hash: the old
+// password must match when verify is set, and the new one must differ.
+func newPasswordHash(user models.User, currentPassword, newPassword string, verify bool) (string, error) {
+	if user.ServiceAccount {
+		return "", ErrServiceAccount
+	}
+	if newPassword == "" {
+		return "", fmt.Errorf("password cannot be empty")
+	}
+	if verify && bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(currentPassword)) != nil {
+		return "", ErrInvalidCredentials
+	}
+	if bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(newPassword)) == nil {
+		return "", ErrPasswordUnchanged
+	}
+	return hashPassword(newPassword)
+}
+
+var defaultPolicy = rbac.DefaultPolicy()
+
+// policyHolder caches the policy built from a store's roles, defaulting to the
+// built-in one.
+type policyHolder struct {
+	policy atomic.Pointer[rbac.Policy]
+}
+
+// Policy returns the role to permission mapping built from the stored roles.
+func (h *policyHolder) Policy() *rbac.Policy {
+	if p := h.policy.Load(); p != nil {
+		return p
+	}
+	return defaultPolicy
+}
+
+func (h *policyHolder) setPolicy(policy *rbac.Policy) {
+	h.policy.Store(policy)
+}
+
+// normalizeRole applies the policy's default role and rejects roles the
+// policy does not define.
+func normalizeRole(policy *rbac.Policy, role string) (string, error) {
+	role = rbac.NormalizeRole(role)
+	if role == "" {
+		role = policy.DefaultRole()
+	}
+	if !policy.HasRole(role) {
+		return "", ErrInvalidRole
+	}
+	return role, nil
+}
+
+// newItem validates the input and builds an item record.
+func newItem(ownerID, title, description string, visibility models.ItemVisibility) (models.Item, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return models.Item{}, fmt.Errorf("title cannot be empty")
+	}
+	visibility, err := normalizeVisibility(visibility)
+	if err != nil {
+		return models.Item{}, err
+	}
+
+	now := time.Now().UTC()
+	return models.Item{
+		ID:          uuid.NewString(),
+		Title:       title,
+		Description: strings.TrimSpace(description),
+		OwnerID:     ownerID,
+		Visibility:  visibility,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}, nil
+}
+
+// ownsItem reports whether the user with userID owns item. Tombstoned items
+// have no owner.
+func ownsItem(item models.Item, userID string) bool {
+	return item.OwnerID != "" && item.OwnerID == userID
+}
+
+func usernameKey(username string) string {
+	return strings.ToLower(strings.TrimSpace(username))
+}
@@ -0,0 +1,329 @@
+package store
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+)
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,31}$`)
+
+// defaultRoles returns the roles of the built-in policy, used to seed new stores.
+func defaultRoles(now time.Time) []models.Role {
+	roles := make([]models.Role, 0, len(defaultPolicy.Roles()))
+	for _, name := range defaultPolicy.Roles() {
+		roles = append(roles, models.Role{
+			Name:        name,
+			Permissions: permissionStrings(defaultPolicy.Permissions(name)),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		})
+	}
+	return roles
+}
+
+func permissionStrings(perms []rbac.Permission) []string {
+	out := make([]string, len(perms))
+	for i, perm := range perms {
+		out[i] = string(perm)
+	}
+	return out
+}
+
+// normalizeRoleName canonicalises a role name and rejects malformed ones.
+func normalizeRoleName(name string) (string, error) {
+	name = rbac.NormalizeRole(name)
+	if !roleNamePattern.MatchString(name) {
+		return "", ErrInvalidRole
+	}
+	return name, nil
+}
+
+// normalizePermissions validates permissions and returns them deduplicated in
+// canonical order.
+func normalizePermissions(perms []string) ([]string, error) {
+	set := make(map[rbac.Permission]struct{}, len(perms))
+	for _, perm := range perms {
+		p := rbac.Permission(strings.TrimSpace(perm))
+		if !rbac.ValidPermission(p) {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidPermission, perm)
+		}
+		set[p] = struct{}{}
+	}
+	out := make([]string, 0, len(set))
+	for _, p := range rbac.AllPermissions {
+		if _, ok := set[p]; ok {
+			out = append(out, string(p))
+		}
+	}
+	return out, nil
+}
+
+func roleGrants(role models.Role, perm rbac.Permission) bool {
+	for _, p := range role.Permissions {
+		if rbac.Permission(p) == perm {
+			return true
+		}
+	}
+	return false
+}
+
+// requireManagerRole returns ErrLastManagerRole unless some role in roles
+// grants users:manage.
+func requireManagerRole(roles []models.Role) error {
+	for _, role := range roles {
+		if roleGrants(role, rbac.UsersManage) {
+			return nil
+		}
+	}
+	return ErrLastManagerRole
+}
+
+// replaceRole returns roles with name swapped for next, or removed when next is nil.
+func replaceRole(roles []models.Role, name string, next *models.Role) []models.Role {
+	out := make([]models.Role, 0, len(roles)+1)
+	found := false
+	for _, role := range roles {
+		if role.Name == name {
+			found = true
+			if next == nil {
+				continue
+			}
+			role = *next
+		}
+		out = append(out, role)
+	}
+	if !found && next != nil {
+		out = append(out, *next)
+	}
+	return out
+}
+
+// buildPolicy turns stored roles into the policy enforced by the store.
+func buildPolicy(defaultRole string, roles []models.Role) (*rbac.Policy, error) {
+	mapping := make(map[string][]rbac.Permission, len(roles))
+	var mfaRoles []string
+	for _, role := range roles {
+		perms := make([]rbac.Permission, len(role.Permissions))
+		for i, p := range role.Permissions {
+			perms[i] = rbac.Permission(p)
+		}
+		mapping[role.Name] = perms
+		if role.RequireMFA {
+			mfaRoles = append(mfaRoles, role.Name)
+		}
+	}
+	policy, err := rbac.NewPolicy(defaultRole, mapping)
+	if err != nil {
+		return nil, err
+	}
+	return policy.WithMFARequired(mfaRoles...)
+}
+
+// policyRoles converts the roles defined by policy into models, keeping the
+// creation time of roles that already exist.
+func policyRoles(policy *rbac.Policy, existing []models.Role, now time.Time) []models.Role {
+	created := make(map[string]time.Time, len(existing))
+	for _, role := range existing {
+		created[role.Name] = role.CreatedAt
+	}
+
+	roles := make([]models.Role, 0, len(policy.Roles()))
+	for _, name := range policy.Roles() {
+		role := models.Role{
+			Name:        name,
+			Permissions: permissionStrings(policy.Permissions(name)),
+			RequireMFA:  policy.RequiresMFA(name),
+			CreatedAt:   now,
+			UpdatedAt:   now,
+		}
+		if at, ok := created[name]; ok {
+			role.CreatedAt = at
+		}
+		roles = append(roles, role)
+	}
+	return roles
+}
+
+func (s *Store) rolesLocked() []models.Role {
+	roles := make([]models.Role, 0, len(s.roles))
+	for _, role := range s.roles {
+		roles = append(roles, role)
+	}
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+	return roles
+}
+
+// refreshPolicyLocked rebuilds the cached policy after the roles changed.
+func (s *Store) refreshPolicyLocked(defaultRole string) {
+	policy, err := buildPolicy(defaultRole, s.rolesLocked())
+	if err != nil {
+		// Mutations are validated up front, so this only happens for a
+		// journal written by a buggy build; keep enforcing the last policy.
+		log.Printf("warning: failed to rebuild rbac policy: %v", err)
+		return
+	}
+	s.setPolicy(policy)
+}
+
+// requireOtherManagerLocked returns ErrLastAdmin if users holding role are the
+// only active ones who can manage users.
+func (s *Store) requireOtherManagerLocked(role string) error {
+	policy := s.Policy()
+	now := time.Now().UTC()
+	managers, outside := 0, 0
+	for _, user := range s.users {
+		if policy.Can(user.Role, rbac.UsersManage) && user.StatusAt(now) == models.UserStatusActive {
+			managers++
+			if user.Role != role {
+				outside++
+			}
+		}
+	}
+	if managers > 0 && outside == 0 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// ListRoles returns all roles sorted by name.
+func (s *Store) ListRoles() ([]models.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.rolesLocked(), nil
+}
+
+// GetRole returns a single role by name.
+func (s *Store) GetRole(name string) (models.Role, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	role, ok := s.roles[rbac.NormalizeRole(name)]
+	if !ok {
+		return models.Role{}, ErrRoleNotFound
+	}
+	return role, nil
+}
+
+// CreateRole stores a new role with the given permissions.
+func (s *Store) CreateRole(name string, permissions []string) (models.Role, error) {
+	name, err := normalizeRoleName(name)
+	if err != nil {
+		return models.Role{}, err
+	}
+	perms, err := normalizePermissions(permissions)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.roles[name]; exists {
+		return models.Role{}, ErrRoleExists
+	}
+	now := time.Now().UTC()
+	role := models.Role{Name: name, Permissions: perms, CreatedAt: now, UpdatedAt: now}
+	if err := s.commit(journalRecord{Op: opPutRole, Role: &role}); err != nil {
+		return models.Role{}, err
+	}
+	return role, nil
+}
+
+// UpdateRole replaces the permissions of a role.
+func (s *Store) UpdateRole(name string, permissions []string) (models.Role, error) {
+	perms, err := normalizePermissions(permissions)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, ok := s.roles[rbac.NormalizeRole(name)]
+	if !ok {
+		return models.Role{}, ErrRoleNotFound
+	}
+	role.Permissions = perms
+	role.UpdatedAt = time.Now().UTC()
+	if err := requireManagerRole(replaceRole(s.rolesLocked(), role.Name, &role)); err != nil {
+		return models.Role{}, err
+	}
+	if roleGrants(s.roles[role.Name], rbac.UsersManage) && !roleGrants(role, rbac.UsersManage) {
+		if err := s.requireOtherManagerLocked(role.Name); err != nil {
+			return models.Role{}, err
+		}
+	}
+	if err := s.commit(journalRecord{Op: opPutRole, Role: &role}); err != nil {
+		return models.Role{}, err
+	}
+	return role, nil
+}
+
+// SetRoleMFARequired sets whether users holding a role must use two-factor
+// authentication.
+func (s *Store) SetRoleMFARequired(name string, required bool) (models.Role, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, ok := s.roles[rbac.NormalizeRole(name)]
+	if !ok {
+		return models.Role{}, ErrRoleNotFound
+	}
+	role.RequireMFA = required
+	role.UpdatedAt = time.Now().UTC()
+	if err := s.commit(journalRecord{Op: opPutRole, Role: &role}); err != nil {
+		return models.Role{}, err
+	}
+	return role, nil
+}
+
+// DeleteRole removes a role that is no longer needed.
+func (s *Store) DeleteRole(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	role, ok := s.roles[rbac.NormalizeRole(name)]
+	if !ok {
+		return ErrRoleNotFound
+	}
+	if role.Name == s.Policy().DefaultRole() {
+		return ErrRoleInUse
+	}
+	for _, user := range s.users {
+		if user.Role == role.Name {
+			return ErrRoleInUse
+		}
+	}
+	if err := requireManagerRole(replaceRole(s.rolesLocked(), role.Name, nil)); err != nil {
+		return err
+	}
+	return s.commit(journalRecord{Op: opDeleteRole, ID: role.Name})
+}
+
+// ApplyPolicy creates or overwrites the roles defined by policy and adopts its
+// default role.
+func (s *Store) ApplyPolicy(policy *rbac.Policy) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	roles := policyRoles(policy, s.rolesLocked(), time.Now().UTC())
+	merged := s.rolesLocked()
+	for i := range roles {
+		merged = replaceRole(merged, roles[i].Name, &roles[i])
+	}
+	if err := requireManagerRole(merged); err != nil {
+		return err
+	}
+	// The default role is journaled with the roles, so it survives a restart
+	// even if the policy is not applied again.
+	return s.commit(journalRecord{Op: opApplyPolicy, ID: policy.DefaultRole(), Roles: roles})
+}
@@ -0,0 +1,119 @@
+package store
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+
+	"github.com/google/uuid"
+)
+
+// newServiceAccount builds a service account holding role and limited to
+// scopes. Unlike registrations it never falls back to the default role, and
+// every scope must be granted by the role.
+func newServiceAccount(policy *rbac.Policy, name, role string, scopes []string, clientID, secretHash string) (models.User, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.User{}, fmt.Errorf("name cannot be empty")
+	}
+	role = rbac.NormalizeRole(role)
+	if role == "" || !policy.HasRole(role) {
+		return models.User{}, ErrInvalidRole
+	}
+	if len(scopes) == 0 {
+		return models.User{}, fmt.Errorf("service account needs at least one scope")
+	}
+	scopes, err := normalizePermissions(scopes)
+	if err != nil {
+		return models.User{}, err
+	}
+	for _, scope := range scopes {
+		if !policy.Can(role, rbac.Permission(scope)) {
+			return models.User{}, fmt.Errorf("%w: role %q does not grant %q", ErrInvalidPermission, role, scope)
+		}
+	}
+
+	return models.User{
+		ID:               uuid.NewString(),
+		Username:         name,
+		Role:             role,
+		CreatedAt:        time.Now().UTC(),
+		ServiceAccount:   true,
+		ClientID:         clientID,
+		ClientSecretHash: secretHash,
+		Scopes:           scopes,
+		Status:           models.UserStatusActive,
+	}, nil
+}
+
+// clientSecretMatches compares secret hashes in constant time.
+func clientSecretMatches(user models.User, secretHash string) bool {
+	return user.ServiceAccount && subtle.ConstantTimeCompare([]byte(user.ClientSecretHash), []byte(secretHash)) == 1
+}
+
+// CreateServiceAccount adds a service account and records it in the audit log
+// under actorID.
+func (s *Store) CreateServiceAccount(actorID, name, role string, scopes []string, clientID, secretHash string) (models.User, error) {
+	user, err := newServiceAccount(s.Policy(), name, role, scopes, clientID, secretHash)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, exists := s.users[usernameKey(user.Username)]; exists {
+		return models.User{}, ErrUserExists
+	}
+	event := newAuditEvent(actorID, AuditServiceAccountCreated, user.ID, map[string]string{"role": user.Role})
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user, AuditEvent: &event}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// AuthenticateClient returns the service account with clientID if secretHash
+// matches its secret.
+func (s *Store) AuthenticateClient(clientID, secretHash string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	for _, user := range s.users {
+		if user.ClientID != "" && user.ClientID == clientID {
+			if !clientSecretMatches(user, secretHash) {
+				return models.User{}, ErrInvalidCredentials
+			}
+			if err := CheckAccountStatus(user); err != nil {
+				return models.User{}, err
+			}
+			return user, nil
+		}
+	}
+	return models.User{}, ErrInvalidCredentials
+}
+
+// RotateClientSecret replaces a service account's secret and records the
+// rotation in the audit log under actorID.
+func (s *Store) RotateClientSecret(actorID, userID, secretHash string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	if !user.ServiceAccount {
+		return models.User{}, ErrNotServiceAccount
+	}
+
+	event := newAuditEvent(actorID, AuditClientSecretRotated, user.ID, nil)
+	user.ClientSecretHash = secretHash
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user, AuditEvent: &event}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
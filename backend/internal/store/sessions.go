@@ -0,0 +1,186 @@
+package store
+
+import (
+	"sort"
+	"time"
+	"unicode/utf8"
+
+	"assignment3/backend/internal/models"
+)
+
+// sessionTouchInterval bounds how often a session's LastSeenAt is written, so
+// that every authenticated request does not cause a write.
+const sessionTouchInterval = time.Minute
+
+// maxUserAgentLength caps the user agent kept for a session; browsers send
+// a few hundred bytes at most, anything longer is not worth storing.
+const maxUserAgentLength = 255
+
+// newSession describes the device that a refresh token family was issued to.
+func newSession(userID, familyID, userAgent, ip string, expiresAt time.Time) models.Session {
+	if len(userAgent) > maxUserAgentLength {
+		userAgent = userAgent[:maxUserAgentLength]
+		for !utf8.ValidString(userAgent) {
+			userAgent = userAgent[:len(userAgent)-1]
+		}
+	}
+	now := time.Now().UTC()
+	return models.Session{
+		ID:         familyID,
+		UserID:     userID,
+		UserAgent:  userAgent,
+		IP:         ip,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  expiresAt.UTC(),
+	}
+}
+
+// sessionActive reports whether session can still be used at now.
+func sessionActive(session models.Session, now time.Time) bool {
+	return session.RevokedAt == nil && now.Before(session.ExpiresAt)
+}
+
+// sessionNeedsTouch reports whether a use of session from ip at now should be
+// recorded.
+func sessionNeedsTouch(session models.Session, ip string, now time.Time) bool {
+	return (ip != "" && ip != session.IP) || now.Sub(session.LastSeenAt) >= sessionTouchInterval
+}
+
+// sortSessions orders sessions most recently seen first.
+func sortSessions(sessions []models.Session) {
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+}
+
+// CreateSession records the device a refresh token family was issued to.
+// Expired sessions of the same user are pruned along the way; revoked ones
+// are kept until then so that they cannot be recreated.
+func (s *Store) CreateSession(userID, familyID, userAgent, ip string, expiresAt time.Time) (models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.userExistsLocked(userID) {
+		return models.Session{}, ErrUserNotFound
+	}
+
+	if _, ok := s.sessions[familyID]; ok {
+		return models.Session{}, ErrSessionExists
+	}
+	session := newSession(userID, familyID, userAgent, ip, expiresAt)
+	for _, existing := range s.sessions {
+		if existing.UserID == userID && !session.CreatedAt.Before(existing.ExpiresAt) {
+			if err := s.commit(journalRecord{Op: opDeleteSession, ID: existing.ID}); err != nil {
+				return models.Session{}, err
+			}
+		}
+	}
+	if err := s.commit(journalRecord{Op: opPutSession, Session: &session}); err != nil {
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+// ListSessions returns a user's active sessions, most recently seen first.
+func (s *Store) ListSessions(userID string) ([]models.Session, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UTC()
+	sessions := make([]models.Session, 0)
+	for _, session := range s.sessions {
+		if session.UserID == userID && sessionActive(session, now) {
+			sessions = append(sessions, session)
+		}
+	}
+	sortSessions(sessions)
+	return sessions, nil
+}
+
+// UseSession looks up an active session and records that it was seen from ip.
+// Most uses need no write, so the session is looked up under the read lock and
+// the write lock is only taken when a touch has to be committed.
+func (s *Store) UseSession(id, ip string) (models.Session, error) {
+	s.mu.RLock()
+	session, ok := s.sessions[id]
+	now := time.Now().UTC()
+	s.mu.RUnlock()
+	if !ok || !sessionActive(session, now) {
+		return models.Session{}, ErrSessionInvalid
+	}
+	if !sessionNeedsTouch(session, ip, now) {
+		return session, nil
+	}
+	return s.touchSession(id, ip)
+}
+
+// touchSession records a use of the session id from ip. The session is looked
+// up again, since it may have been revoked or touched by another request
+// since it was read.
+func (s *Store) touchSession(id, ip string) (models.Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	now := time.Now().UTC()
+	if !ok || !sessionActive(session, now) {
+		return models.Session{}, ErrSessionInvalid
+	}
+	if sessionNeedsTouch(session, ip, now) {
+		session.LastSeenAt = now
+		if ip != "" {
+			session.IP = ip
+		}
+		if err := s.commit(journalRecord{Op: opPutSession, Session: &session}); err != nil {
+			return models.Session{}, err
+		}
+	}
+	return session, nil
+}
+
+// RevokeSession signs a user out of one session and revokes its refresh
+// tokens.
+func (s *Store) RevokeSession(userID, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	now := time.Now().UTC()
+	if !ok || session.UserID != userID || !sessionActive(session, now) {
+		return ErrSessionNotFound
+	}
+	if err := s.revokeRefreshTokensLocked(now, func(t models.RefreshToken) bool {
+		return t.FamilyID == id
+	}); err != nil {
+		return err
+	}
+	return s.revokeSessionsLocked(now, func(session models.Session) bool {
+		return session.ID == id
+	})
+}
+
+func (s *Store) revokeSessionsLocked(now time.Time, match func(models.Session) bool) error {
+	for _, session := range s.sessions {
+		if session.RevokedAt != nil || !match(session) {
+			continue
+		}
+		session.RevokedAt = &now
+		if err := s.commit(journalRecord{Op: opPutSession, Session: &session}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// touchSessionLocked extends the session of a refresh token family when the
+// family is rotated.
+func (s *Store) touchSessionLocked(familyID string, now, expiresAt time.Time) error {
+	session, ok := s.sessions[familyID]
+	if !ok || session.RevokedAt != nil {
+		return nil
+	}
+	session.LastSeenAt = now
+	session.ExpiresAt = expiresAt.UTC()
+	return s.commit(journalRecord{Op: opPutSession, Session: &session})
+}
@@ -0,0 +1,782 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+)
+
+// dialect captures the differences between the SQL databases supported by SQLStore.
+type dialect interface {
+	// name identifies the dialect and selects its migration directory.
+	name() string
+	// rebind rewrites '?' placeholders into the dialect's native form.
+	rebind(query string) string
+	// isUniqueViolation reports whether err was caused by a unique constraint.
+	isUniqueViolation(err error) bool
+	// forUpdate returns the clause that row-locks a SELECT inside a
+	// transaction, or "" where writers are already serialised.
+	forUpdate() string
+}
+
+// SQLStore is a Repository backed by a relational database.
+type SQLStore struct {
+	db      *sql.DB
+	dialect dialect
+	rolesMu sync.Mutex
+	policyHolder
+}
+
+func newSQLStore(db *sql.DB, d dialect) (*SQLStore, error) {
+	s := &SQLStore{db: db, dialect: d}
+	if err := s.migrate(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	if err := s.reloadPolicy(); err != nil {
+		_ = db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// DB exposes the underlying connection pool.
+func (s *SQLStore) DB() *sql.DB {
+	return s.db
+}
+
+// Close releases the underlying connection pool.
+func (s *SQLStore) Close() error {
+	return s.db.Close()
+}
+
+func (s *SQLStore) exec(q queryer, query string, args ...any) (sql.Result, error) {
+	return q.Exec(s.dialect.rebind(query), args...)
+}
+
+func (s *SQLStore) query(q queryer, query string, args ...any) (*sql.Rows, error) {
+	return q.Query(s.dialect.rebind(query), args...)
+}
+
+func (s *SQLStore) queryRow(q queryer, query string, args ...any) *sql.Row {
+	return q.QueryRow(s.dialect.rebind(query), args...)
+}
+
+// queryer is satisfied by both *sql.DB and *sql.Tx.
+type queryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// withTx runs fn inside a transaction, committing on success.
+func (s *SQLStore) withTx(fn func(tx *sql.Tx) error) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	if err := fn(tx); err != nil {
+		_ = tx.Rollback()
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("failed to commit transaction: %w", err)
+	}
+	return nil
+}
+
+const userColumns = "id, username, email, password_hash, role, created_at, must_change_password, mfa_enabled, mfa_secret, mfa_last_counter, recovery_codes, " +
+	"service_account, client_id, client_secret_hash, scopes, status, status_reason, suspended_until"
+
+type rowScanner interface {
+	Scan(dest ...any) error
+}
+
+func scanUser(row rowScanner) (models.User, error) {
+	var (
+		user           models.User
+		recoveryCodes  string
+		scopes         string
+		suspendedUntil sql.NullTime
+	)
+	if err := row.Scan(&user.ID, &user.Username, &user.Email, &user.PasswordHash, &user.Role, &user.CreatedAt, &user.MustChangePassword,
+		&user.MFAEnabled, &user.MFASecret, &user.MFALastCounter, &recoveryCodes,
+		&user.ServiceAccount, &user.ClientID, &user.ClientSecretHash, &scopes,
+		&user.Status, &user.StatusReason, &suspendedUntil); err != nil {
+		return models.User{}, err
+	}
+	user.CreatedAt = user.CreatedAt.UTC()
+	user.SuspendedUntil = nullTimePtr(suspendedUntil)
+	user.RecoveryCodes = splitRecoveryCodes(recoveryCodes)
+	if scopes != "" {
+		user.Scopes = strings.Split(scopes, ",")
+	}
+	return user, nil
+}
+
+// selectItems reads items together with the current username of their owner.
+const selectItems = "SELECT items.id, items.title, items.description, items.owner_id, COALESCE(users.username, ''), items.visibility, items.created_at, items.updated_at " +
+	"FROM items LEFT JOIN users ON users.id = items.owner_id"
+
+func scanItem(row rowScanner) (models.Item, error) {
+	var item models.Item
+	if err := row.Scan(&item.ID, &item.Title, &item.Description, &item.OwnerID, &item.Owner, &item.Visibility, &item.CreatedAt, &item.UpdatedAt); err != nil {
+		return models.Item{}, err
+	}
+	item.CreatedAt = item.CreatedAt.UTC()
+	item.UpdatedAt = item.UpdatedAt.UTC()
+	return item, nil
+}
+
+func (s *SQLStore) insertUser(q queryer, user models.User) error {
+	_, err := s.exec(q,
+		"INSERT INTO users (id, username, username_key, email, password_hash, role, created_at, must_change_password, service_account, client_id, client_secret_hash, scopes, status) "+
+			"VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+		user.ID, user.Username, usernameKey(user.Username), user.Email, user.PasswordHash, user.Role, user.CreatedAt, user.MustChangePassword,
+		user.ServiceAccount, user.ClientID, user.ClientSecretHash, strings.Join(user.Scopes, ","), user.StatusAt(time.Now()),
+	)
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return ErrUserExists
+		}
+		return fmt.Errorf("failed to insert user: %w", err)
+	}
+	return nil
+}
+
+func (s *SQLStore) userByUsername(q queryer, username string) (models.User, error) {
+	user, err := scanUser(s.queryRow(q, "SELECT "+userColumns+" FROM users WHERE username_key = ?", usernameKey(username)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to load user: %w", err)
+	}
+	return user, nil
+}
+
+// EnsureAdminUser creates an admin user if it does not exist. If the user already
+// exists, it is returned and no error is raised. The boolean indicates whether
+// a new user was created.
+func (s *SQLStore) EnsureAdminUser(username, password string) (models.User, bool, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return models.User{}, false, fmt.Errorf("admin username cannot be empty")
+	}
+	if password == "" {
+		return models.User{}, false, fmt.Errorf("admin password cannot be empty")
+	}
+
+	var (
+		result  models.User
+		created bool
+	)
+	err := s.withTx(func(tx *sql.Tx) error {
+		user, err := s.userByUsername(tx, username)
+		if err == nil {
+			// Guarantee the user retains the admin role.
+			if user.Role != "admin" {
+				if _, err := s.exec(tx, "UPDATE users SET role = ? WHERE id = ?", "admin", user.ID); err != nil {
+					return fmt.Errorf("failed to restore admin role: %w", err)
+				}
+				user.Role = "admin"
+			}
+			result = user
+			return nil
+		}
+		if !errors.Is(err, ErrUserNotFound) {
+			return err
+		}
+
+		user, err = newUser(username, password, "admin")
+		if err != nil {
+			return err
+		}
+		// The bootstrap password comes from configuration; make the first
+		// sign-in replace it.
+		user.MustChangePassword = true
+		if err := s.insertUser(tx, user); err != nil {
+			return err
+		}
+		result, created = user, true
+		return nil
+	})
+	if err != nil {
+		return models.User{}, false, err
+	}
+	return result, created, nil
+}
+
+// CreateUser registers a new user with the provided role.
+func (s *SQLStore) CreateUser(username, password, role string) (models.User, error) {
+	role, err := normalizeRole(s.Policy(), role)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err := newUser(username, password, role)
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := s.insertUser(s.db, user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// RegisterUser creates a self-service account with the policy's default role
+// and the given email address, which may be empty.
+func (s *SQLStore) RegisterUser(username, password, email string) (models.User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return models.User{}, err
+	}
+	role, err := normalizeRole(s.Policy(), "")
+	if err != nil {
+		return models.User{}, err
+	}
+
+	user, err := newUser(username, password, role)
+	if err != nil {
+		return models.User{}, err
+	}
+	user.Email = email
+	if err := s.insertUser(s.db, user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// Authenticate validates the provided credentials and returns the user on success.
+func (s *SQLStore) Authenticate(username, password string) (models.User, error) {
+	user, err := s.userByUsername(s.db, username)
+	if err != nil {
+		if errors.Is(err, ErrUserNotFound) {
+			burnPasswordCheck(password)
+			return models.User{}, ErrInvalidCredentials
+		}
+		return models.User{}, err
+	}
+
+	if err := verifyPassword(user, password); err != nil {
+		return models.User{}, err
+	}
+	if err := CheckAccountStatus(user); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+// ListUsers returns all users sorted by creation time.
+func (s *SQLStore) ListUsers() ([]models.User, error) {
+	rows, err := s.query(s.db, "SELECT "+userColumns+" FROM users ORDER BY created_at, id")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users := make([]models.User, 0)
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	return users, nil
+}
+
+// GetUser returns a single user by id.
+func (s *SQLStore) GetUser(id string) (models.User, error) {
+	return s.getUser(s.db, id)
+}
+
+// GetUserByUsername returns a single user by case-insensitive username.
+func (s *SQLStore) GetUserByUsername(username string) (models.User, error) {
+	return s.userByUsername(s.db, username)
+}
+
+// SetUserEmail replaces a user's email address; an empty address removes it.
+func (s *SQLStore) SetUserEmail(userID, email string) (models.User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	var updated models.User
+	err = s.withTx(func(tx *sql.Tx) error {
+		res, err := s.exec(tx, "UPDATE users SET email = ? WHERE id = ?", email, userID)
+		if err != nil {
+			return fmt.Errorf("failed to update email: %w", err)
+		}
+		if err := requireAffected(res, ErrUserNotFound); err != nil {
+			return err
+		}
+		updated, err = s.getUser(tx, userID)
+		return err
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
+
+// ChangeUsername renames a user and records the change in the audit log under
+// actorID. Items are owned by user ID and keep their owner.
+func (s *SQLStore) ChangeUsername(actorID, userID, username string) (models.User, error) {
+	username, err := normalizeUsername(username)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	var updated models.User
+	err = s.withTx(func(tx *sql.Tx) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.Username == username {
+			updated = user
+			return nil
+		}
+
+		event := newAuditEvent(actorID, AuditUserRenamed, user.ID, map[string]string{"from": user.Username, "to": username})
+		if _, err := s.exec(tx,
+			"UPDATE users SET username = ?, username_key = ? WHERE id = ?",
+			username, usernameKey(username), user.ID,
+		); err != nil {
+			if s.dialect.isUniqueViolation(err) {
+				return ErrUserExists
+			}
+			return fmt.Errorf("failed to rename user: %w", err)
+		}
+		if err := s.insertAuditEvent(tx, event); err != nil {
+			return err
+		}
+		user.Username = username
+		updated = user
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
+
+func (s *SQLStore) getUser(q queryer, id string) (models.User, error) {
+	user, err := scanUser(s.queryRow(q, "SELECT "+userColumns+" FROM users WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to load user: %w", err)
+	}
+	return user, nil
+}
+
+// DeleteUser removes a user from the store and disposes of its items as opts
+// selects, returning how many items were affected.
+func (s *SQLStore) DeleteUser(id string, opts UserDeletion) (int, error) {
+	opts, err := opts.normalize(id)
+	if err != nil {
+		return 0, err
+	}
+
+	var affected int64
+	err = s.withTx(func(tx *sql.Tx) error {
+		user, err := s.getUser(tx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.requireOtherManager(tx, user); err != nil {
+			return err
+		}
+
+		if _, err := s.exec(tx, "DELETE FROM item_transfers WHERE from_user_id = ? OR to_user_id = ?", id, id); err != nil {
+			return fmt.Errorf("failed to delete transfers: %w", err)
+		}
+		if _, err := s.exec(tx, "DELETE FROM item_grants WHERE subject_type = ? AND subject = ?", models.GrantToUser, id); err != nil {
+			return fmt.Errorf("failed to delete grants: %w", err)
+		}
+
+		var res sql.Result
+		switch opts.Items {
+		case ItemsCascade:
+			res, err = s.exec(tx, "DELETE FROM items WHERE owner_id = ?", id)
+		case ItemsReassign:
+			if _, err := s.getUser(tx, opts.ReassignTo); err != nil {
+				if errors.Is(err, ErrUserNotFound) {
+					return ErrInvalidReassignTarget
+				}
+				return err
+			}
+			res, err = s.exec(tx, "UPDATE items SET owner_id = ? WHERE owner_id = ?", opts.ReassignTo, id)
+		default:
+			res, err = s.exec(tx, "UPDATE items SET owner_id = '' WHERE owner_id = ?", id)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to dispose of items: %w", err)
+		}
+		if affected, err = res.RowsAffected(); err != nil {
+			return fmt.Errorf("failed to dispose of items: %w", err)
+		}
+
+		res, err = s.exec(tx, "DELETE FROM users WHERE id = ?", id)
+		if err != nil {
+			return fmt.Errorf("failed to delete user: %w", err)
+		}
+		return requireAffected(res, ErrUserNotFound)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(affected), nil
+}
+
+// SetUserRole assigns role to a user and records the change in the audit log
+// under actorID.
+func (s *SQLStore) SetUserRole(actorID, userID, role string) (models.User, error) {
+	role, err := normalizeRoleName(role)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	var updated models.User
+	err = s.withTx(func(tx *sql.Tx) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		target, err := s.getRole(tx, role)
+		if errors.Is(err, ErrRoleNotFound) {
+			return ErrInvalidRole
+		}
+		if err != nil {
+			return err
+		}
+		if user.Role == role {
+			updated = user
+			return nil
+		}
+		if !roleGrants(target, rbac.UsersManage) {
+			if err := s.requireOtherManager(tx, user); err != nil {
+				return err
+			}
+		}
+
+		event := newAuditEvent(actorID, AuditUserRoleChanged, user.ID, map[string]string{"from": user.Role, "to": role})
+		if _, err := s.exec(tx, "UPDATE users SET role = ? WHERE id = ?", role, user.ID); err != nil {
+			return fmt.Errorf("failed to update role: %w", err)
+		}
+		if err := s.insertAuditEvent(tx, event); err != nil {
+			return err
+		}
+		user.Role = role
+		updated = user
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
+
+// ChangePassword replaces a user's password after checking the current one and
+// clears MustChangePassword.
+func (s *SQLStore) ChangePassword(userID, currentPassword, newPassword string) (models.User, error) {
+	var updated models.User
+	err := s.withTx(func(tx *sql.Tx) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		hashed, err := newPasswordHash(user, currentPassword, newPassword, true)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = hashed
+		user.MustChangePassword = false
+		if err := s.setPassword(tx, user); err != nil {
+			return err
+		}
+		updated = user
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
+
+// ResetPassword sets a temporary password that must be changed on the next
+// sign-in and records the reset in the audit log under actorID.
+func (s *SQLStore) ResetPassword(actorID, userID, temporaryPassword string) (models.User, error) {
+	var updated models.User
+	err := s.withTx(func(tx *sql.Tx) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		hashed, err := newPasswordHash(user, "", temporaryPassword, false)
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = hashed
+		user.MustChangePassword = true
+		if err := s.setPassword(tx, user); err != nil {
+			return err
+		}
+		if err := s.insertAuditEvent(tx, newAuditEvent(actorID, AuditUserPasswordReset, user.ID, nil)); err != nil {
+			return err
+		}
+		updated = user
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
+
+// setPassword stores a new password hash and invalidates outstanding reset
+// links, which were issued for the old password.
+func (s *SQLStore) setPassword(q queryer, user models.User) error {
+	res, err := s.exec(q,
+		"UPDATE users SET password_hash = ?, must_change_password = ? WHERE id = ?",
+		user.PasswordHash, user.MustChangePassword, user.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update password: %w", err)
+	}
+	if err := requireAffected(res, ErrUserNotFound); err != nil {
+		return err
+	}
+	if _, err := s.exec(q, "DELETE FROM password_reset_tokens WHERE user_id = ?", user.ID); err != nil {
+		return fmt.Errorf("failed to revoke password reset tokens: %w", err)
+	}
+	return nil
+}
+
+// managerUsers returns the active users whose role grants users:manage, mapped
+// to their role. The rows stay locked until tx ends so concurrent demotions
+// cannot both pass the last-admin check.
+func (s *SQLStore) managerUsers(tx *sql.Tx) (map[string]string, error) {
+	roles, err := s.listRoles(tx)
+	if err != nil {
+		return nil, err
+	}
+	var names []any
+	for _, role := range roles {
+		if roleGrants(role, rbac.UsersManage) {
+			names = append(names, role.Name)
+		}
+	}
+	managers := make(map[string]string)
+	if len(names) == 0 {
+		return managers, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?, ", len(names)), ", ")
+	rows, err := s.query(tx, "SELECT id, role, status, suspended_until FROM users WHERE role IN ("+placeholders+")"+s.dialect.forUpdate(), names...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list administrators: %w", err)
+	}
+	defer rows.Close()
+	now := time.Now().UTC()
+	for rows.Next() {
+		var (
+			user           models.User
+			suspendedUntil sql.NullTime
+		)
+		if err := rows.Scan(&user.ID, &user.Role, &user.Status, &suspendedUntil); err != nil {
+			return nil, fmt.Errorf("failed to scan administrator: %w", err)
+		}
+		user.SuspendedUntil = nullTimePtr(suspendedUntil)
+		if user.StatusAt(now) == models.UserStatusActive {
+			managers[user.ID] = user.Role
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list administrators: %w", err)
+	}
+	return managers, nil
+}
+
+// requireOtherManager returns ErrLastAdmin if user is the only one who can
+// manage users.
+func (s *SQLStore) requireOtherManager(tx *sql.Tx, user models.User) error {
+	managers, err := s.managerUsers(tx)
+	if err != nil {
+		return err
+	}
+	if _, ok := managers[user.ID]; ok && len(managers) == 1 {
+		return ErrLastAdmin
+	}
+	return nil
+}
+
+// ListItems returns the items the user with requesterID and role may see,
+// sorted by creation time.
+func (s *SQLStore) ListItems(requesterID, role string) ([]models.Item, error) {
+	policy := s.Policy()
+	query := selectItems
+	var args []any
+	if !policy.Can(role, rbac.ItemsUpdateAny) {
+		query += " WHERE items.owner_id = ? OR items.visibility = ? OR (items.visibility = ? AND items.id IN (SELECT item_id FROM item_grants WHERE " + grantSubjectMatch + "))"
+		args = append(args, requesterID, models.ItemPublic, models.ItemShared, models.GrantToUser, requesterID, models.GrantToRole, role)
+	}
+	grants, err := s.subjectGrants(s.db, requesterID, role)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.query(s.db, query+" ORDER BY items.created_at, items.id", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+	defer rows.Close()
+
+	items := make([]models.Item, 0)
+	for rows.Next() {
+		item, err := scanItem(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan item: %w", err)
+		}
+		item.Access, _ = itemAccess(policy, item, grants[item.ID], requesterID, role)
+		if item.Access == "" {
+			continue
+		}
+		items = append(items, item)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+	return items, nil
+}
+
+func (s *SQLStore) getItem(q queryer, id string) (models.Item, error) {
+	item, err := scanItem(s.queryRow(q, selectItems+" WHERE items.id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Item{}, ErrItemNotFound
+	}
+	if err != nil {
+		return models.Item{}, fmt.Errorf("failed to load item: %w", err)
+	}
+	return item, nil
+}
+
+// visibleItem returns an item with the access the user with requesterID and
+// role has to it, or ErrItemNotFound if the user may not see it.
+func (s *SQLStore) visibleItem(q queryer, id, requesterID, role string) (models.Item, error) {
+	item, err := s.getItem(q, id)
+	if err != nil {
+		return models.Item{}, err
+	}
+	grants, err := s.itemGrants(q, id)
+	if err != nil {
+		return models.Item{}, err
+	}
+	item.Access, _ = itemAccess(s.Policy(), item, grants, requesterID, role)
+	if item.Access == "" {
+		return models.Item{}, ErrItemNotFound
+	}
+	return item, nil
+}
+
+// GetItem returns a single item by id if the user with requesterID and role
+// may see it, and ErrItemNotFound otherwise.
+func (s *SQLStore) GetItem(id, requesterID, role string) (models.Item, error) {
+	return s.visibleItem(s.db, id, requesterID, role)
+}
+
+// CreateItem inserts a new item owned by the user with ownerID. An empty
+// visibility makes it public.
+func (s *SQLStore) CreateItem(ownerID, title, description string, visibility models.ItemVisibility) (models.Item, error) {
+	item, err := newItem(ownerID, title, description, visibility)
+	if err != nil {
+		return models.Item{}, err
+	}
+
+	_, err = s.exec(s.db,
+		"INSERT INTO items (id, title, description, owner_id, visibility, created_at, updated_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+		item.ID, item.Title, item.Description, item.OwnerID, item.Visibility, item.CreatedAt, item.UpdatedAt,
+	)
+	if err != nil {
+		return models.Item{}, fmt.Errorf("failed to insert item: %w", err)
+	}
+	created, err := s.getItem(s.db, item.ID)
+	if err != nil {
+		return models.Item{}, err
+	}
+	created.Access = models.ItemOwner
+	return created, nil
+}
+
+// UpdateItem updates an existing item if the policy lets the caller's role edit
+// it, either as its owner or editor or through items:update:any. requesterID
+// is the ID of the calling user; items it may not see are not found.
+func (s *SQLStore) UpdateItem(id, requesterID, role, title, description string) (models.Item, error) {
+	title = strings.TrimSpace(title)
+	if title == "" {
+		return models.Item{}, fmt.Errorf("title cannot be empty")
+	}
+
+	var updated models.Item
+	err := s.withTx(func(tx *sql.Tx) error {
+		item, err := s.visibleItem(tx, id, requesterID, role)
+		if err != nil {
+			return err
+		}
+
+		if !mayEditItem(s.Policy(), role, item.Access) {
+			return ErrForbidden
+		}
+
+		item.Title = title
+		item.Description = strings.TrimSpace(description)
+		item.UpdatedAt = time.Now().UTC()
+		if _, err := s.exec(tx,
+			"UPDATE items SET title = ?, description = ?, updated_at = ? WHERE id = ?",
+			item.Title, item.Description, item.UpdatedAt, item.ID,
+		); err != nil {
+			return fmt.Errorf("failed to update item: %w", err)
+		}
+		updated = item
+		return nil
+	})
+	if err != nil {
+		return models.Item{}, err
+	}
+	return updated, nil
+}
+
+// DeleteItem removes an item from the store.
+func (s *SQLStore) DeleteItem(id string) error {
+	res, err := s.exec(s.db, "DELETE FROM items WHERE id = ?", id)
+	if err != nil {
+		return fmt.Errorf("failed to delete item: %w", err)
+	}
+	return requireAffected(res, ErrItemNotFound)
+}
+
+// requireAffected returns notFound when the statement did not touch any row.
+func requireAffected(res sql.Result, notFound error) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return fmt.Errorf("failed to read affected rows: %w", err)
+	}
+	if n == 0 {
+		return notFound
+	}
+	return nil
+}
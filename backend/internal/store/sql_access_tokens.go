@@ -0,0 +1,133 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/models"
+)
+
+const accessTokenColumns = "id, user_id, name, prefix, token_hash, scopes, created_at, expires_at, last_used_at"
+
+func scanAccessToken(row rowScanner) (models.PersonalAccessToken, error) {
+	var (
+		token      models.PersonalAccessToken
+		scopes     string
+		expiresAt  sql.NullTime
+		lastUsedAt sql.NullTime
+	)
+	if err := row.Scan(&token.ID, &token.UserID, &token.Name, &token.Prefix, &token.TokenHash, &scopes, &token.CreatedAt, &expiresAt, &lastUsedAt); err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	token.Scopes = []string{}
+	if scopes != "" {
+		token.Scopes = strings.Split(scopes, ",")
+	}
+	token.CreatedAt = token.CreatedAt.UTC()
+	token.ExpiresAt = nullTimePtr(expiresAt)
+	token.LastUsedAt = nullTimePtr(lastUsedAt)
+	return token, nil
+}
+
+// CreateAccessToken stores the hash of a new personal access token. Names are
+// unique per user.
+func (s *SQLStore) CreateAccessToken(userID, name, prefix, tokenHash string, scopes []string, expiresAt *time.Time) (models.PersonalAccessToken, error) {
+	token, err := newAccessToken(userID, name, prefix, tokenHash, scopes, expiresAt)
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+
+	var expires sql.NullTime
+	if token.ExpiresAt != nil {
+		expires = sql.NullTime{Time: *token.ExpiresAt, Valid: true}
+	}
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.getUser(tx, userID); err != nil {
+			return err
+		}
+		var taken int
+		if err := s.queryRow(tx,
+			"SELECT COUNT(*) FROM access_tokens WHERE user_id = ? AND LOWER(name) = LOWER(?)", userID, token.Name,
+		).Scan(&taken); err != nil {
+			return fmt.Errorf("failed to check access token name: %w", err)
+		}
+		if taken > 0 {
+			return ErrAccessTokenExists
+		}
+		_, err := s.exec(tx,
+			"INSERT INTO access_tokens (id, user_id, name, prefix, token_hash, scopes, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?, ?)",
+			token.ID, token.UserID, token.Name, token.Prefix, token.TokenHash, strings.Join(token.Scopes, ","), token.CreatedAt, expires,
+		)
+		if err != nil {
+			if s.dialect.isUniqueViolation(err) {
+				return ErrAccessTokenExists
+			}
+			return fmt.Errorf("failed to insert access token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.PersonalAccessToken{}, err
+	}
+	return token, nil
+}
+
+// ListAccessTokens returns a user's personal access tokens, newest first.
+// Expired tokens are included until they are deleted.
+func (s *SQLStore) ListAccessTokens(userID string) ([]models.PersonalAccessToken, error) {
+	rows, err := s.query(s.db, "SELECT "+accessTokenColumns+" FROM access_tokens WHERE user_id = ? ORDER BY created_at DESC", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list access tokens: %w", err)
+	}
+	defer rows.Close()
+
+	tokens := make([]models.PersonalAccessToken, 0)
+	for rows.Next() {
+		token, err := scanAccessToken(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan access token: %w", err)
+		}
+		tokens = append(tokens, token)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list access tokens: %w", err)
+	}
+	return tokens, nil
+}
+
+// DeleteAccessToken revokes one of a user's personal access tokens.
+func (s *SQLStore) DeleteAccessToken(userID, id string) error {
+	res, err := s.exec(s.db, "DELETE FROM access_tokens WHERE id = ? AND user_id = ?", id, userID)
+	if err != nil {
+		return fmt.Errorf("failed to delete access token: %w", err)
+	}
+	return requireAffected(res, ErrAccessTokenNotFound)
+}
+
+// UseAccessToken looks up the personal access token identified by tokenHash
+// and records that it was used.
+func (s *SQLStore) UseAccessToken(tokenHash string) (models.PersonalAccessToken, error) {
+	token, err := scanAccessToken(s.queryRow(s.db, "SELECT "+accessTokenColumns+" FROM access_tokens WHERE token_hash = ?", tokenHash))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.PersonalAccessToken{}, ErrAccessTokenInvalid
+	}
+	if err != nil {
+		return models.PersonalAccessToken{}, fmt.Errorf("failed to load access token: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if accessTokenExpired(token, now) {
+		return models.PersonalAccessToken{}, ErrAccessTokenInvalid
+	}
+	if accessTokenNeedsTouch(token, now) {
+		if _, err := s.exec(s.db, "UPDATE access_tokens SET last_used_at = ? WHERE id = ?", now, token.ID); err != nil {
+			return models.PersonalAccessToken{}, fmt.Errorf("failed to record access token use: %w", err)
+		}
+		token.LastUsedAt = &now
+	}
+	return token, nil
+}
@@ -0,0 +1,60 @@
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"assignment3/backend/internal/models"
+)
+
+const auditEventColumns = "id, actor_id, action, target_id, details, created_at"
+
+func (s *SQLStore) insertAuditEvent(q queryer, event models.AuditEvent) error {
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return fmt.Errorf("failed to encode audit details: %w", err)
+	}
+	if _, err := s.exec(q,
+		"INSERT INTO audit_events ("+auditEventColumns+") VALUES (?, ?, ?, ?, ?, ?)",
+		event.ID, event.ActorID, event.Action, event.TargetID, string(details), event.CreatedAt,
+	); err != nil {
+		return fmt.Errorf("failed to insert audit event: %w", err)
+	}
+	return nil
+}
+
+// ListAuditEvents returns up to limit audit events, newest first. A
+// non-positive limit returns every event.
+func (s *SQLStore) ListAuditEvents(limit int) ([]models.AuditEvent, error) {
+	query := "SELECT " + auditEventColumns + " FROM audit_events ORDER BY created_at DESC, id DESC"
+	args := []any{}
+	if limit > 0 {
+		query += " LIMIT ?"
+		args = append(args, limit)
+	}
+	rows, err := s.query(s.db, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	defer rows.Close()
+
+	events := make([]models.AuditEvent, 0)
+	for rows.Next() {
+		var (
+			event   models.AuditEvent
+			details string
+		)
+		if err := rows.Scan(&event.ID, &event.ActorID, &event.Action, &event.TargetID, &details, &event.CreatedAt); err != nil {
+			return nil, fmt.Errorf("failed to scan audit event: %w", err)
+		}
+		if err := json.Unmarshal([]byte(details), &event.Details); err != nil {
+			return nil, fmt.Errorf("failed to decode audit details: %w", err)
+		}
+		event.CreatedAt = event.CreatedAt.UTC()
+		events = append(events, event)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list audit events: %w", err)
+	}
+	return events, nil
+}
@@ -0,0 +1,210 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"assignment3/backend/internal/models"
+)
+
+// selectGrants reads grants together with the current username of the users
+// they were given to.
+const selectGrants = "SELECT g.item_id, g.subject_type, g.subject, CASE WHEN g.subject_type = 'user' THEN COALESCE(users.username, '') ELSE g.subject END, g.access, g.created_at " +
+	"FROM item_grants g LEFT JOIN users ON g.subject_type = 'user' AND users.id = g.subject"
+
+// grantSubjectMatch selects the grants to a user (first two arguments) or to
+// a role (last two).
+const grantSubjectMatch = "(subject_type = ? AND subject = ?) OR (subject_type = ? AND subject = ?)"
+
+func scanGrant(row rowScanner) (models.ItemGrant, error) {
+	var grant models.ItemGrant
+	if err := row.Scan(&grant.ItemID, &grant.SubjectType, &grant.Subject, &grant.SubjectName, &grant.Access, &grant.CreatedAt); err != nil {
+		return models.ItemGrant{}, err
+	}
+	grant.CreatedAt = grant.CreatedAt.UTC()
+	return grant, nil
+}
+
+func (s *SQLStore) queryGrants(q queryer, query string, args ...any) ([]models.ItemGrant, error) {
+	rows, err := s.query(q, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list grants: %w", err)
+	}
+	defer rows.Close()
+
+	grants := make([]models.ItemGrant, 0)
+	for rows.Next() {
+		grant, err := scanGrant(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan grant: %w", err)
+		}
+		grants = append(grants, grant)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list grants: %w", err)
+	}
+	return grants, nil
+}
+
+// itemGrants returns every grant of an item.
+func (s *SQLStore) itemGrants(q queryer, itemID string) ([]models.ItemGrant, error) {
+	return s.queryGrants(q, selectGrants+" WHERE g.item_id = ?", itemID)
+}
+
+// subjectGrants returns the grants to the user with userID or to role, keyed
+// by item ID.
+func (s *SQLStore) subjectGrants(q queryer, userID, role string) (map[string][]models.ItemGrant, error) {
+	grants, err := s.queryGrants(q, selectGrants+" WHERE g.item_id IN (SELECT item_id FROM item_grants WHERE "+grantSubjectMatch+")",
+		models.GrantToUser, userID, models.GrantToRole, role)
+	if err != nil {
+		return nil, err
+	}
+	byItem := make(map[string][]models.ItemGrant)
+	for _, grant := range grants {
+		byItem[grant.ItemID] = append(byItem[grant.ItemID], grant)
+	}
+	return byItem, nil
+}
+
+// managedItem returns an item the user with requesterID and role may change
+// the visibility and grants of. Items the user cannot see are not found; for
+// others without the right it returns ErrForbidden.
+func (s *SQLStore) managedItem(q queryer, id, requesterID, role string) (models.Item, error) {
+	item, err := s.visibleItem(q, id, requesterID, role)
+	if err != nil {
+		return models.Item{}, err
+	}
+	if !mayManageItem(s.Policy(), item, requesterID, role) {
+		return models.Item{}, ErrForbidden
+	}
+	return item, nil
+}
+
+// SetItemVisibility changes who can see an item. Its owner and users who may
+// update any item can do so.
+func (s *SQLStore) SetItemVisibility(id, requesterID, role string, visibility models.ItemVisibility) (models.Item, error) {
+	if !visibility.Valid() {
+		return models.Item{}, ErrInvalidVisibility
+	}
+
+	var updated models.Item
+	err := s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.managedItem(tx, id, requesterID, role); err != nil {
+			return err
+		}
+		if _, err := s.exec(tx, "UPDATE items SET visibility = ?, updated_at = ? WHERE id = ?", visibility, time.Now().UTC(), id); err != nil {
+			return fmt.Errorf("failed to update item: %w", err)
+		}
+		var err error
+		updated, err = s.visibleItem(tx, id, requesterID, role)
+		return err
+	})
+	if err != nil {
+		return models.Item{}, err
+	}
+	return updated, nil
+}
+
+// ListItemGrants returns the grants of an item the requester may manage,
+// users first.
+func (s *SQLStore) ListItemGrants(id, requesterID, role string) ([]models.ItemGrant, error) {
+	if _, err := s.managedItem(s.db, id, requesterID, role); err != nil {
+		return nil, err
+	}
+	grants, err := s.itemGrants(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	sortGrants(grants)
+	return grants, nil
+}
+
+// GrantItemAccess gives a user or role viewer or editor access to an item,
+// replacing an earlier grant to them.
+func (s *SQLStore) GrantItemAccess(id, requesterID, role string, subjectType models.GrantSubject, subject string, access models.ItemAccess) (models.ItemGrant, error) {
+	subject, err := normalizeGrant(subjectType, subject, access)
+	if err != nil {
+		return models.ItemGrant{}, err
+	}
+
+	var grant models.ItemGrant
+	err = s.withTx(func(tx *sql.Tx) error {
+		item, err := s.managedItem(tx, id, requesterID, role)
+		if err != nil {
+			return err
+		}
+		name := subject
+		if subjectType == models.GrantToUser {
+			user, err := s.getUser(tx, subject)
+			if errors.Is(err, ErrUserNotFound) || (err == nil && ownsItem(item, subject)) {
+				return ErrInvalidGrant
+			}
+			if err != nil {
+				return err
+			}
+			name = user.Username
+		} else if !s.Policy().HasRole(subject) {
+			return ErrInvalidGrant
+		}
+
+		grant = models.ItemGrant{
+			ItemID:      item.ID,
+			SubjectType: subjectType,
+			Subject:     subject,
+			SubjectName: name,
+			Access:      access,
+			CreatedAt:   time.Now().UTC(),
+		}
+		_, err = s.exec(tx,
+			"INSERT INTO item_grants (item_id, subject_type, subject, access, created_at) VALUES (?, ?, ?, ?, ?) "+
+				"ON CONFLICT (item_id, subject_type, subject) DO UPDATE SET access = excluded.access, created_at = excluded.created_at",
+			grant.ItemID, grant.SubjectType, grant.Subject, grant.Access, grant.CreatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save grant: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.ItemGrant{}, err
+	}
+	return grant, nil
+}
+
+// RevokeItemAccess removes the grant of an item to a user or role.
+func (s *SQLStore) RevokeItemAccess(id, requesterID, role string, subjectType models.GrantSubject, subject string) error {
+	if subjectType == models.GrantToRole {
+		subject, _ = normalizeGrant(subjectType, subject, models.ItemViewer)
+	}
+
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.managedItem(tx, id, requesterID, role); err != nil {
+			return err
+		}
+		res, err := s.exec(tx, "DELETE FROM item_grants WHERE item_id = ? AND subject_type = ? AND subject = ?", id, subjectType, subject)
+		if err != nil {
+			return fmt.Errorf("failed to delete grant: %w", err)
+		}
+		return requireAffected(res, ErrGrantNotFound)
+	})
+}
+
+// EffectiveItemAccess lists every user who can see an item, with what they may
+// do and why, ordered by username.
+func (s *SQLStore) EffectiveItemAccess(id string) ([]models.EffectiveAccess, error) {
+	item, err := s.getItem(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	grants, err := s.itemGrants(s.db, id)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.ListUsers()
+	if err != nil {
+		return nil, err
+	}
+	return effectiveAccess(s.Policy(), item, grants, users), nil
+}
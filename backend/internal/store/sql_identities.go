@@ -0,0 +1,186 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"assignment3/backend/internal/models"
+)
+
+const identityColumns = "id, user_id, issuer, subject, email, created_at"
+
+func scanIdentity(row rowScanner) (models.ExternalIdentity, error) {
+	var identity models.ExternalIdentity
+	if err := row.Scan(&identity.ID, &identity.UserID, &identity.Issuer, &identity.Subject, &identity.Email, &identity.CreatedAt); err != nil {
+		return models.ExternalIdentity{}, err
+	}
+	identity.CreatedAt = identity.CreatedAt.UTC()
+	return identity, nil
+}
+
+func (s *SQLStore) insertIdentity(q queryer, identity models.ExternalIdentity) error {
+	_, err := s.exec(q,
+		"INSERT INTO identities (id, user_id, issuer, subject, email, created_at) VALUES (?, ?, ?, ?, ?, ?)",
+		identity.ID, identity.UserID, identity.Issuer, identity.Subject, identity.Email, identity.CreatedAt,
+	)
+	if err != nil {
+		if s.dialect.isUniqueViolation(err) {
+			return ErrIdentityExists
+		}
+		return fmt.Errorf("failed to insert identity: %w", err)
+	}
+	return nil
+}
+
+// GetUserByIdentity returns the user linked to the external identity.
+func (s *SQLStore) GetUserByIdentity(issuer, subject string) (models.User, error) {
+	var userID string
+	err := s.queryRow(s.db, "SELECT user_id FROM identities WHERE issuer = ? AND subject = ?", issuer, subject).Scan(&userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrIdentityNotFound
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to load identity: %w", err)
+	}
+	return s.getUser(s.db, userID)
+}
+
+// GetUserByEmail returns the only account with email. Addresses are compared
+// case-insensitively.
+func (s *SQLStore) GetUserByEmail(email string) (models.User, error) {
+	email = strings.TrimSpace(email)
+	if email == "" {
+		return models.User{}, ErrUserNotFound
+	}
+
+	rows, err := s.query(s.db, "SELECT "+userColumns+" FROM users WHERE LOWER(email) = LOWER(?) LIMIT 2", email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to look up email: %w", err)
+	}
+	defer rows.Close()
+
+	var users []models.User
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return models.User{}, fmt.Errorf("failed to scan user: %w", err)
+		}
+		users = append(users, user)
+	}
+	if err := rows.Err(); err != nil {
+		return models.User{}, fmt.Errorf("failed to look up email: %w", err)
+	}
+	if len(users) != 1 {
+		return models.User{}, ErrUserNotFound
+	}
+	return users[0], nil
+}
+
+// CreateExternalUser provisions a passwordless account linked to the external
+// identity and records it in the audit log.
+func (s *SQLStore) CreateExternalUser(username, email, role, issuer, subject string) (models.User, error) {
+	user, err := newExternalUser(s.Policy(), username, email, role)
+	if err != nil {
+		return models.User{}, err
+	}
+	identity, err := newIdentity(user.ID, issuer, subject, email)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		if err := s.insertUser(tx, user); err != nil {
+			return err
+		}
+		if err := s.insertIdentity(tx, identity); err != nil {
+			return err
+		}
+		return s.insertAuditEvent(tx, newAuditEvent(user.ID, AuditUserProvisioned, user.ID, map[string]string{"issuer": issuer, "role": user.Role}))
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// LinkIdentity links an external identity to an existing user.
+func (s *SQLStore) LinkIdentity(userID, issuer, subject, email string) (models.ExternalIdentity, error) {
+	identity, err := newIdentity(userID, issuer, subject, email)
+	if err != nil {
+		return models.ExternalIdentity{}, err
+	}
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.ServiceAccount {
+			return ErrServiceAccount
+		}
+		if err := s.insertIdentity(tx, identity); err != nil {
+			return err
+		}
+		return s.insertAuditEvent(tx, newAuditEvent(userID, AuditIdentityLinked, userID, map[string]string{"issuer": issuer}))
+	})
+	if err != nil {
+		return models.ExternalIdentity{}, err
+	}
+	return identity, nil
+}
+
+// ListIdentities returns the external identities linked to a user, oldest
+// first.
+func (s *SQLStore) ListIdentities(userID string) ([]models.ExternalIdentity, error) {
+	rows, err := s.query(s.db, "SELECT "+identityColumns+" FROM identities WHERE user_id = ? ORDER BY created_at", userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	defer rows.Close()
+
+	identities := make([]models.ExternalIdentity, 0)
+	for rows.Next() {
+		identity, err := scanIdentity(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan identity: %w", err)
+		}
+		identities = append(identities, identity)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list identities: %w", err)
+	}
+	return identities, nil
+}
+
+// UnlinkIdentity removes one of a user's external identities, unless the
+// account has no password and no other identity to sign in with.
+func (s *SQLStore) UnlinkIdentity(userID, id string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		identity, err := scanIdentity(s.queryRow(tx, "SELECT "+identityColumns+" FROM identities WHERE id = ? AND user_id = ?", id, userID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrIdentityNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load identity: %w", err)
+		}
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if user.PasswordHash == "" {
+			var linked int
+			if err := s.queryRow(tx, "SELECT COUNT(*) FROM identities WHERE user_id = ?", userID).Scan(&linked); err != nil {
+				return fmt.Errorf("failed to count identities: %w", err)
+			}
+			if linked <= 1 {
+				return ErrLastSignInMethod
+			}
+		}
+		if _, err := s.exec(tx, "DELETE FROM identities WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to unlink identity: %w", err)
+		}
+		return s.insertAuditEvent(tx, newAuditEvent(userID, AuditIdentityUnlinked, userID, map[string]string{"issuer": identity.Issuer}))
+	})
+}
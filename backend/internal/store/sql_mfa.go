@@ -0,0 +1,165 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+
+	"assignment3/backend/internal/models"
+)
+
+// setMFA stores the MFA columns of user.
+func (s *SQLStore) setMFA(q queryer, user models.User) error {
+	res, err := s.exec(q,
+		"UPDATE users SET mfa_enabled = ?, mfa_secret = ?, mfa_last_counter = ?, recovery_codes = ? WHERE id = ?",
+		user.MFAEnabled, user.MFASecret, user.MFALastCounter, joinRecoveryCodes(user.RecoveryCodes), user.ID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update two-factor settings: %w", err)
+	}
+	return requireAffected(res, ErrUserNotFound)
+}
+
+// updateMFA loads a user inside a transaction, lets fn change it and stores
+// the result.
+func (s *SQLStore) updateMFA(userID string, fn func(user *models.User) error) (models.User, error) {
+	var updated models.User
+	err := s.withTx(func(tx *sql.Tx) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if err := fn(&user); err != nil {
+			return err
+		}
+		if err := s.setMFA(tx, user); err != nil {
+			return err
+		}
+		updated = user
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
+
+// BeginMFAEnrollment stores a new, unconfirmed TOTP secret for a user,
+// replacing any earlier unconfirmed one.
+func (s *SQLStore) BeginMFAEnrollment(userID, secret string) (models.User, error) {
+	return s.updateMFA(userID, func(user *models.User) error {
+		if user.MFAEnabled {
+			return ErrMFAAlreadyEnabled
+		}
+		user.MFASecret = secret
+		return nil
+	})
+}
+
+// EnableMFA confirms a pending enrollment once a code for counter has been
+// verified against its secret, and stores the recovery code hashes.
+func (s *SQLStore) EnableMFA(userID string, counter int64, recoveryCodeHashes []string) (models.User, error) {
+	return s.updateMFA(userID, func(user *models.User) error {
+		if user.MFAEnabled {
+			return ErrMFAAlreadyEnabled
+		}
+		if user.MFASecret == "" {
+			return ErrMFANotEnrolled
+		}
+		user.MFAEnabled = true
+		user.MFALastCounter = counter
+		user.RecoveryCodes = recoveryCodeHashes
+		return nil
+	})
+}
+
+// UseMFACode records that the code for counter was accepted. Codes for the
+// same or an earlier time step are refused with ErrMFACodeReused.
+func (s *SQLStore) UseMFACode(userID string, counter int64) error {
+	res, err := s.exec(s.db,
+		"UPDATE users SET mfa_last_counter = ? WHERE id = ? AND mfa_enabled = ? AND mfa_last_counter < ?",
+		counter, userID, true, counter,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record verification code: %w", err)
+	}
+	if err := requireAffected(res, ErrMFACodeReused); err != nil {
+		// Nothing matched: tell a missing user or authenticator from a replay.
+		user, lookupErr := s.getUser(s.db, userID)
+		if lookupErr != nil {
+			return lookupErr
+		}
+		if !user.MFAEnabled {
+			return ErrMFANotEnrolled
+		}
+		return err
+	}
+	return nil
+}
+
+// UseRecoveryCode spends one of a user's recovery codes. The update only
+// applies if the codes did not change since they were read, so a code cannot
+// be spent twice by concurrent requests.
+func (s *SQLStore) UseRecoveryCode(userID, codeHash string) (models.User, error) {
+	user, err := s.getUser(s.db, userID)
+	if err != nil {
+		return models.User{}, err
+	}
+	if !user.MFAEnabled {
+		return models.User{}, ErrMFANotEnrolled
+	}
+	remaining, found := spendRecoveryCode(user.RecoveryCodes, codeHash)
+	if !found {
+		return models.User{}, ErrRecoveryCodeInvalid
+	}
+	res, err := s.exec(s.db,
+		"UPDATE users SET recovery_codes = ? WHERE id = ? AND recovery_codes = ?",
+		joinRecoveryCodes(remaining), userID, joinRecoveryCodes(user.RecoveryCodes),
+	)
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to spend recovery code: %w", err)
+	}
+	if err := requireAffected(res, ErrRecoveryCodeInvalid); err != nil {
+		return models.User{}, err
+	}
+	user.RecoveryCodes = remaining
+	return user, nil
+}
+
+// ReplaceRecoveryCodes swaps a user's recovery codes for new ones.
+func (s *SQLStore) ReplaceRecoveryCodes(userID string, recoveryCodeHashes []string) (models.User, error) {
+	return s.updateMFA(userID, func(user *models.User) error {
+		if !user.MFAEnabled {
+			return ErrMFANotEnrolled
+		}
+		user.RecoveryCodes = recoveryCodeHashes
+		return nil
+	})
+}
+
+// DisableMFA removes a user's authenticator and recovery codes and records
+// the change in the audit log under actorID.
+func (s *SQLStore) DisableMFA(actorID, userID string) (models.User, error) {
+	var updated models.User
+	err := s.withTx(func(tx *sql.Tx) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.MFAEnabled && user.MFASecret == "" {
+			return ErrMFANotEnrolled
+		}
+		clearMFA(&user)
+		if err := s.setMFA(tx, user); err != nil {
+			return err
+		}
+		if err := s.insertAuditEvent(tx, newAuditEvent(actorID, AuditUserMFADisabled, user.ID, nil)); err != nil {
+			return err
+		}
+		updated = user
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,88 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"assignment3/backend/internal/models"
+)
+
+// CreatePasswordResetToken stores the hash of a self-service reset token,
+// replacing any the user requested before.
+func (s *SQLStore) CreatePasswordResetToken(userID, tokenHash string, expiresAt time.Time) (models.PasswordResetToken, error) {
+	token := newPasswordResetToken(userID, tokenHash, expiresAt)
+	err := s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.getUser(tx, userID); err != nil {
+			return err
+		}
+		if _, err := s.exec(tx, "DELETE FROM password_reset_tokens WHERE user_id = ? OR expires_at <= ?", userID, token.CreatedAt); err != nil {
+			return fmt.Errorf("failed to prune password reset tokens: %w", err)
+		}
+		if _, err := s.exec(tx,
+			"INSERT INTO password_reset_tokens (id, user_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?)",
+			token.ID, token.UserID, token.TokenHash, token.CreatedAt, token.ExpiresAt,
+		); err != nil {
+			return fmt.Errorf("failed to insert password reset token: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.PasswordResetToken{}, err
+	}
+	return token, nil
+}
+
+// ResetPasswordWithToken spends a reset token and sets newPassword, clearing
+// any pending forced password change.
+func (s *SQLStore) ResetPasswordWithToken(tokenHash, newPassword string) (models.User, error) {
+	if newPassword == "" {
+		return models.User{}, fmt.Errorf("password cannot be empty")
+	}
+	hashed, err := hashPassword(newPassword)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	var updated models.User
+	err = s.withTx(func(tx *sql.Tx) error {
+		var (
+			userID    string
+			expiresAt time.Time
+		)
+		// Deleting the row claims the token, so concurrent requests cannot
+		// both spend it.
+		err := s.queryRow(tx,
+			"DELETE FROM password_reset_tokens WHERE token_hash = ? RETURNING user_id, expires_at", tokenHash,
+		).Scan(&userID, &expiresAt)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrResetTokenInvalid
+		}
+		if err != nil {
+			return fmt.Errorf("failed to claim password reset token: %w", err)
+		}
+		if !time.Now().Before(expiresAt) {
+			return ErrResetTokenInvalid
+		}
+
+		user, err := s.getUser(tx, userID)
+		if errors.Is(err, ErrUserNotFound) {
+			return ErrResetTokenInvalid
+		}
+		if err != nil {
+			return err
+		}
+		user.PasswordHash = hashed
+		user.MustChangePassword = false
+		if err := s.setPassword(tx, user); err != nil {
+			return err
+		}
+		updated = user
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
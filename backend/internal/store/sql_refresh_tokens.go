@@ -0,0 +1,211 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"assignment3/backend/internal/models"
+
+	"github.com/google/uuid"
+)
+
+const refreshTokenColumns = "id, user_id, family_id, token_hash, created_at, expires_at, rotated_at, revoked_at"
+
+func scanRefreshToken(row rowScanner) (models.RefreshToken, error) {
+	var (
+		token     models.RefreshToken
+		rotatedAt sql.NullTime
+		revokedAt sql.NullTime
+	)
+	if err := row.Scan(&token.ID, &token.UserID, &token.FamilyID, &token.TokenHash, &token.CreatedAt, &token.ExpiresAt, &rotatedAt, &revokedAt); err != nil {
+		return models.RefreshToken{}, err
+	}
+	token.CreatedAt = token.CreatedAt.UTC()
+	token.ExpiresAt = token.ExpiresAt.UTC()
+	token.RotatedAt = nullTimePtr(rotatedAt)
+	token.RevokedAt = nullTimePtr(revokedAt)
+	return token, nil
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	utc := t.Time.UTC()
+	return &utc
+}
+
+func (s *SQLStore) insertRefreshToken(q queryer, token models.RefreshToken) error {
+	_, err := s.exec(q,
+		"INSERT INTO refresh_tokens (id, user_id, family_id, token_hash, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+		token.ID, token.UserID, token.FamilyID, token.TokenHash, token.CreatedAt, token.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert refresh token: %w", err)
+	}
+	return nil
+}
+
+// CreateRefreshToken stores the hash of a refresh token that starts a new
+// family. Expired tokens of the same user are pruned along the way.
+func (s *SQLStore) CreateRefreshToken(userID, tokenHash string, expiresAt time.Time) (models.RefreshToken, error) {
+	now := time.Now().UTC()
+	id := uuid.NewString()
+	token := models.RefreshToken{
+		ID:        id,
+		UserID:    userID,
+		FamilyID:  id,
+		TokenHash: tokenHash,
+		CreatedAt: now,
+		ExpiresAt: expiresAt.UTC(),
+	}
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.getUser(tx, userID); err != nil {
+			return err
+		}
+		if _, err := s.exec(tx, "DELETE FROM refresh_tokens WHERE user_id = ? AND expires_at < ?", userID, now); err != nil {
+			return fmt.Errorf("failed to prune refresh tokens: %w", err)
+		}
+		return s.insertRefreshToken(tx, token)
+	})
+	if err != nil {
+		return models.RefreshToken{}, err
+	}
+	return token, nil
+}
+
+// RotateRefreshToken spends the token identified by tokenHash and stores its
+// successor in the same family. Presenting a token that was already rotated
+// revokes the family and returns ErrRefreshTokenReused.
+func (s *SQLStore) RotateRefreshToken(tokenHash, newTokenHash string, expiresAt time.Time) (models.RefreshToken, error) {
+	now := time.Now().UTC()
+	var (
+		next   models.RefreshToken
+		reused bool
+	)
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		current, err := scanRefreshToken(s.queryRow(tx, "SELECT "+refreshTokenColumns+" FROM refresh_tokens WHERE token_hash = ?", tokenHash))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load refresh token: %w", err)
+		}
+		if current.RevokedAt != nil && current.RotatedAt == nil {
+			return ErrRefreshTokenInvalid
+		}
+		if current.RotatedAt == nil && now.After(current.ExpiresAt) {
+			return ErrRefreshTokenInvalid
+		}
+
+		spent := current.RotatedAt != nil
+		if !spent {
+			// The guarded update makes concurrent rotations of the same token
+			// race safely: only one of them can flip rotated_at.
+			res, err := s.exec(tx,
+				"UPDATE refresh_tokens SET rotated_at = ? WHERE id = ? AND rotated_at IS NULL AND revoked_at IS NULL",
+				now, current.ID,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to rotate refresh token: %w", err)
+			}
+			n, err := res.RowsAffected()
+			if err != nil {
+				return fmt.Errorf("failed to read affected rows: %w", err)
+			}
+			spent = n == 0
+		}
+		if spent {
+			if err := s.revokeRefreshTokenFamily(tx, current.FamilyID, now); err != nil {
+				return err
+			}
+			// Commit the revocation and report the reuse afterwards.
+			reused = true
+			return nil
+		}
+
+		next = models.RefreshToken{
+			ID:        uuid.NewString(),
+			UserID:    current.UserID,
+			FamilyID:  current.FamilyID,
+			TokenHash: newTokenHash,
+			CreatedAt: now,
+			ExpiresAt: expiresAt.UTC(),
+		}
+		if err := s.insertRefreshToken(tx, next); err != nil {
+			return err
+		}
+		if _, err := s.exec(tx,
+			"UPDATE sessions SET last_seen_at = ?, expires_at = ? WHERE id = ? AND revoked_at IS NULL",
+			now, next.ExpiresAt, next.FamilyID,
+		); err != nil {
+			return fmt.Errorf("failed to extend session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.RefreshToken{}, err
+	}
+	if reused {
+		return models.RefreshToken{}, ErrRefreshTokenReused
+	}
+	return next, nil
+}
+
+// RevokeUserRefreshTokens revokes every outstanding refresh token of a user.
+func (s *SQLStore) RevokeUserRefreshTokens(userID string) error {
+	now := time.Now().UTC()
+	return s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.exec(tx,
+			"UPDATE refresh_tokens SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+			now, userID,
+		); err != nil {
+			return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+		}
+		if _, err := s.exec(tx,
+			"UPDATE sessions SET revoked_at = ? WHERE user_id = ? AND revoked_at IS NULL",
+			now, userID,
+		); err != nil {
+			return fmt.Errorf("failed to revoke sessions: %w", err)
+		}
+		return nil
+	})
+}
+
+// RevokeRefreshTokenFamily revokes the token identified by tokenHash together
+// with every token rotated from the same login.
+func (s *SQLStore) RevokeRefreshTokenFamily(tokenHash string) error {
+	return s.withTx(func(tx *sql.Tx) error {
+		var familyID string
+		err := s.queryRow(tx, "SELECT family_id FROM refresh_tokens WHERE token_hash = ?", tokenHash).Scan(&familyID)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrRefreshTokenInvalid
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load refresh token: %w", err)
+		}
+		return s.revokeRefreshTokenFamily(tx, familyID, time.Now().UTC())
+	})
+}
+
+// revokeRefreshTokenFamily revokes the tokens of a family together with its
+// session.
+func (s *SQLStore) revokeRefreshTokenFamily(q queryer, familyID string, now time.Time) error {
+	if _, err := s.exec(q,
+		"UPDATE refresh_tokens SET revoked_at = ? WHERE family_id = ? AND revoked_at IS NULL",
+		now, familyID,
+	); err != nil {
+		return fmt.Errorf("failed to revoke refresh token family: %w", err)
+	}
+	if _, err := s.exec(q,
+		"UPDATE sessions SET revoked_at = ? WHERE id = ? AND revoked_at IS NULL",
+		now, familyID,
+	); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,73 @@
+package store
+
+import (
+	"fmt"
+	"time"
+
+	"assignment3/backend/internal/models"
+)
+
+func (s *SQLStore) upsertRevokedToken(entry models.RevokedToken) error {
+	if _, err := s.exec(s.db, "DELETE FROM revoked_tokens WHERE expires_at <= ?", entry.RevokedAt); err != nil {
+		return fmt.Errorf("failed to prune revoked tokens: %w", err)
+	}
+	_, err := s.exec(s.db,
+		`INSERT INTO revoked_tokens (id, jti, user_id, revoked_at, expires_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (id) DO UPDATE SET revoked_at = excluded.revoked_at, expires_at = excluded.expires_at`,
+		entry.ID, entry.JTI, entry.UserID, entry.RevokedAt, entry.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+	return nil
+}
+
+// RevokeAccessToken denylists a single access token until it expires.
+func (s *SQLStore) RevokeAccessToken(jti, userID string, expiresAt time.Time) error {
+	return s.upsertRevokedToken(models.RevokedToken{
+		ID:        jti,
+		JTI:       jti,
+		UserID:    userID,
+		RevokedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt.UTC(),
+	})
+}
+
+// RevokeUserAccessTokens denylists every access token of a user issued so far.
+// expiresAt should be at least as late as the newest such token's expiry.
+func (s *SQLStore) RevokeUserAccessTokens(userID string, expiresAt time.Time) error {
+	return s.upsertRevokedToken(models.RevokedToken{
+		ID:        userRevocationID(userID),
+		UserID:    userID,
+		RevokedAt: revocationTime(),
+		ExpiresAt: expiresAt.UTC(),
+	})
+}
+
+// IsAccessTokenRevoked reports whether the token identified by jti, issued to
+// userID at issuedAt, has been revoked.
+func (s *SQLStore) IsAccessTokenRevoked(jti, userID string, issuedAt time.Time) (bool, error) {
+	rows, err := s.query(s.db,
+		"SELECT id, jti, user_id, revoked_at, expires_at FROM revoked_tokens WHERE id IN (?, ?)",
+		jti, userRevocationID(userID),
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	defer rows.Close()
+
+	now := time.Now().UTC()
+	for rows.Next() {
+		var entry models.RevokedToken
+		if err := rows.Scan(&entry.ID, &entry.JTI, &entry.UserID, &entry.RevokedAt, &entry.ExpiresAt); err != nil {
+			return false, fmt.Errorf("failed to scan revoked token: %w", err)
+		}
+		if revocationMatches(entry, issuedAt, now) {
+			return true, nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return false, fmt.Errorf("failed to check token revocation: %w", err)
+	}
+	return false, nil
+}
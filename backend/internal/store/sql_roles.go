@@ -0,0 +1,300 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+)
+
+const roleColumns = "name, permissions, require_mfa, created_at, updated_at"
+
+func scanRole(row rowScanner) (models.Role, error) {
+	var (
+		role  models.Role
+		perms string
+	)
+	if err := row.Scan(&role.Name, &perms, &role.RequireMFA, &role.CreatedAt, &role.UpdatedAt); err != nil {
+		return models.Role{}, err
+	}
+	role.Permissions = []string{}
+	if perms != "" {
+		role.Permissions = strings.Split(perms, ",")
+	}
+	role.CreatedAt = role.CreatedAt.UTC()
+	role.UpdatedAt = role.UpdatedAt.UTC()
+	return role, nil
+}
+
+func (s *SQLStore) listRoles(q queryer) ([]models.Role, error) {
+	rows, err := s.query(q, "SELECT "+roleColumns+" FROM roles ORDER BY name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles := make([]models.Role, 0)
+	for rows.Next() {
+		role, err := scanRole(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan role: %w", err)
+		}
+		roles = append(roles, role)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	return roles, nil
+}
+
+func (s *SQLStore) getRole(q queryer, name string) (models.Role, error) {
+	role, err := scanRole(s.queryRow(q, "SELECT "+roleColumns+" FROM roles WHERE name = ?", rbac.NormalizeRole(name)))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Role{}, ErrRoleNotFound
+	}
+	if err != nil {
+		return models.Role{}, fmt.Errorf("failed to load role: %w", err)
+	}
+	return role, nil
+}
+
+func (s *SQLStore) putRole(q queryer, role models.Role) error {
+	if _, err := s.exec(q,
+		"INSERT INTO roles ("+roleColumns+") VALUES (?, ?, ?, ?, ?) "+
+			"ON CONFLICT (name) DO UPDATE SET permissions = excluded.permissions, require_mfa = excluded.require_mfa, updated_at = excluded.updated_at",
+		role.Name, strings.Join(role.Permissions, ","), role.RequireMFA, role.CreatedAt, role.UpdatedAt,
+	); err != nil {
+		return fmt.Errorf("failed to save role: %w", err)
+	}
+	return nil
+}
+
+// defaultRole returns the role flagged as the default by ApplyPolicy, or the
+// built-in default if no policy has been applied.
+func (s *SQLStore) defaultRole(q queryer) (string, error) {
+	var name string
+	err := s.queryRow(q, "SELECT name FROM roles WHERE is_default").Scan(&name)
+	if errors.Is(err, sql.ErrNoRows) {
+		return defaultPolicy.DefaultRole(), nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to load default role: %w", err)
+	}
+	return name, nil
+}
+
+// reloadPolicy rebuilds the cached policy from the roles table.
+func (s *SQLStore) reloadPolicy() error {
+	roles, err := s.listRoles(s.db)
+	if err != nil {
+		return err
+	}
+	defaultRole, err := s.defaultRole(s.db)
+	if err != nil {
+		return err
+	}
+	policy, err := buildPolicy(defaultRole, roles)
+	if err != nil {
+		return fmt.Errorf("failed to build rbac policy: %w", err)
+	}
+	s.setPolicy(policy)
+	return nil
+}
+
+// changeRoles runs fn in a transaction and refreshes the cached policy once it
+// commits. Role changes are serialised so reloads cannot go back in time.
+func (s *SQLStore) changeRoles(fn func(tx *sql.Tx) error) error {
+	s.rolesMu.Lock()
+	defer s.rolesMu.Unlock()
+
+	if err := s.withTx(fn); err != nil {
+		return err
+	}
+	return s.reloadPolicy()
+}
+
+// ListRoles returns all roles sorted by name.
+func (s *SQLStore) ListRoles() ([]models.Role, error) {
+	return s.listRoles(s.db)
+}
+
+// GetRole returns a single role by name.
+func (s *SQLStore) GetRole(name string) (models.Role, error) {
+	return s.getRole(s.db, name)
+}
+
+// CreateRole stores a new role with the given permissions.
+func (s *SQLStore) CreateRole(name string, permissions []string) (models.Role, error) {
+	name, err := normalizeRoleName(name)
+	if err != nil {
+		return models.Role{}, err
+	}
+	perms, err := normalizePermissions(permissions)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	now := time.Now().UTC()
+	role := models.Role{Name: name, Permissions: perms, CreatedAt: now, UpdatedAt: now}
+	err = s.changeRoles(func(tx *sql.Tx) error {
+		_, err := s.exec(tx,
+			"INSERT INTO roles ("+roleColumns+") VALUES (?, ?, ?, ?, ?)",
+			role.Name, strings.Join(role.Permissions, ","), role.RequireMFA, role.CreatedAt, role.UpdatedAt,
+		)
+		if err != nil {
+			if s.dialect.isUniqueViolation(err) {
+				return ErrRoleExists
+			}
+			return fmt.Errorf("failed to insert role: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Role{}, err
+	}
+	return role, nil
+}
+
+// UpdateRole replaces the permissions of a role.
+func (s *SQLStore) UpdateRole(name string, permissions []string) (models.Role, error) {
+	perms, err := normalizePermissions(permissions)
+	if err != nil {
+		return models.Role{}, err
+	}
+
+	var updated models.Role
+	err = s.changeRoles(func(tx *sql.Tx) error {
+		role, err := s.getRole(tx, name)
+		if err != nil {
+			return err
+		}
+		roles, err := s.listRoles(tx)
+		if err != nil {
+			return err
+		}
+		wasManager := roleGrants(role, rbac.UsersManage)
+		role.Permissions = perms
+		role.UpdatedAt = time.Now().UTC()
+		if err := requireManagerRole(replaceRole(roles, role.Name, &role)); err != nil {
+			return err
+		}
+		if wasManager && !roleGrants(role, rbac.UsersManage) {
+			managers, err := s.managerUsers(tx)
+			if err != nil {
+				return err
+			}
+			outside := 0
+			for _, held := range managers {
+				if held != role.Name {
+					outside++
+				}
+			}
+			if len(managers) > 0 && outside == 0 {
+				return ErrLastAdmin
+			}
+		}
+		if err := s.putRole(tx, role); err != nil {
+			return err
+		}
+		updated = role
+		return nil
+	})
+	if err != nil {
+		return models.Role{}, err
+	}
+	return updated, nil
+}
+
+// SetRoleMFARequired sets whether users holding a role must use two-factor
+// authentication.
+func (s *SQLStore) SetRoleMFARequired(name string, required bool) (models.Role, error) {
+	var updated models.Role
+	err := s.changeRoles(func(tx *sql.Tx) error {
+		role, err := s.getRole(tx, name)
+		if err != nil {
+			return err
+		}
+		role.RequireMFA = required
+		role.UpdatedAt = time.Now().UTC()
+		if err := s.putRole(tx, role); err != nil {
+			return err
+		}
+		updated = role
+		return nil
+	})
+	if err != nil {
+		return models.Role{}, err
+	}
+	return updated, nil
+}
+
+// DeleteRole removes a role that is no longer needed.
+func (s *SQLStore) DeleteRole(name string) error {
+	defaultRole := s.Policy().DefaultRole()
+	return s.changeRoles(func(tx *sql.Tx) error {
+		role, err := s.getRole(tx, name)
+		if err != nil {
+			return err
+		}
+		if role.Name == defaultRole {
+			return ErrRoleInUse
+		}
+
+		var assigned int
+		if err := s.queryRow(tx, "SELECT COUNT(*) FROM users WHERE role = ?", role.Name).Scan(&assigned); err != nil {
+			return fmt.Errorf("failed to count role members: %w", err)
+		}
+		if assigned > 0 {
+			return ErrRoleInUse
+		}
+
+		roles, err := s.listRoles(tx)
+		if err != nil {
+			return err
+		}
+		if err := requireManagerRole(replaceRole(roles, role.Name, nil)); err != nil {
+			return err
+		}
+		if _, err := s.exec(tx, "DELETE FROM item_grants WHERE subject_type = ? AND subject = ?", models.GrantToRole, role.Name); err != nil {
+			return fmt.Errorf("failed to delete role grants: %w", err)
+		}
+		if _, err := s.exec(tx, "DELETE FROM roles WHERE name = ?", role.Name); err != nil {
+			return fmt.Errorf("failed to delete role: %w", err)
+		}
+		return nil
+	})
+}
+
+// ApplyPolicy creates or overwrites the roles defined by policy and adopts its
+// default role.
+func (s *SQLStore) ApplyPolicy(policy *rbac.Policy) error {
+	return s.changeRoles(func(tx *sql.Tx) error {
+		existing, err := s.listRoles(tx)
+		if err != nil {
+			return err
+		}
+		roles := policyRoles(policy, existing, time.Now().UTC())
+		merged := existing
+		for i := range roles {
+			merged = replaceRole(merged, roles[i].Name, &roles[i])
+		}
+		if err := requireManagerRole(merged); err != nil {
+			return err
+		}
+		for _, role := range roles {
+			if err := s.putRole(tx, role); err != nil {
+				return err
+			}
+		}
+		// The default role is stored with the roles, so it survives a
+		// restart even if the policy is not applied again.
+		if _, err := s.exec(tx, "UPDATE roles SET is_default = (name = ?)", policy.DefaultRole()); err != nil {
+			return fmt.Errorf("failed to set default role: %w", err)
+		}
+		return nil
+	})
+}
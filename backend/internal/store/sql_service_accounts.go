@@ -0,0 +1,79 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"assignment3/backend/internal/models"
+)
+
+// CreateServiceAccount adds a service account and records it in the audit log
+// under actorID.
+func (s *SQLStore) CreateServiceAccount(actorID, name, role string, scopes []string, clientID, secretHash string) (models.User, error) {
+	user, err := newServiceAccount(s.Policy(), name, role, scopes, clientID, secretHash)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	err = s.withTx(func(tx *sql.Tx) error {
+		if err := s.insertUser(tx, user); err != nil {
+			return err
+		}
+		return s.insertAuditEvent(tx, newAuditEvent(actorID, AuditServiceAccountCreated, user.ID, map[string]string{"role": user.Role}))
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// AuthenticateClient returns the service account with clientID if secretHash
+// matches its secret.
+func (s *SQLStore) AuthenticateClient(clientID, secretHash string) (models.User, error) {
+	if clientID == "" {
+		return models.User{}, ErrInvalidCredentials
+	}
+	user, err := scanUser(s.queryRow(s.db, "SELECT "+userColumns+" FROM users WHERE client_id = ?", clientID))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("failed to load client: %w", err)
+	}
+	if !clientSecretMatches(user, secretHash) {
+		return models.User{}, ErrInvalidCredentials
+	}
+	if err := CheckAccountStatus(user); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// RotateClientSecret replaces a service account's secret and records the
+// rotation in the audit log under actorID.
+func (s *SQLStore) RotateClientSecret(actorID, userID, secretHash string) (models.User, error) {
+	var updated models.User
+	err := s.withTx(func(tx *sql.Tx) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if !user.ServiceAccount {
+			return ErrNotServiceAccount
+		}
+		if _, err := s.exec(tx, "UPDATE users SET client_secret_hash = ? WHERE id = ?", secretHash, user.ID); err != nil {
+			return fmt.Errorf("failed to rotate client secret: %w", err)
+		}
+		if err := s.insertAuditEvent(tx, newAuditEvent(actorID, AuditClientSecretRotated, user.ID, nil)); err != nil {
+			return err
+		}
+		user.ClientSecretHash = secretHash
+		updated = user
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
@@ -0,0 +1,131 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"assignment3/backend/internal/models"
+)
+
+const sessionColumns = "id, user_id, user_agent, ip, created_at, last_seen_at, expires_at, revoked_at"
+
+func scanSession(row rowScanner) (models.Session, error) {
+	var (
+		session   models.Session
+		revokedAt sql.NullTime
+	)
+	if err := row.Scan(&session.ID, &session.UserID, &session.UserAgent, &session.IP, &session.CreatedAt, &session.LastSeenAt, &session.ExpiresAt, &revokedAt); err != nil {
+		return models.Session{}, err
+	}
+	session.CreatedAt = session.CreatedAt.UTC()
+	session.LastSeenAt = session.LastSeenAt.UTC()
+	session.ExpiresAt = session.ExpiresAt.UTC()
+	session.RevokedAt = nullTimePtr(revokedAt)
+	return session, nil
+}
+
+// CreateSession records the device a refresh token family was issued to.
+// Expired sessions of the same user are pruned along the way; revoked ones
+// are kept until then so that they cannot be recreated.
+func (s *SQLStore) CreateSession(userID, familyID, userAgent, ip string, expiresAt time.Time) (models.Session, error) {
+	session := newSession(userID, familyID, userAgent, ip, expiresAt)
+
+	err := s.withTx(func(tx *sql.Tx) error {
+		if _, err := s.getUser(tx, userID); err != nil {
+			return err
+		}
+		if _, err := s.exec(tx,
+			"DELETE FROM sessions WHERE user_id = ? AND expires_at <= ?",
+			userID, session.CreatedAt,
+		); err != nil {
+			return fmt.Errorf("failed to prune sessions: %w", err)
+		}
+		_, err := s.exec(tx,
+			"INSERT INTO sessions (id, user_id, user_agent, ip, created_at, last_seen_at, expires_at) VALUES (?, ?, ?, ?, ?, ?, ?)",
+			session.ID, session.UserID, session.UserAgent, session.IP, session.CreatedAt, session.LastSeenAt, session.ExpiresAt,
+		)
+		if err != nil {
+			if s.dialect.isUniqueViolation(err) {
+				return ErrSessionExists
+			}
+			return fmt.Errorf("failed to insert session: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Session{}, err
+	}
+	return session, nil
+}
+
+// ListSessions returns a user's active sessions, most recently seen first.
+func (s *SQLStore) ListSessions(userID string) ([]models.Session, error) {
+	rows, err := s.query(s.db,
+		"SELECT "+sessionColumns+" FROM sessions WHERE user_id = ? AND revoked_at IS NULL AND expires_at > ? ORDER BY last_seen_at DESC",
+		userID, time.Now().UTC(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	defer rows.Close()
+
+	sessions := make([]models.Session, 0)
+	for rows.Next() {
+		session, err := scanSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan session: %w", err)
+		}
+		sessions = append(sessions, session)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// UseSession looks up an active session and records that it was seen from ip.
+func (s *SQLStore) UseSession(id, ip string) (models.Session, error) {
+	session, err := scanSession(s.queryRow(s.db, "SELECT "+sessionColumns+" FROM sessions WHERE id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Session{}, ErrSessionInvalid
+	}
+	if err != nil {
+		return models.Session{}, fmt.Errorf("failed to load session: %w", err)
+	}
+
+	now := time.Now().UTC()
+	if !sessionActive(session, now) {
+		return models.Session{}, ErrSessionInvalid
+	}
+	if sessionNeedsTouch(session, ip, now) {
+		if ip != "" {
+			session.IP = ip
+		}
+		if _, err := s.exec(s.db, "UPDATE sessions SET last_seen_at = ?, ip = ? WHERE id = ?", now, session.IP, session.ID); err != nil {
+			return models.Session{}, fmt.Errorf("failed to record session use: %w", err)
+		}
+		session.LastSeenAt = now
+	}
+	return session, nil
+}
+
+// RevokeSession signs a user out of one session and revokes its refresh
+// tokens.
+func (s *SQLStore) RevokeSession(userID, id string) error {
+	now := time.Now().UTC()
+	return s.withTx(func(tx *sql.Tx) error {
+		session, err := scanSession(s.queryRow(tx, "SELECT "+sessionColumns+" FROM sessions WHERE id = ? AND user_id = ?", id, userID))
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrSessionNotFound
+		}
+		if err != nil {
+			return fmt.Errorf("failed to load session: %w", err)
+		}
+		if !sessionActive(session, now) {
+			return ErrSessionNotFound
+		}
+		return s.revokeRefreshTokenFamily(tx, id, now)
+	})
+}
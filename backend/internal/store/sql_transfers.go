@@ -0,0 +1,186 @@
+package store
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"assignment3/backend/internal/models"
+)
+
+// selectTransfers reads transfers together with the item title and the
+// usernames of both parties.
+const selectTransfers = "SELECT t.id, t.item_id, t.from_user_id, t.to_user_id, items.title, COALESCE(sender.username, ''), COALESCE(recipient.username, ''), t.created_at, t.expires_at " +
+	"FROM item_transfers t JOIN items ON items.id = t.item_id " +
+	"LEFT JOIN users sender ON sender.id = t.from_user_id LEFT JOIN users recipient ON recipient.id = t.to_user_id"
+
+func scanTransfer(row rowScanner) (models.ItemTransfer, error) {
+	var transfer models.ItemTransfer
+	if err := row.Scan(&transfer.ID, &transfer.ItemID, &transfer.FromID, &transfer.ToID, &transfer.ItemTitle, &transfer.From, &transfer.To, &transfer.CreatedAt, &transfer.ExpiresAt); err != nil {
+		return models.ItemTransfer{}, err
+	}
+	transfer.Status = models.TransferPending
+	transfer.CreatedAt = transfer.CreatedAt.UTC()
+	transfer.ExpiresAt = transfer.ExpiresAt.UTC()
+	return transfer, nil
+}
+
+// getPendingTransfer loads a transfer that can still be answered.
+func (s *SQLStore) getPendingTransfer(q queryer, id string) (models.ItemTransfer, error) {
+	transfer, err := scanTransfer(s.queryRow(q, selectTransfers+" WHERE t.id = ?", id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.ItemTransfer{}, ErrTransferNotFound
+	}
+	if err != nil {
+		return models.ItemTransfer{}, fmt.Errorf("failed to load transfer: %w", err)
+	}
+	if !transferPending(transfer, time.Now().UTC()) {
+		return models.ItemTransfer{}, ErrTransferNotFound
+	}
+	return transfer, nil
+}
+
+// ProposeTransfer offers an item to the user with toID until expiresAt. With
+// force the item changes hands at once and the returned transfer is already
+// accepted; it replaces any transfer still pending for the item.
+func (s *SQLStore) ProposeTransfer(itemID, requesterID, role, toID string, force bool, expiresAt time.Time) (models.ItemTransfer, error) {
+	var transfer models.ItemTransfer
+	err := s.withTx(func(tx *sql.Tx) error {
+		item, err := s.visibleItem(tx, itemID, requesterID, role)
+		if err != nil {
+			return err
+		}
+		if !mayProposeTransfer(s.Policy(), item, requesterID, role, force) {
+			return ErrForbidden
+		}
+		recipient, err := s.getUser(tx, toID)
+		if errors.Is(err, ErrUserNotFound) || (err == nil && recipient.ID == item.OwnerID) {
+			return ErrInvalidTransferTarget
+		}
+		if err != nil {
+			return err
+		}
+
+		transfer = newTransfer(item, recipient, expiresAt)
+		if _, err := s.exec(tx, "DELETE FROM item_transfers WHERE expires_at <= ?", transfer.CreatedAt); err != nil {
+			return fmt.Errorf("failed to prune transfers: %w", err)
+		}
+		if force {
+			if _, err := s.exec(tx, "DELETE FROM item_transfers WHERE item_id = ?", item.ID); err != nil {
+				return fmt.Errorf("failed to replace transfers: %w", err)
+			}
+			if _, err := s.exec(tx, "UPDATE items SET owner_id = ? WHERE id = ?", recipient.ID, item.ID); err != nil {
+				return fmt.Errorf("failed to transfer item: %w", err)
+			}
+			transfer.Status = models.TransferAccepted
+			return nil
+		}
+
+		_, err = s.exec(tx,
+			"INSERT INTO item_transfers (id, item_id, from_user_id, to_user_id, created_at, expires_at) VALUES (?, ?, ?, ?, ?, ?)",
+			transfer.ID, transfer.ItemID, transfer.FromID, transfer.ToID, transfer.CreatedAt, transfer.ExpiresAt,
+		)
+		if err != nil {
+			if s.dialect.isUniqueViolation(err) {
+				return ErrTransferPending
+			}
+			return fmt.Errorf("failed to insert transfer: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.ItemTransfer{}, err
+	}
+	return transfer, nil
+}
+
+// ListTransfers returns the pending transfers sent or offered to the user with
+// userID, or all of them if userID is empty, newest first.
+func (s *SQLStore) ListTransfers(userID string) ([]models.ItemTransfer, error) {
+	query := selectTransfers + " WHERE t.expires_at > ?"
+	args := []any{time.Now().UTC()}
+	if userID != "" {
+		query += " AND (t.from_user_id = ? OR t.to_user_id = ?)"
+		args = append(args, userID, userID)
+	}
+	rows, err := s.query(s.db, query+" ORDER BY t.created_at DESC", args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list transfers: %w", err)
+	}
+	defer rows.Close()
+
+	transfers := make([]models.ItemTransfer, 0)
+	for rows.Next() {
+		transfer, err := scanTransfer(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan transfer: %w", err)
+		}
+		transfers = append(transfers, transfer)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to list transfers: %w", err)
+	}
+	return transfers, nil
+}
+
+// RespondToTransfer accepts or declines a transfer offered to the user with
+// userID. Accepting makes that user the item's owner.
+func (s *SQLStore) RespondToTransfer(id, userID string, accept bool) (models.ItemTransfer, error) {
+	var transfer models.ItemTransfer
+	err := s.withTx(func(tx *sql.Tx) error {
+		var err error
+		transfer, err = s.getPendingTransfer(tx, id)
+		if err != nil {
+			return err
+		}
+		if transfer.ToID != userID {
+			return ErrTransferNotFound
+		}
+
+		transfer.Status = models.TransferDeclined
+		if accept {
+			res, err := s.exec(tx, "UPDATE items SET owner_id = ? WHERE id = ? AND owner_id = ?", transfer.ToID, transfer.ItemID, transfer.FromID)
+			if err != nil {
+				return fmt.Errorf("failed to transfer item: %w", err)
+			}
+			if err := requireAffected(res, ErrTransferNotFound); err != nil {
+				return err
+			}
+			transfer.Status = models.TransferAccepted
+		}
+		if _, err := s.exec(tx, "DELETE FROM item_transfers WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete transfer: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.ItemTransfer{}, err
+	}
+	return transfer, nil
+}
+
+// CancelTransfer withdraws a pending transfer. Its sender and users who may
+// edit any item can cancel it; for anyone else it does not exist.
+func (s *SQLStore) CancelTransfer(id, requesterID, role string) (models.ItemTransfer, error) {
+	var transfer models.ItemTransfer
+	err := s.withTx(func(tx *sql.Tx) error {
+		var err error
+		transfer, err = s.getPendingTransfer(tx, id)
+		if err != nil {
+			return err
+		}
+		if !mayCancelTransfer(s.Policy(), transfer, requesterID, role) {
+			return ErrTransferNotFound
+		}
+		if _, err := s.exec(tx, "DELETE FROM item_transfers WHERE id = ?", id); err != nil {
+			return fmt.Errorf("failed to delete transfer: %w", err)
+		}
+		transfer.Status = models.TransferCancelled
+		return nil
+	})
+	if err != nil {
+		return models.ItemTransfer{}, err
+	}
+	return transfer, nil
+}
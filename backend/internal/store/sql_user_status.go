@@ -0,0 +1,51 @@
+package store
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"assignment3/backend/internal/models"
+)
+
+// SetUserStatus moves a user to status and records the change in the audit
+// log under actorID. Making the last active user who can manage users
+// inactive fails with ErrLastAdmin.
+func (s *SQLStore) SetUserStatus(actorID, userID string, status models.UserStatus, reason string, until *time.Time) (models.User, error) {
+	var updated models.User
+	err := s.withTx(func(tx *sql.Tx) error {
+		user, err := s.getUser(tx, userID)
+		if err != nil {
+			return err
+		}
+		if status != models.UserStatusActive {
+			if err := s.requireOtherManager(tx, user); err != nil {
+				return err
+			}
+		}
+		event, err := applyStatusChange(actorID, &user, status, reason, until, time.Now().UTC())
+		if err != nil {
+			return err
+		}
+
+		var suspendedUntil sql.NullTime
+		if user.SuspendedUntil != nil {
+			suspendedUntil = sql.NullTime{Time: *user.SuspendedUntil, Valid: true}
+		}
+		if _, err := s.exec(tx,
+			"UPDATE users SET status = ?, status_reason = ?, suspended_until = ? WHERE id = ?",
+			string(user.Status), user.StatusReason, suspendedUntil, user.ID,
+		); err != nil {
+			return fmt.Errorf("failed to update status: %w", err)
+		}
+		if err := s.insertAuditEvent(tx, event); err != nil {
+			return err
+		}
+		updated = user
+		return nil
+	})
+	if err != nil {
+		return models.User{}, err
+	}
+	return updated, nil
+}
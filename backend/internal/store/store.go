@@ -9,9 +9,7 @@ import (
 	"time"
 
 	"assignment3/backend/internal/models"
-
-	"github.com/google/uuid"
-	"golang.org/x/crypto/bcrypt"
+	"assignment3/backend/internal/rbac"
 )
 
 var (
@@ -27,21 +25,147 @@ var (
 	ErrForbidden = errors.New("forbidden")
 	// ErrUserNotFound indicates that a user could not be located.
 	ErrUserNotFound = errors.New("user not found")
+	// ErrRefreshTokenInvalid is returned for unknown, expired or revoked refresh tokens.
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid or expired")
+	// ErrRefreshTokenReused signals that an already rotated refresh token was
+	// presented again; its whole family has been revoked in response.
+	ErrRefreshTokenReused = errors.New("refresh token reuse detected")
+	// ErrRoleNotFound indicates that a role could not be located.
+	ErrRoleNotFound = errors.New("role not found")
+	// ErrRoleExists signals that a role name is already taken.
+	ErrRoleExists = errors.New("role already exists")
+	// ErrRoleInUse is returned when deleting a role that is assigned to users
+	// or is the default role for new accounts.
+	ErrRoleInUse = errors.New("role is in use")
+	// ErrLastManagerRole prevents removing users:manage from the last role
+	// that grants it, which would lock everyone out of administration.
+	ErrLastManagerRole = errors.New("at least one role must be able to manage users")
+	// ErrLastAdmin prevents a role change or deletion that would leave no
+	// user able to manage users.
+	ErrLastAdmin = errors.New("cannot remove the last user who can manage users")
+	// ErrInvalidPermission is returned for permissions the policy engine does not know.
+	ErrInvalidPermission = errors.New("invalid permission")
+	// ErrPasswordUnchanged is returned when a new password equals the current one.
+	ErrPasswordUnchanged = errors.New("new password must differ from the current one")
+	// ErrInvalidEmail is returned for malformed email addresses.
+	ErrInvalidEmail = errors.New("invalid email address")
+	// ErrResetTokenInvalid is returned for unknown, used or expired password
+	// reset tokens.
+	ErrResetTokenInvalid = errors.New("password reset token is invalid or expired")
+	// ErrMFAAlreadyEnabled is returned when enrolling a user who already has
+	// two-factor authentication.
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication is already enabled")
+	// ErrMFANotEnrolled is returned for MFA operations on a user without a
+	// pending or confirmed authenticator.
+	ErrMFANotEnrolled = errors.New("two-factor authentication is not set up")
+	// ErrMFACodeReused is returned when a TOTP code that was already accepted
+	// is presented again.
+	ErrMFACodeReused = errors.New("verification code was already used")
+	// ErrRecoveryCodeInvalid is returned for unknown or spent recovery codes.
+	ErrRecoveryCodeInvalid = errors.New("recovery code is invalid")
+	// ErrAccessTokenNotFound indicates that a personal access token could not
+	// be located.
+	ErrAccessTokenNotFound = errors.New("access token not found")
+	// ErrAccessTokenExists signals that the user already has a personal access
+	// token with the requested name.
+	ErrAccessTokenExists = errors.New("access token name already in use")
+	// ErrAccessTokenInvalid is returned for unknown or expired personal access
+	// tokens.
+	ErrAccessTokenInvalid = errors.New("access token is invalid or expired")
+	// ErrServiceAccount is returned for password operations on a service
+	// account, which has no password.
+	ErrServiceAccount = errors.New("not available for service accounts")
+	// ErrNotServiceAccount is returned for client credential operations on a
+	// regular user.
+	ErrNotServiceAccount = errors.New("user is not a service account")
+	// ErrIdentityNotFound is returned when no user is linked to an external
+	// identity.
+	ErrIdentityNotFound = errors.New("identity not found")
+	// ErrIdentityExists is returned when an external identity is already
+	// linked to a user.
+	ErrIdentityExists = errors.New("identity is already linked to an account")
+	// ErrLastSignInMethod prevents unlinking the only identity of an account
+	// without a password, which could then no longer sign in.
+	ErrLastSignInMethod = errors.New("cannot remove the account's only way to sign in")
+	// ErrSessionNotFound indicates that a user has no such active session.
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrAccountSuspended is returned when a suspended account tries to sign
+	// in.
+	ErrAccountSuspended = errors.New("account is suspended")
+	// ErrAccountInactive is returned when a pending or deactivated account
+	// tries to sign in.
+	ErrAccountInactive = errors.New("account is not active")
+	// ErrInvalidStatus signals an unknown account status.
+	ErrInvalidStatus = errors.New("invalid account status")
+	// ErrSessionExists is returned when a refresh token family already has a
+	// session.
+	ErrSessionExists = errors.New("session already exists")
+	// ErrSessionInvalid is returned for unknown, expired or revoked sessions.
+	ErrSessionInvalid = errors.New("session is invalid or has been revoked")
+	// ErrInvalidItemPolicy signals an unknown policy for a deleted user's
+	// items.
+	ErrInvalidItemPolicy = errors.New("invalid item policy")
+	// ErrInvalidReassignTarget is returned when a deleted user's items are to
+	// be reassigned to the user itself or to one that does not exist.
+	ErrInvalidReassignTarget = errors.New("items must be reassigned to another existing user")
+	// ErrTransferNotFound indicates that a user has no such pending item
+	// transfer, or that it expired.
+	ErrTransferNotFound = errors.New("transfer not found")
+	// ErrTransferPending is returned when proposing a transfer for an item
+	// that already has a pending one.
+	ErrTransferPending = errors.New("item already has a pending transfer")
+	// ErrInvalidTransferTarget is returned when an item is offered to its
+	// owner or to a user that does not exist.
+	ErrInvalidTransferTarget = errors.New("items can only be transferred to another existing user")
+	// ErrInvalidVisibility signals an unknown item visibility.
+	ErrInvalidVisibility = errors.New("visibility must be private, shared or public")
+	// ErrInvalidGrant is returned when access is granted at an unknown level,
+	// to a user or role that does not exist, or to the item's owner.
+	ErrInvalidGrant = errors.New("invalid item grant")
+	// ErrGrantNotFound indicates that an item has no grant for a subject.
+	ErrGrantNotFound = errors.New("grant not found")
 )
 
-// Store provides a concurrency-safe in-memory data store.
+// Store provides a concurrency-safe in-memory data store. When opened with
+// OpenJournaledStore every mutation is also written to a write-ahead log.
 type Store struct {
-	mu    sync.RWMutex
-	items map[string]models.Item
-	users map[string]models.User // keyed by lowercase username
+	mu            sync.RWMutex
+	items         map[string]models.Item
+	users         map[string]models.User                // keyed by lowercase username
+	refreshTokens map[string]models.RefreshToken        // keyed by token hash
+	revokedTokens map[string]models.RevokedToken        // keyed by entry ID
+	roles         map[string]models.Role                // keyed by name
+	auditEvents   map[string]models.AuditEvent          // keyed by ID
+	resetTokens   map[string]models.PasswordResetToken  // keyed by token hash
+	accessTokens  map[string]models.PersonalAccessToken // keyed by token hash
+	identities    map[string]models.ExternalIdentity    // keyed by ID
+	sessions      map[string]models.Session             // keyed by ID
+	transfers     map[string]models.ItemTransfer        // keyed by ID
+	grants        map[string][]models.ItemGrant         // keyed by item ID
+	journal       *journal
+	policyHolder
 }
 
 // NewStore constructs a new store instance.
 func NewStore() *Store {
-	return &Store{
-		items: make(map[string]models.Item),
-		users: make(map[string]models.User),
+	s := &Store{
+		items:         make(map[string]models.Item),
+		users:         make(map[string]models.User),
+		refreshTokens: make(map[string]models.RefreshToken),
+		revokedTokens: make(map[string]models.RevokedToken),
+		roles:         make(map[string]models.Role),
+		auditEvents:   make(map[string]models.AuditEvent),
+		resetTokens:   make(map[string]models.PasswordResetToken),
+		accessTokens:  make(map[string]models.PersonalAccessToken),
+		identities:    make(map[string]models.ExternalIdentity),
+		sessions:      make(map[string]models.Session),
+		transfers:     make(map[string]models.ItemTransfer),
+		grants:        make(map[string][]models.ItemGrant),
+	}
+	for _, role := range defaultRoles(time.Now().UTC()) {
+		s.roles[role.Name] = role
 	}
+	return s
 }
 
 // EnsureAdminUser creates an admin user if it does not exist. If the user already
@@ -56,108 +180,123 @@ func (s *Store) EnsureAdminUser(username, password string) (models.User, bool, e
 		return models.User{}, false, fmt.Errorf("admin password cannot be empty")
 	}
 
-	usernameKey := strings.ToLower(username)
+	key := usernameKey(username)
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if user, ok := s.users[usernameKey]; ok {
+	if user, ok := s.users[key]; ok {
 		// Guarantee the user retains the admin role.
 		if user.Role != "admin" {
 			user.Role = "admin"
-			s.users[usernameKey] = user
+			if err := s.commit(journalRecord{Op: opPutUser, User: &user}); err != nil {
+				return models.User{}, false, err
+			}
 		}
 		return user, false, nil
 	}
 
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	user, err := newUser(username, password, "admin")
 	if err != nil {
-		return models.User{}, false, fmt.Errorf("failed to hash password: %w", err)
+		return models.User{}, false, err
 	}
-
-	now := time.Now().UTC()
-	user := models.User{
-		ID:           uuid.NewString(),
-		Username:     username,
-		PasswordHash: string(hashed),
-		Role:         "admin",
-		CreatedAt:    now,
+	// The bootstrap password comes from configuration; make the first
+	// sign-in replace it.
+	user.MustChangePassword = true
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user}); err != nil {
+		return models.User{}, false, err
 	}
-	s.users[usernameKey] = user
 
 	return user, true, nil
 }
 
 // CreateUser registers a new user with the provided role.
 func (s *Store) CreateUser(username, password, role string) (models.User, error) {
-	username = strings.TrimSpace(username)
-	if username == "" {
-		return models.User{}, fmt.Errorf("username cannot be empty")
+	role, err := normalizeRole(s.Policy(), role)
+	if err != nil {
+		return models.User{}, err
 	}
-	if password == "" {
-		return models.User{}, fmt.Errorf("password cannot be empty")
+
+	user, err := newUser(username, password, role)
+	if err != nil {
+		return models.User{}, err
 	}
+	return s.addUser(user)
+}
 
-	role = strings.TrimSpace(role)
-	if role == "" {
-		role = "user"
+// RegisterUser creates a self-service account with the policy's default role
+// and the given email address, which may be empty.
+func (s *Store) RegisterUser(username, password, email string) (models.User, error) {
+	email, err := NormalizeEmail(email)
+	if err != nil {
+		return models.User{}, err
 	}
-	if role != "user" && role != "admin" {
-		return models.User{}, ErrInvalidRole
+	role, err := normalizeRole(s.Policy(), "")
+	if err != nil {
+		return models.User{}, err
 	}
 
-	usernameKey := strings.ToLower(username)
+	user, err := newUser(username, password, role)
+	if err != nil {
+		return models.User{}, err
+	}
+	user.Email = email
+	return s.addUser(user)
+}
 
+// addUser stores a new user unless the username is taken.
+func (s *Store) addUser(user models.User) (models.User, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	if _, exists := s.users[usernameKey]; exists {
+	if _, exists := s.users[usernameKey(user.Username)]; exists {
 		return models.User{}, ErrUserExists
 	}
-
-	hashed, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
-	if err != nil {
-		return models.User{}, fmt.Errorf("failed to hash password: %w", err)
-	}
-
-	now := time.Now().UTC()
-	user := models.User{
-		ID:           uuid.NewString(),
-		Username:     username,
-		PasswordHash: string(hashed),
-		Role:         role,
-		CreatedAt:    now,
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user}); err != nil {
+		return models.User{}, err
 	}
-	s.users[usernameKey] = user
 
 	return user, nil
 }
 
 // Authenticate validates the provided credentials and returns the user on success.
 func (s *Store) Authenticate(username, password string) (models.User, error) {
-	usernameKey := strings.ToLower(strings.TrimSpace(username))
-
 	s.mu.RLock()
-	user, ok := s.users[usernameKey]
+	user, ok := s.users[usernameKey(username)]
 	s.mu.RUnlock()
 	if !ok {
+		burnPasswordCheck(password)
 		return models.User{}, ErrInvalidCredentials
 	}
 
-	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
-		return models.User{}, ErrInvalidCredentials
+	if err := verifyPassword(user, password); err != nil {
+		return models.User{}, err
+	}
+	if err := CheckAccountStatus(user); err != nil {
+		return models.User{}, err
 	}
 
 	return user, nil
 }
 
-// ListItems returns all items sorted by creation time.
-func (s *Store) ListItems() []models.Item {
+// ListItems returns the items the user with requesterID and role may see,
+// sorted by creation time.
+func (s *Store) ListItems(requesterID, role string) ([]models.Item, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	policy := s.Policy()
+	usernames := make(map[string]string, len(s.users))
+	for _, user := range s.users {
+		usernames[user.ID] = user.Username
+	}
 	items := make([]models.Item, 0, len(s.items))
 	for _, item := range s.items {
+		item.Access, _ = itemAccess(policy, item, s.grants[item.ID], requesterID, role)
+		if item.Access == "" {
+			continue
+		}
+		item.Owner = usernames[item.OwnerID]
 		items = append(items, item)
 	}
 
@@ -165,47 +304,67 @@ func (s *Store) ListItems() []models.Item {
 		return items[i].CreatedAt.Before(items[j].CreatedAt)
 	})
 
-	return items
+	return items, nil
 }
 
-// GetItem returns a single item by id.
-func (s *Store) GetItem(id string) (models.Item, error) {
+// GetItem returns a single item by id if the user with requesterID and role
+// may see it, and ErrItemNotFound otherwise.
+func (s *Store) GetItem(id, requesterID, role string) (models.Item, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
+	item, err := s.visibleItemLocked(id, requesterID, role)
+	if err != nil {
+		return models.Item{}, err
+	}
+	return s.withOwnerLocked(item), nil
+}
+
+// visibleItemLocked returns an item with the access the user with requesterID
+// and role has to it, or ErrItemNotFound if the user may not see it.
+func (s *Store) visibleItemLocked(id, requesterID, role string) (models.Item, error) {
 	item, ok := s.items[id]
 	if !ok {
 		return models.Item{}, ErrItemNotFound
 	}
+	item.Access, _ = itemAccess(s.Policy(), item, s.grants[id], requesterID, role)
+	if item.Access == "" {
+		return models.Item{}, ErrItemNotFound
+	}
 	return item, nil
 }
 
-// CreateItem inserts a new item owned by the specified user.
-func (s *Store) CreateItem(owner, title, description string) (models.Item, error) {
-	title = strings.TrimSpace(title)
-	if title == "" {
-		return models.Item{}, fmt.Errorf("title cannot be empty")
+// withOwnerLocked resolves the username of item's owner.
+func (s *Store) withOwnerLocked(item models.Item) models.Item {
+	item.Owner = ""
+	if owner, ok := s.userByIDLocked(item.OwnerID); ok {
+		item.Owner = owner.Username
 	}
+	return item
+}
 
-	now := time.Now().UTC()
-	item := models.Item{
-		ID:          uuid.NewString(),
-		Title:       title,
-		Description: strings.TrimSpace(description),
-		Owner:       owner,
-		CreatedAt:   now,
-		UpdatedAt:   now,
+// CreateItem inserts a new item owned by the user with ownerID. An empty
+// visibility makes it public.
+func (s *Store) CreateItem(ownerID, title, description string, visibility models.ItemVisibility) (models.Item, error) {
+	item, err := newItem(ownerID, title, description, visibility)
+	if err != nil {
+		return models.Item{}, err
 	}
 
 	s.mu.Lock()
-	s.items[item.ID] = item
-	s.mu.Unlock()
+	defer s.mu.Unlock()
 
-	return item, nil
+	if err := s.commit(journalRecord{Op: opPutItem, Item: &item}); err != nil {
+		return models.Item{}, err
+	}
+	item.Access = models.ItemOwner
+	return s.withOwnerLocked(item), nil
 }
 
-// UpdateItem updates an existing item if the caller is the owner or an admin.
-func (s *Store) UpdateItem(id, requester string, isAdmin bool, title, description string) (models.Item, error) {
+// UpdateItem updates an existing item if the policy lets the caller's role edit
+// it, either as its owner or editor or through items:update:any. requesterID
+// is the ID of the calling user; items it may not see are not found.
+func (s *Store) UpdateItem(id, requesterID, role, title, description string) (models.Item, error) {
 	title = strings.TrimSpace(title)
 	if title == "" {
 		return models.Item{}, fmt.Errorf("title cannot be empty")
@@ -214,22 +373,23 @@ func (s *Store) UpdateItem(id, requester string, isAdmin bool, title, descriptio
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	item, ok := s.items[id]
-	if !ok {
-		return models.Item{}, ErrItemNotFound
+	item, err := s.visibleItemLocked(id, requesterID, role)
+	if err != nil {
+		return models.Item{}, err
 	}
 
-	requester = strings.TrimSpace(requester)
-	if item.Owner != requester && !isAdmin {
+	if !mayEditItem(s.Policy(), role, item.Access) {
 		return models.Item{}, ErrForbidden
 	}
 
 	item.Title = title
 	item.Description = strings.TrimSpace(description)
 	item.UpdatedAt = time.Now().UTC()
-	s.items[id] = item
+	if err := s.commit(journalRecord{Op: opPutItem, Item: &item}); err != nil {
+		return models.Item{}, err
+	}
 
-	return item, nil
+	return s.withOwnerLocked(item), nil
 }
 
 // DeleteItem removes an item from the store.
@@ -241,12 +401,11 @@ func (s *Store) DeleteItem(id string) error {
 		return ErrItemNotFound
 	}
 
-	delete(s.items, id)
-	return nil
+	return s.commit(journalRecord{Op: opDeleteItem, ID: id})
 }
 
 // ListUsers returns all users sorted by creation time.
-func (s *Store) ListUsers() []models.User {
+func (s *Store) ListUsers() ([]models.User, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
 
@@ -259,7 +418,19 @@ func (s *Store) ListUsers() []models.User {
 		return users[i].CreatedAt.Before(users[j].CreatedAt)
 	})
 
-	return users
+	return users, nil
+}
+
+// GetUserByUsername returns a single user by case-insensitive username.
+func (s *Store) GetUserByUsername(username string) (models.User, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	user, ok := s.users[usernameKey(username)]
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	return user, nil
 }
 
 // GetUser returns a single user by id.
@@ -276,15 +447,194 @@ func (s *Store) GetUser(id string) (models.User, error) {
 }
 
 // DeleteUser removes a user from the store.
-func (s *Store) DeleteUser(id string) error {
+func (s *Store) DeleteUser(id string, opts UserDeletion) (int, error) {
+	opts, err := opts.normalize(id)
+	if err != nil {
+		return 0, err
+	}
+
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for key, user := range s.users {
+	user, ok := s.userByIDLocked(id)
+	if !ok {
+		return 0, ErrUserNotFound
+	}
+	if s.isLastManagerLocked(user) {
+		return 0, ErrLastAdmin
+	}
+
+	// The items travel in the same record as the deletion, so that replay
+	// never sees the user gone but the items still pointing at it.
+	rec := journalRecord{Op: opDeleteUser, ID: id}
+	if opts.Items == ItemsReassign && !s.userExistsLocked(opts.ReassignTo) {
+		return 0, ErrInvalidReassignTarget
+	}
+	for _, item := range s.items {
+		if !ownsItem(item, user.ID) {
+			continue
+		}
+		switch opts.Items {
+		case ItemsCascade:
+			rec.ItemIDs = append(rec.ItemIDs, item.ID)
+			continue
+		case ItemsReassign:
+			item.OwnerID = opts.ReassignTo
+		default:
+			item.OwnerID = ""
+		}
+		rec.Items = append(rec.Items, item)
+	}
+	if err := s.commit(rec); err != nil {
+		return 0, err
+	}
+	return len(rec.ItemIDs) + len(rec.Items), nil
+}
+
+// ChangeUsername renames a user and records the change in the audit log under
+// actorID. Items are owned by user ID and keep their owner.
+func (s *Store) ChangeUsername(actorID, userID, username string) (models.User, error) {
+	username, err := normalizeUsername(username)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	if user.Username == username {
+		return user, nil
+	}
+	if existing, ok := s.users[usernameKey(username)]; ok && existing.ID != user.ID {
+		return models.User{}, ErrUserExists
+	}
+
+	event := newAuditEvent(actorID, AuditUserRenamed, user.ID, map[string]string{"from": user.Username, "to": username})
+	user.Username = username
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user, AuditEvent: &event}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// SetUserRole assigns role to a user and records the change in the audit log
+// under actorID. Demoting the last user who can manage users fails with
+// ErrLastAdmin.
+func (s *Store) SetUserRole(actorID, userID, role string) (models.User, error) {
+	role, err := normalizeRoleName(role)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	if _, ok := s.roles[role]; !ok {
+		return models.User{}, ErrInvalidRole
+	}
+	if user.Role == role {
+		return user, nil
+	}
+	if !s.Policy().Can(role, rbac.UsersManage) && s.isLastManagerLocked(user) {
+		return models.User{}, ErrLastAdmin
+	}
+
+	event := newAuditEvent(actorID, AuditUserRoleChanged, user.ID, map[string]string{"from": user.Role, "to": role})
+	user.Role = role
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user, AuditEvent: &event}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// ChangePassword replaces a user's password after checking the current one and
+// clears MustChangePassword.
+func (s *Store) ChangePassword(userID, currentPassword, newPassword string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	hashed, err := newPasswordHash(user, currentPassword, newPassword, true)
+	if err != nil {
+		return models.User{}, err
+	}
+	user.PasswordHash = hashed
+	user.MustChangePassword = false
+	if err := s.setPasswordLocked(user, nil); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+// ResetPassword sets a temporary password that must be changed on the next
+// sign-in and records the reset in the audit log under actorID.
+func (s *Store) ResetPassword(actorID, userID, temporaryPassword string) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	hashed, err := newPasswordHash(user, "", temporaryPassword, false)
+	if err != nil {
+		return models.User{}, err
+	}
+
+	event := newAuditEvent(actorID, AuditUserPasswordReset, user.ID, nil)
+	user.PasswordHash = hashed
+	user.MustChangePassword = true
+	if err := s.setPasswordLocked(user, &event); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
+
+func (s *Store) userByIDLocked(id string) (models.User, bool) {
+	for _, user := range s.users {
 		if user.ID == id {
-			delete(s.users, key)
-			return nil
+			return user, true
 		}
 	}
-	return ErrUserNotFound
+	return models.User{}, false
+}
+
+// isLastManagerLocked reports whether user is the only active one whose role
+// grants users:manage.
+func (s *Store) isLastManagerLocked(user models.User) bool {
+	policy := s.Policy()
+	if !policy.Can(user.Role, rbac.UsersManage) {
+		return false
+	}
+	now := time.Now().UTC()
+	for _, other := range s.users {
+		if other.ID != user.ID && policy.Can(other.Role, rbac.UsersManage) && other.StatusAt(now) == models.UserStatusActive {
+			return false
+		}
+	}
+	return true
+}
+
+// Close flushes and closes the journal, if any.
+func (s *Store) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.journal == nil {
+		return nil
+	}
+	err := s.journal.close()
+	s.journal = nil
+	return err
 }
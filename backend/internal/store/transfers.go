@@ -0,0 +1,192 @@
+package store
+
+import (
+	"sort"
+	"time"
+
+	"assignment3/backend/internal/models"
+	"assignment3/backend/internal/rbac"
+
+	"github.com/google/uuid"
+)
+
+// newTransfer offers item, whose owner is resolved, to recipient until
+// expiresAt.
+func newTransfer(item models.Item, recipient models.User, expiresAt time.Time) models.ItemTransfer {
+	return models.ItemTransfer{
+		ID:        uuid.NewString(),
+		ItemID:    item.ID,
+		FromID:    item.OwnerID,
+		ToID:      recipient.ID,
+		ItemTitle: item.Title,
+		From:      item.Owner,
+		To:        recipient.Username,
+		Status:    models.TransferPending,
+		CreatedAt: time.Now().UTC(),
+		ExpiresAt: expiresAt.UTC(),
+	}
+}
+
+// mayProposeTransfer reports whether role may offer item as the user with
+// requesterID; forcing a transfer needs items:update:any.
+func mayProposeTransfer(policy *rbac.Policy, item models.Item, requesterID, role string, force bool) bool {
+	if force {
+		return policy.Can(role, rbac.ItemsUpdateAny)
+	}
+	return mayManageItem(policy, item, requesterID, role)
+}
+
+// mayCancelTransfer reports whether the user with requesterID and role may
+// withdraw transfer: its sender can, and so can whoever may edit any item.
+func mayCancelTransfer(policy *rbac.Policy, transfer models.ItemTransfer, requesterID, role string) bool {
+	return (transfer.FromID != "" && transfer.FromID == requesterID) || policy.Can(role, rbac.ItemsUpdateAny)
+}
+
+// transferPending reports whether transfer can still be answered at now.
+func transferPending(transfer models.ItemTransfer, now time.Time) bool {
+	return now.Before(transfer.ExpiresAt)
+}
+
+// sortTransfers orders transfers newest first.
+func sortTransfers(transfers []models.ItemTransfer) {
+	sort.Slice(transfers, func(i, j int) bool {
+		return transfers[i].CreatedAt.After(transfers[j].CreatedAt)
+	})
+}
+
+// ProposeTransfer offers an item to the user with toID until expiresAt. With
+// force the item changes hands at once and the returned transfer is already
+// accepted; it replaces any transfer still pending for the item.
+func (s *Store) ProposeTransfer(itemID, requesterID, role, toID string, force bool, expiresAt time.Time) (models.ItemTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, err := s.visibleItemLocked(itemID, requesterID, role)
+	if err != nil {
+		return models.ItemTransfer{}, err
+	}
+	if !mayProposeTransfer(s.Policy(), item, requesterID, role, force) {
+		return models.ItemTransfer{}, ErrForbidden
+	}
+	recipient, ok := s.userByIDLocked(toID)
+	if !ok || recipient.ID == item.OwnerID {
+		return models.ItemTransfer{}, ErrInvalidTransferTarget
+	}
+
+	now := time.Now().UTC()
+	for _, existing := range s.transfers {
+		expired := !transferPending(existing, now)
+		if existing.ItemID == item.ID {
+			if !expired && !force {
+				return models.ItemTransfer{}, ErrTransferPending
+			}
+			continue
+		}
+		// Expired transfers of other items are pruned along the way.
+		if expired {
+			if err := s.commit(journalRecord{Op: opDeleteTransfer, ID: existing.ID}); err != nil {
+				return models.ItemTransfer{}, err
+			}
+		}
+	}
+
+	// One record replaces the item's transfers, so a forced transfer cannot
+	// be replayed with the pending one it supersedes still in place.
+	transfer := newTransfer(s.withOwnerLocked(item), recipient, expiresAt)
+	rec := journalRecord{Op: opTransferItem, ID: item.ID}
+	if force {
+		item.OwnerID = recipient.ID
+		rec.Item = &item
+		transfer.Status = models.TransferAccepted
+	} else {
+		stored := transfer
+		stored.ItemTitle, stored.From, stored.To = "", "", ""
+		rec.Transfer = &stored
+	}
+	if err := s.commit(rec); err != nil {
+		return models.ItemTransfer{}, err
+	}
+	return transfer, nil
+}
+
+// ListTransfers returns the pending transfers sent or offered to the user with
+// userID, or all of them if userID is empty, newest first.
+func (s *Store) ListTransfers(userID string) ([]models.ItemTransfer, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UTC()
+	transfers := make([]models.ItemTransfer, 0)
+	for _, transfer := range s.transfers {
+		if !transferPending(transfer, now) {
+			continue
+		}
+		if userID != "" && transfer.FromID != userID && transfer.ToID != userID {
+			continue
+		}
+		transfers = append(transfers, s.withTransferNamesLocked(transfer))
+	}
+	sortTransfers(transfers)
+	return transfers, nil
+}
+
+// RespondToTransfer accepts or declines a transfer offered to the user with
+// userID. Accepting makes that user the item's owner.
+func (s *Store) RespondToTransfer(id, userID string, accept bool) (models.ItemTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfer, ok := s.transfers[id]
+	if !ok || transfer.ToID != userID || !transferPending(transfer, time.Now().UTC()) {
+		return models.ItemTransfer{}, ErrTransferNotFound
+	}
+	item, ok := s.items[transfer.ItemID]
+	if !ok || item.OwnerID != transfer.FromID {
+		return models.ItemTransfer{}, ErrTransferNotFound
+	}
+
+	transfer = s.withTransferNamesLocked(transfer)
+	transfer.Status = models.TransferDeclined
+	rec := journalRecord{Op: opDeleteTransfer, ID: id}
+	if accept {
+		// The new owner and the spent transfer are journaled together.
+		item.OwnerID = transfer.ToID
+		rec = journalRecord{Op: opTransferItem, ID: item.ID, Item: &item}
+		transfer.Status = models.TransferAccepted
+	}
+	if err := s.commit(rec); err != nil {
+		return models.ItemTransfer{}, err
+	}
+	return transfer, nil
+}
+
+// CancelTransfer withdraws a pending transfer. Its sender and users who may
+// edit any item can cancel it; for anyone else it does not exist.
+func (s *Store) CancelTransfer(id, requesterID, role string) (models.ItemTransfer, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	transfer, ok := s.transfers[id]
+	if !ok || !transferPending(transfer, time.Now().UTC()) || !mayCancelTransfer(s.Policy(), transfer, requesterID, role) {
+		return models.ItemTransfer{}, ErrTransferNotFound
+	}
+
+	transfer = s.withTransferNamesLocked(transfer)
+	transfer.Status = models.TransferCancelled
+	if err := s.commit(journalRecord{Op: opDeleteTransfer, ID: id}); err != nil {
+		return models.ItemTransfer{}, err
+	}
+	return transfer, nil
+}
+
+// withTransferNamesLocked resolves the item title and usernames of transfer.
+func (s *Store) withTransferNamesLocked(transfer models.ItemTransfer) models.ItemTransfer {
+	transfer.ItemTitle = s.items[transfer.ItemID].Title
+	if from, ok := s.userByIDLocked(transfer.FromID); ok {
+		transfer.From = from.Username
+	}
+	if to, ok := s.userByIDLocked(transfer.ToID); ok {
+		transfer.To = to.Username
+	}
+	return transfer
+}
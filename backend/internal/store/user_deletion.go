@@ -0,0 +1,46 @@
+package store
+
+// ItemPolicy decides what happens to the items of a deleted user.
+type ItemPolicy string
+
+const (
+	// ItemsTombstone keeps the items without an owner, so that nobody can edit
+	// them as their owner, not even a later account with the same username.
+	ItemsTombstone ItemPolicy = "tombstone"
+	// ItemsCascade deletes the items along with the user.
+	ItemsCascade ItemPolicy = "cascade"
+	// ItemsReassign hands the items to another user.
+	ItemsReassign ItemPolicy = "reassign"
+)
+
+// Valid reports whether p is a known item policy.
+func (p ItemPolicy) Valid() bool {
+	switch p {
+	case ItemsTombstone, ItemsCascade, ItemsReassign:
+		return true
+	}
+	return false
+}
+
+// UserDeletion selects how DeleteUser disposes of the deleted user's items.
+// The zero value tombstones them.
+type UserDeletion struct {
+	Items ItemPolicy
+	// ReassignTo is the ID of the user receiving the items with ItemsReassign.
+	ReassignTo string
+}
+
+// normalize applies the default policy and validates opts for deleting the
+// user with userID.
+func (opts UserDeletion) normalize(userID string) (UserDeletion, error) {
+	if opts.Items == "" {
+		opts.Items = ItemsTombstone
+	}
+	if !opts.Items.Valid() {
+		return UserDeletion{}, ErrInvalidItemPolicy
+	}
+	if opts.Items == ItemsReassign && (opts.ReassignTo == "" || opts.ReassignTo == userID) {
+		return UserDeletion{}, ErrInvalidReassignTarget
+	}
+	return opts, nil
+}
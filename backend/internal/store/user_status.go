@@ -0,0 +1,86 @@
+package store
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"assignment3/backend/internal/models"
+)
+
+// maxStatusReasonLength bounds the reason kept with a status change.
+const maxStatusReasonLength = 500
+
+// CheckAccountStatus returns ErrAccountSuspended or ErrAccountInactive unless
+// user may sign in now.
+func CheckAccountStatus(user models.User) error {
+	switch user.StatusAt(time.Now().UTC()) {
+	case models.UserStatusActive:
+		return nil
+	case models.UserStatusSuspended:
+		return ErrAccountSuspended
+	default:
+		return ErrAccountInactive
+	}
+}
+
+// applyStatusChange validates a status change, applies it to user and returns
+// the audit event recording it.
+func applyStatusChange(actorID string, user *models.User, status models.UserStatus, reason string, until *time.Time, now time.Time) (models.AuditEvent, error) {
+	if !status.Valid() {
+		return models.AuditEvent{}, ErrInvalidStatus
+	}
+	reason = strings.TrimSpace(reason)
+	if len(reason) > maxStatusReasonLength {
+		return models.AuditEvent{}, fmt.Errorf("reason must be at most %d characters", maxStatusReasonLength)
+	}
+	if status != models.UserStatusActive && reason == "" {
+		return models.AuditEvent{}, fmt.Errorf("a reason is required")
+	}
+	if until != nil {
+		if status != models.UserStatusSuspended {
+			return models.AuditEvent{}, fmt.Errorf("only suspensions can have an end date")
+		}
+		if !until.After(now) {
+			return models.AuditEvent{}, fmt.Errorf("suspension end must be in the future")
+		}
+		utc := until.UTC()
+		until = &utc
+	}
+
+	details := map[string]string{"from": string(user.StatusAt(now)), "to": string(status)}
+	if reason != "" {
+		details["reason"] = reason
+	}
+	if until != nil {
+		details["until"] = until.Format(time.RFC3339)
+	}
+	user.Status = status
+	user.StatusReason = reason
+	user.SuspendedUntil = until
+	return newAuditEvent(actorID, AuditUserStatusChanged, user.ID, details), nil
+}
+
+// SetUserStatus moves a user to status and records the change in the audit
+// log under actorID. Making the last active user who can manage users
+// inactive fails with ErrLastAdmin.
+func (s *Store) SetUserStatus(actorID, userID string, status models.UserStatus, reason string, until *time.Time) (models.User, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user, ok := s.userByIDLocked(userID)
+	if !ok {
+		return models.User{}, ErrUserNotFound
+	}
+	if status != models.UserStatusActive && s.isLastManagerLocked(user) {
+		return models.User{}, ErrLastAdmin
+	}
+	event, err := applyStatusChange(actorID, &user, status, reason, until, time.Now().UTC())
+	if err != nil {
+		return models.User{}, err
+	}
+	if err := s.commit(journalRecord{Op: opPutUser, User: &user, AuditEvent: &event}); err != nil {
+		return models.User{}, err
+	}
+	return user, nil
+}
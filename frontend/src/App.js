@@ -1,45 +1,152 @@
-import { useState } from "react";
+import { useEffect, useRef, useState } from "react";
+import api from "./api/client";
+import AccessTokens from "./components/AccessTokens";
 import AppHeader from "./components/AppHeader";
 import AuthPanel from "./components/AuthPanel";
 import ItemForm from "./components/ItemForm";
 import ItemList from "./components/ItemList";
+import ItemSharing from "./components/ItemSharing";
+import LinkedAccounts from "./components/LinkedAccounts";
 import Loader from "./components/Loader";
+import MfaChallenge from "./components/MfaChallenge";
+import MfaSettings from "./components/MfaSettings";
 import Notification from "./components/Notification";
+import PasswordForm from "./components/PasswordForm";
+import ServiceAccounts from "./components/ServiceAccounts";
+import Sessions from "./components/Sessions";
+import Transfers from "./components/Transfers";
 import UserManagement from "./components/UserManagement";
 import { useAppContext } from "./context/AppContext";
 import "./App.css";
 
 function App() {
   const {
-    state: { user, items, loading, error, notification },
-    actions: {
-      login,
-      register,
-      logout,
-      createItem,
-      updateItem,
-      deleteItem,
-      setError,
-      setNotification,
-    },
+    state: { user, items, loading, error, notification, mfaChallenge, mfaSetupRequired },
+    actions,
   } = useAppContext();
+  const {
+    login,
+    completeMfaLogin,
+    cancelMfaLogin,
+    startSso,
+    completeSso,
+    register,
+      logout,
+    changePassword,
+    changeUsername,
+    requestPasswordReset,
+    completePasswordReset,
+    createItem,
+    updateItem,
+    deleteItem,
+    transferItem,
+    fetchItems,
+    setError,
+    setNotification,
+  } = actions;
   const [editingItem, setEditingItem] = useState(null);
+  const [sharingItem, setSharingItem] = useState(null);
+  const [changingPassword, setChangingPassword] = useState(false);
+  const [managingMfa, setManagingMfa] = useState(false);
+  const [showingTokens, setShowingTokens] = useState(false);
+  const [showingIdentities, setShowingIdentities] = useState(false);
+  const [showingSessions, setShowingSessions] = useState(false);
+  const [showingTransfers, setShowingTransfers] = useState(false);
+  const [sso, setSso] = useState(null);
+  const ssoHandled = useRef(false);
+  // Password reset emails link back here with ?reset_token=...
+  const [resetToken, setResetToken] = useState(
+    () => new URLSearchParams(window.location.search).get("reset_token")
+  );
+
+  useEffect(() => {
+    api.fetchOidcInfo().then(setSso, () => setSso(null));
+  }, []);
+
+  // The identity provider redirects back here with ?code=...&state=...
+  useEffect(() => {
+    const params = new URLSearchParams(window.location.search);
+    if (ssoHandled.current || !params.get("code") || !params.get("state")) {
+      return;
+    }
+    ssoHandled.current = true;
+    window.history.replaceState(null, "", window.location.pathname);
+    completeSso(params.get("code"), params.get("state"));
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, []);
 
   async function handleDelete(id) {
     const ok = await deleteItem(id);
     if (ok && editingItem && editingItem.id === id) {
       setEditingItem(null);
     }
+    if (ok && sharingItem && sharingItem.id === id) {
+      setSharingItem(null);
+    }
+  }
+
+  function handleChangeUsername() {
+    const username = window.prompt("New username (at least 3 characters):", user.username);
+    if (username && username.trim() !== user.username) {
+      changeUsername(username.trim());
+    }
+  }
+
+  function handleTransfer(item) {
+    const to = window.prompt(`Transfer "${item.title}" to which user?`);
+    if (!to || !to.trim()) {
+      return;
+    }
+    // Admins may hand the item over without waiting for an answer.
+    const force =
+      user.role === "admin" &&
+      window.confirm(`Hand "${item.title}" over to ${to.trim()} now? Cancel to send an offer instead.`);
+    transferItem(item.id, to.trim(), force);
   }
 
   function handleLogout() {
     logout();
     setEditingItem(null);
+    setSharingItem(null);
+    setChangingPassword(false);
+    setManagingMfa(false);
+    setShowingTokens(false);
+    setShowingTransfers(false);
+    setShowingIdentities(false);
   }
 
+  async function handlePasswordReset(token, newPassword) {
+    const ok = await completePasswordReset(token, newPassword);
+    if (ok) {
+      setResetToken(null);
+      window.history.replaceState(null, "", window.location.pathname);
+    }
+    return ok;
+  }
+
+  async function handlePasswordChange(payload) {
+    const ok = await changePassword(payload);
+    if (ok) {
+      setChangingPassword(false);
+    }
+    return ok;
+  }
+
+  const settingUpMfa = mfaSetupRequired || managingMfa;
+
   return (
-    <div className={`app-container ${!user ? 'app-container--centered' : ''}`}>
-      <AppHeader user={user} onLogout={handleLogout} />
+    <div className={`app-container ${!user || user.must_change_password || changingPassword || settingUpMfa ? 'app-container--centered' : ''}`}>
+      <AppHeader
+        user={user}
+        onLogout={handleLogout}
+        onChangePassword={() => setChangingPassword(true)}
+        onChangeUsername={handleChangeUsername}
+        onManageMfa={() => setManagingMfa(true)}
+        onToggleTokens={() => setShowingTokens((prev) => !prev)}
+        onToggleSessions={() => setShowingSessions((prev) => !prev)}
+        onToggleTransfers={() => setShowingTransfers((prev) => !prev)}
+        onToggleIdentities={sso ? () => setShowingIdentities((prev) => !prev) : null}
+      />
 
       {(notification || error) && (
         <div className="notification-wrapper">
@@ -58,13 +165,73 @@ function App() {
 
       <Loader visible={loading} />
 
-      {!user ? (
-        <AuthPanel onLogin={login} onRegister={register} loading={loading} />
+      {!user && mfaChallenge ? (
+        <MfaChallenge
+          onSubmit={completeMfaLogin}
+          onCancel={cancelMfaLogin}
+          loading={loading}
+        />
+      ) : !user ? (
+        <AuthPanel
+          onLogin={login}
+          onRegister={register}
+          onForgotPassword={requestPasswordReset}
+          onResetPassword={handlePasswordReset}
+          onSso={sso ? () => startSso() : null}
+          ssoName={sso?.name}
+          resetToken={resetToken}
+          loading={loading}
+        />
+      ) : user.must_change_password || changingPassword ? (
+        <PasswordForm
+          onSubmit={handlePasswordChange}
+          onCancel={() => setChangingPassword(false)}
+          loading={loading}
+          required={user.must_change_password}
+        />
+      ) : settingUpMfa ? (
+        <MfaSettings
+          actions={actions}
+          onDone={() => setManagingMfa(false)}
+          loading={loading}
+          required={mfaSetupRequired}
+        />
       ) : (
         <div className="content-grid">
+          {showingTokens && (
+            <div className="full-width">
+              <AccessTokens />
+            </div>
+          )}
+          {showingSessions && (
+            <div className="full-width">
+              <Sessions />
+            </div>
+          )}
+          {showingTransfers && (
+            <div className="full-width">
+              <Transfers currentUser={user} onAccepted={fetchItems} />
+            </div>
+          )}
+          {showingIdentities && sso && (
+            <div className="full-width">
+              <LinkedAccounts providerName={sso.name} onLink={() => startSso(true)} />
+            </div>
+          )}
           {user.role === "admin" && (
             <div className="admin-section">
               <UserManagement currentUser={user} />
+              <ServiceAccounts />
+            </div>
+          )}
+          {sharingItem && (
+            <div className="full-width">
+              <ItemSharing
+                item={sharingItem}
+                currentUser={user}
+                onChanged={fetchItems}
+                onClose={() => setSharingItem(null)}
+              />
             </div>
           )}
           <ItemForm
@@ -80,6 +247,8 @@ function App() {
             loading={loading}
             onEdit={(item) => setEditingItem(item)}
             onDelete={handleDelete}
+            onTransfer={handleTransfer}
+            onShare={(item) => setSharingItem(item)}
           />
         </div>
       )}
@@ -2,9 +2,27 @@ import axios from "axios";
 
 const API_BASE_URL = process.env.REACT_APP_API_URL || "/api";
 
+// Credentials are included so cookie sessions work when the API runs on
+// another origin of the allowlist.
 const client = axios.create({
   baseURL: API_BASE_URL,
   timeout: 10000,
+  withCredentials: true,
+});
+
+const SAFE_METHODS = ["get", "head", "options"];
+
+let refreshToken = null;
+// Set instead of the tokens when the server keeps the session in cookies.
+let csrfToken = null;
+let refreshInFlight = null;
+let sessionListener = null;
+
+client.interceptors.request.use((config) => {
+  if (csrfToken && !SAFE_METHODS.includes((config.method || "get").toLowerCase())) {
+    config.headers["X-CSRF-Token"] = csrfToken;
+  }
+  return config;
 });
 
 export function setToken(token) {
@@ -15,8 +33,68 @@ export function setToken(token) {
 
 export function clearToken() {
   delete client.defaults.headers.common.Authorization;
+  refreshToken = null;
+  csrfToken = null;
+}
+
+export function setRefreshToken(token) {
+  refreshToken = token || null;
+}
+
+export function setCsrfToken(token) {
+  csrfToken = token || null;
 }
 
+// The listener receives the new login payload after a successful refresh, or
+// null when the session could not be renewed and the user must sign in again.
+export function setSessionListener(listener) {
+  sessionListener = listener;
+}
+
+function refreshSession() {
+  if (!refreshInFlight) {
+    refreshInFlight = client
+      .post("/token/refresh", refreshToken ? { refresh_token: refreshToken } : {})
+      .then((response) => response.data)
+      .finally(() => {
+        refreshInFlight = null;
+      });
+  }
+  return refreshInFlight;
+}
+
+client.interceptors.response.use(undefined, async (error) => {
+  const original = error.config;
+  const skip =
+    !original ||
+    original._retried ||
+    original.url === "/login" ||
+    original.url === "/login/mfa" ||
+    original.url === "/oidc/callback" ||
+    original.url === "/token/refresh";
+  if (error.response?.status !== 401 || !(refreshToken || csrfToken) || skip) {
+    throw error;
+  }
+
+  original._retried = true;
+  let data;
+  try {
+    data = await refreshSession();
+  } catch {
+    sessionListener?.(null);
+    throw error;
+  }
+
+  setToken(data.token);
+  refreshToken = data.refresh_token || null;
+  csrfToken = data.csrf_token || null;
+  sessionListener?.(data);
+  if (data.token) {
+    original.headers.Authorization = `Bearer ${data.token}`;
+  }
+  return client(original);
+});
+
 export async function register(payload) {
   const response = await client.post("/register", payload);
   return response.data;
@@ -27,6 +105,128 @@ export async function login(payload) {
   return response.data;
 }
 
+export async function loginMfa(mfaToken, code) {
+  const response = await client.post("/login/mfa", { mfa_token: mfaToken, code });
+  return response.data;
+}
+
+export async function logout() {
+  await client.post("/logout", refreshToken ? { refresh_token: refreshToken } : {});
+}
+
+export async function changePassword(payload) {
+  const response = await client.post("/me/password", payload);
+  return response.data;
+}
+
+export async function setEmail(email) {
+  const response = await client.put("/me/email", { email });
+  return response.data;
+}
+
+export async function changeUsername(username) {
+  const response = await client.put("/me/username", { username });
+  return response.data;
+}
+
+export async function requestPasswordReset(username) {
+  await client.post("/password/forgot", { username });
+}
+
+export async function completePasswordReset(token, newPassword) {
+  await client.post("/password/reset", { token, new_password: newPassword });
+}
+
+export async function fetchMfaStatus() {
+  const response = await client.get("/me/mfa");
+  return response.data;
+}
+
+export async function enrollMfa() {
+  const response = await client.post("/me/mfa/enroll");
+  return response.data;
+}
+
+export async function confirmMfa(code) {
+  const response = await client.post("/me/mfa/confirm", { code });
+  return response.data;
+}
+
+export async function regenerateRecoveryCodes(code) {
+  const response = await client.post("/me/mfa/recovery-codes", { code });
+  return response.data.recovery_codes;
+}
+
+export async function disableMfa(code) {
+  await client.post("/me/mfa/disable", { code });
+}
+
+// Resolves to the identity provider's details, or null when single sign-on
+// is not configured.
+export async function fetchOidcInfo() {
+  try {
+    const response = await client.get("/oidc");
+    return response.data;
+  } catch (error) {
+    if (error.response?.status === 404) {
+      return null;
+    }
+    throw error;
+  }
+}
+
+export async function startOidcLogin() {
+  const response = await client.post("/oidc/login");
+  return response.data;
+}
+
+export async function completeOidcLogin(payload) {
+  const response = await client.post("/oidc/callback", payload);
+  return response.data;
+}
+
+export async function fetchIdentities() {
+  const response = await client.get("/me/identities");
+  return response.data.identities;
+}
+
+export async function startIdentityLink() {
+  const response = await client.post("/me/identities");
+  return response.data;
+}
+
+export async function completeIdentityLink(payload) {
+  const response = await client.post("/me/identities/callback", payload);
+  return response.data;
+}
+
+export async function unlinkIdentity(id) {
+  await client.delete(`/me/identities/${id}`);
+}
+
+export async function fetchAccessTokens() {
+  const response = await client.get("/me/tokens");
+  return response.data.tokens;
+}
+
+export async function createAccessToken(payload) {
+  const response = await client.post("/me/tokens", payload);
+  return response.data;
+}
+
+export async function deleteAccessToken(id) {
+  await client.delete(`/me/tokens/${id}`);
+}
+
+export async function fetchSessions() {
+  const response = await client.get("/me/sessions");
+  return response.data.sessions;
+}
+
+export async function revokeSession(id) {
+  await client.delete(`/me/sessions/${id}`);
+}
+
 export async function fetchItems() {
   const response = await client.get("/items");
   return response.data.items;
@@ -46,26 +246,189 @@ export async function deleteItem(id) {
   await client.delete(`/items/${id}`);
 }
 
+export async function setItemVisibility(id, visibility) {
+  const response = await client.put(`/items/${id}/visibility`, { visibility });
+  return response.data;
+}
+
+export async function fetchItemGrants(id) {
+  const response = await client.get(`/items/${id}/grants`);
+  return response.data.grants;
+}
+
+export async function grantItemAccess(id, payload) {
+  const response = await client.put(`/items/${id}/grants`, payload);
+  return response.data;
+}
+
+export async function revokeItemAccess(id, subjectType, subject) {
+  await client.delete(`/items/${id}/grants/${subjectType}/${encodeURIComponent(subject)}`);
+}
+
+export async function fetchItemAccess(id) {
+  const response = await client.get(`/items/${id}/access`);
+  return response.data.access;
+}
+
+export async function transferItem(id, to, force = false) {
+  const response = await client.post(`/items/${id}/transfer`, { to, force });
+  return response.data;
+}
+
+export async function fetchTransfers(all = false) {
+  const response = await client.get("/transfers", { params: all ? { all: true } : {} });
+  return response.data.transfers;
+}
+
+export async function acceptTransfer(id) {
+  const response = await client.post(`/transfers/${id}/accept`);
+  return response.data;
+}
+
+export async function declineTransfer(id) {
+  const response = await client.post(`/transfers/${id}/decline`);
+  return response.data;
+}
+
+export async function cancelTransfer(id) {
+  const response = await client.delete(`/transfers/${id}`);
+  return response.data;
+}
+
 export async function fetchUsers() {
   const response = await client.get("/users");
   return response.data.users;
 }
 
-export async function deleteUser(id) {
-  await client.delete(`/users/${id}`);
+export async function fetchUserSessions(id) {
+  const response = await client.get(`/users/${id}/sessions`);
+  return response.data.sessions;
+}
+
+export async function revokeUserSession(id, sessionId) {
+  await client.delete(`/users/${id}/sessions/${sessionId}`);
+}
+
+export async function deleteUser(id, items = "tombstone", reassignTo) {
+  const params = { items };
+  if (reassignTo) {
+    params.reassign_to = reassignTo;
+  }
+  const response = await client.delete(`/users/${id}`, { params });
+  return response.data;
+}
+
+export async function setUserRole(id, role) {
+  const response = await client.put(`/users/${id}/role`, { role });
+  return response.data;
+}
+
+export async function setUserStatus(id, payload) {
+  const response = await client.put(`/users/${id}/status`, payload);
+  return response.data;
+}
+
+export async function setUsername(id, username) {
+  const response = await client.put(`/users/${id}/username`, { username });
+  return response.data;
+}
+
+export async function resetUserPassword(id, temporaryPassword) {
+  const response = await client.post(`/users/${id}/password`, {
+    temporary_password: temporaryPassword,
+  });
+  return response.data;
+}
+
+export async function resetUserMfa(id) {
+  const response = await client.delete(`/users/${id}/mfa`);
+  return response.data;
+}
+
+export async function fetchServiceAccounts() {
+  const response = await client.get("/service-accounts");
+  return response.data.service_accounts;
+}
+
+export async function createServiceAccount(payload) {
+  const response = await client.post("/service-accounts", payload);
+  return response.data;
+}
+
+export async function rotateClientSecret(id) {
+  const response = await client.post(`/service-accounts/${id}/secret`);
+  return response.data;
+}
+
+export async function fetchRoles() {
+  const response = await client.get("/roles");
+  return response.data.roles;
+}
+
+export async function setRoleMfa(name, required) {
+  const response = await client.put(`/roles/${name}/mfa`, { required });
+  return response.data;
 }
 
 const api = {
   setToken,
   clearToken,
+  setRefreshToken,
+  setSessionListener,
   register,
   login,
+  loginMfa,
+  logout,
+  changePassword,
+  setEmail,
+  changeUsername,
+  requestPasswordReset,
+  completePasswordReset,
+  fetchMfaStatus,
+  enrollMfa,
+  confirmMfa,
+  regenerateRecoveryCodes,
+  disableMfa,
+  fetchOidcInfo,
+  startOidcLogin,
+  completeOidcLogin,
+  fetchIdentities,
+  startIdentityLink,
+  completeIdentityLink,
+  unlinkIdentity,
+  fetchAccessTokens,
+  createAccessToken,
+  deleteAccessToken,
+  fetchSessions,
+  revokeSession,
   fetchItems,
   createItem,
   updateItem,
   deleteItem,
+  setItemVisibility,
+  fetchItemGrants,
+  grantItemAccess,
+  revokeItemAccess,
+  fetchItemAccess,
+  transferItem,
+  fetchTransfers,
+  acceptTransfer,
+  declineTransfer,
+  cancelTransfer,
   fetchUsers,
+  fetchUserSessions,
+  revokeUserSession,
   deleteUser,
+  setUserRole,
+  setUserStatus,
+  setUsername,
+  resetUserPassword,
+  resetUserMfa,
+  fetchServiceAccounts,
+  createServiceAccount,
+  rotateClientSecret,
+  fetchRoles,
+  setRoleMfa,
 };
 
 export default api;
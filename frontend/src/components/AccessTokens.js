@@ -0,0 +1,190 @@
+import { useEffect, useState } from "react";
+import api from "../api/client";
+
+// Scopes a token can be limited to; the server refuses any the role lacks.
+const scopes = [
+  "items:read",
+  "items:create",
+  "items:update:own",
+  "items:update:any",
+  "items:delete",
+  "users:manage",
+];
+
+const defaultForm = { name: "", scopes: ["items:read"], expiresInDays: "30" };
+
+function formatDate(value) {
+  return value ? new Date(value).toLocaleDateString() : "never";
+}
+
+export default function AccessTokens() {
+  const [tokens, setTokens] = useState([]);
+  const [form, setForm] = useState(defaultForm);
+  const [created, setCreated] = useState(null);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState(null);
+
+  useEffect(() => {
+    loadTokens();
+  }, []);
+
+  async function loadTokens() {
+    setLoading(true);
+    setError(null);
+    try {
+      setTokens(await api.fetchAccessTokens());
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to load tokens");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  function toggleScope(scope) {
+    setForm((prev) => ({
+      ...prev,
+      scopes: prev.scopes.includes(scope)
+        ? prev.scopes.filter((s) => s !== scope)
+        : [...prev.scopes, scope],
+    }));
+  }
+
+  async function handleCreate(event) {
+    event.preventDefault();
+    if (!form.name.trim() || form.scopes.length === 0) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      const payload = { name: form.name.trim(), scopes: form.scopes };
+      if (form.expiresInDays) {
+        payload.expires_in_days = Number(form.expiresInDays);
+      }
+      const { token, ...record } = await api.createAccessToken(payload);
+      setCreated(token);
+      setTokens((prev) => [record, ...prev]);
+      setForm(defaultForm);
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to create token");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleRevoke(token) {
+    if (!window.confirm(`Revoke token "${token.name}"? Scripts using it will stop working.`)) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      await api.deleteAccessToken(token.id);
+      setTokens((prev) => prev.filter((t) => t.id !== token.id));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to revoke token");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  return (
+    <div className="card">
+      <div className="card-header">
+        <h2>🔑 API Tokens</h2>
+        <span className="badge-count">{tokens.length}</span>
+      </div>
+
+      {error && <div className="error-message">⚠️ {error}</div>}
+
+      {created && (
+        <div className="form">
+          <p className="muted">Copy your new token now. It will not be shown again.</p>
+          <input type="text" value={created} readOnly onFocus={(e) => e.target.select()} />
+          <button type="button" className="secondary" onClick={() => setCreated(null)}>
+            Done
+          </button>
+        </div>
+      )}
+
+      <form onSubmit={handleCreate} className="form">
+        <label>
+          <span>Name</span>
+          <input
+            type="text"
+            value={form.name}
+            onChange={(e) => setForm((prev) => ({ ...prev, name: e.target.value }))}
+            placeholder="e.g. CI deploy"
+            required
+          />
+        </label>
+        <div className="user-info">
+          <span className="muted">Scopes:</span>
+          {scopes.map((scope) => (
+            <label key={scope}>
+              <input
+                type="checkbox"
+                checked={form.scopes.includes(scope)}
+                onChange={() => toggleScope(scope)}
+              />{" "}
+              {scope}
+            </label>
+          ))}
+        </div>
+        <label>
+          <span>Expires</span>
+          <select
+            value={form.expiresInDays}
+            onChange={(e) => setForm((prev) => ({ ...prev, expiresInDays: e.target.value }))}
+          >
+            <option value="7">in 7 days</option>
+            <option value="30">in 30 days</option>
+            <option value="90">in 90 days</option>
+            <option value="365">in a year</option>
+            <option value="">never</option>
+          </select>
+        </label>
+        <button type="submit" className="primary" disabled={loading}>
+          {loading ? "Processing..." : "Create Token"}
+        </button>
+      </form>
+
+      <div className="user-list">
+        {tokens.length === 0 && !loading && (
+          <div className="empty-state">
+            <p className="muted">No tokens yet</p>
+          </div>
+        )}
+        {tokens.map((token) => (
+          <div key={token.id} className="user-item">
+            <div className="user-info">
+              <strong>{token.name}</strong>
+              <code>{token.prefix}…</code>
+              {token.scopes.map((scope) => (
+                <span key={scope} className="badge">
+                  {scope}
+                </span>
+              ))}
+              <span className="muted">
+                Last used {token.last_used_at ? formatDate(token.last_used_at) : "never"} ·
+                Expires {formatDate(token.expires_at)}
+              </span>
+            </div>
+            <div className="user-actions">
+              <button
+                type="button"
+                className="danger"
+                onClick={() => handleRevoke(token)}
+                disabled={loading}
+              >
+                🗑️ Revoke
+              </button>
+            </div>
+          </div>
+        ))}
+      </div>
+    </div>
+  );
+}
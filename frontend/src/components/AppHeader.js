@@ -1,4 +1,14 @@
-export default function AppHeader({ user, onLogout }) {
+export default function AppHeader({
+  user,
+  onLogout,
+  onChangePassword,
+  onChangeUsername,
+  onManageMfa,
+  onToggleTokens,
+  onToggleSessions,
+  onToggleTransfers,
+  onToggleIdentities,
+}) {
   return (
     <header className="app-header">
       <div>
@@ -9,6 +19,33 @@ export default function AppHeader({ user, onLogout }) {
         <div className="user-pill">
           <span>{user.username}</span>
           <span className="badge">{user.role}</span>
+          {!user.must_change_password && (
+            <>
+              <button type="button" onClick={onChangeUsername} className="secondary">
+                Rename
+              </button>
+              <button type="button" onClick={onChangePassword} className="secondary">
+                Change Password
+              </button>
+              <button type="button" onClick={onManageMfa} className="secondary">
+                Two-Factor
+              </button>
+              <button type="button" onClick={onToggleTokens} className="secondary">
+                API Tokens
+              </button>
+              <button type="button" onClick={onToggleSessions} className="secondary">
+                Devices
+              </button>
+              <button type="button" onClick={onToggleTransfers} className="secondary">
+                Transfers
+              </button>
+              {onToggleIdentities && (
+                <button type="button" onClick={onToggleIdentities} className="secondary">
+                  Linked Accounts
+                </button>
+              )}
+            </>
+          )}
           <button type="button" onClick={onLogout} className="secondary">
             Sign Out
           </button>
@@ -1,9 +1,25 @@
 import { useState } from "react";
 
-const defaultForm = { username: "", password: "" };
+const defaultForm = { username: "", password: "", email: "" };
 
-export default function AuthPanel({ onLogin, onRegister, loading }) {
-  const [mode, setMode] = useState("login");
+const headings = {
+  login: ["Welcome Back", "Sign in to continue"],
+  register: ["Create Account", "Register to get started"],
+  forgot: ["Forgot Password", "We will email a reset link to the account's address"],
+  reset: ["Choose a New Password", "Enter the password you want to use from now on"],
+};
+
+export default function AuthPanel({
+  onLogin,
+  onRegister,
+  onForgotPassword,
+  onResetPassword,
+  onSso,
+  ssoName,
+  resetToken,
+  loading,
+}) {
+  const [mode, setMode] = useState(resetToken ? "reset" : "login");
   const [form, setForm] = useState(defaultForm);
 
   function handleChange(event) {
@@ -13,22 +29,34 @@ export default function AuthPanel({ onLogin, onRegister, loading }) {
 
   async function handleSubmit(event) {
     event.preventDefault();
-    if (!form.username || !form.password) {
-      return;
-    }
-    const payload = {
-      username: form.username.trim(),
-      password: form.password,
-    };
     let ok = false;
-    if (mode === "login") {
-      ok = await onLogin(payload);
+    if (mode === "forgot") {
+      if (!form.username) {
+        return;
+      }
+      ok = await onForgotPassword(form.username.trim());
+    } else if (mode === "reset") {
+      if (!form.password) {
+        return;
+      }
+      ok = await onResetPassword(resetToken, form.password);
     } else {
-      ok = await onRegister(payload);
+      if (!form.username || !form.password) {
+        return;
+      }
+      const payload = {
+        username: form.username.trim(),
+        password: form.password,
+      };
+      if (mode === "login") {
+        ok = await onLogin(payload);
+      } else {
+        ok = await onRegister({ ...payload, email: form.email.trim() });
+      }
     }
     if (ok) {
       setForm(defaultForm);
-      if (mode === "register") {
+      if (mode !== "login") {
         setMode("login");
       }
     }
@@ -38,57 +66,96 @@ export default function AuthPanel({ onLogin, onRegister, loading }) {
     setMode((prev) => (prev === "login" ? "register" : "login"));
   }
 
+  const [title, subtitle] = headings[mode];
+
   return (
     <div className="card auth-card">
       <div className="auth-header">
-        <h2>{mode === "login" ? "Welcome Back" : "Create Account"}</h2>
-        <p className="muted">
-          {mode === "login"
-            ? "Sign in to continue"
-            : "Register to get started"}
-        </p>
+        <h2>{title}</h2>
+        <p className="muted">{subtitle}</p>
       </div>
       <form onSubmit={handleSubmit} className="form">
-        <label>
-          <span>Username</span>
-          <input
-            type="text"
-            name="username"
-            value={form.username}
-            onChange={handleChange}
-            autoComplete="username"
-            placeholder="Enter your username"
-            required
-          />
-        </label>
+        {mode !== "reset" && (
+          <label>
+            <span>Username</span>
+            <input
+              type="text"
+              name="username"
+              value={form.username}
+              onChange={handleChange}
+              autoComplete="username"
+              placeholder="Enter your username"
+              required
+            />
+          </label>
+        )}
+
+        {mode === "register" && (
+          <label>
+            <span>Email (optional, for password resets)</span>
+            <input
+              type="email"
+              name="email"
+              value={form.email}
+              onChange={handleChange}
+              autoComplete="email"
+              placeholder="you@example.com"
+            />
+          </label>
+        )}
 
-        <label>
-          <span>Password</span>
-          <input
-            type="password"
-            name="password"
-            value={form.password}
-            onChange={handleChange}
-            autoComplete={mode === "login" ? "current-password" : "new-password"}
-            placeholder="Enter your password"
-            required
-            minLength={6}
-          />
-        </label>
+        {mode !== "forgot" && (
+          <label>
+            <span>{mode === "reset" ? "New password" : "Password"}</span>
+            <input
+              type="password"
+              name="password"
+              value={form.password}
+              onChange={handleChange}
+              autoComplete={mode === "login" ? "current-password" : "new-password"}
+              placeholder="Enter your password"
+              required
+              minLength={6}
+            />
+          </label>
+        )}
 
         <button type="submit" className="primary" disabled={loading}>
-          {loading ? "Processing..." : mode === "login" ? "Sign In" : "Register"}
+          {loading
+            ? "Processing..."
+            : {
+                login: "Sign In",
+                register: "Register",
+                forgot: "Send Reset Link",
+                reset: "Reset Password",
+              }[mode]}
         </button>
       </form>
 
-      <div className="auth-footer">
-        <button type="button" className="link-button" onClick={toggleMode}>
-          {mode === "login"
-            ? "Need an account? Register"
-            : "Already have an account? Sign in"}
+      {mode === "login" && onSso && (
+        <button type="button" className="secondary" onClick={onSso} disabled={loading}>
+          Sign in with {ssoName}
         </button>
+      )}
+
+      <div className="auth-footer">
+        {mode === "login" || mode === "register" ? (
+          <button type="button" className="link-button" onClick={toggleMode}>
+            {mode === "login"
+              ? "Need an account? Register"
+              : "Already have an account? Sign in"}
+          </button>
+        ) : (
+          <button type="button" className="link-button" onClick={() => setMode("login")}>
+            Back to sign in
+          </button>
+        )}
+        {mode === "login" && (
+          <button type="button" className="link-button" onClick={() => setMode("forgot")}>
+            Forgot password?
+          </button>
+        )}
       </div>
     </div>
   );
 }
-
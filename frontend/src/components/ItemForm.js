@@ -1,6 +1,6 @@
 import { useEffect, useState } from "react";
 
-const defaultState = { title: "", description: "" };
+const defaultState = { title: "", description: "", visibility: "public" };
 
 export default function ItemForm({
   onCreate,
@@ -16,6 +16,7 @@ export default function ItemForm({
       setForm({
         title: editingItem.title,
         description: editingItem.description || "",
+        visibility: editingItem.visibility || "public",
       });
     } else {
       setForm(defaultState);
@@ -40,7 +41,7 @@ export default function ItemForm({
     if (editingItem) {
       ok = await onUpdate(editingItem.id, payload);
     } else {
-      ok = await onCreate(payload);
+      ok = await onCreate({ ...payload, visibility: form.visibility });
     }
     if (ok) {
       setForm(defaultState);
@@ -77,6 +78,17 @@ export default function ItemForm({
           />
         </label>
 
+        {!editingItem && (
+          <label>
+            <span>Visibility</span>
+            <select name="visibility" value={form.visibility} onChange={handleChange}>
+              <option value="public">Public – everyone can see it</option>
+              <option value="shared">Shared – only people you share it with</option>
+              <option value="private">Private – only you</option>
+            </select>
+          </label>
+        )}
+
         <div className="form-actions">
           <button type="submit" className="primary" disabled={loading}>
             {loading ? "Saving..." : editingItem ? "Update Item" : "Create Item"}
@@ -15,6 +15,8 @@ export default function ItemList({
   loading,
   onEdit,
   onDelete,
+  onTransfer,
+  onShare,
 }) {
   if (!items.length) {
     return (
@@ -41,14 +43,19 @@ export default function ItemList({
         {items.map((item) => {
           const canEdit =
             currentUser?.role === "admin" ||
-            currentUser?.username === item.owner;
+            item.access === "owner" ||
+            item.access === "editor";
+          const canManage = currentUser?.role === "admin" || item.access === "owner";
           const canDelete = currentUser?.role === "admin";
 
           return (
             <li key={item.id} className="item-card">
               <div className="item-card__header">
                 <h3>{item.title}</h3>
-                <span className="badge-owner">{item.owner}</span>
+                <span className="badge-owner">{item.owner || "deleted user"}</span>
+                {item.visibility && item.visibility !== "public" && (
+                  <span className="badge">{item.visibility}</span>
+                )}
               </div>
               {item.description && (
                 <p className="item-card__description">{item.description}</p>
@@ -67,6 +74,26 @@ export default function ItemList({
                     ✏️ Edit
                   </button>
                 )}
+                {canManage && (
+                  <button
+                    type="button"
+                    className="secondary"
+                    onClick={() => onShare(item)}
+                    disabled={loading}
+                  >
+                    🔗 Share
+                  </button>
+                )}
+                {canManage && (
+                  <button
+                    type="button"
+                    className="secondary"
+                    onClick={() => onTransfer(item)}
+                    disabled={loading}
+                  >
+                    🤝 Transfer
+                  </button>
+                )}
                 {canDelete && (
                   <button
                     type="button"
@@ -0,0 +1,191 @@
+import { useEffect, useState } from "react";
+import api from "../api/client";
+
+const defaultForm = { subjectType: "user", subject: "", access: "viewer" };
+
+export default function ItemSharing({ item, currentUser, onChanged, onClose }) {
+  const [visibility, setVisibility] = useState(item.visibility || "public");
+  const [grants, setGrants] = useState([]);
+  const [access, setAccess] = useState(null);
+  const [form, setForm] = useState(defaultForm);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState(null);
+  const isAdmin = currentUser?.role === "admin";
+
+  useEffect(() => {
+    setVisibility(item.visibility || "public");
+    loadGrants();
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [item.id]);
+
+  async function loadGrants() {
+    setLoading(true);
+    setError(null);
+    try {
+      setGrants(await api.fetchItemGrants(item.id));
+      setAccess(isAdmin ? await api.fetchItemAccess(item.id) : null);
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to load sharing settings");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleVisibility(event) {
+    const next = event.target.value;
+    setLoading(true);
+    setError(null);
+    try {
+      const updated = await api.setItemVisibility(item.id, next);
+      setVisibility(updated.visibility);
+      if (isAdmin) {
+        setAccess(await api.fetchItemAccess(item.id));
+      }
+      onChanged?.();
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to change visibility");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleGrant(event) {
+    event.preventDefault();
+    if (!form.subject.trim()) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      await api.grantItemAccess(item.id, {
+        [form.subjectType]: form.subject.trim(),
+        access: form.access,
+      });
+      setForm(defaultForm);
+      await loadGrants();
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to share item");
+      setLoading(false);
+    }
+  }
+
+  async function handleRevoke(grant) {
+    setLoading(true);
+    setError(null);
+    try {
+      await api.revokeItemAccess(item.id, grant.subject_type, grant.subject);
+      await loadGrants();
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to remove access");
+      setLoading(false);
+    }
+  }
+
+  return (
+    <div className="card">
+      <div className="card-header">
+        <h2>🔗 Sharing “{item.title}”</h2>
+        <button type="button" className="secondary" onClick={onClose}>
+          Close
+        </button>
+      </div>
+
+      {error && <div className="error-message">⚠️ {error}</div>}
+
+      <div className="form">
+        <label>
+          <span>Visibility</span>
+          <select value={visibility} onChange={handleVisibility} disabled={loading}>
+            <option value="public">Public – everyone can see it</option>
+            <option value="shared">Shared – only the users and roles below</option>
+            <option value="private">Private – only the owner</option>
+          </select>
+        </label>
+        {visibility === "private" && grants.length > 0 && (
+          <p className="muted">The grants below take effect again once the item is no longer private.</p>
+        )}
+      </div>
+
+      <form onSubmit={handleGrant} className="form">
+        <label>
+          <span>Share with</span>
+          <select
+            value={form.subjectType}
+            onChange={(e) => setForm((prev) => ({ ...prev, subjectType: e.target.value }))}
+          >
+            <option value="user">User</option>
+            <option value="role">Role</option>
+          </select>
+        </label>
+        <label>
+          <span>{form.subjectType === "user" ? "Username" : "Role name"}</span>
+          <input
+            type="text"
+            value={form.subject}
+            onChange={(e) => setForm((prev) => ({ ...prev, subject: e.target.value }))}
+            required
+          />
+        </label>
+        <label>
+          <span>Access</span>
+          <select
+            value={form.access}
+            onChange={(e) => setForm((prev) => ({ ...prev, access: e.target.value }))}
+          >
+            <option value="viewer">Viewer – can see it</option>
+            <option value="editor">Editor – can also edit it</option>
+          </select>
+        </label>
+        <button type="submit" className="primary" disabled={loading}>
+          {loading ? "Processing..." : "Share"}
+        </button>
+      </form>
+
+      <div className="user-list">
+        {grants.length === 0 && !loading && (
+          <div className="empty-state">
+            <p className="muted">Not shared with anyone</p>
+          </div>
+        )}
+        {grants.map((grant) => (
+          <div key={`${grant.subject_type}:${grant.subject}`} className="user-item">
+            <div className="user-info">
+              <strong>{grant.subject_name}</strong>
+              <span className="badge">{grant.subject_type}</span>
+              <span className="badge">{grant.access}</span>
+            </div>
+            <div className="user-actions">
+              <button
+                type="button"
+                className="danger"
+                onClick={() => handleRevoke(grant)}
+                disabled={loading}
+              >
+                Remove
+              </button>
+            </div>
+          </div>
+        ))}
+      </div>
+
+      {access && (
+        <>
+          <h3>Effective access</h3>
+          <div className="user-list">
+            {access.map((entry) => (
+              <div key={entry.user_id} className="user-item">
+                <div className="user-info">
+                  <strong>{entry.username}</strong>
+                  <span className="badge">{entry.role}</span>
+                  <span className="badge">{entry.access}</span>
+                  <span className="muted">via {entry.source.replace("_", " ")}</span>
+                </div>
+              </div>
+            ))}
+          </div>
+        </>
+      )}
+    </div>
+  );
+}
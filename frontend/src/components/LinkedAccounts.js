@@ -0,0 +1,82 @@
+import { useEffect, useState } from "react";
+import api from "../api/client";
+
+export default function LinkedAccounts({ providerName, onLink }) {
+  const [identities, setIdentities] = useState([]);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState(null);
+
+  useEffect(() => {
+    loadIdentities();
+  }, []);
+
+  async function loadIdentities() {
+    setLoading(true);
+    setError(null);
+    try {
+      setIdentities(await api.fetchIdentities());
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to load linked accounts");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleUnlink(identity) {
+    if (!window.confirm(`Unlink ${identity.email || identity.subject}? You will no longer be able to sign in with it.`)) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      await api.unlinkIdentity(identity.id);
+      setIdentities((prev) => prev.filter((i) => i.id !== identity.id));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to unlink account");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  return (
+    <div className="card">
+      <div className="card-header">
+        <h2>🔗 Linked Accounts</h2>
+        <button type="button" className="primary" onClick={onLink} disabled={loading}>
+          Link {providerName} account
+        </button>
+      </div>
+
+      {error && <div className="error-message">⚠️ {error}</div>}
+
+      <div className="user-list">
+        {identities.length === 0 && !loading && (
+          <div className="empty-state">
+            <p className="muted">No linked accounts</p>
+          </div>
+        )}
+        {identities.map((identity) => (
+          <div key={identity.id} className="user-item">
+            <div className="user-info">
+              <strong>{identity.email || identity.subject}</strong>
+              <span className="muted">
+                {identity.issuer} · Linked {new Date(identity.created_at).toLocaleDateString()}
+              </span>
+            </div>
+            <div className="user-actions">
+              <button
+                type="button"
+                className="danger"
+                onClick={() => handleUnlink(identity)}
+                disabled={loading}
+              >
+                Unlink
+              </button>
+            </div>
+          </div>
+        ))}
+      </div>
+    </div>
+  );
+}
@@ -0,0 +1,49 @@
+import { useState } from "react";
+
+export default function MfaChallenge({ onSubmit, onCancel, loading }) {
+  const [code, setCode] = useState("");
+
+  async function handleSubmit(event) {
+    event.preventDefault();
+    if (!code.trim()) {
+      return;
+    }
+    const ok = await onSubmit(code.trim());
+    if (!ok) {
+      setCode("");
+    }
+  }
+
+  return (
+    <div className="card auth-card">
+      <div className="auth-header">
+        <h2>Two-Factor Authentication</h2>
+        <p className="muted">
+          Enter the code from your authenticator app, or one of your recovery codes.
+        </p>
+      </div>
+      <form onSubmit={handleSubmit} className="form">
+        <label>
+          <span>Verification code</span>
+          <input
+            type="text"
+            name="code"
+            value={code}
+            onChange={(event) => setCode(event.target.value)}
+            autoComplete="one-time-code"
+            placeholder="123456"
+            autoFocus
+            required
+          />
+        </label>
+
+        <button type="submit" className="primary" disabled={loading}>
+          {loading ? "Processing..." : "Verify"}
+        </button>
+        <button type="button" className="secondary" onClick={onCancel}>
+          Back to sign in
+        </button>
+      </form>
+    </div>
+  );
+}
@@ -0,0 +1,151 @@
+import { useEffect, useState } from "react";
+
+function RecoveryCodes({ codes }) {
+  return (
+    <div className="form">
+      <p className="muted">
+        Store these recovery codes somewhere safe. Each one signs you in once if you
+        lose your authenticator; they will not be shown again.
+      </p>
+      <ul className="recovery-codes">
+        {codes.map((code) => (
+          <li key={code}>
+            <code>{code}</code>
+          </li>
+        ))}
+      </ul>
+    </div>
+  );
+}
+
+export default function MfaSettings({ actions, onDone, loading, required }) {
+  const [status, setStatus] = useState(null);
+  const [enrollment, setEnrollment] = useState(null);
+  const [recoveryCodes, setRecoveryCodes] = useState(null);
+  const [code, setCode] = useState("");
+
+  useEffect(() => {
+    actions.fetchMfaStatus().then(setStatus);
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, []);
+
+  async function handleEnroll() {
+    const data = await actions.enrollMfa();
+    if (data) {
+      setEnrollment(data);
+    }
+  }
+
+  async function handleSubmit(event) {
+    event.preventDefault();
+    if (!code.trim()) {
+      return;
+    }
+    if (enrollment) {
+      const codes = await actions.confirmMfa(code.trim());
+      if (codes) {
+        setEnrollment(null);
+        setRecoveryCodes(codes);
+        setStatus((prev) => ({ ...prev, enabled: true, pending: false }));
+      }
+    } else {
+      const codes = await actions.regenerateRecoveryCodes(code.trim());
+      if (codes) {
+        setRecoveryCodes(codes);
+      }
+    }
+    setCode("");
+  }
+
+  async function handleDisable() {
+    if (!code.trim()) {
+      return;
+    }
+    if (!window.confirm("Turn off two-factor authentication?")) {
+      return;
+    }
+    if (await actions.disableMfa(code.trim())) {
+      setStatus((prev) => ({ ...prev, enabled: false }));
+    }
+    setCode("");
+  }
+
+  const enabled = status?.enabled;
+
+  return (
+    <div className="card auth-card">
+      <div className="auth-header">
+        <h2>Two-Factor Authentication</h2>
+        <p className="muted">
+          {required
+            ? "Your role requires two-factor authentication. Set it up to continue."
+            : enabled
+              ? `Enabled. ${status.recovery_codes_remaining} recovery codes remaining.`
+              : "Protect your account with an authenticator app."}
+        </p>
+      </div>
+
+      {recoveryCodes && <RecoveryCodes codes={recoveryCodes} />}
+
+      {enrollment && (
+        <div className="form">
+          <p className="muted">
+            Add this account to your authenticator app, then enter the code it shows.
+          </p>
+          <label>
+            <span>Setup key</span>
+            <input type="text" value={enrollment.secret} readOnly />
+          </label>
+          <a href={enrollment.otpauth_uri} className="link-button">
+            Open in authenticator app
+          </a>
+        </div>
+      )}
+
+      {status && !enabled && !enrollment && (
+        <div className="form">
+          <button type="button" className="primary" onClick={handleEnroll} disabled={loading}>
+            {loading ? "Processing..." : "Set Up Authenticator"}
+          </button>
+        </div>
+      )}
+
+      {(enrollment || enabled) && (
+        <form onSubmit={handleSubmit} className="form">
+          <label>
+            <span>Verification code</span>
+            <input
+              type="text"
+              name="code"
+              value={code}
+              onChange={(event) => setCode(event.target.value)}
+              autoComplete="one-time-code"
+              placeholder="123456"
+              required
+            />
+          </label>
+          <button type="submit" className="primary" disabled={loading}>
+            {loading
+              ? "Processing..."
+              : enrollment
+                ? "Enable Two-Factor"
+                : "Generate New Recovery Codes"}
+          </button>
+          {enabled && !status.required && (
+            <button type="button" className="danger" onClick={handleDisable} disabled={loading}>
+              Turn Off Two-Factor
+            </button>
+          )}
+        </form>
+      )}
+
+      {!(required && !enabled) && (
+        <div className="form">
+          <button type="button" className="secondary" onClick={onDone}>
+            {recoveryCodes ? "I have saved my codes" : "Back"}
+          </button>
+        </div>
+      )}
+    </div>
+  );
+}
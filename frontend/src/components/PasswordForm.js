@@ -0,0 +1,92 @@
+import { useState } from "react";
+
+const defaultForm = { currentPassword: "", newPassword: "", confirmPassword: "" };
+
+export default function PasswordForm({ onSubmit, onCancel, loading, required }) {
+  const [form, setForm] = useState(defaultForm);
+  const [mismatch, setMismatch] = useState(false);
+
+  function handleChange(event) {
+    const { name, value } = event.target;
+    setForm((prev) => ({ ...prev, [name]: value }));
+    setMismatch(false);
+  }
+
+  async function handleSubmit(event) {
+    event.preventDefault();
+    if (form.newPassword !== form.confirmPassword) {
+      setMismatch(true);
+      return;
+    }
+    const ok = await onSubmit({
+      current_password: form.currentPassword,
+      new_password: form.newPassword,
+    });
+    if (ok) {
+      setForm(defaultForm);
+    }
+  }
+
+  return (
+    <div className="card auth-card">
+      <div className="auth-header">
+        <h2>Change Password</h2>
+        <p className="muted">
+          {required
+            ? "Your password was set by an administrator. Choose a new one to continue."
+            : "You will be signed out on your other devices."}
+        </p>
+      </div>
+      <form onSubmit={handleSubmit} className="form">
+        <label>
+          <span>Current password</span>
+          <input
+            type="password"
+            name="currentPassword"
+            value={form.currentPassword}
+            onChange={handleChange}
+            autoComplete="current-password"
+            required
+          />
+        </label>
+
+        <label>
+          <span>New password</span>
+          <input
+            type="password"
+            name="newPassword"
+            value={form.newPassword}
+            onChange={handleChange}
+            autoComplete="new-password"
+            required
+            minLength={6}
+          />
+        </label>
+
+        <label>
+          <span>Confirm new password</span>
+          <input
+            type="password"
+            name="confirmPassword"
+            value={form.confirmPassword}
+            onChange={handleChange}
+            autoComplete="new-password"
+            required
+            minLength={6}
+          />
+        </label>
+
+        {mismatch && <div className="error-message">⚠️ Passwords do not match</div>}
+
+        <button type="submit" className="primary" disabled={loading}>
+          {loading ? "Processing..." : "Change Password"}
+        </button>
+        {!required && (
+          <button type="button" className="secondary" onClick={onCancel}>
+            Cancel
+          </button>
+        )}
+      </form>
+    </div>
+  );
+}
@@ -0,0 +1,190 @@
+import { useEffect, useState } from "react";
+import api from "../api/client";
+
+// Scopes an account can be limited to; the server refuses any the role lacks.
+const scopes = [
+  "items:read",
+  "items:create",
+  "items:update:own",
+  "items:update:any",
+  "items:delete",
+  "users:manage",
+];
+
+const defaultForm = { name: "", role: "user", scopes: ["items:read"] };
+
+export default function ServiceAccounts() {
+  const [accounts, setAccounts] = useState([]);
+  const [roles, setRoles] = useState([]);
+  const [form, setForm] = useState(defaultForm);
+  const [credentials, setCredentials] = useState(null);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState(null);
+
+  useEffect(() => {
+    loadAccounts();
+  }, []);
+
+  async function loadAccounts() {
+    setLoading(true);
+    setError(null);
+    try {
+      const [accountData, roleData] = await Promise.all([
+        api.fetchServiceAccounts(),
+        api.fetchRoles(),
+      ]);
+      setAccounts(accountData);
+      setRoles(roleData.map((role) => role.name));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to load service accounts");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  function toggleScope(scope) {
+    setForm((prev) => ({
+      ...prev,
+      scopes: prev.scopes.includes(scope)
+        ? prev.scopes.filter((s) => s !== scope)
+        : [...prev.scopes, scope],
+    }));
+  }
+
+  async function handleCreate(event) {
+    event.preventDefault();
+    if (!form.name.trim() || form.scopes.length === 0) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      const { client_secret: secret, ...account } = await api.createServiceAccount({
+        ...form,
+        name: form.name.trim(),
+      });
+      setCredentials({ clientId: account.client_id, secret });
+      setAccounts((prev) => [...prev, account]);
+      setForm(defaultForm);
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to create service account");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleRotate(account) {
+    if (!window.confirm(`Issue a new secret for "${account.username}"? The current one stops working immediately.`)) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      const { client_secret: secret } = await api.rotateClientSecret(account.id);
+      setCredentials({ clientId: account.client_id, secret });
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to rotate secret");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  return (
+    <div className="card">
+      <div className="card-header">
+        <h2>🤖 Service Accounts</h2>
+        <span className="badge-count">{accounts.length}</span>
+      </div>
+
+      {error && <div className="error-message">⚠️ {error}</div>}
+
+      {credentials && (
+        <div className="form">
+          <p className="muted">
+            Copy the secret for <code>{credentials.clientId}</code> now. It will not be shown again.
+          </p>
+          <input type="text" value={credentials.secret} readOnly onFocus={(e) => e.target.select()} />
+          <button type="button" className="secondary" onClick={() => setCredentials(null)}>
+            Done
+          </button>
+        </div>
+      )}
+
+      <form onSubmit={handleCreate} className="form">
+        <label>
+          <span>Name</span>
+          <input
+            type="text"
+            value={form.name}
+            onChange={(e) => setForm((prev) => ({ ...prev, name: e.target.value }))}
+            placeholder="e.g. deploy-bot"
+            required
+          />
+        </label>
+        <label>
+          <span>Role</span>
+          <select
+            value={form.role}
+            onChange={(e) => setForm((prev) => ({ ...prev, role: e.target.value }))}
+          >
+            {roles.map((role) => (
+              <option key={role} value={role}>
+                {role}
+              </option>
+            ))}
+          </select>
+        </label>
+        <div className="user-info">
+          <span className="muted">Scopes:</span>
+          {scopes.map((scope) => (
+            <label key={scope}>
+              <input
+                type="checkbox"
+                checked={form.scopes.includes(scope)}
+                onChange={() => toggleScope(scope)}
+              />{" "}
+              {scope}
+            </label>
+          ))}
+        </div>
+        <button type="submit" className="primary" disabled={loading}>
+          {loading ? "Processing..." : "Create Service Account"}
+        </button>
+      </form>
+
+      <div className="user-list">
+        {accounts.length === 0 && !loading && (
+          <div className="empty-state">
+            <p className="muted">No service accounts yet</p>
+          </div>
+        )}
+        {accounts.map((account) => (
+          <div key={account.id} className="user-item">
+            <div className="user-info">
+              <strong>{account.username}</strong>
+              <span className="badge">{account.role}</span>
+              <code>{account.client_id}</code>
+              {(account.scopes || []).map((scope) => (
+                <span key={scope} className="badge">
+                  {scope}
+                </span>
+              ))}
+            </div>
+            <div className="user-actions">
+              <button
+                type="button"
+                className="secondary"
+                onClick={() => handleRotate(account)}
+                disabled={loading}
+              >
+                🔄 Rotate Secret
+              </button>
+            </div>
+          </div>
+        ))}
+      </div>
+    </div>
+  );
+}
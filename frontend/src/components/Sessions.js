@@ -0,0 +1,98 @@
+import { useEffect, useState } from "react";
+import api from "../api/client";
+
+export default function Sessions({ user, onClose }) {
+  const [sessions, setSessions] = useState([]);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState(null);
+
+  useEffect(() => {
+    loadSessions();
+  }, []);
+
+  async function loadSessions() {
+    setLoading(true);
+    setError(null);
+    try {
+      setSessions(user ? await api.fetchUserSessions(user.id) : await api.fetchSessions());
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to load sessions");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleRevoke(session) {
+    if (!window.confirm(`Sign out ${session.user_agent || "this device"}?`)) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      if (user) {
+        await api.revokeUserSession(user.id, session.id);
+      } else {
+        await api.revokeSession(session.id);
+      }
+      setSessions((prev) => prev.filter((s) => s.id !== session.id));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to sign out device");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  return (
+    <div className="card">
+      <div className="card-header">
+        <h2>💻 {user ? `Sessions of ${user.username}` : "Signed-in Devices"}</h2>
+        <div style={{ display: "flex", gap: "0.75rem", alignItems: "center" }}>
+          <button type="button" className="secondary" onClick={loadSessions} disabled={loading}>
+            {loading ? "Loading..." : "🔄 Refresh"}
+          </button>
+          {onClose && (
+            <button type="button" className="secondary" onClick={onClose}>
+              Close
+            </button>
+          )}
+        </div>
+      </div>
+
+      {error && <div className="error-message">⚠️ {error}</div>}
+
+      <div className="user-list">
+        {sessions.length === 0 && !loading && (
+          <div className="empty-state">
+            <p className="muted">No active sessions</p>
+          </div>
+        )}
+        {sessions.map((session) => (
+          <div key={session.id} className="user-item">
+            <div className="user-info">
+              <strong>{session.user_agent || "Unknown device"}</strong>
+              {session.current && <span className="badge">this device</span>}
+              <span className="muted">
+                {session.ip || "unknown address"} · Signed in{" "}
+                {new Date(session.created_at).toLocaleDateString()} · Last seen{" "}
+                {new Date(session.last_seen_at).toLocaleString()}
+              </span>
+            </div>
+            {!session.current && (
+              <div className="user-actions">
+                <button
+                  type="button"
+                  className="danger"
+                  onClick={() => handleRevoke(session)}
+                  disabled={loading}
+                >
+                  Sign Out
+                </button>
+              </div>
+            )}
+          </div>
+        ))}
+      </div>
+    </div>
+  );
+}
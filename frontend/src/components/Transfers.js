@@ -0,0 +1,128 @@
+import { useEffect, useState } from "react";
+import api from "../api/client";
+
+export default function Transfers({ currentUser, onAccepted }) {
+  const [transfers, setTransfers] = useState([]);
+  const [showAll, setShowAll] = useState(false);
+  const [loading, setLoading] = useState(false);
+  const [error, setError] = useState(null);
+
+  useEffect(() => {
+    loadTransfers();
+    // eslint-disable-next-line react-hooks/exhaustive-deps
+  }, [showAll]);
+
+  async function loadTransfers() {
+    setLoading(true);
+    setError(null);
+    try {
+      setTransfers(await api.fetchTransfers(showAll));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to load transfers");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleAction(transfer, action) {
+    setLoading(true);
+    setError(null);
+    try {
+      if (action === "accept") {
+        await api.acceptTransfer(transfer.id);
+      } else if (action === "decline") {
+        await api.declineTransfer(transfer.id);
+      } else {
+        await api.cancelTransfer(transfer.id);
+      }
+      setTransfers((prev) => prev.filter((t) => t.id !== transfer.id));
+      if (action === "accept" && onAccepted) {
+        onAccepted();
+      }
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to update transfer");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  return (
+    <div className="card">
+      <div className="card-header">
+        <h2>🤝 Item Transfers</h2>
+        <div style={{ display: "flex", gap: "0.75rem", alignItems: "center" }}>
+          {currentUser?.role === "admin" && (
+            <label className="muted">
+              <input
+                type="checkbox"
+                checked={showAll}
+                onChange={(e) => setShowAll(e.target.checked)}
+              />{" "}
+              All users
+            </label>
+          )}
+          <button type="button" className="secondary" onClick={loadTransfers} disabled={loading}>
+            {loading ? "Loading..." : "🔄 Refresh"}
+          </button>
+        </div>
+      </div>
+
+      {error && <div className="error-message">⚠️ {error}</div>}
+
+      <div className="user-list">
+        {transfers.length === 0 && !loading && (
+          <div className="empty-state">
+            <p className="muted">No pending transfers</p>
+          </div>
+        )}
+        {transfers.map((transfer) => {
+          const incoming = transfer.to_id === currentUser?.id;
+          const outgoing = transfer.from_id === currentUser?.id;
+
+          return (
+            <div key={transfer.id} className="user-item">
+              <div className="user-info">
+                <strong>{transfer.item_title}</strong>
+                <span className="muted">
+                  {transfer.from || "deleted user"} → {transfer.to} · Expires{" "}
+                  {new Date(transfer.expires_at).toLocaleString()}
+                </span>
+              </div>
+              <div className="user-actions">
+                {incoming && (
+                  <>
+                    <button
+                      type="button"
+                      onClick={() => handleAction(transfer, "accept")}
+                      disabled={loading}
+                    >
+                      Accept
+                    </button>
+                    <button
+                      type="button"
+                      className="secondary"
+                      onClick={() => handleAction(transfer, "decline")}
+                      disabled={loading}
+                    >
+                      Decline
+                    </button>
+                  </>
+                )}
+                {(outgoing || (!incoming && currentUser?.role === "admin")) && (
+                  <button
+                    type="button"
+                    className="danger"
+                    onClick={() => handleAction(transfer, "cancel")}
+                    disabled={loading}
+                  >
+                    Cancel
+                  </button>
+                )}
+              </div>
+            </div>
+          );
+        })}
+      </div>
+    </div>
+  );
+}
@@ -1,10 +1,13 @@
 import { useState, useEffect } from "react";
 import api from "../api/client";
+import Sessions from "./Sessions";
 
 export default function UserManagement({ currentUser }) {
   const [users, setUsers] = useState([]);
+  const [roles, setRoles] = useState([]);
   const [loading, setLoading] = useState(false);
   const [error, setError] = useState(null);
+  const [sessionsUser, setSessionsUser] = useState(null);
 
   useEffect(() => {
     loadUsers();
@@ -14,8 +17,12 @@ export default function UserManagement({ currentUser }) {
     setLoading(true);
     setError(null);
     try {
-      const data = await api.fetchUsers();
-      setUsers(data);
+      const [userData, roleData] = await Promise.all([
+        api.fetchUsers(),
+        api.fetchRoles(),
+      ]);
+      setUsers(userData);
+      setRoles(roleData);
     } catch (err) {
       setError(err.response?.data?.error || "Failed to load users");
     } finally {
@@ -23,16 +30,167 @@ export default function UserManagement({ currentUser }) {
     }
   }
 
+  async function handleRoleChange(user, role) {
+    if (role === user.role) {
+      return;
+    }
+    if (!window.confirm(`Change role of "${user.username}" to "${role}"?`)) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      const updated = await api.setUserRole(user.id, role);
+      setUsers((prev) => prev.map((u) => (u.id === updated.id ? updated : u)));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to change role");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleRoleMfaChange(role, required) {
+    setLoading(true);
+    setError(null);
+    try {
+      const updated = await api.setRoleMfa(role.name, required);
+      setRoles((prev) => prev.map((r) => (r.name === updated.name ? updated : r)));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to update role");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleResetMfa(user) {
+    if (!window.confirm(`Turn off two-factor authentication for "${user.username}"? They will be signed out.`)) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      const updated = await api.resetUserMfa(user.id);
+      setUsers((prev) => prev.map((u) => (u.id === updated.id ? updated : u)));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to reset two-factor authentication");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleSuspend(user) {
+    const reason = window.prompt(`Why is "${user.username}" being suspended?`);
+    if (!reason) {
+      return;
+    }
+    const days = window.prompt("Suspend for how many days? Leave empty to suspend until reactivated.");
+    if (days === null) {
+      return;
+    }
+    let until;
+    if (days.trim() !== "") {
+      const count = Number(days);
+      if (!Number.isInteger(count) || count <= 0) {
+        setError("Days must be a positive whole number");
+        return;
+      }
+      until = new Date(Date.now() + count * 24 * 60 * 60 * 1000).toISOString();
+    }
+
+    await changeStatus(user, { status: "suspended", reason, until });
+  }
+
+  async function handleReactivate(user) {
+    if (!window.confirm(`Reactivate "${user.username}"?`)) {
+      return;
+    }
+    await changeStatus(user, { status: "active" });
+  }
+
+  async function changeStatus(user, payload) {
+    setLoading(true);
+    setError(null);
+    try {
+      const updated = await api.setUserStatus(user.id, payload);
+      setUsers((prev) => prev.map((u) => (u.id === updated.id ? updated : u)));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to change status");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleRename(user) {
+    const username = window.prompt(`New username for "${user.username}":`, user.username);
+    if (!username || username.trim() === user.username) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      const updated = await api.setUsername(user.id, username.trim());
+      setUsers((prev) => prev.map((u) => (u.id === updated.id ? updated : u)));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to rename user");
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function handleResetPassword(user) {
+    const temporaryPassword = window.prompt(
+      `Temporary password for "${user.username}" (at least 6 characters). They must change it when signing in.`
+    );
+    if (!temporaryPassword) {
+      return;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      const updated = await api.resetUserPassword(user.id, temporaryPassword);
+      setUsers((prev) => prev.map((u) => (u.id === updated.id ? updated : u)));
+    } catch (err) {
+      setError(err.response?.data?.error || "Failed to reset password");
+    } finally {
+      setLoading(false);
+    }
+  }
+
   async function handleDelete(userId, username) {
     if (!window.confirm(`Delete user "${username}"?`)) {
       return;
     }
+    const items = window.prompt(
+      `What should happen to the items of "${username}"? Enter tombstone (keep them without an owner), cascade (delete them) or reassign.`,
+      "tombstone"
+    );
+    if (items === null) {
+      return;
+    }
+    let reassignTo;
+    if (items.trim() === "reassign") {
+      const name = window.prompt("Username of the user who receives the items:");
+      if (!name) {
+        return;
+      }
+      const target = users.find((u) => u.username === name.trim());
+      if (!target) {
+        setError(`User "${name.trim()}" not found`);
+        return;
+      }
+      reassignTo = target.id;
+    }
 
     setLoading(true);
     setError(null);
     try {
-      await api.deleteUser(userId);
+      const result = await api.deleteUser(userId, items.trim(), reassignTo);
       setUsers((prev) => prev.filter((u) => u.id !== userId));
+      window.alert(`Deleted "${username}"; ${result.items_affected} item(s) affected (${result.items}).`);
     } catch (err) {
       setError(err.response?.data?.error || "Failed to delete user");
     } finally {
@@ -40,6 +198,8 @@ export default function UserManagement({ currentUser }) {
     }
   }
 
+  const roleNames = roles.map((role) => role.name);
+
   return (
     <div className="card">
       <div className="card-header">
@@ -59,6 +219,23 @@ export default function UserManagement({ currentUser }) {
 
       {error && <div className="error-message">⚠️ {error}</div>}
 
+      {roles.length > 0 && (
+        <div className="user-info">
+          <span className="muted">Require two-factor for:</span>
+          {roles.map((role) => (
+            <label key={role.name}>
+              <input
+                type="checkbox"
+                checked={role.require_mfa}
+                onChange={(e) => handleRoleMfaChange(role, e.target.checked)}
+                disabled={loading}
+              />{" "}
+              {role.name}
+            </label>
+          ))}
+        </div>
+      )}
+
       <div className="user-list">
         {users.length === 0 && !loading && (
           <div className="empty-state">
@@ -69,26 +246,119 @@ export default function UserManagement({ currentUser }) {
           <div key={user.id} className="user-item">
             <div className="user-info">
               <strong>{user.username}</strong>
-              <span className="badge">{user.role}</span>
+              {user.id !== currentUser.id ? (
+                <select
+                  className="role-select"
+                  value={user.role}
+                  onChange={(e) => handleRoleChange(user, e.target.value)}
+                  disabled={loading}
+                  aria-label={`Role of ${user.username}`}
+                >
+                  {(roleNames.includes(user.role) ? roleNames : [user.role, ...roleNames]).map(
+                    (role) => (
+                      <option key={role} value={role}>
+                        {role}
+                      </option>
+                    )
+                  )}
+                </select>
+              ) : (
+                <span className="badge">{user.role}</span>
+              )}
               <span className="muted">
                 Joined {new Date(user.created_at).toLocaleDateString()}
               </span>
+              {user.must_change_password && (
+                <span className="badge">password change pending</span>
+              )}
+              {user.mfa_enabled && <span className="badge">2FA</span>}
+              {user.service_account && <span className="badge">service account</span>}
+              {user.status && user.status !== "active" && (
+                <span className="badge" title={user.status_reason}>
+                  {user.status}
+                  {user.suspended_until &&
+                    ` until ${new Date(user.suspended_until).toLocaleDateString()}`}
+                </span>
+              )}
             </div>
             {user.id !== currentUser.id ? (
-              <button
-                type="button"
-                className="danger"
-                onClick={() => handleDelete(user.id, user.username)}
-                disabled={loading}
-              >
-                🗑️ Delete
-              </button>
+              <div className="user-actions">
+                <button
+                  type="button"
+                  className="secondary"
+                  onClick={() => handleRename(user)}
+                  disabled={loading}
+                >
+                  ✏️ Rename
+                </button>
+                {!user.service_account && (
+                  <button
+                    type="button"
+                    className="secondary"
+                    onClick={() => setSessionsUser(sessionsUser?.id === user.id ? null : user)}
+                    disabled={loading}
+                  >
+                    💻 Sessions
+                  </button>
+                )}
+                {!user.service_account && (
+                  <button
+                    type="button"
+                    className="secondary"
+                    onClick={() => handleResetPassword(user)}
+                    disabled={loading}
+                  >
+                    🔑 Reset Password
+                  </button>
+                )}
+                {user.mfa_enabled && (
+                  <button
+                    type="button"
+                    className="secondary"
+                    onClick={() => handleResetMfa(user)}
+                    disabled={loading}
+                  >
+                    📵 Reset 2FA
+                  </button>
+                )}
+                {user.status && user.status !== "active" ? (
+                  <button
+                    type="button"
+                    className="secondary"
+                    onClick={() => handleReactivate(user)}
+                    disabled={loading}
+                  >
+                    ✅ Reactivate
+                  </button>
+                ) : (
+                  <button
+                    type="button"
+                    className="secondary"
+                    onClick={() => handleSuspend(user)}
+                    disabled={loading}
+                  >
+                    ⏸️ Suspend
+                  </button>
+                )}
+                <button
+                  type="button"
+                  className="danger"
+                  onClick={() => handleDelete(user.id, user.username)}
+                  disabled={loading}
+                >
+                  🗑️ Delete
+                </button>
+              </div>
             ) : (
               <span className="badge-owner">You</span>
             )}
           </div>
         ))}
       </div>
+
+      {sessionsUser && (
+        <Sessions key={sessionsUser.id} user={sessionsUser} onClose={() => setSessionsUser(null)} />
+      )}
     </div>
   );
 }
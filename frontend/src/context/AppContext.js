@@ -1,20 +1,44 @@
 import { createContext, useContext, useEffect, useReducer } from "react";
 import {
+  changePassword as apiChangePassword,
+  changeUsername as apiChangeUsername,
   clearToken as clearClientToken,
+  completeIdentityLink as apiCompleteIdentityLink,
+  completeOidcLogin as apiCompleteOidcLogin,
+  completePasswordReset as apiCompletePasswordReset,
+  confirmMfa as apiConfirmMfa,
   createItem as apiCreateItem,
   deleteItem as apiDeleteItem,
+  disableMfa as apiDisableMfa,
+  enrollMfa as apiEnrollMfa,
   fetchItems as apiFetchItems,
+  fetchMfaStatus as apiFetchMfaStatus,
   login as apiLogin,
+  loginMfa as apiLoginMfa,
+  logout as apiLogout,
+  regenerateRecoveryCodes as apiRegenerateRecoveryCodes,
   register as apiRegister,
+  requestPasswordReset as apiRequestPasswordReset,
+  setCsrfToken as setClientCsrfToken,
+  setRefreshToken as setClientRefreshToken,
+  setSessionListener,
   setToken as setClientToken,
+  startIdentityLink as apiStartIdentityLink,
+  startOidcLogin as apiStartOidcLogin,
+  transferItem as apiTransferItem,
   updateItem as apiUpdateItem,
 } from "../api/client";
 
 const AppContext = createContext(undefined);
 
 const storage = typeof window !== "undefined" ? window.localStorage : null;
+// Single sign-on tickets only need to survive the round trip to the provider.
+const ssoStorage = typeof window !== "undefined" ? window.sessionStorage : null;
 
 const storedToken = storage?.getItem("app_token") || null;
+const storedRefreshToken = storage?.getItem("app_refresh_token") || null;
+// Cookie sessions keep the tokens out of reach; only the CSRF token is stored.
+const storedCsrfToken = storage?.getItem("app_csrf_token") || null;
 let storedUser = null;
 if (storage) {
   try {
@@ -27,7 +51,13 @@ if (storage) {
 const initialState = {
   user: storedUser,
   token: storedToken,
+  refreshToken: storedRefreshToken,
+  csrfToken: storedCsrfToken,
   items: [],
+  // Set between a correct password and the second factor.
+  mfaChallenge: null,
+  // Set when the user's role requires two-factor authentication they lack.
+  mfaSetupRequired: false,
   loading: false,
   error: null,
   notification: null,
@@ -45,11 +75,36 @@ function reducer(state, action) {
       return {
         ...state,
         user: action.payload.user,
-        token: action.payload.token,
+        token: action.payload.token || null,
+        refreshToken: action.payload.refresh_token || null,
+        csrfToken: action.payload.csrf_token || null,
+        mfaChallenge: null,
+        mfaSetupRequired: false,
         error: null,
       };
+    case "MFA_CHALLENGE":
+      return { ...state, mfaChallenge: action.payload };
+    case "MFA_SETUP_REQUIRED":
+      return { ...state, mfaSetupRequired: action.payload };
+    case "TOKENS_REFRESHED":
+      return {
+        ...state,
+        user: action.payload.user,
+        token: action.payload.token || null,
+        refreshToken: action.payload.refresh_token || null,
+        csrfToken: action.payload.csrf_token || null,
+      };
     case "LOGOUT":
-      return { ...state, user: null, token: null, items: [] };
+      return {
+        ...state,
+        user: null,
+        token: null,
+        refreshToken: null,
+        csrfToken: null,
+        items: [],
+        mfaChallenge: null,
+        mfaSetupRequired: false,
+      };
     case "SET_ITEMS":
       return { ...state, items: action.payload };
     case "ADD_ITEM":
@@ -75,25 +130,59 @@ export function AppProvider({ children }) {
   const [state, dispatch] = useReducer(reducer, initialState);
 
   useEffect(() => {
-    if (state.token) {
+    setSessionListener((data) => {
+      if (data) {
+        dispatch({ type: "TOKENS_REFRESHED", payload: data });
+      } else {
+        dispatch({ type: "LOGOUT" });
+        dispatch({
+          type: "SET_NOTIFICATION",
+          payload: "Your session has expired. Please sign in again.",
+        });
+      }
+    });
+    return () => setSessionListener(null);
+  }, []);
+
+  // Either token identifies the session: the access token, or in cookie mode
+  // the CSRF token that goes with the cookies.
+  const session = state.token || state.csrfToken;
+
+  useEffect(() => {
+    if (session) {
       setClientToken(state.token);
-      storage?.setItem("app_token", state.token);
+      setClientRefreshToken(state.refreshToken);
+      setClientCsrfToken(state.csrfToken);
       storage?.setItem("app_user", JSON.stringify(state.user));
+      for (const [key, value] of [
+        ["app_token", state.token],
+        ["app_refresh_token", state.refreshToken],
+        ["app_csrf_token", state.csrfToken],
+      ]) {
+        if (value) {
+          storage?.setItem(key, value);
+        } else {
+          storage?.removeItem(key);
+        }
+      }
     } else {
       clearClientToken();
       storage?.removeItem("app_token");
       storage?.removeItem("app_user");
+      storage?.removeItem("app_refresh_token");
+      storage?.removeItem("app_csrf_token");
     }
-  }, [state.token, state.user]);
+  }, [session, state.token, state.refreshToken, state.csrfToken, state.user]);
 
   useEffect(() => {
-    if (state.token) {
+    // Accounts with a pending password change can only change the password.
+    if (session && !state.user?.must_change_password) {
       fetchItems();
     } else {
       dispatch({ type: "SET_ITEMS", payload: [] });
     }
     // eslint-disable-next-line react-hooks/exhaustive-deps
-  }, [state.token]);
+  }, [session]);
 
   function setLoading(isLoading) {
     dispatch({ type: "SET_LOADING", payload: isLoading });
@@ -112,6 +201,10 @@ export function AppProvider({ children }) {
     setError(null);
     try {
       const data = await apiLogin(credentials);
+      if (data.mfa_required) {
+        dispatch({ type: "MFA_CHALLENGE", payload: data.mfa_token });
+        return true;
+      }
       dispatch({ type: "LOGIN_SUCCESS", payload: data });
       setNotification("Signed in successfully");
       return true;
@@ -125,6 +218,92 @@ export function AppProvider({ children }) {
     }
   }
 
+  async function completeMfaLogin(code) {
+    setLoading(true);
+    setError(null);
+    try {
+      const data = await apiLoginMfa(state.mfaChallenge, code);
+      dispatch({ type: "LOGIN_SUCCESS", payload: data });
+      setNotification("Signed in successfully");
+      return true;
+    } catch (error) {
+      const status = error.response?.status;
+      if (status === 401 && error.response.data?.error !== "invalid verification code") {
+        // The challenge expired; start over with the password.
+        dispatch({ type: "MFA_CHALLENGE", payload: null });
+      }
+      const message =
+        error.response?.data?.error || "Unable to verify the code.";
+      setError(message);
+      return false;
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  // startSso sends the browser to the identity provider. With link set the
+  // identity is linked to the signed-in user instead of signing in.
+  async function startSso(link = false) {
+    setLoading(true);
+    setError(null);
+    try {
+      const data = link ? await apiStartIdentityLink() : await apiStartOidcLogin();
+      ssoStorage?.setItem("sso_ticket", data.ticket);
+      ssoStorage?.setItem("sso_mode", link ? "link" : "login");
+      window.location.assign(data.authorization_url);
+      return true;
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to reach the identity provider.";
+      setError(message);
+      setLoading(false);
+      return false;
+    }
+  }
+
+  // completeSso finishes the flow startSso began, once the provider has
+  // redirected back with code and state.
+  async function completeSso(code, ssoState) {
+    const ticket = ssoStorage?.getItem("sso_ticket");
+    const mode = ssoStorage?.getItem("sso_mode");
+    ssoStorage?.removeItem("sso_ticket");
+    ssoStorage?.removeItem("sso_mode");
+    if (!ticket) {
+      setError("Single sign-on was not started from this browser tab. Please try again.");
+      return false;
+    }
+
+    setLoading(true);
+    setError(null);
+    try {
+      const payload = { code, state: ssoState, ticket };
+      if (mode === "link") {
+        await apiCompleteIdentityLink(payload);
+        setNotification("Account linked");
+        return true;
+      }
+      const data = await apiCompleteOidcLogin(payload);
+      if (data.mfa_required) {
+        dispatch({ type: "MFA_CHALLENGE", payload: data.mfa_token });
+        return true;
+      }
+      dispatch({ type: "LOGIN_SUCCESS", payload: data });
+      setNotification("Signed in successfully");
+      return true;
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Single sign-on failed, please try again.";
+      setError(message);
+      return false;
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  function cancelMfaLogin() {
+    dispatch({ type: "MFA_CHALLENGE", payload: null });
+  }
+
   async function register(credentials) {
     setLoading(true);
     setError(null);
@@ -143,13 +322,190 @@ export function AppProvider({ children }) {
     }
   }
 
-  function logout() {
+  async function logout() {
+    try {
+      await apiLogout();
+    } catch {
+      // The local session is cleared regardless; the tokens expire on their own.
+    }
     dispatch({ type: "LOGOUT" });
     setNotification("You have been signed out.");
   }
 
+  async function changePassword(payload) {
+    setLoading(true);
+    setError(null);
+    try {
+      // The server revokes every session and hands back a fresh one.
+      const data = await apiChangePassword(payload);
+      dispatch({ type: "LOGIN_SUCCESS", payload: data });
+      setNotification("Password changed successfully.");
+      return true;
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to change password.";
+      setError(message);
+      return false;
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function changeUsername(username) {
+    setLoading(true);
+    setError(null);
+    try {
+      const data = await apiChangeUsername(username);
+      dispatch({
+        type: "TOKENS_REFRESHED",
+        payload: {
+          user: { ...state.user, username: data.username },
+          token: state.token,
+          refresh_token: state.refreshToken,
+          csrf_token: state.csrfToken,
+        },
+      });
+      setNotification(`You are now signed in as ${data.username}.`);
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to change username.";
+      setError(message);
+      return false;
+    } finally {
+      setLoading(false);
+    }
+    // Items show their owner's current name.
+    await fetchItems();
+    return true;
+  }
+
+  async function fetchMfaStatus() {
+    try {
+      return await apiFetchMfaStatus();
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to load two-factor settings.";
+      setError(message);
+      return null;
+    }
+  }
+
+  async function enrollMfa() {
+    setLoading(true);
+    setError(null);
+    try {
+      return await apiEnrollMfa();
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to start two-factor setup.";
+      setError(message);
+      return null;
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  // Resolves to the recovery codes, which the server shows only once.
+  async function confirmMfa(code) {
+    setLoading(true);
+    setError(null);
+    try {
+      // Other sessions are signed out and this one is replaced.
+      const data = await apiConfirmMfa(code);
+      dispatch({ type: "LOGIN_SUCCESS", payload: data });
+      setNotification("Two-factor authentication enabled.");
+      return data.recovery_codes;
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to verify the code.";
+      setError(message);
+      return null;
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function regenerateRecoveryCodes(code) {
+    setLoading(true);
+    setError(null);
+    try {
+      const codes = await apiRegenerateRecoveryCodes(code);
+      setNotification("New recovery codes generated; the old ones no longer work.");
+      return codes;
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to generate recovery codes.";
+      setError(message);
+      return null;
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function disableMfa(code) {
+    setLoading(true);
+    setError(null);
+    try {
+      await apiDisableMfa(code);
+      dispatch({
+        type: "TOKENS_REFRESHED",
+        payload: {
+          user: { ...state.user, mfa_enabled: false },
+          token: state.token,
+          refresh_token: state.refreshToken,
+          csrf_token: state.csrfToken,
+        },
+      });
+      setNotification("Two-factor authentication disabled.");
+      return true;
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to disable two-factor authentication.";
+      setError(message);
+      return false;
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function requestPasswordReset(username) {
+    setLoading(true);
+    setError(null);
+    try {
+      await apiRequestPasswordReset(username);
+      setNotification(
+        "If the account has an email address, a reset link is on its way."
+      );
+      return true;
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to request a password reset.";
+      setError(message);
+      return false;
+    } finally {
+      setLoading(false);
+    }
+  }
+
+  async function completePasswordReset(token, newPassword) {
+    setLoading(true);
+    setError(null);
+    try {
+      await apiCompletePasswordReset(token, newPassword);
+      setNotification("Password reset. Please sign in with your new password.");
+      return true;
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to reset password.";
+      setError(message);
+      return false;
+    } finally {
+      setLoading(false);
+    }
+  }
+
   async function fetchItems() {
-    if (!state.token) {
+    if (!session) {
       return false;
     }
     setLoading(true);
@@ -159,6 +515,10 @@ export function AppProvider({ children }) {
       dispatch({ type: "SET_ITEMS", payload: items });
       return true;
     } catch (error) {
+      if (error.response?.data?.code === "mfa_setup_required") {
+        dispatch({ type: "MFA_SETUP_REQUIRED", payload: true });
+        return false;
+      }
       const message =
         error.response?.data?.error || "Unable to load items right now.";
       setError(message);
@@ -222,16 +582,53 @@ export function AppProvider({ children }) {
     }
   }
 
+  async function transferItem(id, to, force) {
+    setLoading(true);
+    setError(null);
+    try {
+      const transfer = await apiTransferItem(id, to, force);
+      if (transfer.status === "accepted") {
+        const items = await apiFetchItems();
+        dispatch({ type: "SET_ITEMS", payload: items });
+        setNotification(`Item handed over to ${transfer.to}.`);
+      } else {
+        setNotification(`Transfer offered to ${transfer.to}.`);
+      }
+      return true;
+    } catch (error) {
+      const message =
+        error.response?.data?.error || "Unable to transfer item.";
+      setError(message);
+      return false;
+    } finally {
+      setLoading(false);
+    }
+  }
+
   const value = {
     state,
     actions: {
       login,
+      completeMfaLogin,
+      cancelMfaLogin,
+      startSso,
+      completeSso,
       register,
       logout,
+      changePassword,
+      changeUsername,
+      fetchMfaStatus,
+      enrollMfa,
+      confirmMfa,
+      regenerateRecoveryCodes,
+      disableMfa,
+      requestPasswordReset,
+      completePasswordReset,
       fetchItems,
       createItem,
       updateItem,
       deleteItem,
+      transferItem,
       setError,
       setNotification,
       setLoading,